@@ -0,0 +1,46 @@
+package sarama
+
+// AddOffsetsToTxnRequest registers a consumer group with the transaction coordinator, before
+// a transactional producer commits offsets of the group as part of the transaction with a
+// TxnOffsetCommitRequest.
+type AddOffsetsToTxnRequest struct {
+	TransactionalID string
+	ProducerID      int64
+	ProducerEpoch   int16
+	GroupID         string
+}
+
+func (r *AddOffsetsToTxnRequest) encode(pe packetEncoder) error {
+	if err := pe.putString(r.TransactionalID); err != nil {
+		return err
+	}
+	pe.putInt64(r.ProducerID)
+	pe.putInt16(r.ProducerEpoch)
+	return pe.putString(r.GroupID)
+}
+
+func (r *AddOffsetsToTxnRequest) decode(pd packetDecoder) (err error) {
+	if r.TransactionalID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if r.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return err
+	}
+	r.GroupID, err = pd.getString()
+	return err
+}
+
+func (r *AddOffsetsToTxnRequest) key() int16 {
+	return 25
+}
+
+func (r *AddOffsetsToTxnRequest) version() int16 {
+	return 0
+}
+
+func (r *AddOffsetsToTxnRequest) requiredVersion() KafkaVersion {
+	return V0_11_0_0
+}
@@ -0,0 +1,19 @@
+package sarama
+
+import "testing"
+
+var addOffsetsToTxnRequest = []byte{
+	0x00, 0x03, 't', 'x', 'n',
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07,
+	0x00, 0x02,
+	0x00, 0x05, 'g', 'r', 'o', 'u', 'p'}
+
+func TestAddOffsetsToTxnRequest(t *testing.T) {
+	request := &AddOffsetsToTxnRequest{
+		TransactionalID: "txn",
+		ProducerID:      7,
+		ProducerEpoch:   2,
+		GroupID:         "group",
+	}
+	testRequest(t, "", request, addOffsetsToTxnRequest)
+}
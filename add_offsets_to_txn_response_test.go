@@ -0,0 +1,18 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var addOffsetsToTxnResponse = []byte{
+	0x00, 0x00, 0x00, 0x64,
+	0x00, 0x2F}
+
+func TestAddOffsetsToTxnResponse(t *testing.T) {
+	response := &AddOffsetsToTxnResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		Err:          ErrInvalidProducerEpoch,
+	}
+	testResponse(t, "", response, addOffsetsToTxnResponse)
+}
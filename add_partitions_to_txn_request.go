@@ -0,0 +1,75 @@
+package sarama
+
+// AddPartitionsToTxnRequest registers partitions with the transaction coordinator before a
+// transactional producer writes to them, so that the coordinator marks them when the
+// transaction ends.
+type AddPartitionsToTxnRequest struct {
+	TransactionalID string
+	ProducerID      int64
+	ProducerEpoch   int16
+	TopicPartitions map[string][]int32
+}
+
+func (r *AddPartitionsToTxnRequest) encode(pe packetEncoder) error {
+	if err := pe.putString(r.TransactionalID); err != nil {
+		return err
+	}
+	pe.putInt64(r.ProducerID)
+	pe.putInt16(r.ProducerEpoch)
+
+	if err := pe.putArrayLength(len(r.TopicPartitions)); err != nil {
+		return err
+	}
+	for topic, partitions := range r.TopicPartitions {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putInt32Array(partitions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *AddPartitionsToTxnRequest) decode(pd packetDecoder) (err error) {
+	if r.TransactionalID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if r.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.TopicPartitions = make(map[string][]int32, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		if r.TopicPartitions[topic], err = pd.getInt32Array(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *AddPartitionsToTxnRequest) key() int16 {
+	return 24
+}
+
+func (r *AddPartitionsToTxnRequest) version() int16 {
+	return 0
+}
+
+func (r *AddPartitionsToTxnRequest) requiredVersion() KafkaVersion {
+	return V0_11_0_0
+}
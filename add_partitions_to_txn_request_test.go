@@ -0,0 +1,22 @@
+package sarama
+
+import "testing"
+
+var addPartitionsToTxnRequest = []byte{
+	0x00, 0x03, 't', 'x', 'n',
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07,
+	0x00, 0x02,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x01}
+
+func TestAddPartitionsToTxnRequest(t *testing.T) {
+	request := &AddPartitionsToTxnRequest{
+		TransactionalID: "txn",
+		ProducerID:      7,
+		ProducerEpoch:   2,
+		TopicPartitions: map[string][]int32{"topic": {1}},
+	}
+	testRequest(t, "", request, addPartitionsToTxnRequest)
+}
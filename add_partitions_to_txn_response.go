@@ -0,0 +1,69 @@
+package sarama
+
+import "time"
+
+type AddPartitionsToTxnResponse struct {
+	ThrottleTime time.Duration // the time the request was throttled for by a quota
+	Errors       map[string]map[int32]KError
+}
+
+func (r *AddPartitionsToTxnResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+
+	if err := pe.putArrayLength(len(r.Errors)); err != nil {
+		return err
+	}
+	for topic, partitions := range r.Errors {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for partition, kerr := range partitions {
+			pe.putInt32(partition)
+			pe.putInt16(int16(kerr))
+		}
+	}
+
+	return nil
+}
+
+func (r *AddPartitionsToTxnResponse) decode(pd packetDecoder) (err error) {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.Errors = make(map[string]map[int32]KError, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		r.Errors[topic] = make(map[int32]KError, m)
+		for j := 0; j < m; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			kerr, err := pd.getInt16()
+			if err != nil {
+				return err
+			}
+			r.Errors[topic][partition] = KError(kerr)
+		}
+	}
+
+	return nil
+}
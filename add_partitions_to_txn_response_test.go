@@ -0,0 +1,24 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var addPartitionsToTxnResponse = []byte{
+	0x00, 0x00, 0x00, 0x64,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x30}
+
+func TestAddPartitionsToTxnResponse(t *testing.T) {
+	response := &AddPartitionsToTxnResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		Errors: map[string]map[int32]KError{
+			"topic": {1: ErrInvalidTxnState},
+		},
+	}
+	testResponse(t, "", response, addPartitionsToTxnResponse)
+}
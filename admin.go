@@ -0,0 +1,539 @@
+package sarama
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ClusterAdmin is the administrative client for Kafka. It sends its requests to the controller
+// broker, which requires Kafka 0.10.1 or later and Config.Version to be set accordingly. Once the
+// controller has carried out a request, the ClusterAdmin waits up to Config.Admin.Timeout for the
+// cluster metadata to show the change. You MUST call Close() on a ClusterAdmin to avoid leaks.
+type ClusterAdmin interface {
+	// CreateTopics creates the given topics. If validateOnly is true, the controller only checks
+	// the request without creating anything, which requires Kafka 0.11 or later. The topics that
+	// could not be created are reported in a TopicErrors.
+	CreateTopics(topics map[string]*TopicDetail, validateOnly bool) error
+
+	// DeleteTopics deletes the given topics, which requires `delete.topic.enable` on the brokers.
+	// The topics that could not be deleted are reported in a TopicErrors.
+	DeleteTopics(topics ...string) error
+
+	// CreatePartitions adds partitions to the given topics, which requires Kafka 1.0 or later. If
+	// validateOnly is true, the controller only checks the request without creating anything. The
+	// topics that could not be extended are reported in a TopicErrors.
+	CreatePartitions(partitions map[string]*TopicPartition, validateOnly bool) error
+
+	// DescribeConfig returns the configuration entries of a topic or broker, all of them unless
+	// resource.ConfigNames lists some. It requires Kafka 0.11 or later, and 1.1 for the entries to
+	// carry their source and synonyms. An error of the resource is returned as a TopicError.
+	DescribeConfig(resource ConfigResource) ([]*ConfigEntry, error)
+
+	// AlterConfig sets the configuration of a topic or broker. Every dynamic entry of the resource
+	// is replaced: the ones left out of entries go back to their default, so use DescribeConfig
+	// first to change a single entry. If validateOnly is true, the broker only checks the request.
+	// It requires Kafka 0.11 or later. An error of the resource is returned as a TopicError.
+	AlterConfig(resourceType ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error
+
+	// ListConsumerGroups lists the groups of every broker of the cluster, mapped to their protocol
+	// type: "consumer" for consumer groups, something else for eg. Kafka Connect workers. If some
+	// brokers fail to answer, the groups of the others are still returned, along with BrokerErrors.
+	// It requires Kafka 0.9 or later.
+	ListConsumerGroups() (map[string]string, error)
+
+	// DescribeConsumerGroups asks the coordinators of the given groups for their state and members,
+	// the partitions assigned to the members being decoded by GroupMemberDescription.GetMemberAssignment.
+	// The descriptions are returned in the order of groups, each with the error of its group if any.
+	// It requires Kafka 0.9 or later.
+	DescribeConsumerGroups(groups []string) ([]*GroupDescription, error)
+
+	// DeleteRecords deletes the records of the given partitions of a topic before the given offsets,
+	// -1 standing for the high watermark, and returns the new low watermarks of the partitions. The
+	// requests go to the leaders of the partitions, and require Kafka 0.11 or later. The partitions
+	// whose records could not be deleted are reported in a PartitionErrors.
+	DeleteRecords(topic string, partitionOffsets map[int32]int64) (map[int32]int64, error)
+
+	// Close shuts down the client of the ClusterAdmin and all its broker connections.
+	Close() error
+}
+
+// TopicErrors is a type that wraps the "TopicError"s of the topics an admin request failed for,
+// and implements the Error interface. The other topics of the request were dealt with.
+type TopicErrors map[string]*TopicError
+
+func (te TopicErrors) Error() string {
+	return fmt.Sprintf("kafka: admin request failed for %d topics.", len(te))
+}
+
+// PartitionErrors is a type that wraps the errors of the partitions an admin request failed for,
+// and implements the Error interface. The other partitions of the request were dealt with.
+type PartitionErrors map[int32]KError
+
+func (pe PartitionErrors) Error() string {
+	return fmt.Sprintf("kafka: admin request failed for %d partitions.", len(pe))
+}
+
+type clusterAdmin struct {
+	client *client
+	conf   *Config
+}
+
+// NewClusterAdmin creates a new ClusterAdmin using the given broker addresses and configuration.
+func NewClusterAdmin(addrs []string, conf *Config) (ClusterAdmin, error) {
+	c, err := NewClient(addrs, conf)
+	if err != nil {
+		return nil, err
+	}
+	return &clusterAdmin{client: c.(*client), conf: c.Config()}, nil
+}
+
+func (ca *clusterAdmin) Close() error {
+	return ca.client.Close()
+}
+
+func (ca *clusterAdmin) CreateTopics(topics map[string]*TopicDetail, validateOnly bool) error {
+	if len(topics) == 0 {
+		return nil
+	}
+
+	request := &CreateTopicsRequest{
+		TopicDetails: topics,
+		Timeout:      ca.conf.Admin.Timeout,
+		ValidateOnly: validateOnly,
+	}
+	if validateOnly {
+		request.Version = 1
+	}
+
+	topicErrors, err := ca.sendToController(func(controller *Broker) (map[string]*TopicError, error) {
+		response, err := controller.CreateTopics(request)
+		if err != nil {
+			return nil, err
+		}
+		return response.TopicErrors, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	failed := collectTopicErrors(topicErrors)
+	if !validateOnly {
+		var created []string
+		for topic := range topics {
+			if _, ok := failed[topic]; !ok {
+				created = append(created, topic)
+			}
+		}
+		ca.waitForMetadata(created, failed, func(topic string, metadata *TopicMetadata) bool {
+			detail := topics[topic]
+			expected := int(detail.NumPartitions)
+			if expected < 0 {
+				expected = len(detail.ReplicaAssignment)
+			}
+			return metadata != nil && metadata.Err == ErrNoError && len(metadata.Partitions) >= expected
+		})
+	}
+
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+func (ca *clusterAdmin) DeleteTopics(topics ...string) error {
+	if len(topics) == 0 {
+		return nil
+	}
+
+	request := &DeleteTopicsRequest{
+		Topics:  topics,
+		Timeout: ca.conf.Admin.Timeout,
+	}
+
+	topicErrors, err := ca.sendToController(func(controller *Broker) (map[string]*TopicError, error) {
+		response, err := controller.DeleteTopics(request)
+		if err != nil {
+			return nil, err
+		}
+		topicErrors := make(map[string]*TopicError, len(response.TopicErrorCodes))
+		for topic, kerr := range response.TopicErrorCodes {
+			topicErrors[topic] = &TopicError{Err: kerr}
+		}
+		return topicErrors, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	failed := collectTopicErrors(topicErrors)
+	var deleted []string
+	for _, topic := range topics {
+		if _, ok := failed[topic]; !ok {
+			deleted = append(deleted, topic)
+		}
+	}
+	ca.waitForMetadata(deleted, failed, func(topic string, metadata *TopicMetadata) bool {
+		return metadata == nil
+	})
+
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+func (ca *clusterAdmin) CreatePartitions(partitions map[string]*TopicPartition, validateOnly bool) error {
+	if len(partitions) == 0 {
+		return nil
+	}
+
+	request := &CreatePartitionsRequest{
+		TopicPartitions: partitions,
+		Timeout:         ca.conf.Admin.Timeout,
+		ValidateOnly:    validateOnly,
+	}
+
+	topicErrors, err := ca.sendToController(func(controller *Broker) (map[string]*TopicError, error) {
+		response, err := controller.CreatePartitions(request)
+		if err != nil {
+			return nil, err
+		}
+		return response.TopicPartitionErrors, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	failed := collectTopicErrors(topicErrors)
+	if !validateOnly {
+		var extended []string
+		for topic := range partitions {
+			if _, ok := failed[topic]; !ok {
+				extended = append(extended, topic)
+			}
+		}
+		ca.waitForMetadata(extended, failed, func(topic string, metadata *TopicMetadata) bool {
+			return metadata != nil && metadata.Err == ErrNoError && len(metadata.Partitions) >= int(partitions[topic].Count)
+		})
+	}
+
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+func (ca *clusterAdmin) DescribeConfig(resource ConfigResource) ([]*ConfigEntry, error) {
+	broker, err := ca.configBroker(resource.Type, resource.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &DescribeConfigsRequest{
+		Resources:       []*ConfigResource{&resource},
+		IncludeSynonyms: true,
+	}
+	response, err := broker.DescribeConfigs(request)
+	if err != nil {
+		closeUnlessUnsent(broker, err)
+		return nil, err
+	}
+
+	for _, res := range response.Resources {
+		if res.Type != resource.Type || res.Name != resource.Name {
+			continue
+		}
+		if res.Err != ErrNoError {
+			return nil, &TopicError{Err: res.Err, ErrMsg: res.ErrMsg}
+		}
+		return res.Configs, nil
+	}
+	return nil, ErrIncompleteResponse
+}
+
+func (ca *clusterAdmin) AlterConfig(resourceType ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error {
+	broker, err := ca.configBroker(resourceType, name)
+	if err != nil {
+		return err
+	}
+
+	request := &AlterConfigsRequest{
+		Resources: []*AlterConfigsResource{{
+			Type:          resourceType,
+			Name:          name,
+			ConfigEntries: entries,
+		}},
+		ValidateOnly: validateOnly,
+	}
+	response, err := broker.AlterConfigs(request)
+	if err != nil {
+		closeUnlessUnsent(broker, err)
+		return err
+	}
+
+	for _, res := range response.Resources {
+		if res.Type != resourceType || res.Name != name {
+			continue
+		}
+		if res.Err != ErrNoError {
+			return &TopicError{Err: res.Err, ErrMsg: res.ErrMsg}
+		}
+		return nil
+	}
+	return ErrIncompleteResponse
+}
+
+func (ca *clusterAdmin) ListConsumerGroups() (map[string]string, error) {
+	groups := make(map[string]string)
+	errs := make(BrokerErrors)
+
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+	)
+	for _, broker := range ca.client.registeredBrokers() {
+		wg.Add(1)
+		go func(broker *Broker) {
+			defer wg.Done()
+
+			_ = broker.Open(ca.conf)
+			response, err := broker.ListGroups(&ListGroupsRequest{})
+			if err != nil {
+				closeUnlessUnsent(broker, err)
+			} else if response.Err != ErrNoError {
+				err = response.Err
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if err != nil {
+				Logger.Printf("admin/groups failed to list the groups of broker #%d: %s\n", broker.ID(), err)
+				errs[broker.ID()] = err
+				return
+			}
+			for groupID, protocolType := range response.Groups {
+				groups[groupID] = protocolType
+			}
+		}(broker)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return groups, errs
+	}
+	return groups, nil
+}
+
+func (ca *clusterAdmin) DescribeConsumerGroups(groups []string) ([]*GroupDescription, error) {
+	requests := make(map[*Broker]*DescribeGroupsRequest)
+	for _, group := range groups {
+		coordinator, err := ca.client.Coordinator(group)
+		if err != nil {
+			return nil, err
+		}
+		if requests[coordinator] == nil {
+			requests[coordinator] = new(DescribeGroupsRequest)
+		}
+		requests[coordinator].AddGroup(group)
+	}
+
+	descriptions := make(map[string]*GroupDescription, len(groups))
+	for coordinator, request := range requests {
+		response, err := coordinator.DescribeGroups(request)
+		if err != nil {
+			closeUnlessUnsent(coordinator, err)
+			return nil, err
+		}
+		for _, description := range response.Groups {
+			descriptions[description.GroupID] = description
+		}
+	}
+
+	ret := make([]*GroupDescription, 0, len(groups))
+	for _, group := range groups {
+		description := descriptions[group]
+		if description == nil {
+			return nil, ErrIncompleteResponse
+		}
+		ret = append(ret, description)
+	}
+	return ret, nil
+}
+
+func (ca *clusterAdmin) DeleteRecords(topic string, partitionOffsets map[int32]int64) (map[int32]int64, error) {
+	requests := make(map[*Broker]*DeleteRecordsRequest)
+	for partition, offset := range partitionOffsets {
+		leader, err := ca.client.Leader(topic, partition)
+		if err != nil {
+			return nil, err
+		}
+		request := requests[leader]
+		if request == nil {
+			request = &DeleteRecordsRequest{
+				Topics:  map[string]map[int32]int64{topic: {}},
+				Timeout: ca.conf.Admin.Timeout,
+			}
+			requests[leader] = request
+		}
+		request.Topics[topic][partition] = offset
+	}
+
+	lowWatermarks := make(map[int32]int64, len(partitionOffsets))
+	failed := make(PartitionErrors)
+	for leader, request := range requests {
+		response, err := leader.DeleteRecords(request)
+		if err != nil {
+			closeUnlessUnsent(leader, err)
+			return nil, err
+		}
+
+		for partition := range request.Topics[topic] {
+			block := response.Topics[topic][partition]
+			if block == nil {
+				return nil, ErrIncompleteResponse
+			}
+			if block.Err != ErrNoError {
+				failed[partition] = block.Err
+				continue
+			}
+			lowWatermarks[partition] = block.LowWatermark
+		}
+	}
+
+	if len(failed) > 0 {
+		// the leaders may have moved, which the next attempt should know about
+		if err := ca.client.RefreshMetadata(topic); err != nil {
+			Logger.Printf("admin/records failed to refresh the metadata of %s: %s\n", topic, err)
+		}
+		return lowWatermarks, failed
+	}
+	return lowWatermarks, nil
+}
+
+// configBroker returns the broker to send the config requests of a resource to: the broker
+// itself for a broker resource, whose config no other broker knows of, the controller otherwise.
+func (ca *clusterAdmin) configBroker(resourceType ConfigResourceType, name string) (*Broker, error) {
+	if resourceType != BrokerResource {
+		return ca.client.Controller()
+	}
+
+	id, err := strconv.ParseInt(name, 10, 32)
+	if err != nil {
+		return nil, ConfigurationError(fmt.Sprintf("broker resource name %q is not a broker ID", name))
+	}
+
+	broker := ca.client.cachedBroker(int32(id))
+	if broker == nil {
+		if err := ca.client.RefreshMetadata(); err != nil {
+			return nil, err
+		}
+		broker = ca.client.cachedBroker(int32(id))
+	}
+	if broker == nil {
+		return nil, ErrBrokerNotAvailable
+	}
+
+	_ = broker.Open(ca.conf)
+	return broker, nil
+}
+
+// sendToController sends a request to the controller. When the broker answers that it is not
+// the controller any more, the controller is looked up again and the request sent to it once more.
+func (ca *clusterAdmin) sendToController(send func(*Broker) (map[string]*TopicError, error)) (map[string]*TopicError, error) {
+	for attemptsRemaining := 1; ; attemptsRemaining-- {
+		controller, err := ca.client.Controller()
+		if err != nil {
+			return nil, err
+		}
+
+		topicErrors, err := send(controller)
+		if err != nil {
+			closeUnlessUnsent(controller, err)
+			return nil, err
+		}
+
+		notController := false
+		for _, topicErr := range topicErrors {
+			if topicErr.Err == ErrNotController {
+				notController = true
+			}
+		}
+		if !notController || attemptsRemaining == 0 {
+			return topicErrors, nil
+		}
+
+		Logger.Printf("admin/controller broker #%d is not the controller any more, looking it up again\n", controller.ID())
+		if err := ca.client.refreshController(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// waitForMetadata polls the metadata of all the topics until done holds for each of the given ones,
+// the metadata being nil for a topic the cluster does not know of. Asking for the topics by name
+// instead would have the brokers create them when `auto.create.topics.enable` is set. The topics
+// still not done after Admin.Timeout are added to failed with ErrRequestTimedOut.
+func (ca *clusterAdmin) waitForMetadata(topics []string, failed TopicErrors, done func(topic string, metadata *TopicMetadata) bool) {
+	deadline := time.Now().Add(ca.conf.Admin.Timeout)
+
+	for len(topics) > 0 {
+		if broker := ca.client.any(); broker != nil {
+			response, err := broker.GetMetadata(&MetadataRequest{})
+			if err == nil {
+				_, _ = ca.client.updateMetadata(response)
+
+				metadata := make(map[string]*TopicMetadata, len(response.Topics))
+				for _, topic := range response.Topics {
+					metadata[topic.Name] = topic
+				}
+
+				var pending []string
+				for _, topic := range topics {
+					if !done(topic, metadata[topic]) {
+						pending = append(pending, topic)
+					}
+				}
+				topics = pending
+				if len(topics) == 0 {
+					return
+				}
+			} else {
+				Logger.Println("admin/metadata got error from broker while fetching metadata:", err)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(ca.conf.Metadata.Retry.Backoff)
+	}
+
+	for _, topic := range topics {
+		Logger.Printf("admin/metadata topic %s did not show the change after %s\n", topic, ca.conf.Admin.Timeout)
+		failed[topic] = &TopicError{Err: ErrRequestTimedOut}
+	}
+}
+
+// closeUnlessUnsent closes the connection to a broker a request failed on, unless the request
+// was refused before being sent.
+func closeUnlessUnsent(broker *Broker, err error) {
+	switch err.(type) {
+	case PacketEncodingError, ConfigurationError, KError:
+		// didn't even send
+	default:
+		_ = broker.Close()
+	}
+}
+
+func collectTopicErrors(topicErrors map[string]*TopicError) TopicErrors {
+	failed := make(TopicErrors)
+	for topic, topicErr := range topicErrors {
+		if topicErr.Err != ErrNoError {
+			failed[topic] = topicErr
+		}
+	}
+	return failed
+}
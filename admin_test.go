@@ -0,0 +1,460 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClusterAdminCreateTopics(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetController(broker0.BrokerID()).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetLeader("my_topic", 1, broker0.BrokerID()),
+		"CreateTopicsRequest": newMockWrapper(&CreateTopicsResponse{
+			TopicErrors: map[string]*TopicError{
+				"my_topic":    {Err: ErrNoError},
+				"other_topic": {Err: ErrTopicAlreadyExists},
+			},
+		}),
+	})
+
+	config := NewConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = admin.CreateTopics(map[string]*TopicDetail{
+		"my_topic":    {NumPartitions: 2, ReplicationFactor: 1},
+		"other_topic": {NumPartitions: 1, ReplicationFactor: 1},
+	}, false)
+	topicErrors, ok := err.(TopicErrors)
+	if !ok || len(topicErrors) != 1 || topicErrors["other_topic"].Err != ErrTopicAlreadyExists {
+		t.Error("Expected other_topic to fail with ErrTopicAlreadyExists, got", err)
+	}
+
+	var request *CreateTopicsRequest
+	for _, rr := range broker0.History() {
+		if req, ok := rr.Request.(*CreateTopicsRequest); ok {
+			request = req
+		}
+	}
+	if request == nil {
+		t.Fatal("Expected a create topics request")
+	}
+	if request.Version != 2 || request.Timeout != config.Admin.Timeout {
+		t.Errorf("Unexpected create topics request %#v", request)
+	}
+
+	partitions, err := admin.(*clusterAdmin).client.Partitions("my_topic")
+	if err != nil || len(partitions) != 2 {
+		t.Error("Expected the metadata of my_topic to be known, got", partitions, err)
+	}
+
+	safeClose(t, admin)
+}
+
+func TestClusterAdminValidateOnlyRequiresVersion(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetController(broker0.BrokerID()).
+			SetBroker(broker0.Addr(), broker0.BrokerID()),
+	})
+
+	config := NewConfig()
+	config.Version = V0_10_1_0
+	admin, err := NewClusterAdmin([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = admin.CreateTopics(map[string]*TopicDetail{"my_topic": {NumPartitions: 1, ReplicationFactor: 1}}, true)
+	if _, ok := err.(ConfigurationError); !ok {
+		t.Error("Expected validating only to require Kafka 0.11, got", err)
+	}
+
+	safeClose(t, admin)
+}
+
+func TestClusterAdminDeleteTopicsTimeout(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetController(broker0.BrokerID()).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"DeleteTopicsRequest": newMockWrapper(&DeleteTopicsResponse{
+			TopicErrorCodes: map[string]KError{
+				"my_topic":    ErrNoError,
+				"other_topic": ErrNoError,
+			},
+		}),
+	})
+
+	config := NewConfig()
+	config.Version = V1_0_0_0
+	config.Admin.Timeout = 50 * time.Millisecond
+	config.Metadata.Retry.Backoff = 10 * time.Millisecond
+	admin, err := NewClusterAdmin([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// my_topic never disappears from the metadata, unlike other_topic
+	err = admin.DeleteTopics("my_topic", "other_topic")
+	topicErrors, ok := err.(TopicErrors)
+	if !ok || len(topicErrors) != 1 || topicErrors["my_topic"].Err != ErrRequestTimedOut {
+		t.Error("Expected my_topic to time out, got", err)
+	}
+
+	safeClose(t, admin)
+}
+
+func TestClusterAdminCreatePartitionsNotController(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+	broker1 := newMockBroker(t, 1)
+	defer broker1.Close()
+
+	metadata := newMockMetadataResponse(t).
+		SetController(broker0.BrokerID()).
+		SetBroker(broker0.Addr(), broker0.BrokerID()).
+		SetBroker(broker1.Addr(), broker1.BrokerID()).
+		SetLeader("my_topic", 0, broker0.BrokerID()).
+		SetLeader("my_topic", 1, broker1.BrokerID()).
+		SetLeader("my_topic", 2, broker1.BrokerID())
+
+	broker0.SetHandler(func(req *request) (res encoder) {
+		switch req.body.(type) {
+		case *MetadataRequest:
+			return metadata.For(req.body)
+		case *CreatePartitionsRequest:
+			// the controller moves to broker1
+			metadata.SetController(broker1.BrokerID())
+			return &CreatePartitionsResponse{
+				TopicPartitionErrors: map[string]*TopicError{"my_topic": {Err: ErrNotController}},
+			}
+		}
+		return nil
+	})
+	broker1.SetHandlerByMap(map[string]MockResponse{
+		"CreatePartitionsRequest": newMockWrapper(&CreatePartitionsResponse{
+			TopicPartitionErrors: map[string]*TopicError{"my_topic": {Err: ErrNoError}},
+		}),
+	})
+
+	config := NewConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = admin.CreatePartitions(map[string]*TopicPartition{"my_topic": {Count: 3}}, false)
+	if err != nil {
+		t.Error(err)
+	}
+
+	safeClose(t, admin)
+}
+
+func TestClusterAdminDescribeConfig(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+	broker1 := newMockBroker(t, 1)
+	defer broker1.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetController(broker0.BrokerID()).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetBroker(broker1.Addr(), broker1.BrokerID()),
+		"DescribeConfigsRequest": newMockWrapper(&DescribeConfigsResponse{
+			Resources: []*DescribeConfigsResource{{
+				Type:    TopicResource,
+				Name:    "my_topic",
+				Configs: []*ConfigEntry{{Name: "retention.ms", Value: "1000", Source: SourceTopic}},
+			}},
+		}),
+	})
+	broker1.SetHandlerByMap(map[string]MockResponse{
+		"DescribeConfigsRequest": newMockWrapper(&DescribeConfigsResponse{
+			Resources: []*DescribeConfigsResource{{
+				Type:    BrokerResource,
+				Name:    "1",
+				Configs: []*ConfigEntry{{Name: "log.retention.ms", ReadOnly: true, Source: SourceStaticBroker}},
+			}},
+		}),
+	})
+
+	config := NewConfig()
+	config.Version = V1_1_0_0
+	admin, err := NewClusterAdmin([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := admin.DescribeConfig(ConfigResource{Type: TopicResource, Name: "my_topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Value != "1000" || entries[0].Source != SourceTopic {
+		t.Errorf("Unexpected topic config %#v", entries)
+	}
+
+	// the config of a broker is asked from the broker itself
+	entries, err = admin.DescribeConfig(ConfigResource{Type: BrokerResource, Name: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !entries[0].ReadOnly || entries[0].Source != SourceStaticBroker {
+		t.Errorf("Unexpected broker config %#v", entries)
+	}
+
+	for _, rr := range broker1.History() {
+		if req, ok := rr.Request.(*DescribeConfigsRequest); ok && (req.Version != 1 || !req.IncludeSynonyms) {
+			t.Errorf("Unexpected describe configs request %#v", req)
+		}
+	}
+
+	if _, err := admin.DescribeConfig(ConfigResource{Type: BrokerResource, Name: "one"}); err == nil {
+		t.Error("Expected a broker resource not named by an ID to be refused")
+	}
+
+	safeClose(t, admin)
+}
+
+func TestClusterAdminAlterConfig(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+
+	msg := "invalid value"
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetController(broker0.BrokerID()).
+			SetBroker(broker0.Addr(), broker0.BrokerID()),
+		"AlterConfigsRequest": newMockWrapper(&AlterConfigsResponse{
+			Resources: []*AlterConfigsResourceResponse{
+				{Err: ErrInvalidConfig, ErrMsg: &msg, Type: TopicResource, Name: "my_topic"},
+			},
+		}),
+	})
+
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	admin, err := NewClusterAdmin([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retention := "-2"
+	err = admin.AlterConfig(TopicResource, "my_topic", map[string]*string{"retention.ms": &retention}, true)
+	if topicErr, ok := err.(*TopicError); !ok || topicErr.Err != ErrInvalidConfig || *topicErr.ErrMsg != msg {
+		t.Error("Expected ErrInvalidConfig, got", err)
+	}
+
+	safeClose(t, admin)
+}
+
+func TestClusterAdminListConsumerGroups(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+	broker1 := newMockBroker(t, 1)
+	defer broker1.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetBroker(broker1.Addr(), broker1.BrokerID()),
+		"ListGroupsRequest": newMockWrapper(&ListGroupsResponse{
+			Groups: map[string]string{"my_group": "consumer"},
+		}),
+	})
+	broker1.SetHandlerByMap(map[string]MockResponse{
+		"ListGroupsRequest": newMockWrapper(&ListGroupsResponse{
+			Groups: map[string]string{"connect": "connect"},
+		}),
+	})
+
+	config := NewConfig()
+	config.Version = V0_9_0_0
+	admin, err := NewClusterAdmin([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := admin.ListConsumerGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups["my_group"] != "consumer" || groups["connect"] != "connect" {
+		t.Error("Expected the groups of both brokers, got", groups)
+	}
+
+	safeClose(t, admin)
+}
+
+func TestClusterAdminListConsumerGroupsPartialFailure(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+	// a broker the metadata names, but which is down
+	broker1 := newMockBroker(t, 1)
+	broker1.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetBroker(broker1.Addr(), broker1.BrokerID()),
+		"ListGroupsRequest": newMockWrapper(&ListGroupsResponse{
+			Groups: map[string]string{"my_group": "consumer"},
+		}),
+	})
+
+	config := NewConfig()
+	config.Version = V0_9_0_0
+	admin, err := NewClusterAdmin([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := admin.ListConsumerGroups()
+	if errs, ok := err.(BrokerErrors); !ok {
+		t.Error("Expected BrokerErrors, got", err)
+	} else if len(errs) != 1 || errs[broker1.BrokerID()] == nil {
+		t.Error("Expected an error for the broker which is down only, got", errs)
+	}
+	if len(groups) != 1 || groups["my_group"] != "consumer" {
+		t.Error("Expected the groups of the broker which answered, got", groups)
+	}
+
+	safeClose(t, admin)
+}
+
+func TestClusterAdminDescribeConsumerGroups(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+	broker1 := newMockBroker(t, 1)
+	defer broker1.Close()
+
+	assignment, err := encode(&ConsumerGroupMemberAssignment{Topics: map[string][]int32{"my_topic": {0, 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetBroker(broker1.Addr(), broker1.BrokerID()),
+		"ConsumerMetadataRequest": newMockConsumerMetadataResponse(t).
+			SetCoordinator("my_group", broker1).
+			SetCoordinator("other_group", broker0),
+		"DescribeGroupsRequest": newMockWrapper(&DescribeGroupsResponse{
+			Groups: []*GroupDescription{{GroupID: "other_group", State: "Empty", ProtocolType: "consumer"}},
+		}),
+	})
+	broker1.SetHandlerByMap(map[string]MockResponse{
+		"DescribeGroupsRequest": newMockWrapper(&DescribeGroupsResponse{
+			Groups: []*GroupDescription{{
+				GroupID:      "my_group",
+				State:        "Stable",
+				ProtocolType: "consumer",
+				Protocol:     "range",
+				Members: map[string]*GroupMemberDescription{
+					"member": {ClientID: "sarama", ClientHost: "/127.0.0.1", MemberAssignment: assignment},
+				},
+			}},
+		}),
+	})
+
+	config := NewConfig()
+	config.Version = V0_9_0_0
+	admin, err := NewClusterAdmin([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptions, err := admin.DescribeConsumerGroups([]string{"my_group", "other_group"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(descriptions) != 2 || descriptions[0].GroupID != "my_group" || descriptions[1].State != "Empty" {
+		t.Fatalf("Unexpected descriptions %#v", descriptions)
+	}
+
+	member := descriptions[0].Members["member"]
+	if member == nil {
+		t.Fatal("Expected the member of my_group to be described")
+	}
+	memberAssignment, err := member.GetMemberAssignment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partitions := memberAssignment.Topics["my_topic"]; len(partitions) != 2 {
+		t.Error("Expected the member to be assigned two partitions, got", partitions)
+	}
+
+	safeClose(t, admin)
+}
+
+func TestClusterAdminDeleteRecords(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+	broker1 := newMockBroker(t, 1)
+	defer broker1.Close()
+
+	response0 := new(DeleteRecordsResponse)
+	response0.AddPartition("my_topic", 0, 100, ErrNoError)
+	response0.AddPartition("my_topic", 1, 200, ErrNoError)
+	response1 := new(DeleteRecordsResponse)
+	response1.AddPartition("my_topic", 2, -1, ErrNotLeaderForPartition)
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetController(broker0.BrokerID()).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetLeader("my_topic", 1, broker0.BrokerID()).
+			SetLeader("my_topic", 2, broker1.BrokerID()),
+		"DeleteRecordsRequest": newMockWrapper(response0),
+	})
+	broker1.SetHandlerByMap(map[string]MockResponse{
+		"DeleteRecordsRequest": newMockWrapper(response1),
+	})
+
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	admin, err := NewClusterAdmin([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lowWatermarks, err := admin.DeleteRecords("my_topic", map[int32]int64{0: 100, 1: -1, 2: 300})
+	partitionErrors, ok := err.(PartitionErrors)
+	if !ok || len(partitionErrors) != 1 || partitionErrors[2] != ErrNotLeaderForPartition {
+		t.Error("Expected partition 2 to fail with ErrNotLeaderForPartition, got", err)
+	}
+	if !reflect.DeepEqual(lowWatermarks, map[int32]int64{0: 100, 1: 200}) {
+		t.Error("Unexpected low watermarks", lowWatermarks)
+	}
+
+	for _, rr := range broker1.History() {
+		if req, ok := rr.Request.(*DeleteRecordsRequest); ok && !reflect.DeepEqual(req.Topics, map[string]map[int32]int64{"my_topic": {2: 300}}) {
+			t.Error("Expected broker1 to only delete the records of the partition it leads, got", req.Topics)
+		}
+	}
+
+	safeClose(t, admin)
+}
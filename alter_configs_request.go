@@ -0,0 +1,144 @@
+package sarama
+
+// AlterConfigsResource is a topic or broker along with the configuration to give it.
+type AlterConfigsResource struct {
+	Type ConfigResourceType
+	Name string
+	// ConfigEntries replace every dynamic configuration entry of the resource, the
+	// entries left out going back to their default.
+	ConfigEntries map[string]*string
+}
+
+func (r *AlterConfigsResource) encode(pe packetEncoder) error {
+	pe.putInt8(int8(r.Type))
+
+	if err := pe.putString(r.Name); err != nil {
+		return err
+	}
+
+	if err := pe.putArrayLength(len(r.ConfigEntries)); err != nil {
+		return err
+	}
+	for name, value := range r.ConfigEntries {
+		if err := pe.putString(name); err != nil {
+			return err
+		}
+		if err := pe.putNullableString(value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *AlterConfigsResource) decode(pd packetDecoder) (err error) {
+	t, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	r.Type = ConfigResourceType(t)
+
+	if r.Name, err = pd.getString(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		r.ConfigEntries = make(map[string]*string, n)
+		for i := 0; i < n; i++ {
+			name, err := pd.getString()
+			if err != nil {
+				return err
+			}
+			if r.ConfigEntries[name], err = pd.getNullableString(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type AlterConfigsRequest struct {
+	Resources []*AlterConfigsResource
+	// ValidateOnly asks the broker to check the request without changing anything.
+	ValidateOnly bool
+	// Version can be:
+	// - 0 (kafka 0.11 and later)
+	// - 1 (kafka 2.0 and later)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version int16
+}
+
+func (r *AlterConfigsRequest) encode(pe packetEncoder) error {
+	if r.Version < 0 || r.Version > 1 {
+		return PacketEncodingError{"invalid or unsupported AlterConfigsRequest version field"}
+	}
+
+	if err := pe.putArrayLength(len(r.Resources)); err != nil {
+		return err
+	}
+	for _, resource := range r.Resources {
+		if err := resource.encode(pe); err != nil {
+			return err
+		}
+	}
+
+	pe.putBool(r.ValidateOnly)
+
+	return nil
+}
+
+func (r *AlterConfigsRequest) decode(pd packetDecoder) (err error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.Resources = make([]*AlterConfigsResource, n)
+	for i := range r.Resources {
+		r.Resources[i] = new(AlterConfigsResource)
+		if err := r.Resources[i].decode(pd); err != nil {
+			return err
+		}
+	}
+
+	if r.ValidateOnly, err = pd.getBool(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AlterConfigsRequest) key() int16 {
+	return 33
+}
+
+func (r *AlterConfigsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *AlterConfigsRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *AlterConfigsRequest) shallowCopy() versionedRequest {
+	c := *r
+	return &c
+}
+
+func (r *AlterConfigsRequest) maxVersion() int16 {
+	return 1
+}
+
+func (r *AlterConfigsRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V0_11_0_0
+	}
+}
@@ -0,0 +1,31 @@
+package sarama
+
+import "testing"
+
+var alterConfigsRequest = []byte{
+	0x00, 0x00, 0x00, 0x01,
+	0x02,
+	0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x0C, 'r', 'e', 't', 'e', 'n', 't', 'i', 'o', 'n', '.', 'm', 's',
+	0x00, 0x04, '1', '0', '0', '0',
+	0x01} // validate only
+
+func TestAlterConfigsRequest(t *testing.T) {
+	retention := "1000"
+	request := &AlterConfigsRequest{
+		Resources: []*AlterConfigsResource{{
+			Type:          TopicResource,
+			Name:          "topic",
+			ConfigEntries: map[string]*string{"retention.ms": &retention},
+		}},
+		ValidateOnly: true,
+	}
+	testRequest(t, "version 0", request, alterConfigsRequest)
+
+	request.Version = 1
+	testRequest(t, "version 1", request, alterConfigsRequest)
+	if request.requiredVersion() != V2_0_0_0 {
+		t.Error("Expected version 1 to require Kafka 2.0, got", request.requiredVersion())
+	}
+}
@@ -0,0 +1,76 @@
+package sarama
+
+import "time"
+
+// AlterConfigsResourceResponse is the outcome of the change of one of the resources of an
+// AlterConfigsRequest.
+type AlterConfigsResourceResponse struct {
+	Err    KError
+	ErrMsg *string
+	Type   ConfigResourceType
+	Name   string
+}
+
+type AlterConfigsResponse struct {
+	ThrottleTime time.Duration // the time the request was throttled for by a quota
+	Resources    []*AlterConfigsResourceResponse
+}
+
+func (r *AlterConfigsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+
+	if err := pe.putArrayLength(len(r.Resources)); err != nil {
+		return err
+	}
+	for _, resource := range r.Resources {
+		pe.putInt16(int16(resource.Err))
+		if err := pe.putNullableString(resource.ErrMsg); err != nil {
+			return err
+		}
+		pe.putInt8(int8(resource.Type))
+		if err := pe.putString(resource.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *AlterConfigsResponse) decode(pd packetDecoder) (err error) {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.Resources = make([]*AlterConfigsResourceResponse, n)
+	for i := range r.Resources {
+		resource := new(AlterConfigsResourceResponse)
+
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		resource.Err = KError(kerr)
+		if resource.ErrMsg, err = pd.getNullableString(); err != nil {
+			return err
+		}
+		t, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		resource.Type = ConfigResourceType(t)
+		if resource.Name, err = pd.getString(); err != nil {
+			return err
+		}
+
+		r.Resources[i] = resource
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var alterConfigsResponse = []byte{
+	0x00, 0x00, 0x00, 0x64,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x28, // error
+	0x00, 0x03, 'm', 's', 'g',
+	0x02,
+	0x00, 0x05, 't', 'o', 'p', 'i', 'c'}
+
+func TestAlterConfigsResponse(t *testing.T) {
+	msg := "msg"
+	response := &AlterConfigsResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		Resources: []*AlterConfigsResourceResponse{
+			{Err: ErrInvalidConfig, ErrMsg: &msg, Type: TopicResource, Name: "topic"},
+		},
+	}
+	testResponse(t, "one resource", response, alterConfigsResponse)
+}
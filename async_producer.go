@@ -1,6 +1,7 @@
 package sarama
 
 import (
+	"encoding/binary"
 	"fmt"
 	"sync"
 	"time"
@@ -100,10 +101,13 @@ type asyncProducer struct {
 	errors                    chan *ProducerError
 	input, successes, retries chan *ProducerMessage
 	inFlight                  SafeWaitGroup
+	shuttingDown              chan none // closed once the producer starts shutting down
 
 	brokers    map[*Broker]chan<- *ProducerMessage
 	brokerRefs map[chan<- *ProducerMessage]int
 	brokerLock sync.Mutex
+
+	txnmgr *transactionManager // only set when Producer.Idempotent is
 }
 
 // NewAsyncProducer creates a new AsyncProducer using the given broker addresses and configuration.
@@ -115,6 +119,7 @@ func NewAsyncProducer(addrs []string, conf *Config) (AsyncProducer, error) {
 
 	p, err := NewAsyncProducerFromClient(client)
 	if err != nil {
+		_ = client.Close()
 		return nil, err
 	}
 	p.(*asyncProducer).ownClient = true
@@ -129,15 +134,37 @@ func NewAsyncProducerFromClient(client Client) (AsyncProducer, error) {
 		return nil, ErrClosedClient
 	}
 
+	if client.Config().Producer.Transaction.ID != "" {
+		return nil, ConfigurationError("Producer.Transaction.ID requires using NewTransactionalProducer")
+	}
+
+	p, err := newAsyncProducer(client)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newAsyncProducer(client Client) (*asyncProducer, error) {
+	var txnmgr *transactionManager
+	if client.Config().Producer.Idempotent {
+		var err error
+		if txnmgr, err = newTransactionManager(client); err != nil {
+			return nil, err
+		}
+	}
+
 	p := &asyncProducer{
-		client:     client,
-		conf:       client.Config(),
-		errors:     make(chan *ProducerError),
-		input:      make(chan *ProducerMessage),
-		successes:  make(chan *ProducerMessage),
-		retries:    make(chan *ProducerMessage),
-		brokers:    make(map[*Broker]chan<- *ProducerMessage),
-		brokerRefs: make(map[chan<- *ProducerMessage]int),
+		client:       client,
+		conf:         client.Config(),
+		errors:       make(chan *ProducerError),
+		input:        make(chan *ProducerMessage),
+		successes:    make(chan *ProducerMessage),
+		retries:      make(chan *ProducerMessage),
+		shuttingDown: make(chan none),
+		brokers:      make(map[*Broker]chan<- *ProducerMessage),
+		brokerRefs:   make(map[chan<- *ProducerMessage]int),
+		txnmgr:       txnmgr,
 	}
 
 	// launch our singleton dispatchers
@@ -152,6 +179,8 @@ type flagSet int8
 const (
 	chaser   flagSet = 1 << iota // message is last in a group that failed
 	shutdown                     // start the shutdown process
+	endtxn                       // the messages of the transaction were all sent before this one
+	intxn                        // message is part of a transaction
 )
 
 // ProducerMessage is the collection of elements passed to the Producer in order to send a message.
@@ -174,26 +203,44 @@ type ProducerMessage struct {
 
 	// Offset is the offset of the message stored on the broker. This is only
 	// guaranteed to be defined if the message was successfully delivered and
-	// RequiredAcks is not NoResponse.
+	// RequiredAcks is not NoResponse. It is -1 when an idempotent producer
+	// finds out the broker already had the message from an earlier attempt.
 	Offset int64
 	// Partition is the partition that the message was sent to. This is only
 	// guaranteed to be defined if the message was successfully delivered.
 	Partition int32
+	// Headers are passed along with the message to the consumers, which requires
+	// Config.Version to be at least V0_11_0_0.
+	Headers []RecordHeader
+	// Timestamp is the creation time of the message. It is set to the time the
+	// message was passed to the producer if left empty, and only sent to the
+	// broker if Config.Version is at least V0_10_0_0. Brokers configured to use
+	// the log append time for the topic overwrite it.
+	Timestamp time.Time
 
 	retries int
 	flags   flagSet
 
 	keyCache, valueCache []byte
+
+	// the idempotent producer stamps messages with these before sending them
+	producerID     int64
+	producerEpoch  int16
+	sequenceNumber int32
+	hasSequence    bool
 }
 
 func (m *ProducerMessage) byteSize() int {
-	size := 26 // the metadata overhead of CRC, flags, etc.
+	size := 34 // the metadata overhead of CRC, flags, timestamp, etc.
 	if m.Key != nil {
 		size += m.Key.Length()
 	}
 	if m.Value != nil {
 		size += m.Value.Length()
 	}
+	for _, h := range m.Headers {
+		size += len(h.Key) + len(h.Value) + 2*binary.MaxVarintLen32
+	}
 	return size
 }
 
@@ -202,6 +249,7 @@ func (m *ProducerMessage) clear() {
 	m.retries = 0
 	m.keyCache = nil
 	m.valueCache = nil
+	m.hasSequence = false
 }
 
 // ProducerError is the type of error generated when the producer fails to deliver a message.
@@ -279,6 +327,9 @@ func (p *asyncProducer) dispatcher() {
 			shuttingDown = true
 			p.inFlight.Done()
 			continue
+		} else if msg.flags&endtxn != 0 {
+			// the producer only waits for the dispatcher to get past the messages of the transaction
+			continue
 		} else if msg.retries == 0 {
 			if shuttingDown {
 				// we can't just call returnError here because that decrements the wait group,
@@ -291,7 +342,25 @@ func (p *asyncProducer) dispatcher() {
 				}
 				continue
 			}
+			if p.txnmgr != nil && !p.txnmgr.accept(msg) {
+				// like above, the wait group wasn't incremented for this message
+				pErr := &ProducerError{Msg: msg, Err: ErrTransactionNotInProgress}
+				if p.conf.Producer.Return.Errors {
+					p.errors <- pErr
+				} else {
+					Logger.Println(pErr)
+				}
+				continue
+			}
 			p.inFlight.Add(1)
+			if msg.Timestamp.IsZero() {
+				msg.Timestamp = time.Now()
+			}
+		}
+
+		if msg.Headers != nil && !p.conf.Version.IsAtLeast(V0_11_0_0) {
+			p.returnError(msg, ConfigurationError("Producing headers requires Version >= V0_11_0_0"))
+			continue
 		}
 
 		if (p.conf.Producer.Compression == CompressionNone && msg.Value != nil && msg.Value.Length() > p.conf.Producer.MaxMessageBytes) ||
@@ -636,8 +705,10 @@ func (a *aggregator) wouldOverflow(msg *ProducerMessage) bool {
 	// Would we overflow our maximum possible size-on-the-wire? 10KiB is arbitrary overhead for safety.
 	case a.bufferBytes+msg.byteSize() >= int(MaxRequestSize-(10*1024)):
 		return true
-	// Would we overflow the size-limit of a compressed message-batch?
-	case a.parent.conf.Producer.Compression != CompressionNone && a.bufferBytes+msg.byteSize() >= a.parent.conf.Producer.MaxMessageBytes:
+	// Would we overflow the size-limit of a compressed message-batch, or of a record batch (which is
+	// sent as a whole, one per partition)?
+	case (a.parent.conf.Producer.Compression != CompressionNone || a.parent.conf.Version.IsAtLeast(V0_11_0_0)) &&
+		a.bufferBytes+msg.byteSize() >= a.parent.conf.Producer.MaxMessageBytes:
 		return true
 	// Would we overflow simply in number of messages?
 	case a.parent.conf.Producer.Flush.MaxMessages > 0 && len(a.buffer) >= a.parent.conf.Producer.Flush.MaxMessages:
@@ -693,6 +764,18 @@ func (f *flusher) run() {
 		}
 
 		msgSets := f.groupAndFilter(batch)
+		if f.parent.txnmgr != nil {
+			if err := f.parent.txnmgr.addPartitions(msgSets); err != nil {
+				Logger.Printf("producer/flusher/%d failed to add partitions to the transaction: %s\n", f.broker.ID(), err)
+				for _, partitionSet := range msgSets {
+					for _, msgs := range partitionSet {
+						f.parent.returnErrors(msgs, err)
+					}
+				}
+				continue
+			}
+			f.parent.txnmgr.assignSequences(msgSets)
+		}
 		request := f.parent.buildRequest(msgSets)
 		if request == nil {
 			continue
@@ -708,8 +791,8 @@ func (f *flusher) run() {
 			continue
 		default:
 			Logger.Printf("producer/flusher/%d state change to [closing] because %s\n", f.broker.ID(), err)
+			// a new flusher takes over the retries, while the broker reopens its connection by itself
 			f.parent.abandonBrokerConnection(f.broker)
-			_ = f.broker.Close()
 			closing = err
 			f.parent.retryMessages(batch, err)
 			continue
@@ -722,6 +805,18 @@ func (f *flusher) run() {
 		}
 
 		f.parseResponse(msgSets, response)
+
+		if response.ThrottleTime > 0 && f.parent.conf.Producer.BackoffOnThrottle {
+			Logger.Printf("producer/flusher/%d backing off for %dms as the broker throttled us\n",
+				f.broker.ID(), response.ThrottleTime/time.Millisecond)
+			timer := time.NewTimer(response.ThrottleTime)
+			select {
+			case <-timer.C:
+			case <-f.parent.shuttingDown:
+				// the messages left are flushed right away, so that they do not hold up the shutdown
+				timer.Stop()
+			}
+		}
 	}
 	Logger.Printf("producer/flusher/%d shut down\n", f.broker.ID())
 }
@@ -793,9 +888,29 @@ func (f *flusher) parseResponse(msgSets map[string]map[int32][]*ProducerMessage,
 					msgs[i].Offset = block.Offset + int64(i)
 				}
 				f.parent.returnSuccesses(msgs)
+			// The broker already wrote these records, from a request whose response was lost
+			case ErrDuplicateSequenceNumber:
+				for i := range msgs {
+					msgs[i].Offset = -1 // unknown
+				}
+				f.parent.returnSuccesses(msgs)
+			// Errors of the idempotent producer, which retries with a new producer ID since
+			// earlier records went missing or the broker forgot about the current one
+			case ErrOutOfOrderSequenceNumber, ErrUnknownProducerID:
+				// a new producer ID would abort the transaction, which has to fail instead
+				if f.parent.txnmgr == nil || f.parent.txnmgr.isTransactional() {
+					f.parent.returnErrors(msgs, block.Err)
+					continue
+				}
+				if err := f.parent.txnmgr.reset(msgs[0].producerID, msgs[0].producerEpoch); err != nil {
+					Logger.Printf("producer/flusher/%d failed to obtain a new producer ID: %s\n", f.broker.ID(), err)
+					f.parent.returnErrors(msgs, err)
+					continue
+				}
+				fallthrough
 			// Retriable errors
 			case ErrUnknownTopicOrPartition, ErrNotLeaderForPartition, ErrLeaderNotAvailable,
-				ErrRequestTimedOut, ErrNotEnoughReplicas, ErrNotEnoughReplicasAfterAppend:
+				ErrRequestTimedOut, ErrNotEnoughReplicas, ErrNotEnoughReplicasAfterAppend, ErrNetworkException:
 				Logger.Printf("producer/flusher/%d state change to [retrying] on %s/%d because %v\n",
 					f.broker.ID(), topic, partition, block.Err)
 				if f.currentRetries[topic] == nil {
@@ -842,6 +957,7 @@ func (p *asyncProducer) retryHandler() {
 
 func (p *asyncProducer) shutdown() {
 	Logger.Println("Producer shutting down.")
+	close(p.shuttingDown)
 	p.inFlight.Add(1)
 	p.input <- &ProducerMessage{flags: shutdown}
 
@@ -863,40 +979,58 @@ func (p *asyncProducer) shutdown() {
 func (p *asyncProducer) buildRequest(batch map[string]map[int32][]*ProducerMessage) *ProduceRequest {
 
 	req := &ProduceRequest{RequiredAcks: p.conf.Producer.RequiredAcks, Timeout: int32(p.conf.Producer.Timeout / time.Millisecond)}
+	if p.conf.Producer.Transaction.ID != "" {
+		req.TransactionalID = &p.conf.Producer.Transaction.ID
+	}
+	var messageVersion int8
+	if p.conf.Producer.Compression == CompressionZSTD {
+		// brokers refuse ZSTD compressed batches below version 7
+		req.Version = 7
+	} else if p.conf.Version.IsAtLeast(V0_11_0_0) {
+		req.Version = 3
+	} else if p.conf.Version.IsAtLeast(V0_10_0_0) {
+		// messages carrying timestamps can only be sent from version 2 on
+		req.Version = 2
+		messageVersion = 1
+	}
 	empty := true
 
 	for topic, partitionSet := range batch {
 		for partition, msgSet := range partitionSet {
+			if req.Version >= 3 {
+				if len(msgSet) > 0 {
+					req.AddBatch(topic, partition, p.buildRecordBatch(msgSet))
+					empty = false
+				}
+				continue
+			}
+
 			setToSend := new(MessageSet)
 			setSize := 0
 			for _, msg := range msgSet {
 				if p.conf.Producer.Compression != CompressionNone && setSize+msg.byteSize() > p.conf.Producer.MaxMessageBytes {
 					// compression causes message-sets to be wrapped as single messages, which have tighter
 					// size requirements, so we have to respect those limits
-					valBytes, err := encode(setToSend)
-					if err != nil {
-						Logger.Println(err) // if this happens, it's basically our fault.
-						panic(err)
-					}
-					req.AddMessage(topic, partition, &Message{Codec: p.conf.Producer.Compression, Key: nil, Value: valBytes})
+					req.AddMessage(topic, partition, p.compressMessageSet(setToSend, messageVersion))
 					setToSend = new(MessageSet)
 					setSize = 0
 				}
 				setSize += msg.byteSize()
 
-				setToSend.addMessage(&Message{Codec: CompressionNone, Key: msg.keyCache, Value: msg.valueCache})
+				setToSend.addMessage(&Message{
+					Codec:     CompressionNone,
+					Key:       msg.keyCache,
+					Value:     msg.valueCache,
+					Version:   messageVersion,
+					Timestamp: msg.Timestamp,
+				})
 				empty = false
 			}
 
 			if p.conf.Producer.Compression == CompressionNone {
 				req.AddSet(topic, partition, setToSend)
 			} else {
-				valBytes, err := encode(setToSend)
-				if err != nil {
-					Logger.Println(err) // if this happens, it's basically our fault.
-					panic(err)
-				}
-				req.AddMessage(topic, partition, &Message{Codec: p.conf.Producer.Compression, Key: nil, Value: valBytes})
+				req.AddMessage(topic, partition, p.compressMessageSet(setToSend, messageVersion))
 			}
 		}
 	}
@@ -907,7 +1041,79 @@ func (p *asyncProducer) buildRequest(batch map[string]map[int32][]*ProducerMessa
 	return req
 }
 
+// buildRecordBatch puts the messages of a partition into a single record batch, whose size the
+// aggregator keeps below Producer.MaxMessageBytes.
+func (p *asyncProducer) buildRecordBatch(msgs []*ProducerMessage) *RecordBatch {
+	batch := &RecordBatch{
+		Version:          2,
+		Codec:            p.conf.Producer.Compression,
+		CompressionLevel: p.conf.Producer.CompressionLevel,
+		LastOffsetDelta:  int32(len(msgs) - 1),
+		FirstTimestamp:   msgs[0].Timestamp.Truncate(time.Millisecond),
+		ProducerID:       -1,
+		ProducerEpoch:    -1,
+		FirstSequence:    -1,
+		IsTransactional:  p.conf.Producer.Transaction.ID != "",
+	}
+	if msgs[0].hasSequence {
+		batch.ProducerID = msgs[0].producerID
+		batch.ProducerEpoch = msgs[0].producerEpoch
+		batch.FirstSequence = msgs[0].sequenceNumber
+	}
+
+	for i, msg := range msgs {
+		timestamp := msg.Timestamp.Truncate(time.Millisecond)
+		if timestamp.After(batch.MaxTimestamp) {
+			batch.MaxTimestamp = timestamp
+		}
+
+		var headers []*RecordHeader
+		for j := range msg.Headers {
+			headers = append(headers, &msg.Headers[j])
+		}
+
+		batch.addRecord(&Record{
+			TimestampDelta: timestamp.Sub(batch.FirstTimestamp),
+			OffsetDelta:    int64(i),
+			Key:            msg.keyCache,
+			Value:          msg.valueCache,
+			Headers:        headers,
+		})
+	}
+	return batch
+}
+
+// compressMessageSet wraps a message set into a single compressed message. In the v1 message format
+// the wrapped messages carry offsets relative to the wrapper, and the wrapper the latest timestamp.
+func (p *asyncProducer) compressMessageSet(set *MessageSet, messageVersion int8) *Message {
+	wrapper := &Message{
+		Codec:            p.conf.Producer.Compression,
+		CompressionLevel: p.conf.Producer.CompressionLevel,
+		Key:              nil,
+		Version:          messageVersion,
+	}
+	if messageVersion >= 1 {
+		for i, block := range set.Messages {
+			block.Offset = int64(i)
+			if block.Msg.Timestamp.After(wrapper.Timestamp) {
+				wrapper.Timestamp = block.Msg.Timestamp
+			}
+		}
+	}
+
+	valBytes, err := encode(set)
+	if err != nil {
+		Logger.Println(err) // if this happens, it's basically our fault.
+		panic(err)
+	}
+	wrapper.Value = valBytes
+	return wrapper
+}
+
 func (p *asyncProducer) returnError(msg *ProducerMessage, err error) {
+	if msg.flags&intxn != 0 {
+		p.txnmgr.done(err)
+	}
 	msg.clear()
 	pErr := &ProducerError{Msg: msg, Err: err}
 	if p.conf.Producer.Return.Errors {
@@ -931,6 +1137,9 @@ func (p *asyncProducer) returnSuccesses(batch []*ProducerMessage) {
 		if msg == nil {
 			continue
 		}
+		if msg.flags&intxn != 0 {
+			p.txnmgr.done(nil)
+		}
 		if p.conf.Producer.Return.Successes {
 			msg.clear()
 			p.successes <- msg
@@ -139,6 +139,190 @@ func TestAsyncProducer(t *testing.T) {
 	seedBroker.Close()
 }
 
+func TestAsyncProducerTimestamps(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	leader := newMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+
+	config := NewConfig()
+	config.Version = V0_10_0_0
+	config.Producer.Flush.Messages = 2
+	config.Producer.Compression = CompressionGZIP
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp := time.Unix(1479847795, 0)
+	before := time.Now()
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Timestamp: timestamp}
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 2, 0)
+
+	closeProducer(t, producer)
+	leader.Close()
+	seedBroker.Close()
+
+	var request *ProduceRequest
+	for _, rr := range leader.History() {
+		if req, ok := rr.Request.(*ProduceRequest); ok {
+			request = req
+		}
+	}
+	if request == nil {
+		t.Fatal("Expected a produce request")
+	}
+	if request.Version != 2 {
+		t.Error("Expected the produce request to be sent at version 2, got", request.Version)
+	}
+
+	wrapper := request.msgSets["my_topic"][0].Messages[0].Msg
+	if wrapper.Version != 1 || wrapper.Set == nil || len(wrapper.Set.Messages) != 2 {
+		t.Fatal("Expected a v1 message wrapping the two messages")
+	}
+	first, second := wrapper.Set.Messages[0], wrapper.Set.Messages[1]
+	if first.Offset != 0 || second.Offset != 1 {
+		t.Error("Expected relative offsets in the wrapped messages, got", first.Offset, second.Offset)
+	}
+	if !first.Msg.Timestamp.Equal(timestamp) {
+		t.Error("Expected the timestamp of the message to be kept, got", first.Msg.Timestamp)
+	}
+	if second.Msg.Timestamp.Before(before.Truncate(time.Millisecond)) {
+		t.Error("Expected the producer to set the current time, got", second.Msg.Timestamp)
+	}
+	if !wrapper.Timestamp.Equal(second.Msg.Timestamp) {
+		t.Error("Expected the wrapper to carry the latest timestamp, got", wrapper.Timestamp)
+	}
+}
+
+func TestAsyncProducerRecordBatches(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	leader := newMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Flush.Messages = 2
+	config.Producer.Compression = CompressionSnappy
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := []RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}}
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Headers: headers}
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 2, 0)
+
+	closeProducer(t, producer)
+	leader.Close()
+	seedBroker.Close()
+
+	var request *ProduceRequest
+	for _, rr := range leader.History() {
+		if req, ok := rr.Request.(*ProduceRequest); ok {
+			request = req
+		}
+	}
+	if request == nil {
+		t.Fatal("Expected a produce request")
+	}
+	if request.Version != 3 {
+		t.Error("Expected the produce request to be sent at version 3, got", request.Version)
+	}
+
+	batch := request.recordBatches["my_topic"][0]
+	if batch == nil || len(batch.Records) != 2 {
+		t.Fatal("Expected a record batch holding the two messages")
+	}
+	if batch.Codec != CompressionSnappy || batch.LastOffsetDelta != 1 || batch.Records[1].OffsetDelta != 1 {
+		t.Error("Unexpected record batch", batch)
+	}
+	if len(batch.Records[0].Headers) != 1 || string(batch.Records[0].Headers[0].Value) != "abc" {
+		t.Error("Expected the headers to be sent, got", batch.Records[0].Headers)
+	}
+}
+
+func TestAsyncProducerZSTD(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	leader := newMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+
+	config := NewConfig()
+	config.Version = V2_1_0_0
+	config.Producer.Compression = CompressionZSTD
+	config.Producer.CompressionLevel = 19
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 1, 0)
+
+	closeProducer(t, producer)
+	leader.Close()
+	seedBroker.Close()
+
+	for _, rr := range leader.History() {
+		if req, ok := rr.Request.(*ProduceRequest); ok {
+			if req.Version != 7 {
+				t.Error("Expected the produce request to be sent at version 7, got", req.Version)
+			}
+			if batch := req.recordBatches["my_topic"][0]; batch == nil || batch.Codec != CompressionZSTD {
+				t.Error("Expected a ZSTD compressed record batch, got", batch)
+			}
+			return
+		}
+	}
+	t.Error("Expected a produce request")
+}
+
+func TestAsyncProducerHeadersRequireVersion(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	seedBroker.Returns(new(MetadataResponse))
+
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Headers: []RecordHeader{{}}}
+	if pErr := <-producer.Errors(); pErr.Err == nil {
+		t.Error("Expected headers to be refused before Kafka 0.11")
+	}
+
+	closeProducer(t, producer)
+	seedBroker.Close()
+}
+
 func TestAsyncProducerMultipleFlushes(t *testing.T) {
 	seedBroker := newMockBroker(t, 1)
 	leader := newMockBroker(t, 2)
@@ -709,6 +893,246 @@ func TestAsyncProducerRetryShutdown(t *testing.T) {
 	}
 }
 
+func TestAsyncProducerThrottle(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	leader := newMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	var lock sync.Mutex
+	var produced []time.Time
+	leader.SetHandler(func(req *request) (res encoder) {
+		if _, ok := req.body.(*ProduceRequest); !ok {
+			return nil
+		}
+		lock.Lock()
+		produced = append(produced, time.Now())
+		lock.Unlock()
+
+		response := &ProduceResponse{ThrottleTime: 100 * time.Millisecond}
+		response.AddTopicPartition("my_topic", 0, ErrNoError)
+		return response
+	})
+
+	config := NewConfig()
+	config.Version = V0_10_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.BackoffOnThrottle = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+		expectResults(t, producer, 1, 0)
+	}
+	closeProducer(t, producer)
+	seedBroker.Close()
+	leader.Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(produced) != 2 {
+		t.Fatal("Expected two produce requests, got", len(produced))
+	}
+	if elapsed := produced[1].Sub(produced[0]); elapsed < 100*time.Millisecond {
+		t.Error("Expected the producer to back off while throttled, sent the next request after", elapsed)
+	}
+
+	for _, name := range []string{"produce-throttle-time-in-ms", "produce-throttle-time-in-ms-for-broker-2"} {
+		histogram := getOrRegisterHistogram(name, config.MetricRegistry)
+		if histogram.Count() != 2 || histogram.Max() != 100 {
+			t.Errorf("Expected %s to record two throttles of 100ms, got %d with max %d", name, histogram.Count(), histogram.Max())
+		}
+	}
+}
+
+func TestAsyncProducerThrottleDoesNotHoldUpShutdown(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	leader := newMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	leader.SetHandler(func(req *request) (res encoder) {
+		if _, ok := req.body.(*ProduceRequest); !ok {
+			return nil
+		}
+		response := &ProduceResponse{ThrottleTime: time.Minute}
+		response.AddTopicPartition("my_topic", 0, ErrNoError)
+		return response
+	})
+
+	config := NewConfig()
+	config.Version = V0_10_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.BackoffOnThrottle = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 1, 0)
+
+	// the second message waits for the throttling of the first request, until the producer shuts down
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	start := time.Now()
+	producer.AsyncClose()
+	var successes int
+	for range producer.Successes() {
+		successes++
+	}
+	for err := range producer.Errors() {
+		t.Error(err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Error("Expected the shutdown to stop waiting out the throttling, took", elapsed)
+	}
+	if successes != 1 {
+		t.Error("Expected the second message to be flushed on shutdown, got", successes, "successes")
+	}
+
+	seedBroker.Close()
+	leader.Close()
+}
+
+func newIdempotentProducerConfig() *Config {
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Idempotent = true
+	config.Producer.RequiredAcks = WaitForAll
+	config.Producer.Flush.Messages = 2
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Backoff = 0
+	return config
+}
+
+func producedBatches(b *mockBroker) []*RecordBatch {
+	var batches []*RecordBatch
+	for _, rr := range b.History() {
+		if req, ok := rr.Request.(*ProduceRequest); ok {
+			batches = append(batches, req.recordBatches["my_topic"][0])
+		}
+	}
+	return batches
+}
+
+func TestAsyncProducerIdempotent(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	leader := newMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+	seedBroker.Returns(&InitProducerIDResponse{ProducerID: 1000, ProducerEpoch: 1})
+
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, newIdempotentProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first attempt times out, and the broker recognises the retry as a duplicate
+	prodTimedOut := new(ProduceResponse)
+	prodTimedOut.AddTopicPartition("my_topic", 0, ErrRequestTimedOut)
+	leader.Returns(prodTimedOut)
+	seedBroker.Returns(metadataResponse)
+	prodDuplicate := new(ProduceResponse)
+	prodDuplicate.AddTopicPartition("my_topic", 0, ErrDuplicateSequenceNumber)
+	leader.Returns(prodDuplicate)
+
+	for i := 0; i < 2; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-producer.Successes():
+			if msg.Offset != -1 {
+				t.Error("Expected the offset of a duplicate to be unknown, got", msg.Offset)
+			}
+		case msg := <-producer.Errors():
+			t.Error(msg.Err)
+		}
+	}
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+	for i := 0; i < 2; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	}
+	expectResults(t, producer, 2, 0)
+
+	closeProducer(t, producer)
+	leader.Close()
+	seedBroker.Close()
+
+	batches := producedBatches(leader)
+	if len(batches) != 3 {
+		t.Fatal("Expected 3 record batches, got", len(batches))
+	}
+	for i, sequence := range []int32{0, 0, 2} {
+		batch := batches[i]
+		if batch.ProducerID != 1000 || batch.ProducerEpoch != 1 || batch.FirstSequence != sequence {
+			t.Errorf("Expected batch %d to be sent by producer 1000/1 from sequence %d, got %d/%d from %d",
+				i, sequence, batch.ProducerID, batch.ProducerEpoch, batch.FirstSequence)
+		}
+	}
+}
+
+func TestAsyncProducerIdempotentOutOfOrderSequence(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	leader := newMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+	seedBroker.Returns(&InitProducerIDResponse{ProducerID: 1000, ProducerEpoch: 1})
+
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, newIdempotentProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the broker misses earlier records, so the producer starts over with a new producer ID
+	prodOutOfOrder := new(ProduceResponse)
+	prodOutOfOrder.AddTopicPartition("my_topic", 0, ErrOutOfOrderSequenceNumber)
+	leader.Returns(prodOutOfOrder)
+	seedBroker.Returns(&InitProducerIDResponse{ProducerID: 1001})
+	seedBroker.Returns(metadataResponse)
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+
+	for i := 0; i < 2; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	}
+	expectResults(t, producer, 2, 0)
+
+	closeProducer(t, producer)
+	leader.Close()
+	seedBroker.Close()
+
+	batches := producedBatches(leader)
+	if len(batches) != 2 {
+		t.Fatal("Expected 2 record batches, got", len(batches))
+	}
+	if batches[0].ProducerID != 1000 || batches[0].FirstSequence != 0 {
+		t.Error("Unexpected first batch", batches[0])
+	}
+	if batches[1].ProducerID != 1001 || batches[1].ProducerEpoch != 0 || batches[1].FirstSequence != 0 {
+		t.Error("Expected the retry to start over with the new producer ID, got", batches[1])
+	}
+}
+
 // This example shows how to use the producer while simultaneously
 // reading the Errors channel to know about any failures.
 func ExampleAsyncProducer_select() {
@@ -799,3 +1223,25 @@ ProducerLoop:
 
 	log.Printf("Successfully produced: %d; errors: %d\n", successes, errors)
 }
+
+func TestAsyncProducerCompressionLevel(t *testing.T) {
+	config := NewConfig()
+	config.Producer.Compression = CompressionGZIP
+	config.Producer.CompressionLevel = 9
+	msgs := map[string]map[int32][]*ProducerMessage{
+		"my_topic": {0: {{Topic: "my_topic", valueCache: []byte(TestMessage)}}},
+	}
+
+	config.Version = V0_10_0_0
+	p := &asyncProducer{conf: config}
+	req := p.buildRequest(msgs)
+	if wrapper := req.msgSets["my_topic"][0].Messages[0].Msg; wrapper.CompressionLevel != 9 {
+		t.Error("Expected the message set to be compressed at level 9, got", wrapper.CompressionLevel)
+	}
+
+	config.Version = V0_11_0_0
+	req = p.buildRequest(msgs)
+	if batch := req.recordBatches["my_topic"][0]; batch.CompressionLevel != 9 {
+		t.Error("Expected the record batch to be compressed at level 9, got", batch.CompressionLevel)
+	}
+}
@@ -0,0 +1,359 @@
+package sarama
+
+import (
+	"sort"
+)
+
+// BalanceStrategyPlan is the result of any BalanceStrategy.Plan attempt. It contains an
+// allocation of partitions by member ID, in the form of a `memberID -> topic -> partitions` map.
+type BalanceStrategyPlan map[string]map[string][]int32
+
+// Add assigns partitions of a topic to a member.
+func (p BalanceStrategyPlan) Add(memberID, topic string, partitions ...int32) {
+	if len(partitions) == 0 {
+		return
+	}
+	if _, ok := p[memberID]; !ok {
+		p[memberID] = make(map[string][]int32, 1)
+	}
+	p[memberID][topic] = append(p[memberID][topic], partitions...)
+}
+
+// BalanceStrategy is used by the leader of a consumer group to distribute the partitions of the
+// subscribed topics between the members of the group. All members of a group must use a strategy
+// with the same name.
+type BalanceStrategy interface {
+	// Name uniquely identifies the strategy. It is sent to the coordinator as the name
+	// of the group protocol, and must match the name used by any JVM members of the group.
+	Name() string
+
+	// Plan accepts a map of `memberID -> metadata` and a map of `topic -> partitions`
+	// and returns a distribution plan.
+	Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error)
+
+	// AssignmentData returns the user data a member sends along with its subscription
+	// when joining the group, given the partitions it was assigned in the previous
+	// generation. Strategies that do not need it return nil.
+	AssignmentData(memberID string, topics map[string][]int32, generationID int32) ([]byte, error)
+}
+
+var (
+	// BalanceStrategyRange is the default and assigns partitions as ranges to consumer
+	// group members. Example with topic T with six partitions (0..5) and two members (M1, M2):
+	//   M1: {T: [0, 1, 2]}
+	//   M2: {T: [3, 4, 5]}
+	BalanceStrategyRange = &balanceStrategy{
+		name:     "range",
+		coreFunc: balanceRange,
+	}
+
+	// BalanceStrategyRoundRobin assigns partitions to members in alternating order.
+	// Example with topic T with six partitions (0..5) and two members (M1, M2):
+	//   M1: {T: [0, 2, 4]}
+	//   M2: {T: [1, 3, 5]}
+	BalanceStrategyRoundRobin = &balanceStrategy{
+		name:     "roundrobin",
+		coreFunc: balanceRoundRobin,
+	}
+
+	// BalanceStrategySticky assigns partitions to members with an attempt to preserve
+	// earlier assignments while maintaining a balanced partition distribution.
+	// Example with topic T with six partitions (0..5) and two members (M1, M2):
+	//   M1: {T: [0, 2, 4]}
+	//   M2: {T: [1, 3, 5]}
+	//
+	// On reassignment with an additional consumer, you might get an assignment plan like:
+	//   M1: {T: [0, 2]}
+	//   M2: {T: [1, 3]}
+	//   M3: {T: [4, 5]}
+	//
+	// Each member sends the partitions it owned in the previous generation as user data,
+	// in the format of the JVM's StickyAssignor, so that the leader can keep them in place.
+	BalanceStrategySticky = &stickyBalanceStrategy{}
+)
+
+// balanceStrategy implements the stateless strategies, which only need to know the sorted
+// subscribers of every topic
+type balanceStrategy struct {
+	name     string
+	coreFunc func(plan BalanceStrategyPlan, subscribers map[string][]string, topics map[string][]int32)
+}
+
+func (s *balanceStrategy) Name() string { return s.name }
+
+func (s *balanceStrategy) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	plan := make(BalanceStrategyPlan, len(members))
+	s.coreFunc(plan, subscribersByTopic(members, topics), topics)
+	return plan, nil
+}
+
+func (s *balanceStrategy) AssignmentData(memberID string, topics map[string][]int32, generationID int32) ([]byte, error) {
+	return nil, nil
+}
+
+// subscribersByTopic returns the sorted IDs of the members subscribed to each of the given topics
+func subscribersByTopic(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) map[string][]string {
+	subscribers := make(map[string][]string, len(topics))
+	for memberID, meta := range members {
+		for _, topic := range meta.Topics {
+			if _, ok := topics[topic]; ok {
+				subscribers[topic] = append(subscribers[topic], memberID)
+			}
+		}
+	}
+	for _, memberIDs := range subscribers {
+		sort.Strings(memberIDs)
+	}
+	return subscribers
+}
+
+// balanceRange assigns every subscriber a contiguous range of the partitions of each topic, the
+// first members in sorted order receiving one extra partition if they cannot be split evenly.
+func balanceRange(plan BalanceStrategyPlan, subscribers map[string][]string, topics map[string][]int32) {
+	for topic, memberIDs := range subscribers {
+		partitions := topics[topic]
+		share, extra := len(partitions)/len(memberIDs), len(partitions)%len(memberIDs)
+
+		start := 0
+		for i, memberID := range memberIDs {
+			length := share
+			if i < extra {
+				length++
+			}
+			plan.Add(memberID, topic, partitions[start:start+length]...)
+			start += length
+		}
+	}
+}
+
+// balanceRoundRobin deals the partitions of all topics, sorted by topic and partition, to the
+// members in turn, skipping the members that are not subscribed to the topic at hand.
+func balanceRoundRobin(plan BalanceStrategyPlan, subscribers map[string][]string, topics map[string][]int32) {
+	var memberIDs []string
+	subscriptions := make(map[string]map[string]bool)
+	for topic, ids := range subscribers {
+		for _, memberID := range ids {
+			if subscriptions[memberID] == nil {
+				subscriptions[memberID] = make(map[string]bool)
+				memberIDs = append(memberIDs, memberID)
+			}
+			subscriptions[memberID][topic] = true
+		}
+	}
+	sort.Strings(memberIDs)
+
+	next := 0
+	for _, tp := range sortedTopicPartitions(topics) {
+		if len(subscribers[tp.topic]) == 0 {
+			continue
+		}
+		for !subscriptions[memberIDs[next]][tp.topic] {
+			next = (next + 1) % len(memberIDs)
+		}
+		plan.Add(memberIDs[next], tp.topic, tp.partition)
+		next = (next + 1) % len(memberIDs)
+	}
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+func sortedTopicPartitions(topics map[string][]int32) []topicPartition {
+	var ret []topicPartition
+	for topic, partitions := range topics {
+		for _, partition := range partitions {
+			ret = append(ret, topicPartition{topic, partition})
+		}
+	}
+	sort.Sort(topicPartitionSlice(ret))
+	return ret
+}
+
+type topicPartitionSlice []topicPartition
+
+func (s topicPartitionSlice) Len() int      { return len(s) }
+func (s topicPartitionSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s topicPartitionSlice) Less(i, j int) bool {
+	if s[i].topic != s[j].topic {
+		return s[i].topic < s[j].topic
+	}
+	return s[i].partition < s[j].partition
+}
+
+// Sticky strategy
+
+type stickyBalanceStrategy struct{}
+
+func (s *stickyBalanceStrategy) Name() string { return "sticky" }
+
+func (s *stickyBalanceStrategy) AssignmentData(memberID string, topics map[string][]int32, generationID int32) ([]byte, error) {
+	return encode(&stickyAssignorUserData{Topics: topics, Generation: generationID})
+}
+
+func (s *stickyBalanceStrategy) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	subscribers := subscribersByTopic(members, topics)
+
+	subscribed := make(map[string]map[string]bool, len(members))
+	var memberIDs []string
+	for memberID := range members {
+		subscribed[memberID] = make(map[string]bool)
+		memberIDs = append(memberIDs, memberID)
+	}
+	sort.Strings(memberIDs)
+	for topic, ids := range subscribers {
+		for _, memberID := range ids {
+			subscribed[memberID][topic] = true
+		}
+	}
+
+	exists := make(map[topicPartition]bool)
+	for _, tp := range sortedTopicPartitions(topics) {
+		exists[tp] = true
+	}
+
+	// keep every partition with its previous owner, as long as that member is still subscribed to
+	// the topic; if several members claim the same partition, the latest generation wins
+	owners := make(map[topicPartition]string)
+	generations := make(map[topicPartition]int32)
+	for _, memberID := range memberIDs {
+		if len(members[memberID].UserData) == 0 {
+			continue
+		}
+
+		userData := new(stickyAssignorUserData)
+		if err := decode(members[memberID].UserData, userData); err != nil {
+			return nil, err
+		}
+
+		for topic, partitions := range userData.Topics {
+			if !subscribed[memberID][topic] {
+				continue
+			}
+			for _, partition := range partitions {
+				tp := topicPartition{topic, partition}
+				if !exists[tp] {
+					continue
+				}
+				if _, claimed := owners[tp]; claimed && generations[tp] >= userData.Generation {
+					continue
+				}
+				owners[tp] = memberID
+				generations[tp] = userData.Generation
+			}
+		}
+	}
+
+	assigned := make(map[string][]topicPartition, len(memberIDs))
+	for _, tp := range sortedTopicPartitions(topics) {
+		if memberID, ok := owners[tp]; ok {
+			assigned[memberID] = append(assigned[memberID], tp)
+		}
+	}
+
+	// the member with the fewest partitions among the subscribers of a topic, except the given one
+	leastLoaded := func(topic, except string) string {
+		choice := ""
+		for _, memberID := range subscribers[topic] {
+			if memberID == except {
+				continue
+			}
+			if choice == "" || len(assigned[memberID]) < len(assigned[choice]) {
+				choice = memberID
+			}
+		}
+		return choice
+	}
+
+	// hand out the partitions that have no (remaining) owner
+	for _, tp := range sortedTopicPartitions(topics) {
+		if _, ok := owners[tp]; ok {
+			continue
+		}
+		if memberID := leastLoaded(tp.topic, ""); memberID != "" {
+			owners[tp] = memberID
+			assigned[memberID] = append(assigned[memberID], tp)
+		}
+	}
+
+	// move partitions from the most to the least loaded members until no move improves the
+	// balance; every move lowers the sum of the squared loads, so this terminates
+	for moved := true; moved; {
+		moved = false
+		for _, memberID := range memberIDs {
+			for i := len(assigned[memberID]) - 1; i >= 0; i-- {
+				tp := assigned[memberID][i]
+				target := leastLoaded(tp.topic, memberID)
+				if target == "" || len(assigned[memberID])-len(assigned[target]) <= 1 {
+					continue
+				}
+
+				assigned[memberID] = append(assigned[memberID][:i], assigned[memberID][i+1:]...)
+				assigned[target] = append(assigned[target], tp)
+				moved = true
+			}
+		}
+	}
+
+	plan := make(BalanceStrategyPlan, len(memberIDs))
+	for _, memberID := range memberIDs {
+		sort.Sort(topicPartitionSlice(assigned[memberID]))
+		for _, tp := range assigned[memberID] {
+			plan.Add(memberID, tp.topic, tp.partition)
+		}
+	}
+	return plan, nil
+}
+
+// stickyAssignorUserData is the user data of the JVM's StickyAssignor: the partitions owned by the
+// member, followed by the generation they were assigned in (version 1 and later only).
+type stickyAssignorUserData struct {
+	Topics     map[string][]int32
+	Generation int32
+}
+
+func (m *stickyAssignorUserData) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(m.Topics)); err != nil {
+		return err
+	}
+	for topic, partitions := range m.Topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putInt32Array(partitions); err != nil {
+			return err
+		}
+	}
+
+	pe.putInt32(m.Generation)
+	return nil
+}
+
+func (m *stickyAssignorUserData) decode(pd packetDecoder) (err error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	m.Topics = make(map[string][]int32, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		if m.Topics[topic], err = pd.getInt32Array(); err != nil {
+			return err
+		}
+	}
+
+	// version 0 of the format does not carry the generation
+	m.Generation = -1
+	if pd.remaining() >= 4 {
+		if m.Generation, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
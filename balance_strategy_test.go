@@ -0,0 +1,222 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBalanceStrategyRange(t *testing.T) {
+	tests := []struct {
+		members  map[string][]string
+		topics   map[string][]int32
+		expected BalanceStrategyPlan
+	}{
+		{
+			members: map[string][]string{"M1": {"T1", "T2"}, "M2": {"T1", "T2"}},
+			topics:  map[string][]int32{"T1": {0, 1, 2, 3}, "T2": {0, 1, 2, 3}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {0, 1}, "T2": {0, 1}},
+				"M2": map[string][]int32{"T1": {2, 3}, "T2": {2, 3}},
+			},
+		},
+		{
+			members: map[string][]string{"M1": {"T1", "T2"}, "M2": {"T1", "T2"}},
+			topics:  map[string][]int32{"T1": {0, 1, 2}, "T2": {0, 1, 2}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {0, 1}, "T2": {0, 1}},
+				"M2": map[string][]int32{"T1": {2}, "T2": {2}},
+			},
+		},
+		{
+			members: map[string][]string{"M1": {"T1"}, "M2": {"T1", "T2"}},
+			topics:  map[string][]int32{"T1": {0, 1}, "T2": {0, 1}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {0}},
+				"M2": map[string][]int32{"T1": {1}, "T2": {0, 1}},
+			},
+		},
+	}
+
+	strategy := BalanceStrategyRange
+	if strategy.Name() != "range" {
+		t.Errorf("Unexpected stategy name\nexpected: range\nactual: %v", strategy.Name())
+	}
+
+	for _, test := range tests {
+		members := make(map[string]ConsumerGroupMemberMetadata)
+		for memberID, topics := range test.members {
+			members[memberID] = ConsumerGroupMemberMetadata{Topics: topics}
+		}
+
+		actual, err := strategy.Plan(members, test.topics)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		} else if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Plan does not match expectation\nexpected: %#v\nactual: %#v", test.expected, actual)
+		}
+	}
+}
+
+func TestBalanceStrategyRoundRobin(t *testing.T) {
+	tests := []struct {
+		members  map[string][]string
+		topics   map[string][]int32
+		expected BalanceStrategyPlan
+	}{
+		{
+			members: map[string][]string{"M1": {"T1", "T2"}, "M2": {"T1", "T2"}},
+			topics:  map[string][]int32{"T1": {0, 1, 2, 3}, "T2": {0, 1, 2, 3}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {0, 2}, "T2": {0, 2}},
+				"M2": map[string][]int32{"T1": {1, 3}, "T2": {1, 3}},
+			},
+		},
+		{
+			members: map[string][]string{"M1": {"T1", "T2"}, "M2": {"T1", "T2"}},
+			topics:  map[string][]int32{"T1": {0, 1, 2}, "T2": {0, 1, 2}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {0, 2}, "T2": {1}},
+				"M2": map[string][]int32{"T1": {1}, "T2": {0, 2}},
+			},
+		},
+		{
+			members: map[string][]string{"M1": {"T1"}, "M2": {"T1", "T2"}},
+			topics:  map[string][]int32{"T1": {0, 1}, "T2": {0, 1}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {0}},
+				"M2": map[string][]int32{"T1": {1}, "T2": {0, 1}},
+			},
+		},
+	}
+
+	strategy := BalanceStrategyRoundRobin
+	if strategy.Name() != "roundrobin" {
+		t.Errorf("Unexpected stategy name\nexpected: roundrobin\nactual: %v", strategy.Name())
+	}
+
+	for _, test := range tests {
+		members := make(map[string]ConsumerGroupMemberMetadata)
+		for memberID, topics := range test.members {
+			members[memberID] = ConsumerGroupMemberMetadata{Topics: topics}
+		}
+
+		actual, err := strategy.Plan(members, test.topics)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		} else if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Plan does not match expectation\nexpected: %#v\nactual: %#v", test.expected, actual)
+		}
+	}
+}
+
+func TestBalanceStrategySticky(t *testing.T) {
+	strategy := BalanceStrategySticky
+	if strategy.Name() != "sticky" {
+		t.Errorf("Unexpected stategy name\nexpected: sticky\nactual: %v", strategy.Name())
+	}
+
+	topics := map[string][]int32{"T": {0, 1, 2, 3, 4, 5}}
+
+	// without previous assignments, partitions are dealt in turn
+	members := map[string]ConsumerGroupMemberMetadata{
+		"M1": {Topics: []string{"T"}},
+		"M2": {Topics: []string{"T"}},
+	}
+	plan, err := strategy.Plan(members, topics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := BalanceStrategyPlan{
+		"M1": map[string][]int32{"T": {0, 2, 4}},
+		"M2": map[string][]int32{"T": {1, 3, 5}},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("Plan does not match expectation\nexpected: %#v\nactual: %#v", expected, plan)
+	}
+
+	// a new member only takes over as many partitions as needed to rebalance
+	members = map[string]ConsumerGroupMemberMetadata{"M3": {Topics: []string{"T"}}}
+	for memberID, topics := range plan {
+		userData, err := strategy.AssignmentData(memberID, topics, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		members[memberID] = ConsumerGroupMemberMetadata{Topics: []string{"T"}, UserData: userData}
+	}
+	plan, err = strategy.Plan(members, topics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = BalanceStrategyPlan{
+		"M1": map[string][]int32{"T": {0, 2}},
+		"M2": map[string][]int32{"T": {1, 3}},
+		"M3": map[string][]int32{"T": {4, 5}},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("Plan does not match expectation\nexpected: %#v\nactual: %#v", expected, plan)
+	}
+
+	// the partitions of a departed member are spread over the others, which keep their own
+	for memberID, topics := range plan {
+		userData, err := strategy.AssignmentData(memberID, topics, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		members[memberID] = ConsumerGroupMemberMetadata{Topics: []string{"T"}, UserData: userData}
+	}
+	delete(members, "M1")
+	plan, err = strategy.Plan(members, topics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = BalanceStrategyPlan{
+		"M2": map[string][]int32{"T": {0, 1, 3}},
+		"M3": map[string][]int32{"T": {2, 4, 5}},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("Plan does not match expectation\nexpected: %#v\nactual: %#v", expected, plan)
+	}
+}
+
+func TestBalanceStrategyStickyConflictingClaims(t *testing.T) {
+	stale, err := BalanceStrategySticky.AssignmentData("M1", map[string][]int32{"T": {0, 1}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := BalanceStrategySticky.AssignmentData("M2", map[string][]int32{"T": {1}}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	members := map[string]ConsumerGroupMemberMetadata{
+		"M1": {Topics: []string{"T"}, UserData: stale},
+		"M2": {Topics: []string{"T"}, UserData: current},
+	}
+	plan, err := BalanceStrategySticky.Plan(members, map[string][]int32{"T": {0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := BalanceStrategyPlan{
+		"M1": map[string][]int32{"T": {0}},
+		"M2": map[string][]int32{"T": {1}},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("Plan does not match expectation\nexpected: %#v\nactual: %#v", expected, plan)
+	}
+}
+
+func TestStickyAssignorUserDataV0(t *testing.T) {
+	// version 0 of the JVM format, without a generation
+	buf := []byte{
+		0, 0, 0, 1, // one topic
+		0, 1, 'T', // topic name
+		0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 3, // partitions 0 and 3
+	}
+
+	userData := new(stickyAssignorUserData)
+	testDecodable(t, "v0", userData, buf)
+	if !reflect.DeepEqual(userData.Topics, map[string][]int32{"T": {0, 3}}) || userData.Generation != -1 {
+		t.Errorf("Unexpected user data %#v", userData)
+	}
+}
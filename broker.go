@@ -1,9 +1,16 @@
 package sarama
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/tls"
+	"encoding/binary"
 	"fmt"
+	"hash"
+	"hash/fnv"
 	"io"
+	"math/rand"
 	"net"
 	"strconv"
 	"sync"
@@ -15,6 +22,7 @@ import (
 type Broker struct {
 	id   int32
 	addr string
+	rack *string
 
 	conf          *Config
 	correlationID int32
@@ -22,13 +30,72 @@ type Broker struct {
 	connErr       error
 	lock          sync.Mutex
 	opened        int32
+	state         int32 // a BrokerState
+	failures      int32 // how many connections failed in a row, see Net.Reconnect
+
+	// while backing off from reconnecting, see waitBackoff: closed once the broker is done backing off,
+	// and by Close to cancel the connection
+	backoffDone   chan none
+	cancelBackoff chan none
+
+	// the versions the broker supports by API key, nil if it wasn't asked (Config.Version < V0_10_0_0)
+	apiVersions map[int16]*ApiVersionsResponseBlock
 
 	responses chan responsePromise
 	done      chan bool
+	broken    chan error // receives the error which broke the connection, see responseReceiver
+
+	// the extra connections of Net.ConnectionsPerBroker, by traffic class, see connectionFor
+	produceConns []*Broker
+	fetchConns   []*Broker
+	nextConn     uint32
+}
+
+// SASLMechanism is the name of a SASL mechanism supported by Config.Net.SASL.
+type SASLMechanism string
+
+const (
+	// SASLTypePlaintext is the SASL/PLAIN mechanism, which sends the credentials in the clear
+	// and should therefore be combined with TLS.
+	SASLTypePlaintext = SASLMechanism("PLAIN")
+	// SASLTypeSCRAMSHA256 is the SASL/SCRAM mechanism using SHA-256, see RFC 5802 and RFC 7677.
+	SASLTypeSCRAMSHA256 = SASLMechanism("SCRAM-SHA-256")
+	// SASLTypeSCRAMSHA512 is the SASL/SCRAM mechanism using SHA-512.
+	SASLTypeSCRAMSHA512 = SASLMechanism("SCRAM-SHA-512")
+)
+
+// BrokerState is the state of the connection of a Broker, see Broker.State.
+type BrokerState int32
+
+const (
+	// BrokerDisconnected is the state of a Broker which was never opened, was closed, or failed to connect.
+	BrokerDisconnected BrokerState = iota
+	// BrokerBackingOff is the state of a Broker waiting to reconnect after failed connections, see
+	// Config.Net.Reconnect.
+	BrokerBackingOff
+	// BrokerConnecting is the state of a Broker dialing and setting up its connection.
+	BrokerConnecting
+	// BrokerConnected is the state of a Broker ready to send requests.
+	BrokerConnected
+)
+
+func (s BrokerState) String() string {
+	switch s {
+	case BrokerDisconnected:
+		return "disconnected"
+	case BrokerBackingOff:
+		return "backing off"
+	case BrokerConnecting:
+		return "connecting"
+	case BrokerConnected:
+		return "connected"
+	}
+	return fmt.Sprintf("BrokerState(%d)", int32(s))
 }
 
 type responsePromise struct {
 	correlationID int32
+	version       int16 // the version the request was sent at, which the response is decoded at
 	packets       chan []byte
 	errors        chan error
 }
@@ -44,6 +111,11 @@ func NewBroker(addr string) *Broker {
 // block waiting for the connection to succeed or fail. To get the effect of a fully synchronous Open call,
 // follow it by a call to Connected(). The only errors Open will return directly are ConfigurationError or
 // AlreadyConnected. If conf is nil, the result of NewConfig() is used.
+//
+// If the previous connections failed, Open first backs off as configured in Net.Reconnect, meanwhile
+// requests wait for the connection unless their context is done first, and Close cancels it. A Broker
+// whose connection failed or broke also reopens itself, that way, on the next request it is asked to
+// send; only one closed with Close stays closed until opened again.
 func (b *Broker) Open(conf *Config) error {
 	if conf == nil {
 		conf = NewConfig()
@@ -54,6 +126,11 @@ func (b *Broker) Open(conf *Config) error {
 		return err
 	}
 
+	return b.open(conf)
+}
+
+// open is Open for a configuration which was validated already.
+func (b *Broker) open(conf *Config) error {
 	if !atomic.CompareAndSwapInt32(&b.opened, 0, 1) {
 		return ErrAlreadyConnected
 	}
@@ -66,41 +143,205 @@ func (b *Broker) Open(conf *Config) error {
 		return ErrAlreadyConnected
 	}
 
+	b.conf = conf
+	backoff := b.reconnectBackoff(conf)
+	var backoffDone, cancelBackoff chan none
+	if backoff > 0 {
+		// the lock is released while backing off, so that the broker can be closed in the meantime
+		atomic.StoreInt32(&b.state, int32(BrokerBackingOff))
+		Logger.Printf("Waiting %dms before reconnecting to broker %s\n", backoff/time.Millisecond, b.addr)
+		backoffDone, cancelBackoff = make(chan none), make(chan none)
+		b.backoffDone, b.cancelBackoff = backoffDone, cancelBackoff
+		b.lock.Unlock()
+	}
+
 	go withRecover(func() {
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-cancelBackoff:
+				timer.Stop()
+			}
+
+			b.lock.Lock()
+			close(backoffDone)
+			select {
+			case <-cancelBackoff:
+				b.lock.Unlock()
+				return // Close reset the broker already
+			default:
+			}
+			b.backoffDone, b.cancelBackoff = nil, nil
+		}
 		defer b.lock.Unlock()
 
-		dialer := net.Dialer{
-			Timeout:   conf.Net.DialTimeout,
-			KeepAlive: conf.Net.KeepAlive,
+		atomic.StoreInt32(&b.state, int32(BrokerConnecting))
+
+		b.conn, b.connErr = dial(conf, b.addr)
+		if b.connErr != nil {
+			b.conn = nil
+			atomic.AddInt32(&b.failures, 1)
+			atomic.StoreInt32(&b.state, int32(BrokerDisconnected))
+			atomic.StoreInt32(&b.opened, 0)
+			Logger.Printf("Failed to connect to broker %s: %s\n", b.addr, b.connErr)
+			return
 		}
 
-		if conf.Net.TLS.Enable {
-			b.conn, b.connErr = tls.DialWithDialer(&dialer, "tcp", b.addr, conf.Net.TLS.Config)
-		} else {
-			b.conn, b.connErr = dialer.Dial("tcp", b.addr)
+		if conf.Version.IsAtLeast(V0_10_0_0) {
+			b.connErr = b.requestAPIVersions()
+		}
+		if b.connErr == nil && conf.Net.SASL.Enable {
+			b.connErr = b.authenticateViaSASL()
 		}
 		if b.connErr != nil {
+			if err := b.conn.Close(); err == nil {
+				Logger.Printf("Closed connection to broker %s\n", b.addr)
+			} else {
+				Logger.Printf("Error while closing connection to broker %s: %s\n", b.addr, err)
+			}
 			b.conn = nil
+			b.apiVersions = nil
+			atomic.AddInt32(&b.failures, 1)
+			atomic.StoreInt32(&b.state, int32(BrokerDisconnected))
 			atomic.StoreInt32(&b.opened, 0)
-			Logger.Printf("Failed to connect to broker %s: %s\n", b.addr, b.connErr)
 			return
 		}
 
-		b.conf = conf
 		b.done = make(chan bool)
 		b.responses = make(chan responsePromise, b.conf.Net.MaxOpenRequests-1)
+		b.broken = make(chan error, 1)
+
+		if conns := conf.Net.ConnectionsPerBroker - 1; conns > 0 {
+			pool := make([]*Broker, conns)
+			for i := range pool {
+				pool[i] = &Broker{id: b.id, addr: b.addr, rack: b.rack}
+				_ = pool[i].open(poolConfig(conf)) // connects in the background, see connectionFor for failures
+			}
+			if conns == 1 {
+				b.produceConns, b.fetchConns = pool, pool
+			} else {
+				for i, conn := range pool {
+					if i%2 == 0 {
+						b.produceConns = append(b.produceConns, conn)
+					} else {
+						b.fetchConns = append(b.fetchConns, conn)
+					}
+				}
+			}
+		}
 
 		if b.id >= 0 {
 			Logger.Printf("Connected to broker at %s (registered as #%d)\n", b.addr, b.id)
 		} else {
 			Logger.Printf("Connected to broker at %s (unregistered)\n", b.addr)
 		}
+		atomic.StoreInt32(&b.state, int32(BrokerConnected))
 		go withRecover(b.responseReceiver)
 	})
 
 	return nil
 }
 
+// reconnectBackoff returns how long to wait before connecting, after the failed connections: nothing
+// after none, Net.Reconnect.Backoff after one, and twice as long after each further one, up to
+// Net.Reconnect.MaxBackoff, give or take Net.Reconnect.Jitter.
+func (b *Broker) reconnectBackoff(conf *Config) time.Duration {
+	failures := atomic.LoadInt32(&b.failures)
+	if failures == 0 || conf.Net.Reconnect.Backoff <= 0 {
+		return 0
+	}
+
+	backoff := conf.Net.Reconnect.Backoff
+	for i := int32(1); i < failures && backoff < conf.Net.Reconnect.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	jitter := (2*rand.Float64() - 1) * conf.Net.Reconnect.Jitter
+	backoff += time.Duration(jitter * float64(backoff))
+	if backoff > conf.Net.Reconnect.MaxBackoff {
+		backoff = conf.Net.Reconnect.MaxBackoff
+	}
+	return backoff
+}
+
+// State returns the state of the connection of the broker. Unlike Connected, it does not wait for a
+// connection attempt in progress.
+func (b *Broker) State() BrokerState {
+	return BrokerState(atomic.LoadInt32(&b.state))
+}
+
+// dial connects to addr with Net.Dialer, through Net.Proxy and over TLS if they are enabled, all of
+// it within Net.DialTimeout.
+func dial(conf *Config, addr string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Net.DialTimeout)
+	defer cancel()
+
+	dialContext := conf.Net.Dialer
+	if dialContext == nil {
+		dialer := &net.Dialer{
+			Timeout:   conf.Net.DialTimeout,
+			KeepAlive: conf.Net.KeepAlive,
+		}
+		dialContext = dialer.DialContext
+	}
+
+	target := addr
+	if conf.Net.Proxy.Enable {
+		target = conf.Net.Proxy.Addr
+	}
+	conn, err := dialContext(ctx, "tcp", target)
+	if err != nil {
+		return nil, err
+	}
+	if !conf.Net.Proxy.Enable && !conf.Net.TLS.Enable {
+		return conn, nil
+	}
+
+	handshake := func() (net.Conn, error) {
+		deadline, _ := ctx.Deadline()
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+
+		tunnel := conn
+		if conf.Net.Proxy.Enable {
+			if tunnel, err = connectThroughProxy(conn, conf, addr); err != nil {
+				return nil, err
+			}
+		}
+
+		if conf.Net.TLS.Enable {
+			tlsConfig := conf.Net.TLS.Config
+			if tlsConfig == nil {
+				tlsConfig = &tls.Config{}
+			}
+			if tlsConfig.ServerName == "" {
+				// like tls.Dial, verify the certificate against the host we meant to reach
+				host, _, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				tlsConfig = tlsConfig.Clone()
+				tlsConfig.ServerName = host
+			}
+			tlsConn := tls.Client(tunnel, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return nil, err
+			}
+			tunnel = tlsConn
+		}
+
+		return tunnel, conn.SetDeadline(time.Time{})
+	}
+
+	tunnel, err := handshake()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tunnel, nil
+}
+
 // Connected returns true if the broker is connected and false otherwise. If the broker is not
 // connected but it had tried to connect, the error from that connection attempt is also returned.
 func (b *Broker) Connected() (bool, error) {
@@ -114,10 +355,42 @@ func (b *Broker) Close() error {
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
+	if b.cancelBackoff != nil {
+		close(b.cancelBackoff)
+		b.backoffDone, b.cancelBackoff = nil, nil
+		b.connErr = nil
+		atomic.StoreInt32(&b.state, int32(BrokerDisconnected))
+		atomic.StoreInt32(&b.opened, 0)
+		Logger.Printf("Cancelled reconnecting to broker %s\n", b.addr)
+		return nil
+	}
+
 	if b.conn == nil {
 		return ErrNotConnected
 	}
 
+	return b.closeConnection()
+}
+
+// closeConnection waits for the responseReceiver to fail the pending requests, then closes the
+// connection. The lock must be held.
+func (b *Broker) closeConnection() error {
+	// whatever their state, so that none of them connects once nothing refers to it any more: Close
+	// cancels a connection backing off, and waits for one being dialed
+	for _, conns := range [][]*Broker{b.produceConns, b.fetchConns} {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}
+	b.produceConns = nil
+	b.fetchConns = nil
+
+	select {
+	case <-b.broken:
+		atomic.AddInt32(&b.failures, 1)
+	default:
+	}
+
 	close(b.responses)
 	<-b.done
 
@@ -127,7 +400,10 @@ func (b *Broker) Close() error {
 	b.connErr = nil
 	b.done = nil
 	b.responses = nil
+	b.broken = nil
+	b.apiVersions = nil
 
+	atomic.StoreInt32(&b.state, int32(BrokerDisconnected))
 	atomic.StoreInt32(&b.opened, 0)
 
 	if err == nil {
@@ -149,10 +425,24 @@ func (b *Broker) Addr() string {
 	return b.addr
 }
 
+// Rack returns the rack of the broker as retrieved from Kafka's metadata, or "" if that is not
+// known. Only Kafka 0.10 and later report the racks of their brokers, when `broker.rack` is set.
+func (b *Broker) Rack() string {
+	if b.rack == nil {
+		return ""
+	}
+	return *b.rack
+}
+
 func (b *Broker) GetMetadata(request *MetadataRequest) (*MetadataResponse, error) {
+	return b.GetMetadataContext(context.Background(), request)
+}
+
+// GetMetadataContext is like GetMetadata, but stops waiting for the response once ctx is done.
+func (b *Broker) GetMetadataContext(ctx context.Context, request *MetadataRequest) (*MetadataResponse, error) {
 	response := new(MetadataResponse)
 
-	err := b.sendAndReceive(request, response)
+	err := b.sendAndReceive(ctx, request, response)
 
 	if err != nil {
 		return nil, err
@@ -162,9 +452,31 @@ func (b *Broker) GetMetadata(request *MetadataRequest) (*MetadataResponse, error
 }
 
 func (b *Broker) GetConsumerMetadata(request *ConsumerMetadataRequest) (*ConsumerMetadataResponse, error) {
+	return b.GetConsumerMetadataContext(context.Background(), request)
+}
+
+// GetConsumerMetadataContext is like GetConsumerMetadata, but stops waiting for the response once ctx is done.
+func (b *Broker) GetConsumerMetadataContext(ctx context.Context, request *ConsumerMetadataRequest) (*ConsumerMetadataResponse, error) {
 	response := new(ConsumerMetadataResponse)
 
-	err := b.sendAndReceive(request, response)
+	err := b.sendAndReceive(ctx, request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) FindCoordinator(request *FindCoordinatorRequest) (*FindCoordinatorResponse, error) {
+	return b.FindCoordinatorContext(context.Background(), request)
+}
+
+// FindCoordinatorContext is like FindCoordinator, but stops waiting for the response once ctx is done.
+func (b *Broker) FindCoordinatorContext(ctx context.Context, request *FindCoordinatorRequest) (*FindCoordinatorResponse, error) {
+	response := new(FindCoordinatorResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
 
 	if err != nil {
 		return nil, err
@@ -174,9 +486,14 @@ func (b *Broker) GetConsumerMetadata(request *ConsumerMetadataRequest) (*Consume
 }
 
 func (b *Broker) GetAvailableOffsets(request *OffsetRequest) (*OffsetResponse, error) {
+	return b.GetAvailableOffsetsContext(context.Background(), request)
+}
+
+// GetAvailableOffsetsContext is like GetAvailableOffsets, but stops waiting for the response once ctx is done.
+func (b *Broker) GetAvailableOffsetsContext(ctx context.Context, request *OffsetRequest) (*OffsetResponse, error) {
 	response := new(OffsetResponse)
 
-	err := b.sendAndReceive(request, response)
+	err := b.sendAndReceive(ctx, request, response)
 
 	if err != nil {
 		return nil, err
@@ -186,39 +503,131 @@ func (b *Broker) GetAvailableOffsets(request *OffsetRequest) (*OffsetResponse, e
 }
 
 func (b *Broker) Produce(request *ProduceRequest) (*ProduceResponse, error) {
+	return b.ProduceContext(context.Background(), request)
+}
+
+// ProduceContext is like Produce, but stops waiting for the response once ctx is done.
+func (b *Broker) ProduceContext(ctx context.Context, request *ProduceRequest) (*ProduceResponse, error) {
+	b.lock.Lock()
+	conns := len(b.produceConns)
+	b.lock.Unlock()
+
+	requests := splitProduceRequest(request, conns)
+	if len(requests) == 1 {
+		return b.produce(ctx, request)
+	}
+
+	// the partitions pinned to different connections are sent over all of them at once
+	responses := make([]*ProduceResponse, len(requests))
+	errs := make([]error, len(requests))
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		wg.Add(1)
+		go func(i int, req *ProduceRequest) {
+			defer wg.Done()
+			responses[i], errs[i] = b.produce(ctx, req)
+		}(i, req)
+	}
+	wg.Wait()
+
+	var failed error
+	failures := 0
+	for _, err := range errs {
+		if err != nil {
+			Logger.Printf("Failed to produce to broker %s over one of its connections: %s\n", b.addr, err)
+			failed = err
+			failures++
+		}
+	}
+	if failures == len(requests) {
+		return nil, failed
+	}
+	if failures == 0 && request.RequiredAcks == NoResponse {
+		return nil, nil
+	}
+
+	// the other sub-requests went through, so only the partitions of the failed ones are reported
+	// as failed, with an error the producer retries
+	response := &ProduceResponse{Version: request.Version}
+	for i, res := range responses {
+		switch {
+		case errs[i] != nil:
+			res = produceResponseFor(requests[i], ErrNetworkException)
+		case res == nil:
+			res = produceResponseFor(requests[i], ErrNoError)
+		default:
+			response.Version = res.Version
+		}
+		for topic, blocks := range res.Blocks {
+			for partition, block := range blocks {
+				if response.Blocks == nil {
+					response.Blocks = make(map[string]map[int32]*ProduceResponseBlock)
+				}
+				if response.Blocks[topic] == nil {
+					response.Blocks[topic] = make(map[int32]*ProduceResponseBlock)
+				}
+				response.Blocks[topic][partition] = block
+			}
+		}
+		if res.ThrottleTime > response.ThrottleTime {
+			response.ThrottleTime = res.ThrottleTime
+		}
+	}
+	return response, nil
+}
+
+func (b *Broker) produce(ctx context.Context, request *ProduceRequest) (*ProduceResponse, error) {
 	var response *ProduceResponse
 	var err error
 
 	if request.RequiredAcks == NoResponse {
-		err = b.sendAndReceive(request, nil)
+		err = b.sendAndReceive(ctx, request, nil)
 	} else {
 		response = new(ProduceResponse)
-		err = b.sendAndReceive(request, response)
+		err = b.sendAndReceive(ctx, request, response)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	if response != nil && response.Version >= 1 {
+		b.updateThrottleMetric("produce-throttle-time-in-ms", response.ThrottleTime)
+	}
+
 	return response, nil
 }
 
 func (b *Broker) Fetch(request *FetchRequest) (*FetchResponse, error) {
+	return b.FetchContext(context.Background(), request)
+}
+
+// FetchContext is like Fetch, but stops waiting for the response once ctx is done.
+func (b *Broker) FetchContext(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
 	response := new(FetchResponse)
 
-	err := b.sendAndReceive(request, response)
+	err := b.sendAndReceive(ctx, request, response)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if response.Version >= 1 {
+		b.updateThrottleMetric("fetch-throttle-time-in-ms", response.ThrottleTime)
+	}
+
 	return response, nil
 }
 
 func (b *Broker) CommitOffset(request *OffsetCommitRequest) (*OffsetCommitResponse, error) {
+	return b.CommitOffsetContext(context.Background(), request)
+}
+
+// CommitOffsetContext is like CommitOffset, but stops waiting for the response once ctx is done.
+func (b *Broker) CommitOffsetContext(ctx context.Context, request *OffsetCommitRequest) (*OffsetCommitResponse, error) {
 	response := new(OffsetCommitResponse)
 
-	err := b.sendAndReceive(request, response)
+	err := b.sendAndReceive(ctx, request, response)
 
 	if err != nil {
 		return nil, err
@@ -228,9 +637,14 @@ func (b *Broker) CommitOffset(request *OffsetCommitRequest) (*OffsetCommitRespon
 }
 
 func (b *Broker) FetchOffset(request *OffsetFetchRequest) (*OffsetFetchResponse, error) {
+	return b.FetchOffsetContext(context.Background(), request)
+}
+
+// FetchOffsetContext is like FetchOffset, but stops waiting for the response once ctx is done.
+func (b *Broker) FetchOffsetContext(ctx context.Context, request *OffsetFetchRequest) (*OffsetFetchResponse, error) {
 	response := new(OffsetFetchResponse)
 
-	err := b.sendAndReceive(request, response)
+	err := b.sendAndReceive(ctx, request, response)
 
 	if err != nil {
 		return nil, err
@@ -239,150 +653,937 @@ func (b *Broker) FetchOffset(request *OffsetFetchRequest) (*OffsetFetchResponse,
 	return response, nil
 }
 
-func (b *Broker) send(rb requestBody, promiseResponse bool) (*responsePromise, error) {
-	b.lock.Lock()
-	defer b.lock.Unlock()
+func (b *Broker) JoinGroup(request *JoinGroupRequest) (*JoinGroupResponse, error) {
+	return b.JoinGroupContext(context.Background(), request)
+}
 
-	if b.conn == nil {
-		if b.connErr != nil {
-			return nil, b.connErr
-		}
-		return nil, ErrNotConnected
-	}
+// JoinGroupContext is like JoinGroup, but stops waiting for the response once ctx is done.
+func (b *Broker) JoinGroupContext(ctx context.Context, request *JoinGroupRequest) (*JoinGroupResponse, error) {
+	response := new(JoinGroupResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
 
-	req := &request{correlationID: b.correlationID, clientID: b.conf.ClientID, body: rb}
-	buf, err := encode(req)
 	if err != nil {
 		return nil, err
 	}
 
-	err = b.conn.SetWriteDeadline(time.Now().Add(b.conf.Net.WriteTimeout))
+	return response, nil
+}
+
+func (b *Broker) SyncGroup(request *SyncGroupRequest) (*SyncGroupResponse, error) {
+	return b.SyncGroupContext(context.Background(), request)
+}
+
+// SyncGroupContext is like SyncGroup, but stops waiting for the response once ctx is done.
+func (b *Broker) SyncGroupContext(ctx context.Context, request *SyncGroupRequest) (*SyncGroupResponse, error) {
+	response := new(SyncGroupResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = b.conn.Write(buf)
+	return response, nil
+}
+
+func (b *Broker) LeaveGroup(request *LeaveGroupRequest) (*LeaveGroupResponse, error) {
+	return b.LeaveGroupContext(context.Background(), request)
+}
+
+// LeaveGroupContext is like LeaveGroup, but stops waiting for the response once ctx is done.
+func (b *Broker) LeaveGroupContext(ctx context.Context, request *LeaveGroupRequest) (*LeaveGroupResponse, error) {
+	response := new(LeaveGroupResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
 	if err != nil {
 		return nil, err
 	}
-	b.correlationID++
 
-	if !promiseResponse {
-		return nil, nil
+	return response, nil
+}
+
+func (b *Broker) Heartbeat(request *HeartbeatRequest) (*HeartbeatResponse, error) {
+	return b.HeartbeatContext(context.Background(), request)
+}
+
+// HeartbeatContext is like Heartbeat, but stops waiting for the response once ctx is done.
+func (b *Broker) HeartbeatContext(ctx context.Context, request *HeartbeatRequest) (*HeartbeatResponse, error) {
+	response := new(HeartbeatResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
+	if err != nil {
+		return nil, err
 	}
 
-	promise := responsePromise{req.correlationID, make(chan []byte), make(chan error)}
-	b.responses <- promise
+	return response, nil
+}
 
-	return &promise, nil
+func (b *Broker) ListGroups(request *ListGroupsRequest) (*ListGroupsResponse, error) {
+	return b.ListGroupsContext(context.Background(), request)
 }
 
-func (b *Broker) sendAndReceive(req requestBody, res decoder) error {
-	promise, err := b.send(req, res != nil)
+// ListGroupsContext is like ListGroups, but stops waiting for the response once ctx is done.
+func (b *Broker) ListGroupsContext(ctx context.Context, request *ListGroupsRequest) (*ListGroupsResponse, error) {
+	response := new(ListGroupsResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if promise == nil {
-		return nil
-	}
+	return response, nil
+}
 
-	select {
-	case buf := <-promise.packets:
-		return decode(buf, res)
-	case err = <-promise.errors:
-		return err
-	}
+func (b *Broker) DescribeGroups(request *DescribeGroupsRequest) (*DescribeGroupsResponse, error) {
+	return b.DescribeGroupsContext(context.Background(), request)
 }
 
-func (b *Broker) decode(pd packetDecoder) (err error) {
-	b.id, err = pd.getInt32()
+// DescribeGroupsContext is like DescribeGroups, but stops waiting for the response once ctx is done.
+func (b *Broker) DescribeGroupsContext(ctx context.Context, request *DescribeGroupsRequest) (*DescribeGroupsResponse, error) {
+	response := new(DescribeGroupsResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	host, err := pd.getString()
+	return response, nil
+}
+
+func (b *Broker) CreateTopics(request *CreateTopicsRequest) (*CreateTopicsResponse, error) {
+	return b.CreateTopicsContext(context.Background(), request)
+}
+
+// CreateTopicsContext is like CreateTopics, but stops waiting for the response once ctx is done.
+func (b *Broker) CreateTopicsContext(ctx context.Context, request *CreateTopicsRequest) (*CreateTopicsResponse, error) {
+	response := new(CreateTopicsResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	port, err := pd.getInt32()
+	return response, nil
+}
+
+func (b *Broker) DeleteTopics(request *DeleteTopicsRequest) (*DeleteTopicsResponse, error) {
+	return b.DeleteTopicsContext(context.Background(), request)
+}
+
+// DeleteTopicsContext is like DeleteTopics, but stops waiting for the response once ctx is done.
+func (b *Broker) DeleteTopicsContext(ctx context.Context, request *DeleteTopicsRequest) (*DeleteTopicsResponse, error) {
+	response := new(DeleteTopicsResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	b.addr = net.JoinHostPort(host, fmt.Sprint(port))
-	if _, _, err := net.SplitHostPort(b.addr); err != nil {
-		return err
-	}
+	return response, nil
+}
 
-	return nil
+func (b *Broker) InitProducerID(request *InitProducerIDRequest) (*InitProducerIDResponse, error) {
+	return b.InitProducerIDContext(context.Background(), request)
 }
 
-func (b *Broker) encode(pe packetEncoder) (err error) {
+// InitProducerIDContext is like InitProducerID, but stops waiting for the response once ctx is done.
+func (b *Broker) InitProducerIDContext(ctx context.Context, request *InitProducerIDRequest) (*InitProducerIDResponse, error) {
+	response := new(InitProducerIDResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
 
-	host, portstr, err := net.SplitHostPort(b.addr)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	port, err := strconv.Atoi(portstr)
+
+	return response, nil
+}
+
+func (b *Broker) AddPartitionsToTxn(request *AddPartitionsToTxnRequest) (*AddPartitionsToTxnResponse, error) {
+	return b.AddPartitionsToTxnContext(context.Background(), request)
+}
+
+// AddPartitionsToTxnContext is like AddPartitionsToTxn, but stops waiting for the response once ctx is done.
+func (b *Broker) AddPartitionsToTxnContext(ctx context.Context, request *AddPartitionsToTxnRequest) (*AddPartitionsToTxnResponse, error) {
+	response := new(AddPartitionsToTxnResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	pe.putInt32(b.id)
+	return response, nil
+}
+
+func (b *Broker) AddOffsetsToTxn(request *AddOffsetsToTxnRequest) (*AddOffsetsToTxnResponse, error) {
+	return b.AddOffsetsToTxnContext(context.Background(), request)
+}
+
+// AddOffsetsToTxnContext is like AddOffsetsToTxn, but stops waiting for the response once ctx is done.
+func (b *Broker) AddOffsetsToTxnContext(ctx context.Context, request *AddOffsetsToTxnRequest) (*AddOffsetsToTxnResponse, error) {
+	response := new(AddOffsetsToTxnResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
 
-	err = pe.putString(host)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	pe.putInt32(int32(port))
+	return response, nil
+}
 
-	return nil
+func (b *Broker) EndTxn(request *EndTxnRequest) (*EndTxnResponse, error) {
+	return b.EndTxnContext(context.Background(), request)
 }
 
-func (b *Broker) responseReceiver() {
-	header := make([]byte, 8)
-	for response := range b.responses {
-		err := b.conn.SetReadDeadline(time.Now().Add(b.conf.Net.ReadTimeout))
-		if err != nil {
-			response.errors <- err
-			continue
-		}
+// EndTxnContext is like EndTxn, but stops waiting for the response once ctx is done.
+func (b *Broker) EndTxnContext(ctx context.Context, request *EndTxnRequest) (*EndTxnResponse, error) {
+	response := new(EndTxnResponse)
 
-		_, err = io.ReadFull(b.conn, header)
-		if err != nil {
-			response.errors <- err
+	err := b.sendAndReceive(ctx, request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) TxnOffsetCommit(request *TxnOffsetCommitRequest) (*TxnOffsetCommitResponse, error) {
+	return b.TxnOffsetCommitContext(context.Background(), request)
+}
+
+// TxnOffsetCommitContext is like TxnOffsetCommit, but stops waiting for the response once ctx is done.
+func (b *Broker) TxnOffsetCommitContext(ctx context.Context, request *TxnOffsetCommitRequest) (*TxnOffsetCommitResponse, error) {
+	response := new(TxnOffsetCommitResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) CreatePartitions(request *CreatePartitionsRequest) (*CreatePartitionsResponse, error) {
+	return b.CreatePartitionsContext(context.Background(), request)
+}
+
+// CreatePartitionsContext is like CreatePartitions, but stops waiting for the response once ctx is done.
+func (b *Broker) CreatePartitionsContext(ctx context.Context, request *CreatePartitionsRequest) (*CreatePartitionsResponse, error) {
+	response := new(CreatePartitionsResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) DeleteRecords(request *DeleteRecordsRequest) (*DeleteRecordsResponse, error) {
+	return b.DeleteRecordsContext(context.Background(), request)
+}
+
+// DeleteRecordsContext is like DeleteRecords, but stops waiting for the response once ctx is done.
+func (b *Broker) DeleteRecordsContext(ctx context.Context, request *DeleteRecordsRequest) (*DeleteRecordsResponse, error) {
+	response := new(DeleteRecordsResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) DescribeConfigs(request *DescribeConfigsRequest) (*DescribeConfigsResponse, error) {
+	return b.DescribeConfigsContext(context.Background(), request)
+}
+
+// DescribeConfigsContext is like DescribeConfigs, but stops waiting for the response once ctx is done.
+func (b *Broker) DescribeConfigsContext(ctx context.Context, request *DescribeConfigsRequest) (*DescribeConfigsResponse, error) {
+	response := new(DescribeConfigsResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) AlterConfigs(request *AlterConfigsRequest) (*AlterConfigsResponse, error) {
+	return b.AlterConfigsContext(context.Background(), request)
+}
+
+// AlterConfigsContext is like AlterConfigs, but stops waiting for the response once ctx is done.
+func (b *Broker) AlterConfigsContext(ctx context.Context, request *AlterConfigsRequest) (*AlterConfigsResponse, error) {
+	response := new(AlterConfigsResponse)
+
+	err := b.sendAndReceive(ctx, request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) send(rb requestBody, promiseResponse bool) (*responsePromise, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.conn == nil {
+		if b.connErr != nil {
+			return nil, b.connErr
+		}
+		return nil, ErrNotConnected
+	}
+
+	if err := b.closeIfBroken(); err != nil {
+		return nil, err
+	}
+
+	if vr, ok := rb.(versionedRequest); ok {
+		// the negotiated version only holds for this broker, see versionedRequest
+		rb = vr.shallowCopy()
+	}
+	if err := b.negotiateVersion(rb); err != nil {
+		return nil, err
+	}
+
+	req := &request{correlationID: b.correlationID, clientID: b.conf.ClientID, body: rb}
+	buf, err := encode(req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.conn.SetWriteDeadline(time.Now().Add(b.conf.Net.WriteTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = b.conn.Write(buf)
+	if err != nil {
+		// part of the request may have been written, so the connection cannot be trusted anymore
+		b.breakConnection(err)
+		return nil, err
+	}
+	b.correlationID++
+
+	if !promiseResponse {
+		return nil, nil
+	}
+
+	// buffered so that the responseReceiver never waits for a promise which was abandoned
+	promise := responsePromise{req.correlationID, rb.version(), make(chan []byte, 1), make(chan error, 1)}
+	b.responses <- promise
+
+	return &promise, nil
+}
+
+// closeIfBroken closes the connection if it broke, so that it is reopened, see reopenIfFailed, and
+// returns the error which broke it. The lock must be held.
+func (b *Broker) closeIfBroken() error {
+	select {
+	case err := <-b.broken:
+		Logger.Printf("Closing broken connection to broker %s: %s\n", b.addr, err)
+		atomic.AddInt32(&b.failures, 1)
+		_ = b.closeConnection()
+		b.connErr = err
+		return err
+	default:
+		return nil
+	}
+}
+
+// reopenIfFailed opens the broker again if its connection failed or broke, unlike if it was closed, and
+// waits for it to be done backing off, see waitBackoff.
+func (b *Broker) reopenIfFailed(ctx context.Context) error {
+	b.lock.Lock()
+	if b.conn != nil {
+		_ = b.closeIfBroken()
+	}
+	failed := b.conn == nil && b.connErr != nil && b.conf != nil
+	conf := b.conf
+	b.lock.Unlock()
+
+	if failed {
+		_ = b.open(conf)
+	}
+	return b.waitBackoff(ctx)
+}
+
+// waitBackoff waits until the broker is done backing off from reconnecting, see Net.Reconnect, unless
+// ctx is done first, in which case it fails with the error which made the broker reconnect.
+func (b *Broker) waitBackoff(ctx context.Context) error {
+	b.lock.Lock()
+	backoffDone, connErr := b.backoffDone, b.connErr
+	b.lock.Unlock()
+
+	if backoffDone == nil {
+		return nil
+	}
+	select {
+	case <-backoffDone:
+		return nil
+	case <-ctx.Done():
+		if connErr != nil {
+			return connErr
+		}
+		return ctx.Err()
+	}
+}
+
+// breakConnection reports an error which broke the connection, which is reopened by the next request.
+func (b *Broker) breakConnection(err error) {
+	select {
+	case b.broken <- err:
+	default: // the connection was already broken
+	}
+}
+
+// connectionFor returns the Broker whose connection is to carry the request, when Net.ConnectionsPerBroker
+// provides connections for produce and fetch requests; all the other requests go over the control
+// connection of b itself. Each partition is pinned to one of the produce connections, so that its records
+// are written in order, see splitProduceRequest, while fetch requests, which name the offsets they start
+// from, are spread over the fetch connections in turn. A connection which failed is reopened.
+func (b *Broker) connectionFor(rb requestBody) (*Broker, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var conn *Broker
+	switch req := rb.(type) {
+	case *ProduceRequest:
+		if len(b.produceConns) > 0 {
+			conn = b.produceConns[produceRequestConn(req, len(b.produceConns))]
+		}
+	case *FetchRequest:
+		if len(b.fetchConns) > 0 {
+			conn = b.fetchConns[atomic.AddUint32(&b.nextConn, 1)%uint32(len(b.fetchConns))]
+		}
+	}
+	if conn == nil || b.conn == nil {
+		return b, nil
+	}
+
+	if conn.State() == BrokerDisconnected {
+		if err := conn.open(poolConfig(b.conf)); err != nil && err != ErrAlreadyConnected {
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// poolConfig returns the configuration of the connections of a pool, which have no pool of their own.
+func poolConfig(conf *Config) *Config {
+	poolConf := *conf
+	poolConf.Net.ConnectionsPerBroker = 1
+	return &poolConf
+}
+
+// pinnedConn returns which of n connections carries the records of a partition.
+func pinnedConn(topic string, partition int32, n int) int {
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(topic))
+	return int((hasher.Sum32() + uint32(partition)) % uint32(n))
+}
+
+// produceRequestConn returns which of n connections carries the request, whose partitions are all pinned
+// to the same one once it went through splitProduceRequest.
+func produceRequestConn(req *ProduceRequest, n int) int {
+	for topic, partitions := range req.msgSets {
+		for partition := range partitions {
+			return pinnedConn(topic, partition, n)
+		}
+	}
+	for topic, partitions := range req.recordBatches {
+		for partition := range partitions {
+			return pinnedConn(topic, partition, n)
+		}
+	}
+	return 0
+}
+
+// splitProduceRequest splits the request into one request for each of the n connections its partitions
+// are pinned to, see pinnedConn.
+func splitProduceRequest(req *ProduceRequest, n int) []*ProduceRequest {
+	if n <= 1 {
+		return []*ProduceRequest{req}
+	}
+
+	split := make(map[int]*ProduceRequest)
+	requestFor := func(topic string, partition int32) *ProduceRequest {
+		conn := pinnedConn(topic, partition, n)
+		if split[conn] == nil {
+			split[conn] = &ProduceRequest{
+				TransactionalID: req.TransactionalID,
+				RequiredAcks:    req.RequiredAcks,
+				Timeout:         req.Timeout,
+				Version:         req.Version,
+			}
+		}
+		return split[conn]
+	}
+	for topic, partitions := range req.msgSets {
+		for partition, set := range partitions {
+			requestFor(topic, partition).AddSet(topic, partition, set)
+		}
+	}
+	for topic, partitions := range req.recordBatches {
+		for partition, batch := range partitions {
+			requestFor(topic, partition).AddBatch(topic, partition, batch)
+		}
+	}
+	if len(split) <= 1 {
+		return []*ProduceRequest{req}
+	}
+
+	requests := make([]*ProduceRequest, 0, len(split))
+	for _, request := range split {
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+// produceResponseFor builds the response of a sub-request that has none of its own, with the same
+// error on the block of every partition in it.
+func produceResponseFor(req *ProduceRequest, err KError) *ProduceResponse {
+	response := &ProduceResponse{Version: req.Version}
+	for topic, partitions := range req.msgSets {
+		for partition := range partitions {
+			response.AddTopicPartition(topic, partition, err)
+		}
+	}
+	for topic, partitions := range req.recordBatches {
+		for partition := range partitions {
+			response.AddTopicPartition(topic, partition, err)
+		}
+	}
+	return response
+}
+
+// negotiateVersion raises versioned requests to the highest version supported by both Config.Version
+// and the broker, then makes sure the request can be sent at all.
+func (b *Broker) negotiateVersion(rb requestBody) error {
+	if vr, ok := rb.(versionedRequest); ok {
+		lowest := vr.version()
+		for v := vr.maxVersion(); v > lowest; v-- {
+			vr.setVersion(v)
+			if b.conf.Version.IsAtLeast(vr.requiredVersion()) && b.supportsVersion(vr.key(), v) {
+				return nil
+			}
+		}
+		vr.setVersion(lowest)
+	}
+
+	if !b.conf.Version.IsAtLeast(rb.requiredVersion()) {
+		return ConfigurationError(fmt.Sprintf("%T version %d requires Version >= %s, but it is set to %s",
+			rb, rb.version(), rb.requiredVersion(), b.conf.Version))
+	}
+	if !b.supportsVersion(rb.key(), rb.version()) {
+		Logger.Printf("Broker %s does not support version %d of API key %d\n", b.addr, rb.version(), rb.key())
+		return ErrUnsupportedVersion
+	}
+	return nil
+}
+
+// supportsVersion tells whether the broker advertised the given version of an API; brokers that
+// were not asked are assumed to support everything allowed by Config.Version.
+func (b *Broker) supportsVersion(key, version int16) bool {
+	if b.apiVersions == nil {
+		return true
+	}
+
+	block, ok := b.apiVersions[key]
+	return ok && block.MinVersion <= version && version <= block.MaxVersion
+}
+
+// sendAndReceive sends the request and waits for its response, unless ctx is done first. A request
+// which is abandoned that way is not taken back, and the responseReceiver still reads its response off
+// the connection, which therefore remains usable for the next requests.
+func (b *Broker) sendAndReceive(ctx context.Context, req requestBody, res decoder) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := b.reopenIfFailed(ctx); err != nil {
+		return err
+	}
+
+	conn, err := b.connectionFor(req)
+	if err != nil {
+		return err
+	}
+	if conn != b {
+		if err := conn.reopenIfFailed(ctx); err != nil {
+			return err
+		}
+	}
+	promise, err := conn.send(req, res != nil)
+
+	if err != nil {
+		return err
+	}
+
+	if promise == nil {
+		return nil
+	}
+
+	if vr, ok := res.(versionedResponse); ok {
+		vr.setVersion(promise.version)
+	}
+
+	select {
+	case buf := <-promise.packets:
+		atomic.StoreInt32(&conn.failures, 0)
+		return decode(buf, res)
+	case err = <-promise.errors:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// updateThrottleMetric records the time a response says its request was throttled for, both
+// for the whole client and for this broker.
+func (b *Broker) updateThrottleMetric(name string, throttleTime time.Duration) {
+	millis := int64(throttleTime / time.Millisecond)
+	getOrRegisterHistogram(name, b.conf.MetricRegistry).Update(millis)
+	getOrRegisterHistogram(getMetricNameForBroker(name, b), b.conf.MetricRegistry).Update(millis)
+}
+
+func (b *Broker) decode(pd packetDecoder) (err error) {
+	b.id, err = pd.getInt32()
+	if err != nil {
+		return err
+	}
+
+	host, err := pd.getString()
+	if err != nil {
+		return err
+	}
+
+	port, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+
+	b.addr = net.JoinHostPort(host, fmt.Sprint(port))
+	if _, _, err := net.SplitHostPort(b.addr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (b *Broker) encode(pe packetEncoder) (err error) {
+
+	host, portstr, err := net.SplitHostPort(b.addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portstr)
+	if err != nil {
+		return err
+	}
+
+	pe.putInt32(b.id)
+
+	err = pe.putString(host)
+	if err != nil {
+		return err
+	}
+
+	pe.putInt32(int32(port))
+
+	return nil
+}
+
+// authenticateViaSASL runs the SASL exchange configured in Net.SASL on a freshly dialed connection,
+// before the responseReceiver is started; requests and responses are therefore written and read
+// directly on the connection.
+func (b *Broker) authenticateViaSASL() error {
+	if b.conf.Net.SASL.Handshake {
+		if err := b.sendAndReceiveSASLHandshake(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	switch b.conf.Net.SASL.Mechanism {
+	case SASLTypeSCRAMSHA256:
+		err = b.sendAndReceiveSASLSCRAM(sha256.New)
+	case SASLTypeSCRAMSHA512:
+		err = b.sendAndReceiveSASLSCRAM(sha512.New)
+	default:
+		err = b.sendAndReceiveSASLPlain()
+	}
+	if err != nil {
+		Logger.Printf("SASL authentication with broker %s failed: %s\n", b.addr, err)
+		return err
+	}
+
+	Logger.Printf("SASL authentication with broker %s succeeded\n", b.addr)
+	return nil
+}
+
+// requestAPIVersions asks a freshly connected broker which versions of each API it supports.
+func (b *Broker) requestAPIVersions() error {
+	res := &ApiVersionsResponse{}
+	if err := b.sendAndReceiveDirect(&ApiVersionsRequest{}, res); err != nil {
+		return err
+	}
+	if res.Err != ErrNoError {
+		return res.Err
+	}
+
+	b.apiVersions = make(map[int16]*ApiVersionsResponseBlock, len(res.ApiVersions))
+	for _, block := range res.ApiVersions {
+		b.apiVersions[block.ApiKey] = block
+	}
+	return nil
+}
+
+func (b *Broker) sendAndReceiveSASLHandshake() error {
+	rb := &SaslHandshakeRequest{Mechanism: string(b.conf.Net.SASL.Mechanism)}
+	if !b.supportsVersion(rb.key(), rb.version()) {
+		return ErrUnsupportedVersion
+	}
+
+	res := &SaslHandshakeResponse{}
+	if err := b.sendAndReceiveDirect(rb, res); err != nil {
+		return err
+	}
+	if res.Err != ErrNoError {
+		Logger.Printf("SASL mechanism %s rejected by broker %s (enabled mechanisms: %v)\n", rb.Mechanism, b.addr, res.EnabledMechanisms)
+		return res.Err
+	}
+
+	return nil
+}
+
+// sendAndReceiveDirect writes a request and reads its response directly on the connection; it is
+// used while setting up the connection, before the responseReceiver is started.
+func (b *Broker) sendAndReceiveDirect(rb requestBody, res decoder) error {
+	req := &request{correlationID: b.correlationID, clientID: b.conf.ClientID, body: rb}
+	buf, err := encode(req)
+	if err != nil {
+		return err
+	}
+
+	if err = b.conn.SetWriteDeadline(time.Now().Add(b.conf.Net.WriteTimeout)); err != nil {
+		return err
+	}
+	if _, err = b.conn.Write(buf); err != nil {
+		return err
+	}
+	b.correlationID++
+
+	if err = b.conn.SetReadDeadline(time.Now().Add(b.conf.Net.ReadTimeout)); err != nil {
+		return err
+	}
+	header := make([]byte, 8)
+	if _, err = io.ReadFull(b.conn, header); err != nil {
+		return err
+	}
+
+	decodedHeader := responseHeader{}
+	if err = decode(header, &decodedHeader); err != nil {
+		return err
+	}
+	if decodedHeader.correlationID != req.correlationID {
+		return PacketDecodingError{fmt.Sprintf("correlation ID didn't match, wanted %d, got %d", req.correlationID, decodedHeader.correlationID)}
+	}
+
+	payload := make([]byte, decodedHeader.length-4)
+	if _, err = io.ReadFull(b.conn, payload); err != nil {
+		return err
+	}
+
+	return decode(payload, res)
+}
+
+// sendAndReceiveSASLToken sends a raw, length-prefixed SASL token and returns the broker's answer. The
+// broker closes the connection instead of answering when it rejects the credentials.
+func (b *Broker) sendAndReceiveSASLToken(token []byte) ([]byte, error) {
+	buf := make([]byte, 4+len(token))
+	binary.BigEndian.PutUint32(buf, uint32(len(token)))
+	copy(buf[4:], token)
+
+	if err := b.conn.SetWriteDeadline(time.Now().Add(b.conf.Net.WriteTimeout)); err != nil {
+		return nil, err
+	}
+	if _, err := b.conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	if err := b.conn.SetReadDeadline(time.Now().Add(b.conf.Net.ReadTimeout)); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(b.conn, header); err != nil {
+		if err == io.EOF {
+			return nil, ErrSASLAuthenticationFailed
+		}
+		return nil, err
+	}
+
+	length := int32(binary.BigEndian.Uint32(header))
+	if length < 0 || length > MaxResponseSize {
+		return nil, PacketDecodingError{fmt.Sprintf("SASL token of length %d too large or too small", length)}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(b.conn, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrSASLAuthenticationFailed
+		}
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+func (b *Broker) sendAndReceiveSASLPlain() error {
+	// the authorization identity (left empty), the user and the password, separated by NUL bytes; see RFC 4616
+	token := []byte("\x00" + b.conf.Net.SASL.User + "\x00" + b.conf.Net.SASL.Password)
+	_, err := b.sendAndReceiveSASLToken(token)
+	return err
+}
+
+func (b *Broker) sendAndReceiveSASLSCRAM(hashGen func() hash.Hash) error {
+	client, err := newSCRAMClient(hashGen, b.conf.Net.SASL.User, b.conf.Net.SASL.Password)
+	if err != nil {
+		return err
+	}
+
+	serverFirst, err := b.sendAndReceiveSASLToken(client.firstMessage())
+	if err != nil {
+		return err
+	}
+
+	clientFinal, err := client.finalMessage(serverFirst)
+	if err != nil {
+		return err
+	}
+
+	serverFinal, err := b.sendAndReceiveSASLToken(clientFinal)
+	if err != nil {
+		return err
+	}
+
+	return client.verifyServer(serverFinal)
+}
+
+// responseReceiver hands the responses read off the connection to the promises of the requests they
+// answer, matching them by correlation ID. Kafka answers the requests of a connection in order, so a
+// response to an earlier request is a late one to a request which already failed, typically by timing
+// out, and is discarded; a response to a later request means the broker skipped the current one, and is
+// kept for the promise it answers. Failing in the middle of a response, or reading something which is not
+// one, leaves the stream misaligned: the connection is then poisoned, every pending request fails with
+// ErrConnectionPoisoned, and the next request reopens the connection. So does it after any other failure to
+// read but a timeout, which leaves the stream in line.
+func (b *Broker) responseReceiver() {
+	var poisoned bool
+	poison := func(err error) {
+		Logger.Printf("Connection to broker %s is poisoned: %s\n", b.addr, err)
+		poisoned = true
+		b.breakConnection(ErrConnectionPoisoned)
+	}
+
+	var lastRead int32 // the correlation ID of the last response read, if any was
+	var read bool
+	early := make(map[int32][]byte)
+	header := make([]byte, 8)
+
+	for response := range b.responses {
+		if poisoned {
+			response.errors <- ErrConnectionPoisoned
 			continue
 		}
 
-		decodedHeader := responseHeader{}
-		err = decode(header, &decodedHeader)
-		if err != nil {
-			response.errors <- err
+		if buf, ok := early[response.correlationID]; ok {
+			delete(early, response.correlationID)
+			response.packets <- buf
 			continue
 		}
-		if decodedHeader.correlationID != response.correlationID {
-			// TODO if decoded ID < cur ID, discard until we catch up
-			// TODO if decoded ID > cur ID, save it so when cur ID catches up we have a response
-			response.errors <- PacketDecodingError{fmt.Sprintf("correlation ID didn't match, wanted %d, got %d", response.correlationID, decodedHeader.correlationID)}
+		// correlation IDs wrap around, so they are compared by their difference
+		if read && response.correlationID-lastRead <= 0 {
+			response.errors <- PacketDecodingError{fmt.Sprintf("broker skipped the response to correlation ID %d", response.correlationID)}
 			continue
 		}
 
-		buf := make([]byte, decodedHeader.length-4)
-		_, err = io.ReadFull(b.conn, buf)
+		err := b.conn.SetReadDeadline(time.Now().Add(b.conf.Net.ReadTimeout))
 		if err != nil {
-			// XXX: the above ReadFull call inherits the same ReadDeadline set at the top of this loop, so it may
-			// fail with a timeout error. If this happens, our connection is permanently toast since we will no longer
-			// be aligned correctly on the stream (we'll be reading garbage Kafka headers from the middle of data).
-			// Can we/should we fail harder in that case?
 			response.errors <- err
 			continue
 		}
 
-		response.packets <- buf
+		for {
+			correlationID, buf, desync, err := b.readResponse(header)
+			if err != nil {
+				if desync {
+					poison(err)
+				} else if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+					b.breakConnection(err)
+				}
+				response.errors <- err
+				break
+			}
+			lastRead, read = correlationID, true
+
+			if correlationID == response.correlationID {
+				response.packets <- buf
+				break
+			}
+			if correlationID-response.correlationID < 0 {
+				Logger.Printf("Discarding late response from broker %s to correlation ID %d\n", b.addr, correlationID)
+				continue
+			}
+
+			err = PacketDecodingError{fmt.Sprintf("correlation ID didn't match, wanted %d, got %d", response.correlationID, correlationID)}
+			early[correlationID] = buf
+			if len(early) > b.conf.Net.MaxOpenRequests {
+				// there are more responses than requests in flight, they cannot be trusted
+				poison(err)
+			}
+			response.errors <- err
+			break
+		}
 	}
 	close(b.done)
 }
+
+// readResponse reads the next response off the connection. The connection is desynchronized if it
+// fails after reading part of the response, or if the header makes no sense.
+func (b *Broker) readResponse(header []byte) (correlationID int32, buf []byte, desync bool, err error) {
+	n, err := io.ReadFull(b.conn, header)
+	if err != nil {
+		return 0, nil, n > 0, err
+	}
+
+	decodedHeader := responseHeader{}
+	if err = decode(header, &decodedHeader); err != nil {
+		return 0, nil, true, err
+	}
+
+	buf = make([]byte, decodedHeader.length-4)
+	if _, err = io.ReadFull(b.conn, buf); err != nil {
+		return 0, nil, true, err
+	}
+	return decodedHeader.correlationID, buf, false, nil
+}
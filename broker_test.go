@@ -1,8 +1,19 @@
 package sarama
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
 	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func ExampleBroker() {
@@ -56,7 +67,9 @@ func TestSimpleBrokerCommunication(t *testing.T) {
 	defer mb.Close()
 
 	broker := NewBroker(mb.Addr())
-	err := broker.Open(nil)
+	conf := NewConfig()
+	conf.Version = V0_10_0_0
+	err := broker.Open(conf)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -79,7 +92,7 @@ var brokerTestTable = []struct {
 	response []byte
 	runner   func(*testing.T, *Broker)
 }{
-	{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{[]byte{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00},
 		func(t *testing.T, broker *Broker) {
 			request := MetadataRequest{}
 			response, err := broker.GetMetadata(&request)
@@ -116,7 +129,7 @@ var brokerTestTable = []struct {
 			}
 		}},
 
-	{[]byte{0x00, 0x00, 0x00, 0x00},
+	{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
 		func(t *testing.T, broker *Broker) {
 			request := ProduceRequest{}
 			request.RequiredAcks = WaitForLocal
@@ -129,7 +142,7 @@ var brokerTestTable = []struct {
 			}
 		}},
 
-	{[]byte{0x00, 0x00, 0x00, 0x00},
+	{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
 		func(t *testing.T, broker *Broker) {
 			request := FetchRequest{}
 			response, err := broker.Fetch(&request)
@@ -176,4 +189,916 @@ var brokerTestTable = []struct {
 				t.Error("Offset request got no response!")
 			}
 		}},
+
+	{[]byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := JoinGroupRequest{}
+			response, err := broker.JoinGroup(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("JoinGroup request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := SyncGroupRequest{}
+			response, err := broker.SyncGroup(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("SyncGroup request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := LeaveGroupRequest{}
+			response, err := broker.LeaveGroup(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("LeaveGroup request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := HeartbeatRequest{}
+			response, err := broker.Heartbeat(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("Heartbeat request got no response!")
+			}
+		}},
+}
+
+func newSASLTestConfig(mechanism SASLMechanism, password string) *Config {
+	conf := NewConfig()
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.Mechanism = mechanism
+	conf.Net.SASL.User = "user"
+	conf.Net.SASL.Password = password
+	conf.Version = V0_10_2_0
+	return conf
+}
+
+func TestBrokerSASLPlain(t *testing.T) {
+	testTable := []struct {
+		name      string
+		password  string
+		handshake KError
+		expected  error
+	}{
+		{"authenticated", "secret", ErrNoError, nil},
+		{"rejected credentials", "wrong", ErrNoError, ErrSASLAuthenticationFailed},
+		{"unsupported mechanism", "secret", ErrUnsupportedSASLMechanism, ErrUnsupportedSASLMechanism},
+	}
+
+	for _, tt := range testTable {
+		mb := newMockBroker(t, 0)
+		mb.SetHandlerByMap(map[string]MockResponse{
+			"SaslHandshakeRequest": newMockWrapper(&SaslHandshakeResponse{Err: tt.handshake, EnabledMechanisms: []string{"PLAIN"}}),
+			"MetadataRequest":      newMockMetadataResponse(t),
+		})
+		mb.SetSASLHandler(func(token []byte) ([]byte, bool, error) {
+			if string(token) != "\x00user\x00secret" {
+				return nil, false, fmt.Errorf("invalid credentials %q", token)
+			}
+			return nil, true, nil
+		})
+
+		broker := NewBroker(mb.Addr())
+		if err := broker.Open(newSASLTestConfig(SASLTypePlaintext, tt.password)); err != nil {
+			t.Fatal(err)
+		}
+
+		connected, err := broker.Connected()
+		if err != tt.expected {
+			t.Errorf("%s: expected %v from Connected(), got %v", tt.name, tt.expected, err)
+		}
+		if connected != (tt.expected == nil) {
+			t.Errorf("%s: unexpected connection state %v", tt.name, connected)
+		}
+
+		if connected {
+			// the request stream continues normally after the SASL exchange
+			if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			safeClose(t, broker)
+		}
+		mb.Close()
+	}
+}
+
+// scramTestServer scripts the server side of a SCRAM exchange for the given password.
+func scramTestServer(t *testing.T, mechanism SASLMechanism, password string) saslHandlerFunc {
+	hashGen := sha256.New
+	if mechanism == SASLTypeSCRAMSHA512 {
+		hashGen = sha512.New
+	}
+	keys := &scramClient{hashGen: hashGen, password: password}
+	salt := []byte("salt")
+	saltedPassword := keys.saltPassword(salt, 4096)
+	storedKey := keys.hash(keys.hmac(saltedPassword, []byte("Client Key")))
+	serverKey := keys.hmac(saltedPassword, []byte("Server Key"))
+
+	var clientFirstBare, serverFirst string
+	return func(token []byte) ([]byte, bool, error) {
+		if clientFirstBare == "" {
+			if !strings.HasPrefix(string(token), "n,,n=user,r=") {
+				t.Errorf("Unexpected client-first message %q", token)
+			}
+			clientFirstBare = strings.TrimPrefix(string(token), "n,,")
+			serverFirst = "r=" + strings.TrimPrefix(clientFirstBare, "n=user,r=") + "server,s=" +
+				base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+			return []byte(serverFirst), false, nil
+		}
+
+		proofAt := strings.LastIndex(string(token), ",p=")
+		authMessage := []byte(clientFirstBare + "," + serverFirst + "," + string(token[:proofAt]))
+		proof, err := base64.StdEncoding.DecodeString(string(token[proofAt+3:]))
+		if err != nil {
+			return nil, false, err
+		}
+		clientSignature := keys.hmac(storedKey, authMessage)
+		for i := range proof {
+			proof[i] ^= clientSignature[i]
+		}
+		if !bytes.Equal(keys.hash(proof), storedKey) {
+			return nil, false, fmt.Errorf("invalid proof")
+		}
+		return []byte("v=" + base64.StdEncoding.EncodeToString(keys.hmac(serverKey, authMessage))), true, nil
+	}
+}
+
+func TestBrokerSASLSCRAM(t *testing.T) {
+	testTable := []struct {
+		mechanism SASLMechanism
+		password  string
+		expected  error
+	}{
+		{SASLTypeSCRAMSHA256, "secret", nil},
+		{SASLTypeSCRAMSHA512, "secret", nil},
+		{SASLTypeSCRAMSHA512, "wrong", ErrSASLAuthenticationFailed},
+	}
+
+	for _, tt := range testTable {
+		mb := newMockBroker(t, 0)
+		mb.SetHandlerByMap(map[string]MockResponse{
+			"SaslHandshakeRequest": newMockWrapper(&SaslHandshakeResponse{EnabledMechanisms: []string{string(tt.mechanism)}}),
+			"MetadataRequest":      newMockMetadataResponse(t),
+		})
+		mb.SetSASLHandler(scramTestServer(t, tt.mechanism, "secret"))
+
+		broker := NewBroker(mb.Addr())
+		if err := broker.Open(newSASLTestConfig(tt.mechanism, tt.password)); err != nil {
+			t.Fatal(err)
+		}
+
+		connected, err := broker.Connected()
+		if err != tt.expected {
+			t.Errorf("%s/%s: expected %v from Connected(), got %v", tt.mechanism, tt.password, tt.expected, err)
+		}
+		if connected {
+			if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+				t.Error(err)
+			}
+			safeClose(t, broker)
+		}
+		mb.Close()
+	}
+}
+
+// sentVersion returns the version the last request with the given API key was received at.
+func sentVersion(mb *mockBroker, key int16) int16 {
+	version := int16(-1)
+	for _, rr := range mb.History() {
+		if rr.Request.key() == key {
+			version = rr.Request.version()
+		}
+	}
+	return version
+}
+
+func TestBrokerVersionNegotiation(t *testing.T) {
+	mb := newMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetApiVersions(&ApiVersionsResponse{
+		ApiVersions: []*ApiVersionsResponseBlock{
+			{ApiKey: 3, MinVersion: 0, MaxVersion: 1},
+			{ApiKey: 8, MinVersion: 0, MaxVersion: 1},
+		},
+	})
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest":     newMockMetadataResponse(t),
+		"OffsetCommitRequest": newMockOffsetCommitResponse(t),
+	})
+
+	conf := NewConfig()
+	conf.Version = V0_10_0_0
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	// raised to the highest version the broker advertises, without changing the request itself
+	metadata := &MetadataRequest{Topics: []string{}}
+	if _, err := broker.GetMetadata(metadata); err != nil {
+		t.Fatal(err)
+	}
+	if v := sentVersion(mb, 3); v != 1 {
+		t.Error("Expected the metadata request to be sent at version 1, got", v)
+	}
+	if metadata.Version != 0 {
+		t.Error("Expected the metadata request to be left at version 0, got", metadata.Version)
+	}
+	if metadata.Topics == nil {
+		t.Error("Expected the topics of the metadata request to be left as they were")
+	}
+
+	// so it can still be sent to a broker which only supports version 0
+	old := newMockBroker(t, 1)
+	defer old.Close()
+	old.SetApiVersions(&ApiVersionsResponse{
+		ApiVersions: []*ApiVersionsResponseBlock{{ApiKey: 3, MinVersion: 0, MaxVersion: 0}},
+	})
+	old.SetHandlerByMap(map[string]MockResponse{"MetadataRequest": newMockMetadataResponse(t)})
+	oldBroker := NewBroker(old.Addr())
+	if err := oldBroker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, oldBroker)
+	if _, err := oldBroker.GetMetadata(metadata); err != nil {
+		t.Error("Expected the metadata request to be sent at version 0, got", err)
+	}
+
+	// version 0 commits store the offsets in zookeeper, which later versions would move to kafka
+	if _, err := broker.CommitOffset(&OffsetCommitRequest{ConsumerGroup: "group"}); err != nil {
+		t.Fatal(err)
+	}
+	if v := sentVersion(mb, 8); v != 0 {
+		t.Error("Expected the commit to be sent at version 0, got", v)
+	}
+
+	// capped by the broker rather than by the configured version
+	if _, err := broker.CommitOffset(&OffsetCommitRequest{ConsumerGroup: "group", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if v := sentVersion(mb, 8); v != 1 {
+		t.Error("Expected the commit to be sent at version 1, got", v)
+	}
+
+	// not advertised by the broker at all
+	if _, err := broker.JoinGroup(&JoinGroupRequest{GroupID: "group"}); err != ErrUnsupportedVersion {
+		t.Error("Expected ErrUnsupportedVersion, got", err)
+	}
+}
+
+func TestBrokerVersionNegotiationWithoutApiVersions(t *testing.T) {
+	mb := newMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"OffsetCommitRequest": newMockOffsetCommitResponse(t),
+	})
+
+	conf := NewConfig()
+	conf.Version = V0_9_0_0
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	// capped by the configured version only
+	if _, err := broker.CommitOffset(&OffsetCommitRequest{ConsumerGroup: "group", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if v := sentVersion(mb, 8); v != 2 {
+		t.Error("Expected the commit to be sent at version 2, got", v)
+	}
+	for _, rr := range mb.History() {
+		if _, ok := rr.Request.(*ApiVersionsRequest); ok {
+			t.Error("Did not expect an ApiVersionsRequest before 0.10")
+		}
+	}
+
+	conf = NewConfig()
+	broker = NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	if _, err := broker.Heartbeat(&HeartbeatRequest{}); err == nil {
+		t.Error("Expected a configuration error when the configured version is too old")
+	} else if _, ok := err.(ConfigurationError); !ok {
+		t.Error("Expected a configuration error, got", err)
+	}
+}
+
+// listenRawBroker starts a fake broker which hands each connection to serve, for tests which need to
+// write responses the mockBroker would not.
+func listenRawBroker(t *testing.T, serve func(conn net.Conn, idx int)) net.Listener {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for idx := 0; ; idx++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(idx int) {
+				defer conn.Close()
+				serve(conn, idx)
+			}(idx)
+		}
+	}()
+	return listener
+}
+
+func rawResponse(t *testing.T, correlationID int32, body encoder) []byte {
+	buf, err := encode(body)
+	if err != nil {
+		t.Error(err)
+	}
+	res := make([]byte, 8, 8+len(buf))
+	binary.BigEndian.PutUint32(res, uint32(len(buf)+4))
+	binary.BigEndian.PutUint32(res[4:], uint32(correlationID))
+	return append(res, buf...)
+}
+
+func TestBrokerDiscardsLateResponses(t *testing.T) {
+	listener := listenRawBroker(t, func(conn net.Conn, idx int) {
+		// leave the first request unanswered until it timed out, then answer both
+		for i := 0; i < 2; i++ {
+			if _, err := decodeRequest(conn); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		for correlationID := int32(0); correlationID < 2; correlationID++ {
+			if _, err := conn.Write(rawResponse(t, correlationID, new(MetadataResponse))); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		_, _ = conn.Read(make([]byte, 1))
+	})
+	defer listener.Close()
+
+	conf := NewConfig()
+	conf.Net.ReadTimeout = 100 * time.Millisecond
+	broker := NewBroker(listener.Addr().String())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err == nil {
+		t.Fatal("Expected the first request to time out")
+	}
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); !connected {
+		t.Error("Expected the connection to survive a timeout between responses, got", err)
+	}
+}
+
+func TestBrokerPoisonedConnection(t *testing.T) {
+	received := make(chan none)
+	listener := listenRawBroker(t, func(conn net.Conn, idx int) {
+		if idx > 0 {
+			for {
+				req, err := decodeRequest(conn)
+				if err != nil {
+					return
+				}
+				if _, err := conn.Write(rawResponse(t, req.correlationID, new(MetadataResponse))); err != nil {
+					return
+				}
+			}
+		}
+
+		// answer the first of two requests with a truncated response
+		for i := 0; i < 2; i++ {
+			if _, err := decodeRequest(conn); err != nil {
+				t.Error(err)
+				return
+			}
+			received <- none{}
+		}
+		if _, err := conn.Write(rawResponse(t, 0, new(MetadataResponse))[:10]); err != nil {
+			t.Error(err)
+			return
+		}
+		_, _ = conn.Read(make([]byte, 1))
+	})
+	defer listener.Close()
+
+	conf := NewConfig()
+	conf.Net.ReadTimeout = 100 * time.Millisecond
+	broker := NewBroker(listener.Addr().String())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	var poisoned int32
+	errs := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := broker.GetMetadata(new(MetadataRequest))
+			if err == ErrConnectionPoisoned {
+				atomic.AddInt32(&poisoned, 1)
+			}
+			errs <- err
+		}()
+		<-received
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == nil {
+			t.Error("Expected the requests to fail")
+		}
+	}
+	if poisoned != 1 {
+		t.Error("Expected the pending request to fail with ErrConnectionPoisoned, got", poisoned, "of them")
+	}
+
+	// the next request closes the poisoned connection and goes over a new one
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err != nil {
+		t.Error(err)
+	}
+	if broker.State() != BrokerConnected {
+		t.Error("Expected the broker to be connected again, got", broker.State())
+	}
+}
+
+func TestBrokerContextCancellation(t *testing.T) {
+	mb := newMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetLatency(100 * time.Millisecond)
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).SetBroker(mb.Addr(), mb.BrokerID()),
+	})
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := broker.GetMetadataContext(ctx, new(MetadataRequest)); err != context.Canceled {
+		t.Error("Expected context.Canceled, got", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := broker.GetMetadataContext(ctx, new(MetadataRequest)); err != context.DeadlineExceeded {
+		t.Error("Expected context.DeadlineExceeded, got", err)
+	}
+
+	// the response to the abandoned request is read off the connection, which is still in line
+	response, err := broker.GetMetadata(new(MetadataRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Brokers) != 1 {
+		t.Error("Expected the metadata to list one broker, got", len(response.Brokers))
+	}
+	if history := mb.History(); len(history) != 2 {
+		t.Error("Expected the broker to receive only the requests which were not cancelled beforehand, got", len(history))
+	}
+}
+
+func TestBrokerDialer(t *testing.T) {
+	mb := newMockBroker(t, 0)
+	defer mb.Close()
+	mb.Returns(new(MetadataResponse))
+
+	var dialed string
+	wg := &sync.WaitGroup{}
+	conf := NewConfig()
+	conf.Net.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("Expected the dial to have a deadline")
+		}
+		dialed = address
+		client, server := net.Pipe()
+		wg.Add(1)
+		go mb.handleRequests(server, 0, wg)
+		return client, nil
+	}
+
+	broker := NewBroker("kafka.internal:9092")
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err != nil {
+		t.Error(err)
+	}
+	safeClose(t, broker)
+	wg.Wait()
+
+	if dialed != "kafka.internal:9092" {
+		t.Error("Expected the dialer to be asked for the broker address, got", dialed)
+	}
+}
+
+// recordingConn records the API keys of the requests written to it, and whether it was closed.
+type recordingConn struct {
+	net.Conn
+	lock   sync.Mutex
+	keys   map[int16]bool
+	closed bool
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.lock.Lock()
+	c.keys[int16(binary.BigEndian.Uint16(b[4:]))] = true
+	c.lock.Unlock()
+	return c.Conn.Write(b)
+}
+
+func (c *recordingConn) Close() error {
+	c.lock.Lock()
+	c.closed = true
+	c.lock.Unlock()
+	return c.Conn.Close()
+}
+
+func TestBrokerConnectionsPerBroker(t *testing.T) {
+	mb := newMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t),
+		"ProduceRequest":  newMockProduceResponse(t),
+		"FetchRequest":    newMockFetchResponse(t, 1),
+	})
+
+	var lock sync.Mutex
+	var conns []*recordingConn
+	conf := NewConfig()
+	conf.Net.ConnectionsPerBroker = 3
+	conf.Net.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := new(net.Dialer).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		recorder := &recordingConn{Conn: conn, keys: make(map[int16]bool)}
+		lock.Lock()
+		conns = append(conns, recorder)
+		lock.Unlock()
+		return recorder, nil
+	}
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := broker.GetMetadata(new(MetadataRequest)); err != nil {
+			t.Error(err)
+		}
+		if _, err := broker.Produce(&ProduceRequest{RequiredAcks: WaitForLocal}); err != nil {
+			t.Error(err)
+		}
+		if _, err := broker.Fetch(new(FetchRequest)); err != nil {
+			t.Error(err)
+		}
+	}
+	safeClose(t, broker)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(conns) != 3 {
+		t.Fatal("Expected 3 connections, got", len(conns))
+	}
+	seen := make(map[int16]bool)
+	for i, conn := range conns {
+		if len(conn.keys) != 1 {
+			t.Error("Expected connection", i, "to carry a single kind of request, got", conn.keys)
+		}
+		for key := range conn.keys {
+			seen[key] = true
+		}
+		if !conn.closed {
+			t.Error("Expected connection", i, "to be closed with the broker")
+		}
+	}
+	if !seen[0] || !seen[1] || !seen[3] {
+		t.Error("Expected the produce, fetch and metadata requests to have their own connections, got", seen)
+	}
+}
+
+func TestBrokerPinsPartitionsToProduceConnections(t *testing.T) {
+	mb := newMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"ProduceRequest": newMockProduceResponse(t),
+	})
+
+	var lock sync.Mutex
+	var conns []*recordingConn
+	conf := NewConfig()
+	conf.Net.ConnectionsPerBroker = 5
+	conf.Net.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := new(net.Dialer).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		recorder := &recordingConn{Conn: conn, keys: make(map[int16]bool)}
+		lock.Lock()
+		conns = append(conns, recorder)
+		lock.Unlock()
+		return recorder, nil
+	}
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	request := &ProduceRequest{RequiredAcks: WaitForLocal}
+	for partition := int32(0); partition < 8; partition++ {
+		request.AddMessage("my_topic", partition, &Message{Value: []byte("foo")})
+	}
+	response, err := broker.Produce(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for partition := int32(0); partition < 8; partition++ {
+		if response.GetBlock("my_topic", partition) == nil {
+			t.Error("Expected a response for partition", partition)
+		}
+	}
+	safeClose(t, broker)
+
+	produced := make(map[int32]bool)
+	pinned := make(map[int]bool)
+	for _, rr := range mb.History() {
+		req, ok := rr.Request.(*ProduceRequest)
+		if !ok {
+			continue
+		}
+		conn := -1
+		for partition := range req.msgSets["my_topic"] {
+			if produced[partition] {
+				t.Error("Expected partition", partition, "to be produced once")
+			}
+			produced[partition] = true
+			if conn == -1 {
+				conn = pinnedConn("my_topic", partition, 2)
+			} else if conn != pinnedConn("my_topic", partition, 2) {
+				t.Error("Expected a request to carry the partitions pinned to one connection, got", req.msgSets)
+			}
+		}
+		pinned[conn] = true
+	}
+	if len(produced) != 8 || len(pinned) != 2 {
+		t.Error("Expected the 8 partitions to be split over the 2 produce connections, got", produced, pinned)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	var produceConns int
+	for _, conn := range conns {
+		if conn.keys[0] {
+			produceConns++
+		}
+	}
+	if produceConns != 2 {
+		t.Error("Expected both produce connections to be used, got", produceConns)
+	}
+}
+
+func TestBrokerProduceKeepsPartialResults(t *testing.T) {
+	mb := newMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"ProduceRequest": newMockProduceResponse(t),
+	})
+
+	conf := NewConfig()
+	conf.Net.ConnectionsPerBroker = 5
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); !connected {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	// the first produce connection backs off for longer than the request may take
+	conn := broker.produceConns[0]
+	safeClose(t, conn)
+	conn.failures = 1
+	backoffConf := poolConfig(conf)
+	backoffConf.Net.Reconnect.Backoff = time.Minute
+	backoffConf.Net.Reconnect.MaxBackoff = time.Minute
+	if err := conn.open(backoffConf); err != nil {
+		t.Fatal(err)
+	}
+
+	request := &ProduceRequest{RequiredAcks: WaitForLocal}
+	for partition := int32(0); partition < 8; partition++ {
+		request.AddMessage("my_topic", partition, &Message{Value: []byte("foo")})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	response, err := broker.ProduceContext(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for partition := int32(0); partition < 8; partition++ {
+		block := response.GetBlock("my_topic", partition)
+		expected := ErrNoError
+		if pinnedConn("my_topic", partition, 2) == 0 {
+			expected = ErrNetworkException
+		}
+		if block == nil || block.Err != expected {
+			t.Error("Expected partition", partition, "to be answered with", expected, "got", block)
+		}
+	}
+}
+
+func TestBrokerClosesPoolConnectionsBackingOff(t *testing.T) {
+	mb := newMockBroker(t, 0)
+	defer mb.Close()
+
+	conf := NewConfig()
+	conf.Net.ConnectionsPerBroker = 2
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); !connected {
+		t.Fatal(err)
+	}
+
+	// the pool connection failed, and backs off from reconnecting
+	conn := broker.produceConns[0]
+	safeClose(t, conn)
+	conn.failures = 1
+	backoffConf := poolConfig(conf)
+	backoffConf.Net.Reconnect.Backoff = time.Minute
+	backoffConf.Net.Reconnect.MaxBackoff = time.Minute
+	if err := conn.open(backoffConf); err != nil {
+		t.Fatal(err)
+	}
+	if conn.State() != BrokerBackingOff {
+		t.Fatal("Expected the pool connection to back off, got", conn.State())
+	}
+
+	safeClose(t, broker)
+	if conn.State() != BrokerDisconnected {
+		t.Error("Expected the pool connection to be closed with the broker, got", conn.State())
+	}
+}
+
+func TestBrokerReconnectBackoff(t *testing.T) {
+	conf := NewConfig()
+	conf.Net.Reconnect.Backoff = 10 * time.Millisecond
+	conf.Net.Reconnect.MaxBackoff = 35 * time.Millisecond
+	conf.Net.Reconnect.Jitter = 0
+
+	broker := NewBroker("localhost:9092")
+	for failures, expected := range []time.Duration{0, 10, 20, 35, 35} {
+		broker.failures = int32(failures)
+		if backoff := broker.reconnectBackoff(conf); backoff != expected*time.Millisecond {
+			t.Errorf("Expected a backoff of %dms after %d failures, got %s", expected, failures, backoff)
+		}
+	}
+
+	conf.Net.Reconnect.Jitter = 0.5
+	broker.failures = 1
+	for i := 0; i < 100; i++ {
+		if backoff := broker.reconnectBackoff(conf); backoff < 5*time.Millisecond || backoff > 15*time.Millisecond {
+			t.Fatal("Expected the backoff to vary by up to half of itself, got", backoff)
+		}
+	}
+
+	broker.failures = 10
+	for i := 0; i < 100; i++ {
+		if backoff := broker.reconnectBackoff(conf); backoff > 35*time.Millisecond {
+			t.Fatal("Expected the backoff to stay within the maximum, got", backoff)
+		}
+	}
+}
+
+func TestBrokerBackoffIsCancellable(t *testing.T) {
+	// find an address nobody listens on
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	safeClose(t, listener)
+
+	conf := NewConfig()
+	conf.Net.Reconnect.Backoff = time.Minute
+	conf.Net.Reconnect.MaxBackoff = time.Minute
+	broker := NewBroker(addr)
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	_, connErr := broker.Connected()
+	if connErr == nil {
+		t.Fatal("Expected the connection to fail")
+	}
+
+	// the request gives up on the backoff with the error which caused it
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := broker.GetMetadataContext(ctx, new(MetadataRequest)); err != connErr {
+		t.Error("Expected the connection error, got", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Error("Expected the request to give up with its context, took", elapsed)
+	}
+	if broker.State() != BrokerBackingOff {
+		t.Error("Expected the broker to back off, got", broker.State())
+	}
+
+	// nor does the backoff hold up the other methods
+	if connected, _ := broker.Connected(); connected {
+		t.Error("Expected the broker to be disconnected while backing off")
+	}
+	safeClose(t, broker)
+	if broker.State() != BrokerDisconnected {
+		t.Error("Expected the closed broker to be disconnected, got", broker.State())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Error("Expected the broker to be closed while backing off, took", elapsed)
+	}
+}
+
+func TestBrokerReconnectsAfterFailure(t *testing.T) {
+	// find an address nobody listens on, for now
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	safeClose(t, listener)
+
+	conf := NewConfig()
+	conf.Net.Reconnect.Backoff = 100 * time.Millisecond
+	conf.Net.Reconnect.Jitter = 0
+	broker := NewBroker(addr)
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); connected || err == nil {
+		t.Fatal("Expected the connection to fail")
+	}
+	if broker.State() != BrokerDisconnected {
+		t.Error("Expected the broker to be disconnected, got", broker.State())
+	}
+
+	mb := newMockBrokerAddr(t, 0, addr)
+	defer mb.Close()
+	mb.Returns(new(MetadataResponse))
+
+	// the next request reopens the broker by itself, after backing off
+	start := time.Now()
+	errs := make(chan error)
+	go func() {
+		_, err := broker.GetMetadata(new(MetadataRequest))
+		errs <- err
+	}()
+	for broker.State() != BrokerBackingOff && time.Since(start) < time.Second {
+		time.Sleep(time.Millisecond)
+	}
+	if broker.State() != BrokerBackingOff {
+		t.Error("Expected the broker to back off, got", broker.State())
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Error("Expected the broker to back off for 100ms, reconnected after", elapsed)
+	}
+	if broker.State() != BrokerConnected {
+		t.Error("Expected the broker to be connected, got", broker.State())
+	}
+
+	safeClose(t, broker)
+	if broker.State() != BrokerDisconnected {
+		t.Error("Expected the closed broker to be disconnected, got", broker.State())
+	}
 }
@@ -1,6 +1,7 @@
 package sarama
 
 import (
+	"context"
 	"math/rand"
 	"sort"
 	"sync"
@@ -39,12 +40,30 @@ type Client interface {
 	// metadata for all topics.
 	RefreshMetadata(topics ...string) error
 
+	// RefreshMetadataContext is like RefreshMetadata, but gives up once ctx is done,
+	// including while waiting for a broker to answer or to retry.
+	RefreshMetadataContext(ctx context.Context, topics ...string) error
+
 	// GetOffset queries the cluster to get the most recent available offset at the
 	// given time on the topic/partition combination. Time should be OffsetOldest for
 	// the earliest available offset, OffsetNewest for the offset of the message that
 	// will be produced next, or a time.
 	GetOffset(topic string, partitionID int32, time int64) (int64, error)
 
+	// GetOffsetContext is like GetOffset, but gives up once ctx is done.
+	GetOffsetContext(ctx context.Context, topic string, partitionID int32, time int64) (int64, error)
+
+	// GetOffsetsForTimes queries the cluster for the offset of the first message at
+	// or after the given time, for every topic/partition combination of the map, with
+	// one request per leader broker. The offset is -1 when there is no such message.
+	// This function only works on Kafka 0.10.1 and higher.
+	GetOffsetsForTimes(times map[string]map[int32]time.Time) (map[string]map[int32]int64, error)
+
+	// Controller returns the controller broker of the cluster, as named by the last
+	// metadata received, or by fresh metadata if none named it. This function only
+	// works on Kafka 0.10 and higher.
+	Controller() (*Broker, error)
+
 	// Coordinator returns the coordinating broker for a consumer group. It will
 	// return a locally cached value if it's available. You can call
 	// RefreshCoordinator to update the cached value. This function only works on
@@ -55,6 +74,20 @@ type Client interface {
 	// in local cache. This function only works on Kafka 0.8.2 and higher.
 	RefreshCoordinator(consumerGroup string) error
 
+	// TransactionCoordinator returns the coordinating broker for a transactional ID,
+	// like Coordinator does for consumer groups. This function only works on Kafka
+	// 0.11 and higher.
+	TransactionCoordinator(transactionalID string) (*Broker, error)
+
+	// RefreshTransactionCoordinator retrieves the coordinator for a transactional ID
+	// and stores it in local cache. This function only works on Kafka 0.11 and higher.
+	RefreshTransactionCoordinator(transactionalID string) error
+
+	// InitProducerID obtains a producer ID and epoch from the cluster, which an
+	// idempotent producer stamps its record batches with. This function only works
+	// on Kafka 0.11 and higher.
+	InitProducerID() (*InitProducerIDResponse, error)
+
 	// Close shuts down all broker connections managed by this client. It is required
 	// to call this function before a client object passes out of scope, as it will
 	// otherwise leak memory. You must close any Producers or Consumers using a client
@@ -86,11 +119,12 @@ type client struct {
 	// the cluster metadata (I *think* it only returns brokers who are currently leading partitions?)
 	// so we store them separately
 	seedBrokers []*Broker
-	deadSeeds   []*Broker
 
-	brokers      map[int32]*Broker                       // maps broker ids to brokers
-	metadata     map[string]map[int32]*PartitionMetadata // maps topics to partition ids to metadata
-	coordinators map[string]int32                        // Maps consumer group names to coordinating broker IDs
+	brokers                 map[int32]*Broker                       // maps broker ids to brokers
+	metadata                map[string]map[int32]*PartitionMetadata // maps topics to partition ids to metadata
+	coordinators            map[string]int32                        // Maps consumer group names to coordinating broker IDs
+	transactionCoordinators map[string]int32                        // maps transactional IDs to coordinating broker IDs
+	controllerID            int32                                   // the controller broker ID, -1 until a version 1+ metadata response names it
 
 	// If the number of partitions is large, we can get some churn calling cachedPartitions,
 	// so the result is cached.  It is important to update this value whenever metadata is changed
@@ -125,6 +159,8 @@ func NewClient(addrs []string, conf *Config) (Client, error) {
 		metadata:                make(map[string]map[int32]*PartitionMetadata),
 		cachedPartitionsResults: make(map[string][maxPartitionIndex][]int32),
 		coordinators:            make(map[string]int32),
+		transactionCoordinators: make(map[string]int32),
+		controllerID:            -1,
 	}
 
 	random := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -282,6 +318,10 @@ func (client *client) Replicas(topic string, partitionID int32) ([]int32, error)
 }
 
 func (client *client) Leader(topic string, partitionID int32) (*Broker, error) {
+	return client.leader(context.Background(), topic, partitionID)
+}
+
+func (client *client) leader(ctx context.Context, topic string, partitionID int32) (*Broker, error) {
 	if client.Closed() {
 		return nil, ErrClosedClient
 	}
@@ -289,7 +329,7 @@ func (client *client) Leader(topic string, partitionID int32) (*Broker, error) {
 	leader, err := client.cachedLeader(topic, partitionID)
 
 	if leader == nil {
-		err := client.RefreshMetadata(topic)
+		err := client.RefreshMetadataContext(ctx, topic)
 		if err != nil {
 			return nil, err
 		}
@@ -300,6 +340,10 @@ func (client *client) Leader(topic string, partitionID int32) (*Broker, error) {
 }
 
 func (client *client) RefreshMetadata(topics ...string) error {
+	return client.RefreshMetadataContext(context.Background(), topics...)
+}
+
+func (client *client) RefreshMetadataContext(ctx context.Context, topics ...string) error {
 	if client.Closed() {
 		return ErrClosedClient
 	}
@@ -313,26 +357,54 @@ func (client *client) RefreshMetadata(topics ...string) error {
 		}
 	}
 
-	return client.tryRefreshMetadata(topics, client.conf.Metadata.Retry.Max)
+	return client.tryRefreshMetadata(ctx, topics, client.conf.Metadata.Retry.Max)
 }
 
 func (client *client) GetOffset(topic string, partitionID int32, time int64) (int64, error) {
+	return client.GetOffsetContext(context.Background(), topic, partitionID, time)
+}
+
+func (client *client) GetOffsetContext(ctx context.Context, topic string, partitionID int32, time int64) (int64, error) {
 	if client.Closed() {
 		return -1, ErrClosedClient
 	}
 
-	offset, err := client.getOffset(topic, partitionID, time)
+	offset, err := client.getOffset(ctx, topic, partitionID, time)
 
 	if err != nil {
-		if err := client.RefreshMetadata(topic); err != nil {
+		if ctx.Err() != nil {
 			return -1, err
 		}
-		return client.getOffset(topic, partitionID, time)
+		if err := client.RefreshMetadataContext(ctx, topic); err != nil {
+			return -1, err
+		}
+		return client.getOffset(ctx, topic, partitionID, time)
 	}
 
 	return offset, err
 }
 
+func (client *client) GetOffsetsForTimes(times map[string]map[int32]time.Time) (map[string]map[int32]int64, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	offsets, err := client.getOffsetsForTimes(times)
+
+	if err != nil {
+		topics := make([]string, 0, len(times))
+		for topic := range times {
+			topics = append(topics, topic)
+		}
+		if err := client.RefreshMetadata(topics...); err != nil {
+			return nil, err
+		}
+		return client.getOffsetsForTimes(times)
+	}
+
+	return offsets, nil
+}
+
 func (client *client) Coordinator(consumerGroup string) (*Broker, error) {
 	if client.Closed() {
 		return nil, ErrClosedClient
@@ -372,6 +444,74 @@ func (client *client) RefreshCoordinator(consumerGroup string) error {
 	return nil
 }
 
+func (client *client) TransactionCoordinator(transactionalID string) (*Broker, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	coordinator := client.cachedTransactionCoordinator(transactionalID)
+
+	if coordinator == nil {
+		if err := client.RefreshTransactionCoordinator(transactionalID); err != nil {
+			return nil, err
+		}
+		coordinator = client.cachedTransactionCoordinator(transactionalID)
+	}
+
+	if coordinator == nil {
+		return nil, ErrConsumerCoordinatorNotAvailable
+	}
+
+	_ = coordinator.Open(client.conf)
+	return coordinator, nil
+}
+
+func (client *client) RefreshTransactionCoordinator(transactionalID string) error {
+	if client.Closed() {
+		return ErrClosedClient
+	}
+
+	response, err := client.getTransactionCoordinator(transactionalID, client.conf.Metadata.Retry.Max)
+	if err != nil {
+		return err
+	}
+
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	client.registerBroker(response.Coordinator)
+	client.transactionCoordinators[transactionalID] = response.Coordinator.ID()
+	return nil
+}
+
+func (client *client) InitProducerID() (*InitProducerIDResponse, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	tried := make(map[*Broker]bool)
+	for broker := client.anyUntried(tried); broker != nil; broker = client.anyUntried(tried) {
+		Logger.Printf("client/producer-id fetching a producer ID from broker %s\n", broker.addr)
+		response, err := broker.InitProducerID(&InitProducerIDRequest{})
+
+		switch err.(type) {
+		case nil:
+			if response.Err != ErrNoError {
+				return nil, response.Err
+			}
+			return response, nil
+		case PacketEncodingError, ConfigurationError:
+			// didn't even send, so retrying would not help
+			return nil, err
+		default:
+			Logger.Println("client/producer-id got error from broker while fetching a producer ID:", err)
+			client.deregisterBroker(broker)
+		}
+	}
+
+	Logger.Println("client/producer-id no available broker to send the request to")
+	return nil, ErrOutOfBrokers
+}
+
 // private broker management helpers
 
 // registerBroker makes sure a broker received by a Metadata or Coordinator request is registered
@@ -388,15 +528,14 @@ func (client *client) registerBroker(broker *Broker) {
 	}
 }
 
-// deregisterBroker removes a broker from the seedsBroker list, and if it's
-// not the seedbroker, removes it from brokers map completely.
+// deregisterBroker moves a seed broker to the back of the seedBrokers list, and
+// removes any other broker from the brokers map completely.
 func (client *client) deregisterBroker(broker *Broker) {
 	client.lock.Lock()
-	defer client.lock.Unlock()
-
-	if len(client.seedBrokers) > 0 && broker == client.seedBrokers[0] {
-		client.deadSeeds = append(client.deadSeeds, broker)
-		client.seedBrokers = client.seedBrokers[1:]
+	seed := client.seedIndex(broker)
+	if seed >= 0 {
+		// the next requests go to the other seeds first, while this one backs off from reconnecting
+		client.seedBrokers = append(append(client.seedBrokers[:seed], client.seedBrokers[seed+1:]...), broker)
 	} else {
 		// we do this so that our loop in `tryRefreshMetadata` doesn't go on forever,
 		// but we really shouldn't have to; once that loop is made better this case can be
@@ -405,35 +544,110 @@ func (client *client) deregisterBroker(broker *Broker) {
 		Logger.Printf("client/brokers deregistered broker #%d at %s", broker.ID(), broker.Addr())
 		delete(client.brokers, broker.ID())
 	}
-}
+	client.lock.Unlock()
 
-func (client *client) resurrectDeadBrokers() {
-	client.lock.Lock()
-	defer client.lock.Unlock()
+	if seed < 0 {
+		// the next metadata registers a new Broker in its place, so this one would leak its connection
+		if err := broker.Close(); err != nil && err != ErrNotConnected {
+			Logger.Println("Error closing broker", broker.ID(), ":", err)
+		}
+	}
+}
 
-	Logger.Printf("client/brokers resurrecting %d dead seed brokers", len(client.deadSeeds))
-	client.seedBrokers = append(client.seedBrokers, client.deadSeeds...)
-	client.deadSeeds = nil
+// seedIndex returns the index of broker among the seed brokers, or -1. You must hold the lock.
+func (client *client) seedIndex(broker *Broker) int {
+	for i, seed := range client.seedBrokers {
+		if seed == broker {
+			return i
+		}
+	}
+	return -1
 }
 
 func (client *client) any() *Broker {
+	return client.anyUntried(nil)
+}
+
+// anyUntried is like any, but skips the brokers in tried, and adds the one it returns to them, so that
+// looping over it tries each broker once.
+func (client *client) anyUntried(tried map[*Broker]bool) *Broker {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
 
-	if len(client.seedBrokers) > 0 {
-		_ = client.seedBrokers[0].Open(client.conf)
-		return client.seedBrokers[0]
+	pick := func(broker *Broker) *Broker {
+		_ = broker.Open(client.conf)
+		if tried != nil {
+			tried[broker] = true
+		}
+		return broker
+	}
+
+	for _, broker := range client.seedBrokers {
+		if !tried[broker] {
+			return pick(broker)
+		}
 	}
 
 	// not guaranteed to be random *or* deterministic
 	for _, broker := range client.brokers {
-		_ = broker.Open(client.conf)
-		return broker
+		if !tried[broker] {
+			return pick(broker)
+		}
 	}
 
 	return nil
 }
 
+func (client *client) Controller() (*Broker, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	controller := client.cachedController()
+	if controller == nil {
+		if err := client.refreshController(); err != nil {
+			return nil, err
+		}
+		controller = client.cachedController()
+	}
+
+	if controller == nil {
+		return nil, ErrControllerNotAvailable
+	}
+
+	_ = controller.Open(client.conf)
+	return controller, nil
+}
+
+// refreshController asks for the metadata of no topic at all, which still lists the brokers
+// and the controller.
+func (client *client) refreshController() error {
+	if client.Closed() {
+		return ErrClosedClient
+	}
+
+	tried := make(map[*Broker]bool)
+	for broker := client.anyUntried(tried); broker != nil; broker = client.anyUntried(tried) {
+		Logger.Printf("client/controller fetching the controller from broker %s\n", broker.addr)
+		response, err := broker.GetMetadata(&MetadataRequest{Version: 1, Topics: []string{}})
+
+		switch err.(type) {
+		case nil:
+			_, err = client.updateMetadata(response)
+			return err
+		case PacketEncodingError, ConfigurationError, KError:
+			// didn't even send, or the broker cannot tell
+			return err
+		default:
+			Logger.Println("client/controller got error from broker while fetching metadata:", err)
+			client.deregisterBroker(broker)
+		}
+	}
+
+	Logger.Println("client/controller no available broker to send metadata request to")
+	return ErrOutOfBrokers
+}
+
 // private caching/lazy metadata helpers
 
 type partitionType int
@@ -513,8 +727,8 @@ func (client *client) cachedLeader(topic string, partitionID int32) (*Broker, er
 	return nil, ErrUnknownTopicOrPartition
 }
 
-func (client *client) getOffset(topic string, partitionID int32, time int64) (int64, error) {
-	broker, err := client.Leader(topic, partitionID)
+func (client *client) getOffset(ctx context.Context, topic string, partitionID int32, time int64) (int64, error) {
+	broker, err := client.leader(ctx, topic, partitionID)
 	if err != nil {
 		return -1, err
 	}
@@ -522,9 +736,12 @@ func (client *client) getOffset(topic string, partitionID int32, time int64) (in
 	request := &OffsetRequest{}
 	request.AddBlock(topic, partitionID, time, 1)
 
-	response, err := broker.GetAvailableOffsets(request)
+	response, err := broker.GetAvailableOffsetsContext(ctx, request)
 	if err != nil {
-		_ = broker.Close()
+		if ctx.Err() == nil {
+			// the connection is fine if we only stopped waiting
+			_ = broker.Close()
+		}
 		return -1, err
 	}
 
@@ -543,6 +760,52 @@ func (client *client) getOffset(topic string, partitionID int32, time int64) (in
 	return block.Offsets[0], nil
 }
 
+func (client *client) getOffsetsForTimes(times map[string]map[int32]time.Time) (map[string]map[int32]int64, error) {
+	requests := make(map[*Broker]*OffsetRequest)
+	for topic, partitions := range times {
+		for partitionID, t := range partitions {
+			broker, err := client.Leader(topic, partitionID)
+			if err != nil {
+				return nil, err
+			}
+			request := requests[broker]
+			if request == nil {
+				request = &OffsetRequest{Version: 1}
+				requests[broker] = request
+			}
+			request.AddBlock(topic, partitionID, t.UnixNano()/int64(time.Millisecond), 1)
+		}
+	}
+
+	offsets := make(map[string]map[int32]int64, len(times))
+	for broker, request := range requests {
+		response, err := broker.GetAvailableOffsets(request)
+		if err != nil {
+			_ = broker.Close()
+			return nil, err
+		}
+
+		for topic, partitions := range request.blocks {
+			if offsets[topic] == nil {
+				offsets[topic] = make(map[int32]int64, len(partitions))
+			}
+			for partitionID := range partitions {
+				block := response.GetBlock(topic, partitionID)
+				if block == nil {
+					_ = broker.Close()
+					return nil, ErrIncompleteResponse
+				}
+				if block.Err != ErrNoError {
+					return nil, block.Err
+				}
+				offsets[topic][partitionID] = block.Offset
+			}
+		}
+	}
+
+	return offsets, nil
+}
+
 // core metadata update logic
 
 func (client *client) backgroundMetadataUpdater() {
@@ -567,23 +830,32 @@ func (client *client) backgroundMetadataUpdater() {
 	}
 }
 
-func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int) error {
+func (client *client) tryRefreshMetadata(ctx context.Context, topics []string, attemptsRemaining int) error {
 	retry := func(err error) error {
 		if attemptsRemaining > 0 {
 			Logger.Printf("client/metadata retrying after %dms... (%d attempts remaining)\n", client.conf.Metadata.Retry.Backoff/time.Millisecond, attemptsRemaining)
-			time.Sleep(client.conf.Metadata.Retry.Backoff)
-			return client.tryRefreshMetadata(topics, attemptsRemaining-1)
+			select {
+			case <-time.After(client.conf.Metadata.Retry.Backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return client.tryRefreshMetadata(ctx, topics, attemptsRemaining-1)
 		}
 		return err
 	}
 
-	for broker := client.any(); broker != nil; broker = client.any() {
+	tried := make(map[*Broker]bool)
+	for broker := client.anyUntried(tried); broker != nil; broker = client.anyUntried(tried) {
 		if len(topics) > 0 {
 			Logger.Printf("client/metadata fetching metadata for %v from broker %s\n", topics, broker.addr)
 		} else {
 			Logger.Printf("client/metadata fetching metadata for all topics from broker %s\n", broker.addr)
 		}
-		response, err := broker.GetMetadata(&MetadataRequest{Topics: topics})
+		response, err := broker.GetMetadataContext(ctx, &MetadataRequest{Topics: topics})
+		if err != nil && ctx.Err() != nil {
+			// the broker is not to blame, we only stopped waiting for it
+			return err
+		}
 
 		switch err.(type) {
 		case nil:
@@ -601,13 +873,11 @@ func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int)
 		default:
 			// some other error, remove that broker and try again
 			Logger.Println("client/metadata got error from broker while fetching metadata:", err)
-			_ = broker.Close()
 			client.deregisterBroker(broker)
 		}
 	}
 
 	Logger.Println("client/metadata no available broker to send metadata request to")
-	client.resurrectDeadBrokers()
 	return retry(ErrOutOfBrokers)
 }
 
@@ -624,6 +894,10 @@ func (client *client) updateMetadata(data *MetadataResponse) (retry bool, err er
 		client.registerBroker(broker)
 	}
 
+	if data.Version >= 1 {
+		client.controllerID = data.ControllerID
+	}
+
 	for _, topic := range data.Topics {
 		delete(client.metadata, topic.Name)
 		delete(client.cachedPartitionsResults, topic.Name)
@@ -664,6 +938,32 @@ func (client *client) updateMetadata(data *MetadataResponse) (retry bool, err er
 	return
 }
 
+// registeredBrokers returns the brokers of the cluster, as known from the metadata.
+func (client *client) registeredBrokers() []*Broker {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+
+	brokers := make([]*Broker, 0, len(client.brokers))
+	for _, broker := range client.brokers {
+		brokers = append(brokers, broker)
+	}
+	return brokers
+}
+
+func (client *client) cachedBroker(brokerID int32) *Broker {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+
+	return client.brokers[brokerID]
+}
+
+func (client *client) cachedController() *Broker {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+
+	return client.brokers[client.controllerID]
+}
+
 func (client *client) cachedCoordinator(consumerGroup string) *Broker {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
@@ -674,6 +974,16 @@ func (client *client) cachedCoordinator(consumerGroup string) *Broker {
 	}
 }
 
+func (client *client) cachedTransactionCoordinator(transactionalID string) *Broker {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	coordinatorID, ok := client.transactionCoordinators[transactionalID]
+	if !ok {
+		return nil
+	}
+	return client.brokers[coordinatorID]
+}
+
 func (client *client) getConsumerMetadata(consumerGroup string, attemptsRemaining int) (*ConsumerMetadataResponse, error) {
 	retry := func(err error) (*ConsumerMetadataResponse, error) {
 		if attemptsRemaining > 0 {
@@ -684,7 +994,8 @@ func (client *client) getConsumerMetadata(consumerGroup string, attemptsRemainin
 		return nil, err
 	}
 
-	for broker := client.any(); broker != nil; broker = client.any() {
+	tried := make(map[*Broker]bool)
+	for broker := client.anyUntried(tried); broker != nil; broker = client.anyUntried(tried) {
 		Logger.Printf("client/coordinator requesting coordinator for consumergoup %s from %s\n", consumerGroup, broker.Addr())
 
 		request := new(ConsumerMetadataRequest)
@@ -699,7 +1010,6 @@ func (client *client) getConsumerMetadata(consumerGroup string, attemptsRemainin
 			case PacketEncodingError:
 				return nil, err
 			default:
-				_ = broker.Close()
 				client.deregisterBroker(broker)
 				continue
 			}
@@ -728,6 +1038,55 @@ func (client *client) getConsumerMetadata(consumerGroup string, attemptsRemainin
 	}
 
 	Logger.Println("client/coordinator no available broker to send consumer metadata request to")
-	client.resurrectDeadBrokers()
+	return retry(ErrOutOfBrokers)
+}
+
+func (client *client) getTransactionCoordinator(transactionalID string, attemptsRemaining int) (*FindCoordinatorResponse, error) {
+	retry := func(err error) (*FindCoordinatorResponse, error) {
+		if attemptsRemaining > 0 {
+			Logger.Printf("client/txn-coordinator retrying after %dms... (%d attempts remaining)\n", client.conf.Metadata.Retry.Backoff/time.Millisecond, attemptsRemaining)
+			time.Sleep(client.conf.Metadata.Retry.Backoff)
+			return client.getTransactionCoordinator(transactionalID, attemptsRemaining-1)
+		}
+		return nil, err
+	}
+
+	tried := make(map[*Broker]bool)
+	for broker := client.anyUntried(tried); broker != nil; broker = client.anyUntried(tried) {
+		Logger.Printf("client/txn-coordinator requesting coordinator for transactional ID %s from %s\n", transactionalID, broker.Addr())
+
+		request := &FindCoordinatorRequest{
+			CoordinatorKey:  transactionalID,
+			CoordinatorType: CoordinatorTransaction,
+			Version:         1,
+		}
+
+		response, err := broker.FindCoordinator(request)
+
+		if err != nil {
+			Logger.Printf("client/txn-coordinator request to broker %s failed: %s\n", broker.Addr(), err)
+
+			switch err.(type) {
+			case PacketEncodingError, ConfigurationError, KError:
+				return nil, err
+			default:
+				client.deregisterBroker(broker)
+				continue
+			}
+		}
+
+		switch response.Err {
+		case ErrNoError:
+			Logger.Printf("client/txn-coordinator coordinator for transactional ID %s is #%d (%s)\n", transactionalID, response.Coordinator.ID(), response.Coordinator.Addr())
+			return response, nil
+		case ErrConsumerCoordinatorNotAvailable, ErrOffsetsLoadInProgress:
+			Logger.Printf("client/txn-coordinator coordinator for transactional ID %s is not available\n", transactionalID)
+			return retry(response.Err)
+		default:
+			return nil, response.Err
+		}
+	}
+
+	Logger.Println("client/txn-coordinator no available broker to send find coordinator request to")
 	return retry(ErrOutOfBrokers)
 }
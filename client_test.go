@@ -1,7 +1,9 @@
 package sarama
 
 import (
+	"context"
 	"io"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -249,6 +251,75 @@ func TestClientGetOffset(t *testing.T) {
 	safeClose(t, client)
 }
 
+func TestClientGetOffsetsForTimes(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	leader1 := newMockBroker(t, 2)
+	leader2 := newMockBroker(t, 3)
+
+	ts := time.Unix(1500000000, 123*int64(time.Millisecond))
+	millis := int64(1500000000123)
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(leader1.Addr(), leader1.BrokerID()).
+			SetBroker(leader2.Addr(), leader2.BrokerID()).
+			SetLeader("foo", 0, leader1.BrokerID()).
+			SetLeader("foo", 1, leader2.BrokerID()).
+			SetLeader("bar", 0, leader1.BrokerID()),
+	})
+	leader1.SetHandlerByMap(map[string]MockResponse{
+		"OffsetRequest": newMockOffsetResponse(t).
+			SetOffset("foo", 0, millis, 123).
+			SetOffset("bar", 0, millis, -1),
+	})
+	leader2.SetHandlerByMap(map[string]MockResponse{
+		"OffsetRequest": newMockOffsetResponse(t).
+			SetOffset("foo", 1, millis, 456),
+	})
+
+	config := NewConfig()
+	config.Version = V0_10_1_0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offsets, err := client.GetOffsetsForTimes(map[string]map[int32]time.Time{
+		"foo": {0: ts, 1: ts},
+		"bar": {0: ts},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[int32]int64{
+		"foo": {0: 123, 1: 456},
+		"bar": {0: -1},
+	}
+	if !reflect.DeepEqual(offsets, expected) {
+		t.Error("Unexpected offsets, got", offsets)
+	}
+
+	for _, leader := range []*mockBroker{leader1, leader2} {
+		var requests int
+		for _, rr := range leader.History() {
+			if req, ok := rr.Request.(*OffsetRequest); ok {
+				requests++
+				if req.Version != 1 {
+					t.Error("Expected a version 1 offset request, got", req.Version)
+				}
+			}
+		}
+		if requests != 1 {
+			t.Errorf("Expected broker %d to receive 1 offset request, got %d", leader.BrokerID(), requests)
+		}
+	}
+
+	safeClose(t, client)
+	leader2.Close()
+	leader1.Close()
+	seedBroker.Close()
+}
+
 func TestClientReceivingUnknownTopic(t *testing.T) {
 	seedBroker := newMockBroker(t, 1)
 
@@ -374,7 +445,47 @@ func TestClientRefreshBehaviour(t *testing.T) {
 	safeClose(t, client)
 }
 
-func TestClientResurrectDeadSeeds(t *testing.T) {
+func TestClientDeregisterBrokerClosesIt(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	leader := newMockBroker(t, 5)
+	defer seedBroker.Close()
+	defer leader.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	seedBroker.Returns(metadataResponse)
+
+	c, err := NewClient([]string{seedBroker.Addr()}, NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*client)
+
+	broker := client.cachedBroker(leader.BrokerID())
+	if broker == nil {
+		t.Fatal("Expected the leader to be registered")
+	}
+	if err := broker.Open(client.conf); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); !connected {
+		t.Fatal("Expected the leader to be connected, got", err)
+	}
+
+	client.deregisterBroker(broker)
+	if client.cachedBroker(leader.BrokerID()) != nil {
+		t.Error("Expected the leader to be deregistered")
+	}
+	if connected, _ := broker.Connected(); connected {
+		t.Error("Expected the deregistered broker to be closed")
+	}
+
+	safeClose(t, c)
+}
+
+// TestClientRotatesFailedSeeds checks that a seed broker which fails is moved behind the others,
+// instead of being dropped, so that the next requests try the other seeds first.
+func TestClientRotatesFailedSeeds(t *testing.T) {
 	initialSeed := newMockBroker(t, 0)
 	emptyMetadata := new(MetadataResponse)
 	initialSeed.Returns(emptyMetadata)
@@ -400,7 +511,6 @@ func TestClientResurrectDeadSeeds(t *testing.T) {
 	// Overwrite the seed brokers with a fixed ordering to make this test deterministic.
 	safeClose(t, client.seedBrokers[0])
 	client.seedBrokers = []*Broker{NewBroker(addr1), NewBroker(addr2), NewBroker(addr3)}
-	client.deadSeeds = []*Broker{}
 
 	wg := sync.WaitGroup{}
 	wg.Add(1)
@@ -410,6 +520,7 @@ func TestClientResurrectDeadSeeds(t *testing.T) {
 		}
 		wg.Done()
 	}()
+	// all three seeds fail at first, then only seed2 answers
 	seed1.Close()
 	seed2.Close()
 
@@ -423,11 +534,11 @@ func TestClientResurrectDeadSeeds(t *testing.T) {
 
 	wg.Wait()
 
-	if len(client.seedBrokers) != 2 {
-		t.Error("incorrect number of live seeds")
+	if len(client.seedBrokers) != 3 {
+		t.Error("incorrect number of seeds")
 	}
-	if len(client.deadSeeds) != 1 {
-		t.Error("incorrect number of dead seeds")
+	if client.seedBrokers[0].Addr() != addr2 || client.seedBrokers[2].Addr() != addr1 {
+		t.Error("Expected the failed seed to be moved behind the one which answered")
 	}
 
 	safeClose(t, c)
@@ -606,3 +717,97 @@ func TestClientAutorefreshShutdownRace(t *testing.T) {
 	// give the update time to happen so we get a panic if it's still running (which it shouldn't)
 	time.Sleep(10 * time.Millisecond)
 }
+
+func TestClientController(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	controller := newMockBroker(t, 2)
+
+	rack := "rack2"
+	metadataResponse := &MetadataResponse{ControllerID: controller.BrokerID()}
+	metadataResponse.Brokers = append(metadataResponse.Brokers,
+		&Broker{id: controller.BrokerID(), addr: controller.Addr(), rack: &rack})
+	seedBroker.Returns(metadataResponse)
+
+	config := NewConfig()
+	config.Version = V0_10_0_0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker, err := client.Controller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if broker.ID() != controller.BrokerID() {
+		t.Error("Expected the controller to be broker", controller.BrokerID(), "got", broker.ID())
+	}
+	if broker.Rack() != rack {
+		t.Errorf("Expected the controller to be in rack %q, got %q", rack, broker.Rack())
+	}
+
+	safeClose(t, client)
+	controller.Close()
+	seedBroker.Close()
+}
+
+func TestClientControllerNotNamed(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+
+	// version 0 metadata does not name the controller, and asking for it needs Kafka 0.10
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	seedBroker.Returns(metadataResponse)
+
+	client, err := NewClient([]string{seedBroker.Addr()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Controller(); err == nil {
+		t.Error("Expected the controller to be unavailable before Kafka 0.10")
+	} else if _, ok := err.(ConfigurationError); !ok {
+		t.Error("Expected a ConfigurationError, got", err)
+	}
+
+	safeClose(t, client)
+	seedBroker.Close()
+}
+
+func TestClientContextCancellation(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+		"OffsetRequest": newMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234),
+	})
+
+	seedBroker.SetLatency(100 * time.Millisecond)
+
+	client, err := NewClient([]string{seedBroker.Addr()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := client.RefreshMetadataContext(ctx, "my_topic"); err != context.DeadlineExceeded {
+		t.Error("Expected context.DeadlineExceeded, got", err)
+	}
+	if _, err := client.GetOffsetContext(ctx, "my_topic", 0, OffsetNewest); err != context.DeadlineExceeded {
+		t.Error("Expected context.DeadlineExceeded, got", err)
+	}
+
+	// the broker was not given up on for being slow
+	offset, err := client.GetOffsetContext(context.Background(), "my_topic", 0, OffsetNewest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 1234 {
+		t.Error("Expected offset 1234, got", offset)
+	}
+}
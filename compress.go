@@ -0,0 +1,85 @@
+package sarama
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+// gzip writers are pooled per compression level: allocating one costs more than compressing a
+// typical message set.
+var gzipWriterPools [gzip.BestCompression - gzip.HuffmanOnly + 1]sync.Pool
+
+// compress encodes the payload of a message or record batch with the given codec. The level is
+// only honoured by the codecs supporting one, zero meaning the codec's default.
+func compress(cc CompressionCodec, level int, data []byte) ([]byte, error) {
+	switch cc {
+	case CompressionNone:
+		return data, nil
+	case CompressionGZIP:
+		return gzipEncode(level, data)
+	case CompressionSnappy:
+		return snappyEncode(data), nil
+	case CompressionLZ4:
+		return lz4Encode(data, false), nil
+	case CompressionZSTD:
+		return zstdEncode(level, data)
+	default:
+		return nil, PacketEncodingError{fmt.Sprintf("unsupported compression codec (%d)", cc)}
+	}
+}
+
+// decompress decodes the payload of a message or record batch compressed with the given codec.
+func decompress(cc CompressionCodec, data []byte) ([]byte, error) {
+	switch cc {
+	case CompressionNone:
+		return data, nil
+	case CompressionGZIP:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(reader)
+	case CompressionSnappy:
+		return snappyDecode(data)
+	case CompressionLZ4:
+		return lz4Decode(data)
+	case CompressionZSTD:
+		return zstdDecode(data)
+	default:
+		return nil, PacketDecodingError{fmt.Sprintf("invalid compression specified (%d)", cc)}
+	}
+}
+
+// gzipEncode compresses data at one of the levels of compress/gzip, zero meaning the default.
+func gzipEncode(level int, data []byte) ([]byte, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, PacketEncodingError{fmt.Sprintf("invalid gzip compression level (%d)", level)}
+	}
+
+	var buf bytes.Buffer
+	pool := &gzipWriterPools[level-gzip.HuffmanOnly]
+	writer, ok := pool.Get().(*gzip.Writer)
+	if ok {
+		writer.Reset(&buf)
+	} else {
+		var err error
+		if writer, err = gzip.NewWriterLevel(&buf, level); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	pool.Put(writer)
+	return buf.Bytes(), nil
+}
@@ -1,8 +1,14 @@
 package sarama
 
 import (
+	"compress/gzip"
+	"context"
 	"crypto/tls"
+	"fmt"
+	"net"
 	"time"
+
+	"github.com/rcrowley/go-metrics"
 )
 
 // Config is used to pass multiple configuration options to Sarama's constructors.
@@ -32,9 +38,75 @@ type Config struct {
 			Config *tls.Config
 		}
 
+		// SASL based authentication with the broker. This requires Kafka 0.10 or
+		// later for PLAIN, and Kafka 0.10.2 or later for SCRAM.
+		SASL struct {
+			// Whether or not to use SASL authentication when connecting to the
+			// broker (defaults to false).
+			Enable bool
+			// Whether or not to send the Kafka SASL handshake first if enabled
+			// (defaults to true). You should only set this to false if you're
+			// using a non-Kafka SASL proxy.
+			Handshake bool
+			// The SASL mechanism to use, one of SASLTypePlaintext (the default),
+			// SASLTypeSCRAMSHA256 or SASLTypeSCRAMSHA512.
+			Mechanism SASLMechanism
+			// The credentials to authenticate with.
+			User     string
+			Password string
+		}
+
 		// KeepAlive specifies the keep-alive period for an active network connection.
 		// If zero, keep-alives are disabled. (default is 0: disabled).
 		KeepAlive time.Duration
+
+		// How many connections to keep to each broker (default 1). With more than
+		// one, the first is dedicated to control requests such as metadata, offset
+		// and group requests, and the others carry the produce and fetch requests:
+		// both share the second connection if there are only two, and otherwise
+		// each has its own share of them, so that large fetches do not hold up the
+		// other requests. Each partition is produced to over the same connection,
+		// so that its messages stay in order. MaxOpenRequests applies to each
+		// connection.
+		ConnectionsPerBroker int
+
+		// Reconnect is how a Broker backs off from connecting again after its
+		// connections failed, or broke, in a row. It waits Backoff after the first
+		// failure, then twice as long after each further one, up to MaxBackoff; the
+		// delay varies randomly by up to Jitter times itself, so that clients do
+		// not all reconnect at once. Similar to `reconnect.backoff.ms` and
+		// `reconnect.backoff.max.ms` in the JVM version.
+		Reconnect struct {
+			// Defaults to 50ms, set to 0 to reconnect right away.
+			Backoff time.Duration
+			// Defaults to 1s.
+			MaxBackoff time.Duration
+			// Between 0 and 1, defaults to 0.2.
+			Jitter float64
+		}
+
+		// Dialer, if set, opens the connections to the brokers, or to the proxy if one is
+		// enabled, in place of a net.Dialer using DialTimeout and KeepAlive (defaults to
+		// nil). The context expires after DialTimeout. TLS and the proxy handshake are
+		// still taken care of on the connections it returns, which need not be TCP ones:
+		// net.Pipe works too.
+		Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
+		// Proxy to connect to the brokers through, for networks which only reach them
+		// via a bastion.
+		Proxy struct {
+			// Whether or not to connect to the brokers through a proxy (defaults to
+			// false).
+			Enable bool
+			// The kind of proxy, ProxyTypeSOCKS5 (the default) or
+			// ProxyTypeHTTPConnect.
+			Type ProxyType
+			// The address of the proxy, as host:port.
+			Addr string
+			// The credentials to authenticate with, if the proxy requires any.
+			User     string
+			Password string
+		}
 	}
 
 	// Metadata is the namespace for metadata management properties used by the
@@ -54,6 +126,16 @@ type Config struct {
 		RefreshFrequency time.Duration
 	}
 
+	// Admin is the namespace for configuration related to administering the
+	// cluster, used by the ClusterAdmin.
+	Admin struct {
+		// How long the controller may take to carry out a request, and how long
+		// the ClusterAdmin then waits for the metadata to reflect it (defaults to
+		// 3 seconds). Only supports millisecond resolution, nanoseconds will be
+		// truncated.
+		Timeout time.Duration
+	}
+
 	// Producer is the namespace for configuration related to producing messages,
 	// used by the Producer.
 	Producer struct {
@@ -71,12 +153,47 @@ type Config struct {
 		// the JVM producer's `request.timeout.ms` setting.
 		Timeout time.Duration
 		// The type of compression to use on messages (defaults to no compression).
-		// Similar to `compression.codec` setting of the JVM producer.
+		// Similar to `compression.codec` setting of the JVM producer. CompressionZSTD
+		// requires Version >= V2_1_0_0.
 		Compression CompressionCodec
+		// The level of compression to use, whose meaning depends on the codec
+		// (defaults to 0, the codec's own default). CompressionGZIP takes the
+		// levels of compress/gzip, from gzip.HuffmanOnly to gzip.BestCompression,
+		// and CompressionZSTD the ones of the zstd command line tool. Snappy and
+		// LZ4 have no levels.
+		CompressionLevel int
 		// Generates partitioners for choosing the partition to send messages to
 		// (defaults to hashing the message key). Similar to the `partitioner.class`
 		// setting for the JVM producer.
 		Partitioner PartitionerConstructor
+		// If enabled, the producer obtains a producer ID and stamps the record
+		// batches it sends with sequence numbers, so that brokers discard the
+		// duplicates retries would otherwise write (default disabled). Requires
+		// Version >= V0_11_0_0, RequiredAcks set to WaitForAll and Retry.Max > 0.
+		// Equivalent to the `enable.idempotence` setting of the JVM producer.
+		Idempotent bool
+
+		// Transaction is the namespace for configuring the TransactionalProducer.
+		Transaction struct {
+			// The transactional ID, which identifies the producer across restarts:
+			// a new producer with the same ID fences off the older one and aborts
+			// its pending transaction. Requires Idempotent to be enabled, and the
+			// producer to be created with NewTransactionalProducer. Equivalent to
+			// the `transactional.id` setting of the JVM producer.
+			ID string
+			// How long the transaction coordinator waits for a transaction to be
+			// ended before aborting it itself (defaults to 1 minute). Equivalent to
+			// the `transaction.timeout.ms` setting of the JVM producer.
+			Timeout time.Duration
+
+			Retry struct {
+				// The total number of times to retry a request to the transaction
+				// coordinator when it moved or is busy (default 50).
+				Max int
+				// How long to wait between such retries (default 100ms).
+				Backoff time.Duration
+			}
+		}
 
 		// Return specifies what channels will be populated. If they are set to true,
 		// you must read from the respective channels to prevent deadlock.
@@ -119,6 +236,11 @@ type Config struct {
 			// JVM producer.
 			Backoff time.Duration
 		}
+
+		// If enabled, the producer waits out the time a broker throttled a
+		// produce request for by a quota before sending it the next one, rather
+		// than adding to the load of a client over its quota (default disabled).
+		BackoffOnThrottle bool
 	}
 
 	// Consumer is the namespace for configuration related to consuming messages,
@@ -167,6 +289,18 @@ type Config struct {
 		// (MaxProcessingTime * ChanneBufferSize). Defaults to 100ms.
 		MaxProcessingTime time.Duration
 
+		// Which records of transactional producers to consume (defaults to
+		// ReadUncommitted). With ReadCommitted, the records of aborted transactions
+		// are skipped, and the ones of pending transactions are held back until
+		// they are committed. ReadCommitted requires Version >= V0_11_0_0.
+		// Equivalent to the `isolation.level` setting of the JVM consumer.
+		IsolationLevel IsolationLevel
+
+		// If enabled, the consumer waits out the time a broker throttled a fetch
+		// request for by a quota before sending it the next one, rather than
+		// adding to the load of a client over its quota (default disabled).
+		BackoffOnThrottle bool
+
 		// Return specifies what channels will be populated. If they are set to true,
 		// you must read from them to prevent deadlock.
 		Return struct {
@@ -186,6 +320,41 @@ type Config struct {
 			// Should be OffsetNewest or OffsetOldest. Defaults to OffsetNewest.
 			Initial int64
 		}
+
+		// Group is the namespace for configuring consumer group membership, used
+		// by the ConsumerGroup. This requires Kafka 0.9 or later.
+		Group struct {
+			Session struct {
+				// The timeout used to detect failures of a group member. The member
+				// sends periodic heartbeats to the coordinator to indicate its liveness;
+				// if none are received before this timeout expires, the coordinator
+				// removes the member from the group and initiates a rebalance. Must be
+				// within the broker's `group.min.session.timeout.ms` and
+				// `group.max.session.timeout.ms` (default 10s). Similar to the JVM's
+				// `session.timeout.ms`.
+				Timeout time.Duration
+			}
+			Heartbeat struct {
+				// The expected time between heartbeats to the group coordinator. Must be
+				// lower than Consumer.Group.Session.Timeout, and typically no higher
+				// than 1/3 of it (default 3s). Similar to the JVM's
+				// `heartbeat.interval.ms`.
+				Interval time.Duration
+			}
+			Rebalance struct {
+				// The strategy the group leader uses to assign partitions to the
+				// members of the group (default BalanceStrategyRange). All members
+				// of a group must use a strategy with the same name.
+				Strategy BalanceStrategy
+				Retry    struct {
+					// The number of times to retry joining the group before reporting
+					// an error (default 4).
+					Max int
+					// How long to wait between attempts to join the group (default 2s).
+					Backoff time.Duration
+				}
+			}
+		}
 	}
 
 	// A user-provided string sent with every request to the brokers for logging,
@@ -197,6 +366,23 @@ type Config struct {
 	// in the background while user code is working, greatly improving throughput.
 	// Defaults to 256.
 	ChannelBufferSize int
+	// The version of Kafka that Sarama will assume it is running against.
+	// Defaults to the oldest supported stable version. Since Kafka provides
+	// backwards-compatibility, setting it to a version older than you have
+	// will not break anything, although it may prevent you from using the
+	// latest features. Setting it to a version greater than you are actually
+	// running may lead to random breakage. From V0_10_0_0 on, the brokers
+	// are also asked which protocol versions they support when connecting,
+	// and every request is sent at the highest version both sides support.
+	Version KafkaVersion
+	// The registry to define metrics into (defaults to a local registry). To
+	// disable metrics gathering, set "metrics.UseNilMetrics" to true before
+	// creating any client. The metrics are:
+	// - produce-throttle-time-in-ms and produce-throttle-time-in-ms-for-broker-<id>:
+	//   histograms of the time produce requests were throttled for by a quota
+	// - fetch-throttle-time-in-ms and fetch-throttle-time-in-ms-for-broker-<id>:
+	//   histograms of the time fetch requests were throttled for by a quota
+	MetricRegistry metrics.Registry
 }
 
 // NewConfig returns a new configuration instance with sane defaults.
@@ -204,20 +390,32 @@ func NewConfig() *Config {
 	c := &Config{}
 
 	c.Net.MaxOpenRequests = 5
+	c.Net.ConnectionsPerBroker = 1
 	c.Net.DialTimeout = 30 * time.Second
 	c.Net.ReadTimeout = 30 * time.Second
 	c.Net.WriteTimeout = 30 * time.Second
+	c.Net.SASL.Handshake = true
+	c.Net.SASL.Mechanism = SASLTypePlaintext
+	c.Net.Proxy.Type = ProxyTypeSOCKS5
+	c.Net.Reconnect.Backoff = 50 * time.Millisecond
+	c.Net.Reconnect.MaxBackoff = time.Second
+	c.Net.Reconnect.Jitter = 0.2
 
 	c.Metadata.Retry.Max = 3
 	c.Metadata.Retry.Backoff = 250 * time.Millisecond
 	c.Metadata.RefreshFrequency = 10 * time.Minute
 
+	c.Admin.Timeout = 3 * time.Second
+
 	c.Producer.MaxMessageBytes = 1000000
 	c.Producer.RequiredAcks = WaitForLocal
 	c.Producer.Timeout = 10 * time.Second
 	c.Producer.Partitioner = NewHashPartitioner
 	c.Producer.Retry.Max = 3
 	c.Producer.Retry.Backoff = 100 * time.Millisecond
+	c.Producer.Transaction.Timeout = 1 * time.Minute
+	c.Producer.Transaction.Retry.Max = 50
+	c.Producer.Transaction.Retry.Backoff = 100 * time.Millisecond
 	c.Producer.Return.Errors = true
 
 	c.Consumer.Fetch.Min = 1
@@ -228,8 +426,15 @@ func NewConfig() *Config {
 	c.Consumer.Return.Errors = false
 	c.Consumer.Offsets.CommitInterval = 1 * time.Second
 	c.Consumer.Offsets.Initial = OffsetNewest
+	c.Consumer.Group.Session.Timeout = 10 * time.Second
+	c.Consumer.Group.Heartbeat.Interval = 3 * time.Second
+	c.Consumer.Group.Rebalance.Strategy = BalanceStrategyRange
+	c.Consumer.Group.Rebalance.Retry.Max = 4
+	c.Consumer.Group.Rebalance.Retry.Backoff = 2 * time.Second
 
 	c.ChannelBufferSize = 256
+	c.MetricRegistry = metrics.NewRegistry()
+	c.Version = minVersion
 
 	return c
 }
@@ -241,6 +446,14 @@ func (c *Config) Validate() error {
 	if c.Net.TLS.Enable == false && c.Net.TLS.Config != nil {
 		Logger.Println("Net.TLS is disabled but a non-nil configuration was provided.")
 	}
+	if c.Net.SASL.Enable == false {
+		if c.Net.SASL.User != "" {
+			Logger.Println("Net.SASL is disabled but a non-empty username was provided.")
+		}
+		if c.Net.SASL.Password != "" {
+			Logger.Println("Net.SASL is disabled but a non-empty password was provided.")
+		}
+	}
 	if c.Producer.RequiredAcks > 1 {
 		Logger.Println("Producer.RequiredAcks > 1 is deprecated and will raise an exception with kafka >= 0.8.2.0.")
 	}
@@ -267,6 +480,8 @@ func (c *Config) Validate() error {
 	switch {
 	case c.Net.MaxOpenRequests <= 0:
 		return ConfigurationError("Net.MaxOpenRequests must be > 0")
+	case c.Net.ConnectionsPerBroker <= 0:
+		return ConfigurationError("Net.ConnectionsPerBroker must be > 0")
 	case c.Net.DialTimeout <= 0:
 		return ConfigurationError("Net.DialTimeout must be > 0")
 	case c.Net.ReadTimeout <= 0:
@@ -275,6 +490,41 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Net.WriteTimeout must be > 0")
 	case c.Net.KeepAlive < 0:
 		return ConfigurationError("Net.KeepAlive must be >= 0")
+	case c.Net.Reconnect.Backoff < 0:
+		return ConfigurationError("Net.Reconnect.Backoff must be >= 0")
+	case c.Net.Reconnect.MaxBackoff < c.Net.Reconnect.Backoff:
+		return ConfigurationError("Net.Reconnect.MaxBackoff must be >= Net.Reconnect.Backoff")
+	case c.Net.Reconnect.Jitter < 0 || c.Net.Reconnect.Jitter > 1:
+		return ConfigurationError("Net.Reconnect.Jitter must be between 0 and 1")
+	case c.Net.SASL.Enable && c.Net.SASL.User == "":
+		return ConfigurationError("Net.SASL.User must not be empty when SASL is enabled")
+	case c.Net.SASL.Enable && c.Net.SASL.Password == "":
+		return ConfigurationError("Net.SASL.Password must not be empty when SASL is enabled")
+	case c.Net.Proxy.Enable && c.Net.Proxy.Addr == "":
+		return ConfigurationError("Net.Proxy.Addr must not be empty when the proxy is enabled")
+	}
+
+	if c.Net.Proxy.Enable {
+		switch c.Net.Proxy.Type {
+		case ProxyTypeSOCKS5, ProxyTypeHTTPConnect:
+		default:
+			return ConfigurationError(fmt.Sprintf("Net.Proxy.Type %q is not supported", c.Net.Proxy.Type))
+		}
+	}
+
+	if c.Net.SASL.Enable {
+		switch c.Net.SASL.Mechanism {
+		case SASLTypePlaintext:
+		case SASLTypeSCRAMSHA256, SASLTypeSCRAMSHA512:
+			if !c.Version.IsAtLeast(V0_10_2_0) {
+				return ConfigurationError("Net.SASL.Mechanism " + string(c.Net.SASL.Mechanism) + " requires Version >= V0_10_2_0")
+			}
+		default:
+			return ConfigurationError(fmt.Sprintf("Net.SASL.Mechanism %q is not supported", c.Net.SASL.Mechanism))
+		}
+		if c.Net.SASL.Handshake && !c.Version.IsAtLeast(V0_10_0_0) {
+			return ConfigurationError("Net.SASL.Handshake requires Version >= V0_10_0_0")
+		}
 	}
 
 	// validate the Metadata values
@@ -287,6 +537,12 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Metadata.RefreshFrequency must be >= 0")
 	}
 
+	// validate the Admin values
+	switch {
+	case c.Admin.Timeout <= 0:
+		return ConfigurationError("Admin.Timeout must be > 0")
+	}
+
 	// validate the Producer values
 	switch {
 	case c.Producer.MaxMessageBytes <= 0:
@@ -311,6 +567,25 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Producer.Retry.Max must be >= 0")
 	case c.Producer.Retry.Backoff < 0:
 		return ConfigurationError("Producer.Retry.Backoff must be >= 0")
+	case c.Producer.Compression == CompressionGZIP &&
+		(c.Producer.CompressionLevel < gzip.HuffmanOnly || c.Producer.CompressionLevel > gzip.BestCompression):
+		return ConfigurationError("Producer.CompressionLevel must be a valid gzip level when using GZIP")
+	case c.Producer.Compression == CompressionZSTD && !c.Version.IsAtLeast(V2_1_0_0):
+		return ConfigurationError("Producer.Compression ZSTD requires Version >= V2_1_0_0")
+	case c.Producer.Idempotent && !c.Version.IsAtLeast(V0_11_0_0):
+		return ConfigurationError("Producer.Idempotent requires Version >= V0_11_0_0")
+	case c.Producer.Idempotent && c.Producer.RequiredAcks != WaitForAll:
+		return ConfigurationError("Producer.Idempotent requires Producer.RequiredAcks to be WaitForAll")
+	case c.Producer.Idempotent && c.Producer.Retry.Max == 0:
+		return ConfigurationError("Producer.Idempotent requires Producer.Retry.Max > 0")
+	case c.Producer.Transaction.ID != "" && !c.Producer.Idempotent:
+		return ConfigurationError("Producer.Transaction.ID requires Producer.Idempotent")
+	case c.Producer.Transaction.Timeout <= 0:
+		return ConfigurationError("Producer.Transaction.Timeout must be > 0")
+	case c.Producer.Transaction.Retry.Max < 0:
+		return ConfigurationError("Producer.Transaction.Retry.Max must be >= 0")
+	case c.Producer.Transaction.Retry.Backoff < 0:
+		return ConfigurationError("Producer.Transaction.Retry.Backoff must be >= 0")
 	}
 
 	// validate the Consumer values
@@ -327,17 +602,36 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Consumer.MaxProcessingTime must be > 0")
 	case c.Consumer.Retry.Backoff < 0:
 		return ConfigurationError("Consumer.Retry.Backoff must be >= 0")
+	case c.Consumer.IsolationLevel != ReadUncommitted && c.Consumer.IsolationLevel != ReadCommitted:
+		return ConfigurationError("Consumer.IsolationLevel must be ReadUncommitted or ReadCommitted")
+	case c.Consumer.IsolationLevel == ReadCommitted && !c.Version.IsAtLeast(V0_11_0_0):
+		return ConfigurationError("Consumer.IsolationLevel ReadCommitted requires Version >= V0_11_0_0")
 	case c.Consumer.Offsets.CommitInterval <= 0:
 		return ConfigurationError("Consumer.Offsets.CommitInterval must be > 0")
 	case c.Consumer.Offsets.Initial != OffsetOldest && c.Consumer.Offsets.Initial != OffsetNewest:
 		return ConfigurationError("Consumer.Offsets.Initial must be OffsetOldest or OffsetNewest")
-
+	case c.Consumer.Group.Session.Timeout < 2*time.Millisecond:
+		return ConfigurationError("Consumer.Group.Session.Timeout must be >= 2ms")
+	case c.Consumer.Group.Heartbeat.Interval < 1*time.Millisecond:
+		return ConfigurationError("Consumer.Group.Heartbeat.Interval must be >= 1ms")
+	case c.Consumer.Group.Heartbeat.Interval >= c.Consumer.Group.Session.Timeout:
+		return ConfigurationError("Consumer.Group.Heartbeat.Interval must be < Consumer.Group.Session.Timeout")
+	case c.Consumer.Group.Rebalance.Strategy == nil:
+		return ConfigurationError("Consumer.Group.Rebalance.Strategy must not be empty")
+	case c.Consumer.Group.Rebalance.Retry.Max < 0:
+		return ConfigurationError("Consumer.Group.Rebalance.Retry.Max must be >= 0")
+	case c.Consumer.Group.Rebalance.Retry.Backoff < 0:
+		return ConfigurationError("Consumer.Group.Rebalance.Retry.Backoff must be >= 0")
 	}
 
 	// validate misc shared values
 	switch {
 	case c.ChannelBufferSize < 0:
 		return ConfigurationError("ChannelBufferSize must be >= 0")
+	case c.MetricRegistry == nil:
+		return ConfigurationError("MetricRegistry must not be nil")
+	case !c.Version.IsAtLeast(minVersion):
+		return ConfigurationError("Version must be one of SupportedVersions")
 	}
 
 	return nil
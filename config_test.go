@@ -1,6 +1,9 @@
 package sarama
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestDefaultConfigValidates(t *testing.T) {
 	config := NewConfig()
@@ -8,3 +11,135 @@ func TestDefaultConfigValidates(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestZSTDConfigRequiresVersion(t *testing.T) {
+	config := NewConfig()
+	config.Producer.Compression = CompressionZSTD
+	if err := config.Validate(); err == nil {
+		t.Error("Expected ZSTD compression to require Version >= V2_1_0_0")
+	}
+
+	config.Version = V2_1_0_0
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGZIPConfigRequiresValidLevel(t *testing.T) {
+	config := NewConfig()
+	config.Producer.Compression = CompressionGZIP
+	config.Producer.CompressionLevel = 10
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an invalid gzip level to be refused")
+	}
+
+	config.Producer.CompressionLevel = 9
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIdempotentProducerConfigValidation(t *testing.T) {
+	config := NewConfig()
+	config.Producer.Idempotent = true
+	if err := config.Validate(); err == nil {
+		t.Error("Expected the idempotent producer to require Version >= V0_11_0_0")
+	}
+
+	config.Version = V0_11_0_0
+	if err := config.Validate(); err == nil {
+		t.Error("Expected the idempotent producer to require RequiredAcks to be WaitForAll")
+	}
+
+	config.Producer.RequiredAcks = WaitForAll
+	config.Producer.Retry.Max = 0
+	if err := config.Validate(); err == nil {
+		t.Error("Expected the idempotent producer to require retries")
+	}
+
+	config.Producer.Retry.Max = 1
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransactionalProducerConfigValidation(t *testing.T) {
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Transaction.ID = "txn"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected the transactional producer to require Producer.Idempotent")
+	}
+
+	config.Producer.Idempotent = true
+	config.Producer.RequiredAcks = WaitForAll
+	config.Producer.Transaction.Timeout = 0
+	if err := config.Validate(); err == nil {
+		t.Error("Expected the transactional producer to require a timeout")
+	}
+
+	config.Producer.Transaction.Timeout = time.Minute
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadCommittedConfigRequiresVersion(t *testing.T) {
+	config := NewConfig()
+	config.Consumer.IsolationLevel = ReadCommitted
+	if err := config.Validate(); err == nil {
+		t.Error("Expected ReadCommitted to require Version >= V0_11_0_0")
+	}
+
+	config.Version = V0_11_0_0
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProxyConfigValidation(t *testing.T) {
+	config := NewConfig()
+	config.Net.Proxy.Enable = true
+	if err := config.Validate(); err == nil {
+		t.Error("Expected the proxy to require an address")
+	}
+
+	config.Net.Proxy.Addr = "bastion:1080"
+	config.Net.Proxy.Type = "SOCKS4"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an unsupported proxy type to be refused")
+	}
+
+	config.Net.Proxy.Type = ProxyTypeHTTPConnect
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConnectionsPerBrokerConfigValidation(t *testing.T) {
+	config := NewConfig()
+	config.Net.ConnectionsPerBroker = 0
+	if err := config.Validate(); err == nil {
+		t.Error("Expected at least one connection per broker to be required")
+	}
+}
+
+func TestReconnectConfigValidation(t *testing.T) {
+	config := NewConfig()
+	config.Net.Reconnect.MaxBackoff = config.Net.Reconnect.Backoff / 2
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a MaxBackoff shorter than the Backoff to be refused")
+	}
+
+	config = NewConfig()
+	config.Net.Reconnect.Jitter = 1.5
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a Jitter above 1 to be refused")
+	}
+
+	config = NewConfig()
+	config.Net.Reconnect.Backoff = 0
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
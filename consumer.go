@@ -1,8 +1,10 @@
 package sarama
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,6 +16,8 @@ type ConsumerMessage struct {
 	Topic      string
 	Partition  int32
 	Offset     int64
+	Timestamp  time.Time       // only set if kafka is version 0.10+, and the message was produced with a timestamp
+	Headers    []*RecordHeader // only set if kafka is version 0.11+
 }
 
 // ConsumerError is what is provided to the user when an error occurs.
@@ -41,9 +45,8 @@ func (ce ConsumerErrors) Error() string {
 // on a consumer to avoid leaks, it will not be garbage-collected automatically when it passes out of
 // scope.
 //
-// Sarama's Consumer type does not currently support automatic consumer group rebalancing and offset tracking,
-// however the https://github.com/wvanbergen/kafka library builds on Sarama to add this support. We plan
-// to properly integrate this functionality at a later date.
+// The Consumer type does not coordinate with other consumers; use a ConsumerGroup to have the partitions
+// of a set of topics automatically balanced between the members of a consumer group, with offset tracking.
 type Consumer interface {
 
 	// Topics returns the set of available topics as retrieved from the cluster
@@ -447,10 +450,10 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 		return nil, block.Err
 	}
 
-	if len(block.MsgSet.Messages) == 0 {
+	if len(block.MsgSet.Messages) == 0 && len(block.RecordBatches) == 0 {
 		// We got no messages. If we got a trailing one then we need to ask for more data.
 		// Otherwise we just poll again and wait for one to be produced...
-		if block.MsgSet.PartialTrailingMessage {
+		if block.Partial {
 			if child.conf.Consumer.Fetch.Max > 0 && child.fetchSize == child.conf.Consumer.Fetch.Max {
 				// we can't ask for more data, we've hit the configured limit
 				child.sendError(ErrMessageTooLarge)
@@ -470,39 +473,157 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 	child.fetchSize = child.conf.Consumer.Fetch.Default
 	atomic.StoreInt64(&child.highWaterMarkOffset, block.HighWaterMarkOffset)
 
+	// the response may mix messages of the legacy formats and record batches, each in offset order
+	all := mergeByOffset(child.parseMessages(&block.MsgSet), child.parseRecords(block.RecordBatches, block.AbortedTransactions))
+
 	incomplete := false
 	prelude := true
 	var messages []*ConsumerMessage
-	for _, msgBlock := range block.MsgSet.Messages {
+	for _, msg := range all {
+		if prelude && msg.Offset < child.offset {
+			continue
+		}
+		prelude = false
 
-		for _, msg := range msgBlock.Messages() {
-			if prelude && msg.Offset < child.offset {
-				continue
-			}
-			prelude = false
-
-			if msg.Offset >= child.offset {
-				messages = append(messages, &ConsumerMessage{
-					Topic:     child.topic,
-					Partition: child.partition,
-					Key:       msg.Msg.Key,
-					Value:     msg.Msg.Value,
-					Offset:    msg.Offset,
-				})
-				child.offset = msg.Offset + 1
-			} else {
-				incomplete = true
-			}
+		if msg.Offset >= child.offset {
+			messages = append(messages, msg)
+			child.offset = msg.Offset + 1
+		} else {
+			incomplete = true
 		}
+	}
 
+	// skip over whatever the record batches hold besides the records we returned: the control
+	// records and aborted records of transactions, and the gaps left by compaction
+	skipped := false
+	for _, batch := range block.RecordBatches {
+		if next := batch.LastOffset() + 1; next > child.offset {
+			child.offset = next
+			skipped = true
+		}
 	}
 
-	if incomplete || len(messages) == 0 {
+	if incomplete || (len(messages) == 0 && !skipped) {
 		return nil, ErrIncompleteResponse
 	}
 	return messages, nil
 }
 
+// parseMessages unwraps the messages of the v0 and v1 formats.
+func (child *partitionConsumer) parseMessages(msgSet *MessageSet) []*ConsumerMessage {
+	var messages []*ConsumerMessage
+	for _, msgBlock := range msgSet.Messages {
+
+		inner := msgBlock.Messages()
+		for _, msg := range inner {
+			offset := msg.Offset
+			if msg != msgBlock && msgBlock.Msg.Version >= 1 {
+				// the messages wrapped in a v1 message carry offsets relative to the wrapper,
+				// which holds the absolute offset of the last one
+				offset += msgBlock.Offset - inner[len(inner)-1].Offset
+			}
+
+			timestamp := msg.Msg.Timestamp
+			if msgBlock.Msg.LogAppendTime {
+				// the broker only sets the log append time on the wrapper
+				timestamp = msgBlock.Msg.Timestamp
+			}
+
+			messages = append(messages, &ConsumerMessage{
+				Topic:     child.topic,
+				Partition: child.partition,
+				Key:       msg.Msg.Key,
+				Value:     msg.Msg.Value,
+				Offset:    offset,
+				Timestamp: timestamp,
+			})
+		}
+	}
+	return messages
+}
+
+// parseRecords extracts the records of the v2 format, leaving out the control batches and the
+// batches of aborted transactions. Brokers only list the aborted transactions to read_committed
+// consumers, each from the offset of its first record; the transaction then ends at the abort
+// marker its producer writes.
+func (child *partitionConsumer) parseRecords(batches []*RecordBatch, abortedTransactions []*AbortedTransaction) []*ConsumerMessage {
+	pending := make([]*AbortedTransaction, len(abortedTransactions))
+	copy(pending, abortedTransactions)
+	sort.Sort(abortedTransactionSlice(pending))
+	abortedProducers := make(map[int64]bool)
+
+	var messages []*ConsumerMessage
+	for _, batch := range batches {
+		for len(pending) > 0 && pending[0].FirstOffset <= batch.LastOffset() {
+			abortedProducers[pending[0].ProducerID] = true
+			pending = pending[1:]
+		}
+
+		if batch.Control {
+			if batch.IsTransactional && isAbortMarker(batch) {
+				delete(abortedProducers, batch.ProducerID)
+			}
+			continue
+		}
+		if batch.IsTransactional && abortedProducers[batch.ProducerID] {
+			continue
+		}
+
+		for _, record := range batch.Records {
+			timestamp := batch.MaxTimestamp
+			if !batch.LogAppendTime && !batch.FirstTimestamp.IsZero() {
+				timestamp = batch.FirstTimestamp.Add(record.TimestampDelta)
+			}
+
+			messages = append(messages, &ConsumerMessage{
+				Topic:     child.topic,
+				Partition: child.partition,
+				Key:       record.Key,
+				Value:     record.Value,
+				Offset:    batch.FirstOffset + record.OffsetDelta,
+				Timestamp: timestamp,
+				Headers:   record.Headers,
+			})
+		}
+	}
+	return messages
+}
+
+// isAbortMarker tells whether a control batch holds the marker ending an aborted transaction. The
+// key of a control record holds its version and its type, which is 0 for aborts and 1 for commits.
+func isAbortMarker(batch *RecordBatch) bool {
+	if len(batch.Records) == 0 || len(batch.Records[0].Key) < 4 {
+		return false
+	}
+	return binary.BigEndian.Uint16(batch.Records[0].Key[2:]) == 0
+}
+
+type abortedTransactionSlice []*AbortedTransaction
+
+func (s abortedTransactionSlice) Len() int           { return len(s) }
+func (s abortedTransactionSlice) Less(i, j int) bool { return s[i].FirstOffset < s[j].FirstOffset }
+func (s abortedTransactionSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// mergeByOffset merges two lists of messages sorted by offset.
+func mergeByOffset(a, b []*ConsumerMessage) []*ConsumerMessage {
+	if len(a) == 0 {
+		return b
+	} else if len(b) == 0 {
+		return a
+	}
+
+	merged := make([]*ConsumerMessage, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if a[0].Offset <= b[0].Offset {
+			merged, a = append(merged, a[0]), a[1:]
+		} else {
+			merged, b = append(merged, b[0]), b[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
+
 // brokerConsumer
 
 type brokerConsumer struct {
@@ -601,6 +722,42 @@ func (bc *brokerConsumer) subscriptionConsumer() {
 		}
 		bc.acks.Wait()
 		bc.handleResponses()
+
+		if response.ThrottleTime > 0 && bc.consumer.conf.Consumer.BackoffOnThrottle {
+			Logger.Printf("consumer/broker/%d backing off for %dms as the broker throttled us\n",
+				bc.broker.ID(), response.ThrottleTime/time.Millisecond)
+			bc.waitThrottled(response.ThrottleTime)
+		}
+	}
+}
+
+// waitThrottled waits out the throttling of the last fetch, but lets go of the subscriptions closed
+// in the meantime, so that closing a partition consumer is not held up by it.
+func (bc *brokerConsumer) waitThrottled(throttle time.Duration) {
+	timer := time.NewTimer(throttle)
+	defer timer.Stop()
+
+	for len(bc.subscriptions) > 0 {
+		stop := make(chan none)
+		dying := make(chan none, len(bc.subscriptions))
+		for child := range bc.subscriptions {
+			go func(child *partitionConsumer) {
+				select {
+				case <-child.dying:
+					dying <- none{}
+				case <-stop:
+				}
+			}(child)
+		}
+
+		select {
+		case <-timer.C:
+			close(stop)
+			return
+		case <-dying:
+			close(stop)
+			bc.updateSubscriptions(nil)
+		}
 	}
 }
 
@@ -681,10 +838,26 @@ func (bc *brokerConsumer) fetchNewMessages() (*FetchResponse, error) {
 		MinBytes:    bc.consumer.conf.Consumer.Fetch.Min,
 		MaxWaitTime: int32(bc.consumer.conf.Consumer.MaxWaitTime / time.Millisecond),
 	}
+	if bc.consumer.conf.Version.IsAtLeast(V0_11_0_0) {
+		// record batches, which carry the headers, are only returned from version 4 on
+		request.Version = 4
+		request.MaxBytes = MaxResponseSize
+		request.Isolation = bc.consumer.conf.Consumer.IsolationLevel
+	} else if bc.consumer.conf.Version.IsAtLeast(V0_10_0_0) {
+		request.Version = 2
+	}
 
 	for child := range bc.subscriptions {
 		request.AddBlock(child.topic, child.partition, child.offset, child.fetchSize)
 	}
 
-	return bc.broker.Fetch(request)
+	response, err := bc.broker.Fetch(request)
+	if err != nil {
+		return nil, err
+	}
+	// from version 7 on, errors concerning the request as a whole are not reported per partition
+	if response.Err != ErrNoError {
+		return nil, response.Err
+	}
+	return response, nil
 }
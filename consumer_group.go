@@ -0,0 +1,626 @@
+package sarama
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConsumerGroup is a member of a Kafka consumer group. It joins the group through the group's
+// coordinator broker, gets a set of partitions assigned by the group leader, consumes them and
+// stores the consumed offsets through an OffsetManager. Whenever the membership of the group
+// changes, the ConsumerGroup commits the offsets of the partitions it holds, releases them and
+// rejoins the group to receive a new assignment. This requires Kafka 0.9 or later, with Config.Version
+// set accordingly.
+//
+// You MUST call Close() on a ConsumerGroup to leave the group and avoid leaks, it will not be
+// garbage-collected automatically when it passes out of scope.
+type ConsumerGroup interface {
+	// Messages returns the read channel for the messages of all the partitions that
+	// are currently assigned to this member of the group.
+	Messages() <-chan *ConsumerMessage
+
+	// Errors returns a read channel of errors that occurred while consuming or while
+	// managing the group membership, if enabled. By default, errors are logged and
+	// not returned over this channel. If you want to implement any custom error
+	// handling, set your config's Consumer.Return.Errors setting to true, and read
+	// from this channel.
+	Errors() <-chan error
+
+	// MarkOffset marks the provided message as processed, alongside a metadata
+	// string that represents the state of the consumer at that point in time. The
+	// offsets are committed periodically (see Consumer.Offsets.CommitInterval) and
+	// whenever the partition is handed over to another member of the group. Messages
+	// of partitions that are no longer assigned to this member are ignored.
+	MarkOffset(msg *ConsumerMessage, metadata string)
+
+	// Claims returns the sorted partition IDs currently assigned to this member, by topic.
+	Claims() map[string][]int32
+
+	// Close commits the marked offsets, stops consuming and leaves the group, which
+	// triggers a rebalance of the remaining members. Any errors that have not been
+	// read from the Errors channel are discarded. It is required to call this
+	// function before a ConsumerGroup object passes out of scope, as it will
+	// otherwise leak memory. You must call this before calling Close on the
+	// underlying client.
+	Close() error
+}
+
+type consumerGroup struct {
+	client    Client
+	conf      *Config
+	ownClient bool
+
+	groupID  string
+	topics   []string
+	consumer Consumer
+	offsets  OffsetManager
+
+	// only accessed by the mainLoop goroutine
+	memberID     string
+	generationID int32
+	assignment   map[string][]int32
+
+	lock   sync.Mutex
+	claims map[string]map[int32]*partitionClaim
+
+	messages chan *ConsumerMessage
+	errors   chan error
+
+	dying, dead chan none
+	closeOnce   sync.Once
+	leaveErr    error
+}
+
+// NewConsumerGroup creates a new consumer group member using the given broker addresses and
+// configuration, and starts joining the group in the background.
+func NewConsumerGroup(addrs []string, groupID string, topics []string, config *Config) (ConsumerGroup, error) {
+	client, err := NewClient(addrs, config)
+	if err != nil {
+		return nil, err
+	}
+
+	cg, err := NewConsumerGroupFromClient(groupID, topics, client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	cg.(*consumerGroup).ownClient = true
+	return cg, nil
+}
+
+// NewConsumerGroupFromClient creates a new consumer group member using the given client, and
+// starts joining the group in the background. It is still necessary to call Close() on the
+// underlying client when shutting down this consumer group member.
+func NewConsumerGroupFromClient(groupID string, topics []string, client Client) (ConsumerGroup, error) {
+	// Check that we are not dealing with a closed Client before processing any other arguments
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	if !client.Config().Version.IsAtLeast(V0_9_0_0) {
+		return nil, ConfigurationError("Consumer groups require Version to be >= V0_9_0_0")
+	}
+	if groupID == "" {
+		return nil, ConfigurationError("A consumer group requires a group ID")
+	}
+	if len(topics) == 0 {
+		return nil, ConfigurationError("A consumer group requires at least one topic")
+	}
+
+	consumer, err := NewConsumerFromClient(client)
+	if err != nil {
+		return nil, err
+	}
+
+	cg := &consumerGroup{
+		client:   client,
+		conf:     client.Config(),
+		groupID:  groupID,
+		topics:   topics,
+		consumer: consumer,
+		claims:   make(map[string]map[int32]*partitionClaim),
+		messages: make(chan *ConsumerMessage, client.Config().ChannelBufferSize),
+		errors:   make(chan error, client.Config().ChannelBufferSize),
+		dying:    make(chan none),
+		dead:     make(chan none),
+	}
+
+	go withRecover(cg.mainLoop)
+
+	return cg, nil
+}
+
+func (cg *consumerGroup) Messages() <-chan *ConsumerMessage {
+	return cg.messages
+}
+
+func (cg *consumerGroup) Errors() <-chan error {
+	return cg.errors
+}
+
+func (cg *consumerGroup) MarkOffset(msg *ConsumerMessage, metadata string) {
+	cg.lock.Lock()
+	claim := cg.claims[msg.Topic][msg.Partition]
+	cg.lock.Unlock()
+
+	if claim == nil {
+		Logger.Printf("consumergroup/%s ignoring offset %d of unclaimed partition %s/%d\n", cg.groupID, msg.Offset, msg.Topic, msg.Partition)
+		return
+	}
+
+	claim.offsets.MarkOffset(msg.Offset, metadata)
+}
+
+func (cg *consumerGroup) Claims() map[string][]int32 {
+	cg.lock.Lock()
+	defer cg.lock.Unlock()
+
+	ret := make(map[string][]int32, len(cg.claims))
+	for topic, claims := range cg.claims {
+		partitions := make([]int32, 0, len(claims))
+		for partition := range claims {
+			partitions = append(partitions, partition)
+		}
+		sort.Sort(int32Slice(partitions))
+		ret[topic] = partitions
+	}
+	return ret
+}
+
+func (cg *consumerGroup) Close() error {
+	// Close may be called more than once, say by a deferred Close after an explicit one
+	cg.closeOnce.Do(func() {
+		close(cg.dying)
+
+		go withRecover(func() {
+			for _ = range cg.messages {
+				// drain
+			}
+		})
+
+		for _ = range cg.errors {
+			// drain
+		}
+	})
+
+	<-cg.dead
+	return cg.leaveErr
+}
+
+// membership management
+
+func (cg *consumerGroup) mainLoop() {
+	defer cg.shutdown()
+
+	for {
+		if err := cg.rebalance(); err != nil {
+			cg.handleError(err)
+
+			select {
+			case <-cg.dying:
+				return
+			case <-time.After(cg.conf.Consumer.Group.Rebalance.Retry.Backoff):
+			}
+			continue
+		}
+
+		// heartbeat only returns once the group needs rebalancing or we are shutting down
+		cg.heartbeat()
+
+		select {
+		case <-cg.dying:
+			return
+		default:
+		}
+	}
+}
+
+func (cg *consumerGroup) rebalance() error {
+	// hand over the partitions we currently hold before rejoining, so that their offsets are
+	// committed while our generation is still valid
+	cg.releaseClaims()
+
+	assignment, err := cg.join(cg.conf.Consumer.Group.Rebalance.Retry.Max)
+	if err != nil {
+		return err
+	}
+
+	return cg.claim(assignment)
+}
+
+func (cg *consumerGroup) join(attemptsRemaining int) (map[string][]int32, error) {
+	retry := func(err error) (map[string][]int32, error) {
+		if attemptsRemaining <= 0 {
+			return nil, err
+		}
+
+		Logger.Printf("consumergroup/%s retrying join after %dms... (%d attempts remaining) because %s\n",
+			cg.groupID, cg.conf.Consumer.Group.Rebalance.Retry.Backoff/time.Millisecond, attemptsRemaining, err)
+		select {
+		case <-cg.dying:
+			return nil, err
+		case <-time.After(cg.conf.Consumer.Group.Rebalance.Retry.Backoff):
+		}
+		return cg.join(attemptsRemaining - 1)
+	}
+
+	coordinator, err := cg.refreshCoordinator()
+	if err != nil {
+		return retry(err)
+	}
+
+	joinResponse, err := cg.joinGroup(coordinator)
+	if err != nil {
+		_ = coordinator.Close()
+		return retry(err)
+	}
+
+	switch joinResponse.Err {
+	case ErrNoError:
+		cg.memberID = joinResponse.MemberID
+	case ErrUnknownMemberID, ErrIllegalGeneration:
+		cg.memberID = ""
+		return retry(joinResponse.Err)
+	case ErrNotCoordinatorForConsumer, ErrConsumerCoordinatorNotAvailable, ErrOffsetsLoadInProgress, ErrRebalanceInProgress:
+		return retry(joinResponse.Err)
+	default:
+		return nil, joinResponse.Err
+	}
+
+	var plan BalanceStrategyPlan
+	if joinResponse.LeaderID == joinResponse.MemberID {
+		Logger.Printf("consumergroup/%s member %s elected leader of generation %d\n", cg.groupID, cg.memberID, joinResponse.GenerationID)
+		if plan, err = cg.balance(joinResponse); err != nil {
+			return nil, err
+		}
+	}
+
+	syncResponse, err := cg.syncGroup(coordinator, joinResponse.GenerationID, plan)
+	if err != nil {
+		_ = coordinator.Close()
+		return retry(err)
+	}
+
+	switch syncResponse.Err {
+	case ErrNoError:
+		cg.generationID = joinResponse.GenerationID
+	case ErrUnknownMemberID, ErrIllegalGeneration:
+		cg.memberID = ""
+		return retry(syncResponse.Err)
+	case ErrNotCoordinatorForConsumer, ErrConsumerCoordinatorNotAvailable, ErrRebalanceInProgress:
+		return retry(syncResponse.Err)
+	default:
+		return nil, syncResponse.Err
+	}
+
+	assignment, err := syncResponse.GetMemberAssignment()
+	if err != nil {
+		return nil, err
+	}
+
+	cg.assignment = assignment.Topics
+
+	Logger.Printf("consumergroup/%s member %s joined generation %d and was assigned %v\n", cg.groupID, cg.memberID, cg.generationID, assignment.Topics)
+	return assignment.Topics, nil
+}
+
+func (cg *consumerGroup) refreshCoordinator() (*Broker, error) {
+	if err := cg.client.RefreshCoordinator(cg.groupID); err != nil {
+		return nil, err
+	}
+
+	return cg.client.Coordinator(cg.groupID)
+}
+
+func (cg *consumerGroup) joinGroup(coordinator *Broker) (*JoinGroupResponse, error) {
+	request := &JoinGroupRequest{
+		GroupID:        cg.groupID,
+		MemberID:       cg.memberID,
+		SessionTimeout: int32(cg.conf.Consumer.Group.Session.Timeout / time.Millisecond),
+		ProtocolType:   "consumer",
+	}
+
+	strategy := cg.conf.Consumer.Group.Rebalance.Strategy
+	userData, err := strategy.AssignmentData(cg.memberID, cg.assignment, cg.generationID)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &ConsumerGroupMemberMetadata{Topics: cg.topics, UserData: userData}
+	if err := request.AddGroupProtocolMetadata(strategy.Name(), meta); err != nil {
+		return nil, err
+	}
+
+	return coordinator.JoinGroup(request)
+}
+
+func (cg *consumerGroup) syncGroup(coordinator *Broker, generationID int32, plan BalanceStrategyPlan) (*SyncGroupResponse, error) {
+	request := &SyncGroupRequest{
+		GroupID:      cg.groupID,
+		GenerationID: generationID,
+		MemberID:     cg.memberID,
+	}
+
+	for memberID, topics := range plan {
+		if err := request.AddGroupAssignmentMember(memberID, &ConsumerGroupMemberAssignment{Topics: topics}); err != nil {
+			return nil, err
+		}
+	}
+
+	return coordinator.SyncGroup(request)
+}
+
+// balance computes the assignment of every member of the group; it is only called on the leader
+func (cg *consumerGroup) balance(joinResponse *JoinGroupResponse) (BalanceStrategyPlan, error) {
+	strategy := cg.conf.Consumer.Group.Rebalance.Strategy
+	if joinResponse.GroupProtocol != strategy.Name() {
+		return nil, ConfigurationError(fmt.Sprintf("The group chose the %s balance strategy, which this member does not support", joinResponse.GroupProtocol))
+	}
+
+	members, err := joinResponse.GetMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	var topics []string
+	seen := make(map[string]none)
+	for _, meta := range members {
+		for _, topic := range meta.Topics {
+			if _, ok := seen[topic]; !ok {
+				seen[topic] = none{}
+				topics = append(topics, topic)
+			}
+		}
+	}
+	sort.Strings(topics)
+
+	if err := cg.client.RefreshMetadata(topics...); err != nil {
+		return nil, err
+	}
+
+	partitions := make(map[string][]int32, len(topics))
+	for _, topic := range topics {
+		if partitions[topic], err = cg.client.Partitions(topic); err != nil {
+			return nil, err
+		}
+	}
+
+	return strategy.Plan(members, partitions)
+}
+
+func (cg *consumerGroup) heartbeat() {
+	ticker := time.NewTicker(cg.conf.Consumer.Group.Heartbeat.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-cg.dying:
+			return
+		}
+
+		coordinator, err := cg.client.Coordinator(cg.groupID)
+		if err != nil {
+			cg.handleError(err)
+			return
+		}
+
+		response, err := coordinator.Heartbeat(&HeartbeatRequest{
+			GroupID:      cg.groupID,
+			GenerationID: cg.generationID,
+			MemberID:     cg.memberID,
+		})
+		if err != nil {
+			_ = coordinator.Close()
+			cg.handleError(err)
+			return
+		}
+
+		switch response.Err {
+		case ErrNoError:
+			continue
+		case ErrRebalanceInProgress:
+			Logger.Printf("consumergroup/%s rebalance of generation %d triggered by the coordinator\n", cg.groupID, cg.generationID)
+		case ErrUnknownMemberID:
+			Logger.Printf("consumergroup/%s member %s was evicted from the group\n", cg.groupID, cg.memberID)
+			cg.memberID = ""
+		case ErrIllegalGeneration, ErrNotCoordinatorForConsumer, ErrConsumerCoordinatorNotAvailable:
+			Logger.Printf("consumergroup/%s rejoining the group because %s\n", cg.groupID, response.Err)
+		default:
+			cg.handleError(response.Err)
+		}
+		return
+	}
+}
+
+func (cg *consumerGroup) leave() error {
+	if cg.memberID == "" {
+		return nil
+	}
+
+	coordinator, err := cg.client.Coordinator(cg.groupID)
+	if err != nil {
+		return err
+	}
+
+	response, err := coordinator.LeaveGroup(&LeaveGroupRequest{
+		GroupID:  cg.groupID,
+		MemberID: cg.memberID,
+	})
+	if err != nil {
+		_ = coordinator.Close()
+		return err
+	}
+
+	cg.memberID = ""
+
+	switch response.Err {
+	case ErrNoError, ErrUnknownMemberID:
+		// if the coordinator doesn't know about us anymore, we have already left
+		return nil
+	default:
+		return response.Err
+	}
+}
+
+func (cg *consumerGroup) shutdown() {
+	cg.releaseClaims()
+
+	if cg.leaveErr = cg.leave(); cg.leaveErr != nil {
+		Logger.Printf("consumergroup/%s failed to leave the group: %s\n", cg.groupID, cg.leaveErr)
+	}
+
+	if err := cg.consumer.Close(); err != nil {
+		Logger.Printf("consumergroup/%s failed to close the consumer: %s\n", cg.groupID, err)
+	}
+
+	if cg.ownClient {
+		if err := cg.client.Close(); err != nil {
+			Logger.Printf("consumergroup/%s failed to close the embedded client: %s\n", cg.groupID, err)
+		}
+	}
+
+	close(cg.messages)
+	close(cg.errors)
+	close(cg.dead)
+}
+
+func (cg *consumerGroup) handleError(err error) {
+	if cg.conf.Consumer.Return.Errors {
+		cg.errors <- err
+	} else {
+		Logger.Printf("consumergroup/%s error: %s\n", cg.groupID, err)
+	}
+}
+
+// partition claims
+
+type partitionClaim struct {
+	topic     string
+	partition int32
+	consumer  PartitionConsumer
+	offsets   PartitionOffsetManager
+
+	dying, dead chan none
+}
+
+func (cg *consumerGroup) claim(assignment map[string][]int32) error {
+	offsets, err := newOffsetManagerFromClient(cg.groupID, cg.memberID, cg.generationID, cg.client)
+	if err != nil {
+		return err
+	}
+	cg.offsets = offsets
+
+	for topic, partitions := range assignment {
+		for _, partition := range partitions {
+			claim, err := cg.newPartitionClaim(topic, partition)
+			if err != nil {
+				return err
+			}
+
+			cg.lock.Lock()
+			if cg.claims[topic] == nil {
+				cg.claims[topic] = make(map[int32]*partitionClaim)
+			}
+			cg.claims[topic][partition] = claim
+			cg.lock.Unlock()
+
+			go withRecover(func() { cg.forward(claim) })
+		}
+	}
+
+	return nil
+}
+
+func (cg *consumerGroup) newPartitionClaim(topic string, partition int32) (*partitionClaim, error) {
+	pom, err := cg.offsets.ManagePartition(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, _ := pom.NextOffset()
+	pc, err := cg.consumer.ConsumePartition(topic, partition, offset)
+	if err == ErrOffsetOutOfRange {
+		// the committed offset is no longer available on the broker
+		Logger.Printf("consumergroup/%s offset %d of %s/%d is out of range, resetting\n", cg.groupID, offset, topic, partition)
+		pc, err = cg.consumer.ConsumePartition(topic, partition, cg.conf.Consumer.Offsets.Initial)
+	}
+	if err != nil {
+		_ = pom.Close()
+		return nil, err
+	}
+
+	return &partitionClaim{
+		topic:     topic,
+		partition: partition,
+		consumer:  pc,
+		offsets:   pom,
+		dying:     make(chan none),
+		dead:      make(chan none),
+	}, nil
+}
+
+// forward pipes the messages and errors of a single claimed partition into the group's channels
+func (cg *consumerGroup) forward(claim *partitionClaim) {
+	defer close(claim.dead)
+
+	for {
+		select {
+		case msg, ok := <-claim.consumer.Messages():
+			if !ok {
+				return
+			}
+			select {
+			case cg.messages <- msg:
+			case <-claim.dying:
+				// not yet processed, so it will be redelivered to the next owner of the partition
+				return
+			}
+		case err := <-claim.consumer.Errors():
+			select {
+			case cg.errors <- err:
+			case <-claim.dying:
+				return
+			}
+		case err := <-claim.offsets.Errors():
+			select {
+			case cg.errors <- err:
+			case <-claim.dying:
+				return
+			}
+		case <-claim.dying:
+			return
+		}
+	}
+}
+
+func (cg *consumerGroup) releaseClaims() {
+	cg.lock.Lock()
+	claims := cg.claims
+	cg.claims = make(map[string]map[int32]*partitionClaim)
+	cg.lock.Unlock()
+
+	for _, partitions := range claims {
+		for _, claim := range partitions {
+			close(claim.dying)
+			<-claim.dead
+
+			if err := claim.consumer.Close(); err != nil {
+				cg.handleError(err)
+			}
+			if err := claim.offsets.Close(); err != nil {
+				cg.handleError(err)
+			}
+			Logger.Printf("consumergroup/%s released %s/%d\n", cg.groupID, claim.topic, claim.partition)
+		}
+	}
+
+	if cg.offsets != nil {
+		if err := cg.offsets.Close(); err != nil {
+			cg.handleError(err)
+		}
+		cg.offsets = nil
+	}
+}
@@ -0,0 +1,99 @@
+package sarama
+
+// ConsumerGroupMemberMetadata holds the metadata a member of a consumer group sends to the
+// coordinator when joining, using the embedded protocol of the "consumer" protocol type. It is
+// forwarded to the group leader so that it can compute the partition assignment.
+type ConsumerGroupMemberMetadata struct {
+	Version  int16
+	Topics   []string
+	UserData []byte
+}
+
+func (m *ConsumerGroupMemberMetadata) encode(pe packetEncoder) error {
+	pe.putInt16(m.Version)
+
+	if err := pe.putArrayLength(len(m.Topics)); err != nil {
+		return err
+	}
+	for _, topic := range m.Topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+	}
+
+	return pe.putBytes(m.UserData)
+}
+
+func (m *ConsumerGroupMemberMetadata) decode(pd packetDecoder) (err error) {
+	if m.Version, err = pd.getInt16(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		m.Topics = make([]string, n)
+		for i := range m.Topics {
+			if m.Topics[i], err = pd.getString(); err != nil {
+				return err
+			}
+		}
+	}
+
+	m.UserData, err = pd.getBytes()
+	return err
+}
+
+// ConsumerGroupMemberAssignment holds the partitions the group leader assigned to a member of a
+// consumer group. It is distributed to the members by the coordinator in the SyncGroup response.
+type ConsumerGroupMemberAssignment struct {
+	Version  int16
+	Topics   map[string][]int32
+	UserData []byte
+}
+
+func (m *ConsumerGroupMemberAssignment) encode(pe packetEncoder) error {
+	pe.putInt16(m.Version)
+
+	if err := pe.putArrayLength(len(m.Topics)); err != nil {
+		return err
+	}
+	for topic, partitions := range m.Topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putInt32Array(partitions); err != nil {
+			return err
+		}
+	}
+
+	return pe.putBytes(m.UserData)
+}
+
+func (m *ConsumerGroupMemberAssignment) decode(pd packetDecoder) (err error) {
+	if m.Version, err = pd.getInt16(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		m.Topics = make(map[string][]int32, n)
+		for i := 0; i < n; i++ {
+			topic, err := pd.getString()
+			if err != nil {
+				return err
+			}
+			if m.Topics[topic], err = pd.getInt32Array(); err != nil {
+				return err
+			}
+		}
+	}
+
+	m.UserData, err = pd.getBytes()
+	return err
+}
@@ -0,0 +1,169 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newConsumerGroupTestHandlers(t *testing.T, broker *mockBroker, heartbeat KError) map[string]MockResponse {
+	memberMetadata, err := encode(&ConsumerGroupMemberMetadata{Topics: []string{"my_topic"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()).
+			SetLeader("my_topic", 1, broker.BrokerID()),
+		"ConsumerMetadataRequest": newMockConsumerMetadataResponse(t).
+			SetCoordinator("my_group", broker),
+		"JoinGroupRequest": newMockWrapper(&JoinGroupResponse{
+			GenerationID:  1,
+			GroupProtocol: "range",
+			LeaderID:      "my_member",
+			MemberID:      "my_member",
+			Members:       map[string][]byte{"my_member": memberMetadata},
+		}),
+		"SyncGroupRequest":  newMockSyncGroupResponse(t),
+		"HeartbeatRequest":  newMockWrapper(&HeartbeatResponse{Err: heartbeat}),
+		"LeaveGroupRequest": newMockWrapper(&LeaveGroupResponse{}),
+		"OffsetFetchRequest": newMockOffsetFetchResponse(t).
+			SetOffset("my_group", "my_topic", 0, 4, "", ErrNoError).
+			SetOffset("my_group", "my_topic", 1, 6, "", ErrNoError),
+		"OffsetCommitRequest": newMockOffsetCommitResponse(t),
+		"OffsetRequest": newMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 10).
+			SetOffset("my_topic", 1, OffsetOldest, 0).
+			SetOffset("my_topic", 1, OffsetNewest, 10),
+		"FetchRequest": newMockFetchResponse(t, 1).
+			SetMessage("my_topic", 0, 5, testMsg).
+			SetMessage("my_topic", 1, 7, testMsg).
+			SetHighWaterMark("my_topic", 0, 10).
+			SetHighWaterMark("my_topic", 1, 10),
+	}
+}
+
+func countRequests(broker *mockBroker, name string) int {
+	count := 0
+	for _, rr := range broker.History() {
+		if reflect.TypeOf(rr.Request).Elem().Name() == name {
+			count++
+		}
+	}
+	return count
+}
+
+func TestConsumerGroupJoinsConsumesAndLeaves(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.SetHandlerByMap(newConsumerGroupTestHandlers(t, broker0, ErrNoError))
+
+	config := NewConfig()
+	config.Version = V0_9_0_0
+	config.Consumer.Group.Heartbeat.Interval = 10 * time.Millisecond
+	config.Consumer.Offsets.CommitInterval = 10 * time.Millisecond
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my_group", []string{"my_topic"}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offsets := make(map[int32]int64)
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-group.Messages():
+			offsets[msg.Partition] = msg.Offset
+			group.MarkOffset(msg, "")
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for messages")
+		}
+	}
+	if offsets[0] != 5 || offsets[1] != 7 {
+		t.Error("Consumption did not resume from the committed offsets, got", offsets)
+	}
+
+	if claims := group.Claims(); !reflect.DeepEqual(claims, map[string][]int32{"my_topic": {0, 1}}) {
+		t.Error("Unexpected claims", claims)
+	}
+
+	safeClose(t, group)
+	// closing again, as a deferred Close would, must not panic nor leave the group twice
+	safeClose(t, group)
+
+	var commit *OffsetCommitRequest
+	for _, rr := range broker0.History() {
+		if req, ok := rr.Request.(*OffsetCommitRequest); ok {
+			commit = req
+		}
+	}
+	if commit == nil {
+		t.Fatal("Expected the marked offsets to be committed")
+	}
+	if commit.ConsumerID != "my_member" || commit.ConsumerGroupGeneration != 1 {
+		t.Error("Expected the commit to carry the group membership, got", commit.ConsumerID, commit.ConsumerGroupGeneration)
+	}
+	if countRequests(broker0, "LeaveGroupRequest") != 1 {
+		t.Error("Expected the member to leave the group on Close")
+	}
+}
+
+func TestConsumerGroupRejoinsOnRebalance(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.SetHandlerByMap(newConsumerGroupTestHandlers(t, broker0, ErrNoError))
+
+	config := NewConfig()
+	config.Version = V0_9_0_0
+	config.Consumer.Group.Heartbeat.Interval = 10 * time.Millisecond
+	config.Consumer.Group.Rebalance.Retry.Backoff = 10 * time.Millisecond
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my_group", []string{"my_topic"}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-group.Messages():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for messages")
+	}
+
+	// the coordinator asks the members to rejoin
+	broker0.SetHandlerByMap(newConsumerGroupTestHandlers(t, broker0, ErrRebalanceInProgress))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for countRequests(broker0, "JoinGroupRequest") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the member to rejoin")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	broker0.SetHandlerByMap(newConsumerGroupTestHandlers(t, broker0, ErrNoError))
+
+	safeClose(t, group)
+}
+
+func TestConsumerGroupValidation(t *testing.T) {
+	broker0 := newMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.Returns(new(MetadataResponse))
+
+	client, err := NewClient([]string{broker0.Addr()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	if _, err := NewConsumerGroupFromClient("my_group", []string{"my_topic"}, client); err == nil {
+		t.Error("Expected a configuration error for Kafka versions before 0.9")
+	}
+
+	client.Config().Version = V0_9_0_0
+	if _, err := NewConsumerGroupFromClient("my_group", nil, client); err == nil {
+		t.Error("Expected a configuration error")
+	}
+}
@@ -837,6 +837,352 @@ func TestConsumerOffsetOutOfRange(t *testing.T) {
 	broker0.Close()
 }
 
+func TestConsumerTimestamps(t *testing.T) {
+	createTime := time.Unix(1479847795, 0)
+	appendTime := time.Unix(1479847800, 0)
+
+	// two messages wrapped in a message of the v1 format, with offsets relative to the wrapper
+	inner := new(MessageSet)
+	inner.Messages = []*MessageBlock{
+		{Offset: 0, Msg: &Message{Version: 1, Timestamp: createTime, Value: []byte("foo")}},
+		{Offset: 1, Msg: &Message{Version: 1, Timestamp: createTime, Value: []byte("bar")}},
+	}
+	value, err := encode(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetchResponse := &FetchResponse{}
+	fetchResponse.AddError("my_topic", 0, ErrNoError)
+	fetchResponse.GetBlock("my_topic", 0).MsgSet.Messages = []*MessageBlock{
+		{Offset: 1233, Msg: &Message{Version: 1, Timestamp: createTime, Value: []byte("baz")}},
+		{Offset: 1235, Msg: &Message{Version: 1, Codec: CompressionGZIP, LogAppendTime: true, Timestamp: appendTime, Value: value}},
+	}
+
+	broker0 := newMockBroker(t, 0)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": newMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2345),
+		"FetchRequest": newMockWrapper(fetchResponse),
+	})
+
+	config := NewConfig()
+	config.Version = V0_10_0_0
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, 1233)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedTimestamps := []time.Time{createTime, appendTime, appendTime}
+	for i, offset := range []int64{1233, 1234, 1235} {
+		select {
+		case message := <-consumer.Messages():
+			assertMessageOffset(t, message, offset)
+			if !message.Timestamp.Equal(expectedTimestamps[i]) {
+				t.Errorf("Incorrect timestamp of message %d: expected=%v, actual=%v", offset, expectedTimestamps[i], message.Timestamp)
+			}
+		case err := <-consumer.Errors():
+			t.Error(err)
+		}
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+
+	for _, rr := range broker0.History() {
+		if req, ok := rr.Request.(*FetchRequest); ok && req.Version != 2 {
+			t.Error("Expected the fetch request to be sent at version 2, got", req.Version)
+		}
+	}
+}
+
+func TestConsumerThrottleMetric(t *testing.T) {
+	fetchResponse := &FetchResponse{ThrottleTime: 50 * time.Millisecond}
+	fetchResponse.AddMessage("my_topic", 0, nil, testMsg, 1)
+
+	broker0 := newMockBroker(t, 0)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": newMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2),
+		"FetchRequest": newMockWrapper(fetchResponse),
+	})
+
+	config := NewConfig()
+	config.Version = V0_10_0_0
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMessageOffset(t, <-consumer.Messages(), 1)
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+
+	histogram := getOrRegisterHistogram("fetch-throttle-time-in-ms-for-broker-0", config.MetricRegistry)
+	if histogram.Count() == 0 || histogram.Max() != 50 {
+		t.Errorf("Expected the fetch throttle time to be recorded, got %d with max %d", histogram.Count(), histogram.Max())
+	}
+}
+
+func TestConsumerThrottleDoesNotHoldUpClose(t *testing.T) {
+	fetchResponse := &FetchResponse{ThrottleTime: time.Minute}
+	fetchResponse.AddMessage("my_topic", 0, nil, testMsg, 1)
+
+	broker0 := newMockBroker(t, 0)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": newMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2),
+		"FetchRequest": newMockWrapper(fetchResponse),
+	})
+
+	config := NewConfig()
+	config.Version = V0_10_0_0
+	config.Consumer.BackoffOnThrottle = true
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMessageOffset(t, <-consumer.Messages(), 1)
+
+	start := time.Now()
+	safeClose(t, consumer)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Error("Expected closing the partition consumer to stop waiting out the throttling, took", elapsed)
+	}
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestConsumerMixedFormats(t *testing.T) {
+	timestamp := time.Unix(1479847795, 0)
+
+	fetchResponse := &FetchResponse{}
+	fetchResponse.AddMessage("my_topic", 0, nil, testMsg, 1233)
+	fetchResponse.AddRecord("my_topic", 0, nil, testMsg, 1234)
+	fetchResponse.AddRecord("my_topic", 0, nil, testMsg, 1235)
+	block := fetchResponse.GetBlock("my_topic", 0)
+	block.RecordBatches[0].FirstTimestamp = timestamp
+	block.RecordBatches[0].Records[1].TimestampDelta = time.Second
+	block.RecordBatches[0].Records[1].Headers = []*RecordHeader{{Key: []byte("trace"), Value: []byte("1")}}
+	// a transaction marker, which is not returned but still moves the consumer on
+	block.RecordBatches = append(block.RecordBatches, &RecordBatch{
+		Version:     2,
+		FirstOffset: 1236,
+		Control:     true,
+		Records:     []*Record{{Key: []byte{0, 0, 0, 1}, Value: []byte{0, 0, 0, 0, 0, 0}}},
+	})
+
+	broker0 := newMockBroker(t, 0)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": newMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2345),
+		"FetchRequest": newMockWrapper(fetchResponse),
+	})
+
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, 1233)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []*ConsumerMessage
+	for i := 0; i < 3; i++ {
+		select {
+		case message := <-consumer.Messages():
+			assertMessageOffset(t, message, int64(1233+i))
+			messages = append(messages, message)
+		case err := <-consumer.Errors():
+			t.Error(err)
+		}
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+
+	if len(messages) != 3 {
+		t.FailNow()
+	}
+	if !messages[2].Timestamp.Equal(timestamp.Add(time.Second)) {
+		t.Error("Incorrect timestamp of the last record:", messages[2].Timestamp)
+	}
+	if len(messages[2].Headers) != 1 || string(messages[2].Headers[0].Key) != "trace" {
+		t.Error("Incorrect headers of the last record:", messages[2].Headers)
+	}
+	if consumer.(*partitionConsumer).offset != 1237 {
+		t.Error("Expected the consumer to move past the control batch, at", consumer.(*partitionConsumer).offset)
+	}
+}
+
+func TestConsumerReadCommitted(t *testing.T) {
+	transactionalBatch := func(producerID, offset int64, records int, control bool) *RecordBatch {
+		batch := &RecordBatch{
+			Version:         2,
+			FirstOffset:     offset,
+			LastOffsetDelta: int32(records - 1),
+			IsTransactional: true,
+			Control:         control,
+			ProducerID:      producerID,
+		}
+		for i := 0; i < records; i++ {
+			batch.addRecord(&Record{OffsetDelta: int64(i), Value: []byte(testMsg)})
+		}
+		return batch
+	}
+	abortMarker := transactionalBatch(7, 1236, 1, true)
+	abortMarker.Records[0].Key = []byte{0, 0, 0, 0}
+	commitMarker := transactionalBatch(8, 1237, 1, true)
+	commitMarker.Records[0].Key = []byte{0, 0, 0, 1}
+
+	// producer 7 aborts its first transaction and commits its second one, producer 8
+	// commits its transaction in between
+	fetchResponse := &FetchResponse{}
+	fetchResponse.AddError("my_topic", 0, ErrNoError)
+	block := fetchResponse.GetBlock("my_topic", 0)
+	block.AbortedTransactions = []*AbortedTransaction{{ProducerID: 7, FirstOffset: 1233}}
+	block.RecordBatches = []*RecordBatch{
+		transactionalBatch(7, 1233, 2, false),
+		transactionalBatch(8, 1235, 1, false),
+		abortMarker,
+		commitMarker,
+		transactionalBatch(7, 1238, 1, false),
+	}
+
+	broker0 := newMockBroker(t, 0)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": newMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2345),
+		"FetchRequest": newMockWrapper(fetchResponse),
+	})
+
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	config.Consumer.IsolationLevel = ReadCommitted
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, 1233)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, offset := range []int64{1235, 1238} {
+		select {
+		case message := <-consumer.Messages():
+			assertMessageOffset(t, message, offset)
+		case err := <-consumer.Errors():
+			t.Error(err)
+		}
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+
+	if consumer.(*partitionConsumer).offset != 1239 {
+		t.Error("Expected the consumer to move past the last batch, at", consumer.(*partitionConsumer).offset)
+	}
+	for _, rr := range broker0.History() {
+		if req, ok := rr.Request.(*FetchRequest); ok && req.Isolation != ReadCommitted {
+			t.Error("Expected the fetch request to read committed records, got", req.Isolation)
+		}
+	}
+}
+
+func TestConsumerZSTD(t *testing.T) {
+	fetchResponse := &FetchResponse{}
+	fetchResponse.AddRecord("my_topic", 0, nil, testMsg, 1233)
+	fetchResponse.AddRecord("my_topic", 0, nil, testMsg, 1234)
+	fetchResponse.GetBlock("my_topic", 0).RecordBatches[0].Codec = CompressionZSTD
+
+	broker0 := newMockBroker(t, 0)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": newMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2345),
+		"FetchRequest": newMockWrapper(fetchResponse),
+	})
+
+	config := NewConfig()
+	config.Version = V2_1_0_0
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, 1233)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case message := <-consumer.Messages():
+			assertMessageOffset(t, message, int64(1233+i))
+		case err := <-consumer.Errors():
+			t.Error(err)
+		}
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+
+	for _, rr := range broker0.History() {
+		if req, ok := rr.Request.(*FetchRequest); ok && req.Version != 10 {
+			t.Error("Expected fetch requests to be sent at version 10, got", req.Version)
+		}
+	}
+}
+
 func assertMessageOffset(t *testing.T, msg *ConsumerMessage, expectedOffset int64) {
 	if msg.Offset != expectedOffset {
 		t.Errorf("Incorrect message offset: expected=%d, actual=%d", expectedOffset, msg.Offset)
@@ -6,9 +6,26 @@ import (
 	"github.com/klauspost/crc32"
 )
 
+type crcPolynomial int8
+
+const (
+	crcIEEE       crcPolynomial = iota // used by the v0 and v1 message formats
+	crcCastagnoli                      // used by the v2 record batch format
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
 // crc32Field implements the pushEncoder and pushDecoder interfaces for calculating CRC32s.
 type crc32Field struct {
 	startOffset int
+	polynomial  crcPolynomial
+}
+
+func (c *crc32Field) crc(data []byte) uint32 {
+	if c.polynomial == crcCastagnoli {
+		return crc32.Checksum(data, castagnoliTable)
+	}
+	return crc32.ChecksumIEEE(data)
 }
 
 func (c *crc32Field) saveOffset(in int) {
@@ -20,13 +37,13 @@ func (c *crc32Field) reserveLength() int {
 }
 
 func (c *crc32Field) run(curOffset int, buf []byte) error {
-	crc := crc32.ChecksumIEEE(buf[c.startOffset+4 : curOffset])
+	crc := c.crc(buf[c.startOffset+4 : curOffset])
 	binary.BigEndian.PutUint32(buf[c.startOffset:], crc)
 	return nil
 }
 
 func (c *crc32Field) check(curOffset int, buf []byte) error {
-	crc := crc32.ChecksumIEEE(buf[c.startOffset+4 : curOffset])
+	crc := c.crc(buf[c.startOffset+4 : curOffset])
 
 	if crc != binary.BigEndian.Uint32(buf[c.startOffset:]) {
 		return PacketDecodingError{"CRC didn't match"}
@@ -0,0 +1,129 @@
+package sarama
+
+import "time"
+
+// TopicPartition describes the partitions to add to a topic.
+type TopicPartition struct {
+	// Count is the total number of partitions the topic should have once the new ones are added.
+	Count int32
+	// Assignment lists the IDs of the brokers holding the replicas of each new partition, the
+	// first one being the preferred leader. When nil, the controller picks the brokers itself.
+	Assignment [][]int32
+}
+
+func (t *TopicPartition) encode(pe packetEncoder) error {
+	pe.putInt32(t.Count)
+
+	if t.Assignment == nil {
+		pe.putInt32(-1)
+		return nil
+	}
+	if err := pe.putArrayLength(len(t.Assignment)); err != nil {
+		return err
+	}
+	for _, replicas := range t.Assignment {
+		if err := pe.putInt32Array(replicas); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *TopicPartition) decode(pd packetDecoder) (err error) {
+	if t.Count, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	n, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		t.Assignment = nil
+		return nil
+	}
+	if int(n)*4 > pd.remaining() {
+		return ErrInsufficientData
+	}
+
+	t.Assignment = make([][]int32, n)
+	for i := range t.Assignment {
+		if t.Assignment[i], err = pd.getInt32Array(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type CreatePartitionsRequest struct {
+	TopicPartitions map[string]*TopicPartition
+	// Timeout is how long the controller waits for the partitions to be created.
+	Timeout time.Duration
+	// ValidateOnly asks the controller to check the request without creating anything.
+	ValidateOnly bool
+}
+
+func (r *CreatePartitionsRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.TopicPartitions)); err != nil {
+		return err
+	}
+	for topic, partition := range r.TopicPartitions {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := partition.encode(pe); err != nil {
+			return err
+		}
+	}
+
+	pe.putInt32(int32(r.Timeout / time.Millisecond))
+	pe.putBool(r.ValidateOnly)
+
+	return nil
+}
+
+func (r *CreatePartitionsRequest) decode(pd packetDecoder) (err error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.TopicPartitions = make(map[string]*TopicPartition, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		partition := new(TopicPartition)
+		if err := partition.decode(pd); err != nil {
+			return err
+		}
+		r.TopicPartitions[topic] = partition
+	}
+
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.Timeout = time.Duration(timeout) * time.Millisecond
+
+	if r.ValidateOnly, err = pd.getBool(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *CreatePartitionsRequest) key() int16 {
+	return 37
+}
+
+func (r *CreatePartitionsRequest) version() int16 {
+	return 0
+}
+
+func (r *CreatePartitionsRequest) requiredVersion() KafkaVersion {
+	return V1_0_0_0
+}
@@ -0,0 +1,43 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	createPartitionsRequestNoAssignment = []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x03,
+		0xFF, 0xFF, 0xFF, 0xFF, // assignment (null)
+		0x00, 0x00, 0x00, 0x64,
+		0x00}
+
+	createPartitionsRequestAssignment = []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x03,
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03,
+		0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x64,
+		0x01}
+)
+
+func TestCreatePartitionsRequest(t *testing.T) {
+	request := &CreatePartitionsRequest{
+		TopicPartitions: map[string]*TopicPartition{"topic": {Count: 3}},
+		Timeout:         100 * time.Millisecond,
+	}
+	testRequest(t, "no assignment", request, createPartitionsRequestNoAssignment)
+
+	request = &CreatePartitionsRequest{
+		TopicPartitions: map[string]*TopicPartition{
+			"topic": {Count: 3, Assignment: [][]int32{{2, 3}, {3, 1}}},
+		},
+		Timeout:      100 * time.Millisecond,
+		ValidateOnly: true,
+	}
+	testRequest(t, "assignment", request, createPartitionsRequestAssignment)
+}
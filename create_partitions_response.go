@@ -0,0 +1,59 @@
+package sarama
+
+import "time"
+
+type CreatePartitionsResponse struct {
+	ThrottleTime         time.Duration // the time the request was throttled for by a quota
+	TopicPartitionErrors map[string]*TopicError
+}
+
+func (r *CreatePartitionsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+
+	if err := pe.putArrayLength(len(r.TopicPartitionErrors)); err != nil {
+		return err
+	}
+	for topic, topicErr := range r.TopicPartitionErrors {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		pe.putInt16(int16(topicErr.Err))
+		if err := pe.putNullableString(topicErr.ErrMsg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *CreatePartitionsResponse) decode(pd packetDecoder) (err error) {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.TopicPartitionErrors = make(map[string]*TopicError, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		topicErr := &TopicError{Err: KError(kerr)}
+		if topicErr.ErrMsg, err = pd.getNullableString(); err != nil {
+			return err
+		}
+		r.TopicPartitionErrors[topic] = topicErr
+	}
+
+	return nil
+}
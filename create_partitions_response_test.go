@@ -0,0 +1,24 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var createPartitionsResponse = []byte{
+	0x00, 0x00, 0x00, 0x64,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+	0x00, 0x25,
+	0x00, 0x03, 'm', 's', 'g'}
+
+func TestCreatePartitionsResponse(t *testing.T) {
+	msg := "msg"
+	response := &CreatePartitionsResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		TopicPartitionErrors: map[string]*TopicError{
+			"topic": {Err: ErrInvalidPartitions, ErrMsg: &msg},
+		},
+	}
+	testResponse(t, "one topic", response, createPartitionsResponse)
+}
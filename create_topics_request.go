@@ -0,0 +1,199 @@
+package sarama
+
+import "time"
+
+// TopicDetail describes a topic to create. Either NumPartitions and ReplicationFactor, or
+// ReplicaAssignment must be given; the fields that are not must be -1.
+type TopicDetail struct {
+	NumPartitions     int32
+	ReplicationFactor int16
+	// ReplicaAssignment maps each partition to the IDs of the brokers holding its replicas,
+	// the first one being the preferred leader.
+	ReplicaAssignment map[int32][]int32
+	// ConfigEntries overrides the broker's topic configuration, eg. "cleanup.policy" or "retention.ms".
+	ConfigEntries map[string]*string
+}
+
+func (t *TopicDetail) encode(pe packetEncoder) error {
+	pe.putInt32(t.NumPartitions)
+	pe.putInt16(t.ReplicationFactor)
+
+	if err := pe.putArrayLength(len(t.ReplicaAssignment)); err != nil {
+		return err
+	}
+	for partition, replicas := range t.ReplicaAssignment {
+		pe.putInt32(partition)
+		if err := pe.putInt32Array(replicas); err != nil {
+			return err
+		}
+	}
+
+	if err := pe.putArrayLength(len(t.ConfigEntries)); err != nil {
+		return err
+	}
+	for name, value := range t.ConfigEntries {
+		if err := pe.putString(name); err != nil {
+			return err
+		}
+		if err := pe.putNullableString(value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *TopicDetail) decode(pd packetDecoder) (err error) {
+	if t.NumPartitions, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if t.ReplicationFactor, err = pd.getInt16(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		t.ReplicaAssignment = make(map[int32][]int32, n)
+		for i := 0; i < n; i++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			if t.ReplicaAssignment[partition], err = pd.getInt32Array(); err != nil {
+				return err
+			}
+		}
+	}
+
+	n, err = pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		t.ConfigEntries = make(map[string]*string, n)
+		for i := 0; i < n; i++ {
+			name, err := pd.getString()
+			if err != nil {
+				return err
+			}
+			if t.ConfigEntries[name], err = pd.getNullableString(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type CreateTopicsRequest struct {
+	TopicDetails map[string]*TopicDetail
+	// Timeout is how long the controller waits for the topics to be created, a
+	// timed out topic is reported with ErrRequestTimedOut but may still appear later.
+	Timeout time.Duration
+	// ValidateOnly asks the controller to check the request without creating anything (version 1+ only).
+	ValidateOnly bool
+	// Version can be:
+	// - 0 (kafka 0.10.1 and later)
+	// - 1 (kafka 0.11 and later, adds ValidateOnly and error messages)
+	// - 2 (kafka 1.0 and later, the response carries the throttle time)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version int16
+}
+
+func (r *CreateTopicsRequest) encode(pe packetEncoder) error {
+	if r.Version < 0 || r.Version > 2 {
+		return PacketEncodingError{"invalid or unsupported CreateTopicsRequest version field"}
+	}
+	if r.ValidateOnly && r.Version < 1 {
+		return PacketEncodingError{"CreateTopicsRequest version 0 cannot validate only"}
+	}
+
+	if err := pe.putArrayLength(len(r.TopicDetails)); err != nil {
+		return err
+	}
+	for topic, detail := range r.TopicDetails {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := detail.encode(pe); err != nil {
+			return err
+		}
+	}
+
+	pe.putInt32(int32(r.Timeout / time.Millisecond))
+
+	if r.Version >= 1 {
+		pe.putBool(r.ValidateOnly)
+	}
+
+	return nil
+}
+
+func (r *CreateTopicsRequest) decode(pd packetDecoder) (err error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.TopicDetails = make(map[string]*TopicDetail, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		detail := new(TopicDetail)
+		if err := detail.decode(pd); err != nil {
+			return err
+		}
+		r.TopicDetails[topic] = detail
+	}
+
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.Timeout = time.Duration(timeout) * time.Millisecond
+
+	if r.Version >= 1 {
+		if r.ValidateOnly, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *CreateTopicsRequest) key() int16 {
+	return 19
+}
+
+func (r *CreateTopicsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *CreateTopicsRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *CreateTopicsRequest) shallowCopy() versionedRequest {
+	c := *r
+	return &c
+}
+
+func (r *CreateTopicsRequest) maxVersion() int16 {
+	return 2
+}
+
+func (r *CreateTopicsRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	case 2:
+		return V1_0_0_0
+	default:
+		return V0_10_1_0
+	}
+}
@@ -0,0 +1,63 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	createTopicsRequestV0 = []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0xFF, 0xFF, 0xFF, 0xFF, // num partitions
+		0xFF, 0xFF, // replication factor
+		0x00, 0x00, 0x00, 0x01, // replica assignment
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x01, // config entries
+		0x00, 0x0C, 'r', 'e', 't', 'e', 'n', 't', 'i', 'o', 'n', '.', 'm', 's',
+		0x00, 0x02, '-', '1',
+		0x00, 0x00, 0x00, 0x64} // timeout
+
+	createTopicsRequestV1 = []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x03,
+		0x00, 0x02,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x64,
+		0x01} // validate only
+)
+
+func TestCreateTopicsRequest(t *testing.T) {
+	retention := "-1"
+
+	request := &CreateTopicsRequest{
+		TopicDetails: map[string]*TopicDetail{
+			"topic": {
+				NumPartitions:     -1,
+				ReplicationFactor: -1,
+				ReplicaAssignment: map[int32][]int32{0: {0, 1}},
+				ConfigEntries:     map[string]*string{"retention.ms": &retention},
+			},
+		},
+		Timeout: 100 * time.Millisecond,
+	}
+	testRequest(t, "version 0", request, createTopicsRequestV0)
+
+	request = &CreateTopicsRequest{
+		TopicDetails: map[string]*TopicDetail{
+			"topic": {NumPartitions: 3, ReplicationFactor: 2},
+		},
+		Timeout:      100 * time.Millisecond,
+		ValidateOnly: true,
+		Version:      1,
+	}
+	testRequest(t, "version 1", request, createTopicsRequestV1)
+
+	request.Version = 0
+	if _, err := encode(request); err == nil {
+		t.Error("Expected ValidateOnly to be refused at version 0")
+	}
+}
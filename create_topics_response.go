@@ -0,0 +1,90 @@
+package sarama
+
+import (
+	"fmt"
+	"time"
+)
+
+// TopicError is the outcome of an admin request for a single topic, or other resource.
+type TopicError struct {
+	Err    KError
+	ErrMsg *string // a more detailed message, not sent by every broker or request version
+}
+
+func (t *TopicError) Error() string {
+	text := t.Err.Error()
+	if t.ErrMsg != nil {
+		text = fmt.Sprintf("%s - %s", text, *t.ErrMsg)
+	}
+	return text
+}
+
+type CreateTopicsResponse struct {
+	// Version must match the version of the request, it is set by the Broker before decoding
+	Version      int16
+	ThrottleTime time.Duration // the time the request was throttled for by a quota (version 2+ only)
+	TopicErrors  map[string]*TopicError
+}
+
+func (r *CreateTopicsResponse) encode(pe packetEncoder) error {
+	if r.Version >= 2 {
+		pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	}
+
+	if err := pe.putArrayLength(len(r.TopicErrors)); err != nil {
+		return err
+	}
+	for topic, topicErr := range r.TopicErrors {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		pe.putInt16(int16(topicErr.Err))
+		if r.Version >= 1 {
+			if err := pe.putNullableString(topicErr.ErrMsg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *CreateTopicsResponse) decode(pd packetDecoder) (err error) {
+	if r.Version >= 2 {
+		millis, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		r.ThrottleTime = time.Duration(millis) * time.Millisecond
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.TopicErrors = make(map[string]*TopicError, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		topicErr := &TopicError{Err: KError(kerr)}
+		if r.Version >= 1 {
+			if topicErr.ErrMsg, err = pd.getNullableString(); err != nil {
+				return err
+			}
+		}
+		r.TopicErrors[topic] = topicErr
+	}
+
+	return nil
+}
+
+func (r *CreateTopicsResponse) setVersion(v int16) {
+	r.Version = v
+}
@@ -0,0 +1,45 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	createTopicsResponseV0 = []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x24}
+
+	createTopicsResponseV2 = []byte{
+		0x00, 0x00, 0x00, 0x64,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x2A,
+		0x00, 0x03, 'm', 's', 'g'}
+)
+
+func TestCreateTopicsResponse(t *testing.T) {
+	response := &CreateTopicsResponse{
+		TopicErrors: map[string]*TopicError{"topic": {Err: ErrTopicAlreadyExists}},
+	}
+	testResponse(t, "version 0", response, createTopicsResponseV0)
+
+	msg := "msg"
+	response = &CreateTopicsResponse{
+		Version:      2,
+		ThrottleTime: 100 * time.Millisecond,
+		TopicErrors:  map[string]*TopicError{"topic": {Err: ErrInvalidRequest, ErrMsg: &msg}},
+	}
+	testEncodable(t, "version 2", response, createTopicsResponseV2)
+	decoded := &CreateTopicsResponse{Version: 2}
+	testDecodable(t, "version 2", decoded, createTopicsResponseV2)
+	if !reflect.DeepEqual(decoded, response) {
+		t.Errorf("Decoding produced %#v", decoded)
+	}
+
+	if err := decoded.TopicErrors["topic"].Error(); err != ErrInvalidRequest.Error()+" - msg" {
+		t.Error("Unexpected topic error message", err)
+	}
+}
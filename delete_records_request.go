@@ -0,0 +1,83 @@
+package sarama
+
+import "time"
+
+// DeleteRecordsRequest deletes the records of partitions before the given offsets, moving their
+// low watermarks up. It is sent to the leaders of the partitions.
+type DeleteRecordsRequest struct {
+	// Topics holds, for each partition, the offset before which its records are deleted. The
+	// offset -1 stands for the high watermark, deleting every record of the partition.
+	Topics map[string]map[int32]int64
+	// Timeout is how long the leaders wait for the followers to delete the records too.
+	Timeout time.Duration
+}
+
+func (r *DeleteRecordsRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.Topics)); err != nil {
+		return err
+	}
+	for topic, partitions := range r.Topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for partition, offset := range partitions {
+			pe.putInt32(partition)
+			pe.putInt64(offset)
+		}
+	}
+	pe.putInt32(int32(r.Timeout / time.Millisecond))
+
+	return nil
+}
+
+func (r *DeleteRecordsRequest) decode(pd packetDecoder) (err error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.Topics = make(map[string]map[int32]int64, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		r.Topics[topic] = make(map[int32]int64, m)
+		for j := 0; j < m; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			if r.Topics[topic][partition], err = pd.getInt64(); err != nil {
+				return err
+			}
+		}
+	}
+
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.Timeout = time.Duration(timeout) * time.Millisecond
+
+	return nil
+}
+
+func (r *DeleteRecordsRequest) key() int16 {
+	return 21
+}
+
+func (r *DeleteRecordsRequest) version() int16 {
+	return 0
+}
+
+func (r *DeleteRecordsRequest) requiredVersion() KafkaVersion {
+	return V0_11_0_0
+}
@@ -0,0 +1,22 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var deleteRecordsRequest = []byte{
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC8,
+	0x00, 0x00, 0x00, 0x64}
+
+func TestDeleteRecordsRequest(t *testing.T) {
+	request := &DeleteRecordsRequest{
+		Topics:  map[string]map[int32]int64{"topic": {1: 200}},
+		Timeout: 100 * time.Millisecond,
+	}
+	testRequest(t, "", request, deleteRecordsRequest)
+}
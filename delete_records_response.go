@@ -0,0 +1,92 @@
+package sarama
+
+import "time"
+
+type DeleteRecordsResponsePartition struct {
+	LowWatermark int64 // the first offset of the partition once the records were deleted
+	Err          KError
+}
+
+type DeleteRecordsResponse struct {
+	ThrottleTime time.Duration // the time the request was throttled for by a quota
+	Topics       map[string]map[int32]*DeleteRecordsResponsePartition
+}
+
+func (r *DeleteRecordsResponse) AddPartition(topic string, partition int32, lowWatermark int64, kerror KError) {
+	if r.Topics == nil {
+		r.Topics = make(map[string]map[int32]*DeleteRecordsResponsePartition)
+	}
+	partitions := r.Topics[topic]
+	if partitions == nil {
+		partitions = make(map[int32]*DeleteRecordsResponsePartition)
+		r.Topics[topic] = partitions
+	}
+	partitions[partition] = &DeleteRecordsResponsePartition{LowWatermark: lowWatermark, Err: kerror}
+}
+
+func (r *DeleteRecordsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+
+	if err := pe.putArrayLength(len(r.Topics)); err != nil {
+		return err
+	}
+	for topic, partitions := range r.Topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for partition, block := range partitions {
+			pe.putInt32(partition)
+			pe.putInt64(block.LowWatermark)
+			pe.putInt16(int16(block.Err))
+		}
+	}
+
+	return nil
+}
+
+func (r *DeleteRecordsResponse) decode(pd packetDecoder) (err error) {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.Topics = make(map[string]map[int32]*DeleteRecordsResponsePartition, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		r.Topics[topic] = make(map[int32]*DeleteRecordsResponsePartition, m)
+		for j := 0; j < m; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			block := new(DeleteRecordsResponsePartition)
+			if block.LowWatermark, err = pd.getInt64(); err != nil {
+				return err
+			}
+			kerr, err := pd.getInt16()
+			if err != nil {
+				return err
+			}
+			block.Err = KError(kerr)
+			r.Topics[topic][partition] = block
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var deleteRecordsResponse = []byte{
+	0x00, 0x00, 0x00, 0x64,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x01,
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+	0x00, 0x01}
+
+func TestDeleteRecordsResponse(t *testing.T) {
+	response := &DeleteRecordsResponse{ThrottleTime: 100 * time.Millisecond}
+	response.AddPartition("topic", 1, -1, ErrOffsetOutOfRange)
+	testResponse(t, "", response, deleteRecordsResponse)
+}
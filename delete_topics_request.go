@@ -0,0 +1,72 @@
+package sarama
+
+import "time"
+
+type DeleteTopicsRequest struct {
+	Topics []string
+	// Timeout is how long the controller waits for the topics to be deleted, a
+	// timed out topic is reported with ErrRequestTimedOut but may still disappear later.
+	Timeout time.Duration
+	// Version can be:
+	// - 0 (kafka 0.10.1 and later)
+	// - 1 (kafka 0.11 and later, the response carries the throttle time)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version int16
+}
+
+func (r *DeleteTopicsRequest) encode(pe packetEncoder) error {
+	if r.Version < 0 || r.Version > 1 {
+		return PacketEncodingError{"invalid or unsupported DeleteTopicsRequest version field"}
+	}
+
+	if err := pe.putStringArray(r.Topics); err != nil {
+		return err
+	}
+	pe.putInt32(int32(r.Timeout / time.Millisecond))
+
+	return nil
+}
+
+func (r *DeleteTopicsRequest) decode(pd packetDecoder) (err error) {
+	if r.Topics, err = pd.getStringArray(); err != nil {
+		return err
+	}
+
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.Timeout = time.Duration(timeout) * time.Millisecond
+
+	return nil
+}
+
+func (r *DeleteTopicsRequest) key() int16 {
+	return 20
+}
+
+func (r *DeleteTopicsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DeleteTopicsRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *DeleteTopicsRequest) shallowCopy() versionedRequest {
+	c := *r
+	return &c
+}
+
+func (r *DeleteTopicsRequest) maxVersion() int16 {
+	return 1
+}
+
+func (r *DeleteTopicsRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	default:
+		return V0_10_1_0
+	}
+}
@@ -0,0 +1,26 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var deleteTopicsRequest = []byte{
+	0x00, 0x00, 0x00, 0x02,
+	0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+	0x00, 0x05, 'o', 't', 'h', 'e', 'r',
+	0x00, 0x00, 0x00, 0x64}
+
+func TestDeleteTopicsRequest(t *testing.T) {
+	request := &DeleteTopicsRequest{
+		Topics:  []string{"topic", "other"},
+		Timeout: 100 * time.Millisecond,
+	}
+	testRequest(t, "version 0", request, deleteTopicsRequest)
+
+	request.Version = 1
+	testRequest(t, "version 1", request, deleteTopicsRequest)
+	if request.requiredVersion() != V0_11_0_0 {
+		t.Error("Expected version 1 to require Kafka 0.11, got", request.requiredVersion())
+	}
+}
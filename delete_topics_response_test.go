@@ -0,0 +1,39 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	deleteTopicsResponseV0 = []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x03}
+
+	deleteTopicsResponseV1 = []byte{
+		0x00, 0x00, 0x00, 0x64,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00}
+)
+
+func TestDeleteTopicsResponse(t *testing.T) {
+	response := &DeleteTopicsResponse{
+		TopicErrorCodes: map[string]KError{"topic": ErrUnknownTopicOrPartition},
+	}
+	testResponse(t, "version 0", response, deleteTopicsResponseV0)
+
+	response = &DeleteTopicsResponse{
+		Version:         1,
+		ThrottleTime:    100 * time.Millisecond,
+		TopicErrorCodes: map[string]KError{"topic": ErrNoError},
+	}
+	testEncodable(t, "version 1", response, deleteTopicsResponseV1)
+	decoded := &DeleteTopicsResponse{Version: 1}
+	testDecodable(t, "version 1", decoded, deleteTopicsResponseV1)
+	if !reflect.DeepEqual(decoded, response) {
+		t.Errorf("Decoding produced %#v", decoded)
+	}
+}
@@ -0,0 +1,157 @@
+package sarama
+
+// ConfigResourceType is the kind of resource a configuration belongs to.
+type ConfigResourceType int8
+
+const (
+	UnknownResource ConfigResourceType = 0
+	AnyResource     ConfigResourceType = 1
+	TopicResource   ConfigResourceType = 2
+	GroupResource   ConfigResourceType = 3
+	// BrokerResource is named by the ID of the broker, eg. "1"
+	BrokerResource ConfigResourceType = 4
+)
+
+// ConfigResource names a topic or broker along with the configuration entries to describe.
+type ConfigResource struct {
+	Type ConfigResourceType
+	Name string
+	// ConfigNames are the entries to describe, nil for all of them.
+	ConfigNames []string
+}
+
+func (r *ConfigResource) encode(pe packetEncoder) error {
+	pe.putInt8(int8(r.Type))
+
+	if err := pe.putString(r.Name); err != nil {
+		return err
+	}
+
+	if r.ConfigNames == nil {
+		pe.putInt32(-1)
+		return nil
+	}
+	return pe.putStringArray(r.ConfigNames)
+}
+
+func (r *ConfigResource) decode(pd packetDecoder) (err error) {
+	t, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	r.Type = ConfigResourceType(t)
+
+	if r.Name, err = pd.getString(); err != nil {
+		return err
+	}
+
+	n, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		r.ConfigNames = nil
+		return nil
+	}
+	if int(n)*2 > pd.remaining() {
+		return ErrInsufficientData
+	}
+
+	r.ConfigNames = make([]string, n)
+	for i := range r.ConfigNames {
+		if r.ConfigNames[i], err = pd.getString(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type DescribeConfigsRequest struct {
+	Resources []*ConfigResource
+	// IncludeSynonyms asks for the other definitions of each entry, eg. the broker default
+	// of a topic setting, in order of precedence (version 1+ only).
+	IncludeSynonyms bool
+	// Version can be:
+	// - 0 (kafka 0.11 and later)
+	// - 1 (kafka 1.1 and later, the response carries the source and synonyms of the entries)
+	// - 2 (kafka 2.0 and later)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version int16
+}
+
+func (r *DescribeConfigsRequest) encode(pe packetEncoder) error {
+	if r.Version < 0 || r.Version > 2 {
+		return PacketEncodingError{"invalid or unsupported DescribeConfigsRequest version field"}
+	}
+
+	if err := pe.putArrayLength(len(r.Resources)); err != nil {
+		return err
+	}
+	for _, resource := range r.Resources {
+		if err := resource.encode(pe); err != nil {
+			return err
+		}
+	}
+
+	if r.Version >= 1 {
+		pe.putBool(r.IncludeSynonyms)
+	}
+
+	return nil
+}
+
+func (r *DescribeConfigsRequest) decode(pd packetDecoder) (err error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.Resources = make([]*ConfigResource, n)
+	for i := range r.Resources {
+		r.Resources[i] = new(ConfigResource)
+		if err := r.Resources[i].decode(pd); err != nil {
+			return err
+		}
+	}
+
+	if r.Version >= 1 {
+		if r.IncludeSynonyms, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *DescribeConfigsRequest) key() int16 {
+	return 32
+}
+
+func (r *DescribeConfigsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeConfigsRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *DescribeConfigsRequest) shallowCopy() versionedRequest {
+	c := *r
+	return &c
+}
+
+func (r *DescribeConfigsRequest) maxVersion() int16 {
+	return 2
+}
+
+func (r *DescribeConfigsRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V1_1_0_0
+	case 2:
+		return V2_0_0_0
+	default:
+		return V0_11_0_0
+	}
+}
@@ -0,0 +1,42 @@
+package sarama
+
+import "testing"
+
+var (
+	describeConfigsRequestAll = []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x02, // topic
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0xFF, 0xFF, 0xFF, 0xFF} // all config names
+
+	describeConfigsRequestV1 = []byte{
+		0x00, 0x00, 0x00, 0x02,
+		0x02,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x0C, 'r', 'e', 't', 'e', 'n', 't', 'i', 'o', 'n', '.', 'm', 's',
+		0x04, // broker
+		0x00, 0x01, '1',
+		0x00, 0x00, 0x00, 0x00,
+		0x01} // include synonyms
+)
+
+func TestDescribeConfigsRequest(t *testing.T) {
+	request := &DescribeConfigsRequest{
+		Resources: []*ConfigResource{{Type: TopicResource, Name: "topic"}},
+	}
+	testRequest(t, "all configs", request, describeConfigsRequestAll)
+
+	request = &DescribeConfigsRequest{
+		Resources: []*ConfigResource{
+			{Type: TopicResource, Name: "topic", ConfigNames: []string{"retention.ms"}},
+			{Type: BrokerResource, Name: "1", ConfigNames: []string{}},
+		},
+		IncludeSynonyms: true,
+		Version:         1,
+	}
+	testRequest(t, "version 1", request, describeConfigsRequestV1)
+	if request.requiredVersion() != V1_1_0_0 {
+		t.Error("Expected version 1 to require Kafka 1.1, got", request.requiredVersion())
+	}
+}
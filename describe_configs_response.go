@@ -0,0 +1,263 @@
+package sarama
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConfigSource tells where the value of a configuration entry comes from.
+type ConfigSource int8
+
+const (
+	SourceUnknown ConfigSource = iota
+	// SourceTopic is a dynamic topic config, set for the topic itself
+	SourceTopic
+	// SourceDynamicBroker is a dynamic broker config, set for the broker itself
+	SourceDynamicBroker
+	// SourceDynamicDefaultBroker is a dynamic broker config, set as the default of every broker
+	SourceDynamicDefaultBroker
+	// SourceStaticBroker is a broker config read from `server.properties`
+	SourceStaticBroker
+	// SourceDefault is the default value, no config setting it
+	SourceDefault
+)
+
+func (s ConfigSource) String() string {
+	switch s {
+	case SourceUnknown:
+		return "Unknown"
+	case SourceTopic:
+		return "Topic"
+	case SourceDynamicBroker:
+		return "DynamicBroker"
+	case SourceDynamicDefaultBroker:
+		return "DynamicDefaultBroker"
+	case SourceStaticBroker:
+		return "StaticBroker"
+	case SourceDefault:
+		return "Default"
+	default:
+		return fmt.Sprintf("unknown(%d)", int8(s))
+	}
+}
+
+// ConfigSynonym is another definition of a configuration entry, which it would fall back to.
+type ConfigSynonym struct {
+	Name   string
+	Value  string
+	Source ConfigSource
+}
+
+// ConfigEntry is a configuration entry of a topic or broker.
+type ConfigEntry struct {
+	Name  string
+	Value string // empty for sensitive entries, which the brokers do not disclose
+	// ReadOnly entries cannot be changed through AlterConfigs.
+	ReadOnly bool
+	// Default tells whether the entry has its default value. Version 0 responses only carry this
+	// flag, from which Source is set to SourceDefault or SourceUnknown.
+	Default   bool
+	Source    ConfigSource
+	Sensitive bool
+	// Synonyms, in order of precedence, are only sent when asked for (version 1+ only).
+	Synonyms []*ConfigSynonym
+}
+
+func (e *ConfigEntry) encode(pe packetEncoder, version int16) error {
+	if err := pe.putString(e.Name); err != nil {
+		return err
+	}
+	if err := pe.putString(e.Value); err != nil {
+		return err
+	}
+	pe.putBool(e.ReadOnly)
+	if version == 0 {
+		pe.putBool(e.Default)
+	} else {
+		pe.putInt8(int8(e.Source))
+	}
+	pe.putBool(e.Sensitive)
+
+	if version >= 1 {
+		if err := pe.putArrayLength(len(e.Synonyms)); err != nil {
+			return err
+		}
+		for _, synonym := range e.Synonyms {
+			if err := pe.putString(synonym.Name); err != nil {
+				return err
+			}
+			if err := pe.putString(synonym.Value); err != nil {
+				return err
+			}
+			pe.putInt8(int8(synonym.Source))
+		}
+	}
+
+	return nil
+}
+
+func (e *ConfigEntry) decode(pd packetDecoder, version int16) (err error) {
+	if e.Name, err = pd.getString(); err != nil {
+		return err
+	}
+	if e.Value, err = getNullableStringValue(pd); err != nil {
+		return err
+	}
+	if e.ReadOnly, err = pd.getBool(); err != nil {
+		return err
+	}
+	if version == 0 {
+		if e.Default, err = pd.getBool(); err != nil {
+			return err
+		}
+		if e.Default {
+			e.Source = SourceDefault
+		}
+	} else {
+		source, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		e.Source = ConfigSource(source)
+		e.Default = e.Source == SourceDefault
+	}
+	if e.Sensitive, err = pd.getBool(); err != nil {
+		return err
+	}
+
+	if version >= 1 {
+		n, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			e.Synonyms = make([]*ConfigSynonym, n)
+			for i := range e.Synonyms {
+				synonym := new(ConfigSynonym)
+				if synonym.Name, err = pd.getString(); err != nil {
+					return err
+				}
+				if synonym.Value, err = getNullableStringValue(pd); err != nil {
+					return err
+				}
+				source, err := pd.getInt8()
+				if err != nil {
+					return err
+				}
+				synonym.Source = ConfigSource(source)
+				e.Synonyms[i] = synonym
+			}
+		}
+	}
+
+	return nil
+}
+
+// getNullableStringValue decodes a nullable string, null being returned as an empty string.
+func getNullableStringValue(pd packetDecoder) (string, error) {
+	value, err := pd.getNullableString()
+	if err != nil || value == nil {
+		return "", err
+	}
+	return *value, nil
+}
+
+// DescribeConfigsResource is the configuration of one of the resources of a DescribeConfigsRequest.
+type DescribeConfigsResource struct {
+	Err     KError
+	ErrMsg  *string
+	Type    ConfigResourceType
+	Name    string
+	Configs []*ConfigEntry
+}
+
+type DescribeConfigsResponse struct {
+	// Version must match the version of the request, it is set by the Broker before decoding
+	Version      int16
+	ThrottleTime time.Duration // the time the request was throttled for by a quota
+	Resources    []*DescribeConfigsResource
+}
+
+func (r *DescribeConfigsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+
+	if err := pe.putArrayLength(len(r.Resources)); err != nil {
+		return err
+	}
+	for _, resource := range r.Resources {
+		pe.putInt16(int16(resource.Err))
+		if err := pe.putNullableString(resource.ErrMsg); err != nil {
+			return err
+		}
+		pe.putInt8(int8(resource.Type))
+		if err := pe.putString(resource.Name); err != nil {
+			return err
+		}
+
+		if err := pe.putArrayLength(len(resource.Configs)); err != nil {
+			return err
+		}
+		for _, entry := range resource.Configs {
+			if err := entry.encode(pe, r.Version); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *DescribeConfigsResponse) decode(pd packetDecoder) (err error) {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.Resources = make([]*DescribeConfigsResource, n)
+	for i := range r.Resources {
+		resource := new(DescribeConfigsResource)
+
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		resource.Err = KError(kerr)
+		if resource.ErrMsg, err = pd.getNullableString(); err != nil {
+			return err
+		}
+		t, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		resource.Type = ConfigResourceType(t)
+		if resource.Name, err = pd.getString(); err != nil {
+			return err
+		}
+
+		entries, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		resource.Configs = make([]*ConfigEntry, entries)
+		for j := range resource.Configs {
+			resource.Configs[j] = new(ConfigEntry)
+			if err := resource.Configs[j].decode(pd, r.Version); err != nil {
+				return err
+			}
+		}
+
+		r.Resources[i] = resource
+	}
+
+	return nil
+}
+
+func (r *DescribeConfigsResponse) setVersion(v int16) {
+	r.Version = v
+}
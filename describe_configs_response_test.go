@@ -0,0 +1,83 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	describeConfigsResponseV0 = []byte{
+		0x00, 0x00, 0x00, 0x64, // throttle time
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, // error
+		0xFF, 0xFF, // error message
+		0x02,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x0E, 'c', 'l', 'e', 'a', 'n', 'u', 'p', '.', 'p', 'o', 'l', 'i', 'c', 'y',
+		0x00, 0x06, 'd', 'e', 'l', 'e', 't', 'e',
+		0x00, // read only
+		0x01, // default
+		0x00} // sensitive
+
+	describeConfigsResponseV1 = []byte{
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00,
+		0xFF, 0xFF,
+		0x02,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x0C, 'r', 'e', 't', 'e', 'n', 't', 'i', 'o', 'n', '.', 'm', 's',
+		0x00, 0x02, '-', '1',
+		0x00,
+		0x01, // source: topic
+		0x00,
+		0x00, 0x00, 0x00, 0x02, // synonyms
+		0x00, 0x0C, 'r', 'e', 't', 'e', 'n', 't', 'i', 'o', 'n', '.', 'm', 's',
+		0x00, 0x02, '-', '1',
+		0x01,
+		0x00, 0x10, 'l', 'o', 'g', '.', 'r', 'e', 't', 'e', 'n', 't', 'i', 'o', 'n', '.', 'm', 's',
+		0xFF, 0xFF, // value (null)
+		0x05}
+)
+
+func TestDescribeConfigsResponse(t *testing.T) {
+	response := &DescribeConfigsResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		Resources: []*DescribeConfigsResource{{
+			Type: TopicResource,
+			Name: "topic",
+			Configs: []*ConfigEntry{
+				{Name: "cleanup.policy", Value: "delete", Default: true, Source: SourceDefault},
+			},
+		}},
+	}
+	testResponse(t, "version 0", response, describeConfigsResponseV0)
+
+	decoded := &DescribeConfigsResponse{Version: 1}
+	testDecodable(t, "version 1", decoded, describeConfigsResponseV1)
+	expected := &DescribeConfigsResponse{
+		Version: 1,
+		Resources: []*DescribeConfigsResource{{
+			Type: TopicResource,
+			Name: "topic",
+			Configs: []*ConfigEntry{{
+				Name:   "retention.ms",
+				Value:  "-1",
+				Source: SourceTopic,
+				Synonyms: []*ConfigSynonym{
+					{Name: "retention.ms", Value: "-1", Source: SourceTopic},
+					{Name: "log.retention.ms", Source: SourceDefault},
+				},
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Decoding produced %#v", decoded.Resources[0].Configs[0])
+	}
+	if expected.Resources[0].Configs[0].Source.String() != "Topic" {
+		t.Error("Unexpected config source name", expected.Resources[0].Configs[0].Source)
+	}
+}
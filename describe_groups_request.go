@@ -0,0 +1,59 @@
+package sarama
+
+type DescribeGroupsRequest struct {
+	Groups []string
+	// Version can be:
+	// - 0 (kafka 0.9 and later)
+	// - 1 (kafka 0.11 and later, the response carries the throttle time)
+	// - 2 (kafka 2.0 and later)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version int16
+}
+
+func (r *DescribeGroupsRequest) encode(pe packetEncoder) error {
+	if r.Version < 0 || r.Version > 2 {
+		return PacketEncodingError{"invalid or unsupported DescribeGroupsRequest version field"}
+	}
+	return pe.putStringArray(r.Groups)
+}
+
+func (r *DescribeGroupsRequest) decode(pd packetDecoder) (err error) {
+	r.Groups, err = pd.getStringArray()
+	return err
+}
+
+func (r *DescribeGroupsRequest) key() int16 {
+	return 15
+}
+
+func (r *DescribeGroupsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeGroupsRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *DescribeGroupsRequest) shallowCopy() versionedRequest {
+	c := *r
+	return &c
+}
+
+func (r *DescribeGroupsRequest) maxVersion() int16 {
+	return 2
+}
+
+func (r *DescribeGroupsRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	case 2:
+		return V2_0_0_0
+	default:
+		return V0_9_0_0
+	}
+}
+
+func (r *DescribeGroupsRequest) AddGroup(group string) {
+	r.Groups = append(r.Groups, group)
+}
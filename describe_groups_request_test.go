@@ -0,0 +1,34 @@
+package sarama
+
+import "testing"
+
+var (
+	describeGroupsRequestEmpty = []byte{
+		0, 0, 0, 0} // 0 groups
+
+	describeGroupsRequestOneGroup = []byte{
+		0, 0, 0, 1, // 1 group
+		0, 3, 'f', 'o', 'o'} // group name: foo
+
+	describeGroupsRequestTwoGroups = []byte{
+		0, 0, 0, 2, // 2 groups
+		0, 3, 'f', 'o', 'o', // group name: foo
+		0, 3, 'b', 'a', 'r'} // group name: bar
+)
+
+func TestDescribeGroupsRequest(t *testing.T) {
+	request := new(DescribeGroupsRequest)
+	testRequest(t, "no groups", request, describeGroupsRequestEmpty)
+
+	request = new(DescribeGroupsRequest)
+	request.AddGroup("foo")
+	testRequest(t, "one group", request, describeGroupsRequestOneGroup)
+
+	request = new(DescribeGroupsRequest)
+	request.AddGroup("foo")
+	request.AddGroup("bar")
+	testRequest(t, "two groups", request, describeGroupsRequestTwoGroups)
+
+	request.Version = 2
+	testRequest(t, "version 2", request, describeGroupsRequestTwoGroups)
+}
@@ -0,0 +1,202 @@
+package sarama
+
+import "time"
+
+// GroupMemberDescription is a member of a group described by a DescribeGroupsResponse.
+type GroupMemberDescription struct {
+	ClientID         string
+	ClientHost       string
+	MemberMetadata   []byte
+	MemberAssignment []byte
+}
+
+// GetMemberMetadata decodes the metadata the member joined a consumer group with.
+func (m *GroupMemberDescription) GetMemberMetadata() (*ConsumerGroupMemberMetadata, error) {
+	metadata := new(ConsumerGroupMemberMetadata)
+	if err := decode(m.MemberMetadata, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// GetMemberAssignment decodes the partitions assigned to the member of a consumer group.
+func (m *GroupMemberDescription) GetMemberAssignment() (*ConsumerGroupMemberAssignment, error) {
+	assignment := new(ConsumerGroupMemberAssignment)
+	if len(m.MemberAssignment) == 0 {
+		// the assignment stays empty while the group is rebalancing
+		return assignment, nil
+	}
+	if err := decode(m.MemberAssignment, assignment); err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+func (m *GroupMemberDescription) encode(pe packetEncoder) error {
+	if err := pe.putString(m.ClientID); err != nil {
+		return err
+	}
+	if err := pe.putString(m.ClientHost); err != nil {
+		return err
+	}
+	if err := pe.putBytes(m.MemberMetadata); err != nil {
+		return err
+	}
+	return pe.putBytes(m.MemberAssignment)
+}
+
+func (m *GroupMemberDescription) decode(pd packetDecoder) (err error) {
+	if m.ClientID, err = pd.getString(); err != nil {
+		return err
+	}
+	if m.ClientHost, err = pd.getString(); err != nil {
+		return err
+	}
+	if m.MemberMetadata, err = pd.getBytes(); err != nil {
+		return err
+	}
+	m.MemberAssignment, err = pd.getBytes()
+	return err
+}
+
+// GroupDescription is a group described by a DescribeGroupsResponse.
+type GroupDescription struct {
+	Err     KError
+	GroupID string
+	// State is one of "Dead", "Empty", "PreparingRebalance", "CompletingRebalance" (or
+	// "AwaitingSync" before Kafka 1.1) and "Stable".
+	State        string
+	ProtocolType string
+	// Protocol is the protocol the members agreed on, the partition assignment strategy
+	// for consumer groups. It is empty while the group is rebalancing.
+	Protocol string
+	Members  map[string]*GroupMemberDescription // maps member IDs to members
+}
+
+func (g *GroupDescription) encode(pe packetEncoder) error {
+	pe.putInt16(int16(g.Err))
+
+	if err := pe.putString(g.GroupID); err != nil {
+		return err
+	}
+	if err := pe.putString(g.State); err != nil {
+		return err
+	}
+	if err := pe.putString(g.ProtocolType); err != nil {
+		return err
+	}
+	if err := pe.putString(g.Protocol); err != nil {
+		return err
+	}
+
+	if err := pe.putArrayLength(len(g.Members)); err != nil {
+		return err
+	}
+	for memberID, member := range g.Members {
+		if err := pe.putString(memberID); err != nil {
+			return err
+		}
+		if err := member.encode(pe); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *GroupDescription) decode(pd packetDecoder) (err error) {
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	g.Err = KError(kerr)
+
+	if g.GroupID, err = pd.getString(); err != nil {
+		return err
+	}
+	if g.State, err = pd.getString(); err != nil {
+		return err
+	}
+	if g.ProtocolType, err = pd.getString(); err != nil {
+		return err
+	}
+	if g.Protocol, err = pd.getString(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	g.Members = make(map[string]*GroupMemberDescription, n)
+	for i := 0; i < n; i++ {
+		memberID, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		member := new(GroupMemberDescription)
+		if err := member.decode(pd); err != nil {
+			return err
+		}
+		g.Members[memberID] = member
+	}
+
+	return nil
+}
+
+type DescribeGroupsResponse struct {
+	// Version must match the version of the request, it is set by the Broker before decoding
+	Version      int16
+	ThrottleTime time.Duration // the time the request was throttled for by a quota (version 1+ only)
+	Groups       []*GroupDescription
+}
+
+func (r *DescribeGroupsResponse) encode(pe packetEncoder) error {
+	if r.Version >= 1 {
+		pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	}
+
+	if err := pe.putArrayLength(len(r.Groups)); err != nil {
+		return err
+	}
+	for _, group := range r.Groups {
+		if err := group.encode(pe); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *DescribeGroupsResponse) decode(pd packetDecoder) error {
+	if r.Version >= 1 {
+		millis, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		r.ThrottleTime = time.Duration(millis) * time.Millisecond
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.Groups = make([]*GroupDescription, n)
+	for i := range r.Groups {
+		r.Groups[i] = new(GroupDescription)
+		if err := r.Groups[i].decode(pd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *DescribeGroupsResponse) setVersion(v int16) {
+	r.Version = v
+}
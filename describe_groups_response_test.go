@@ -0,0 +1,94 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	describeGroupsResponseEmpty = []byte{
+		0, 0, 0, 0} // no groups
+
+	describeGroupsResponsePopulated = []byte{
+		0, 0, 0, 2, // 2 groups
+
+		0, 0, // no error
+		0, 3, 'f', 'o', 'o', // Group ID
+		0, 3, 'b', 'a', 'r', // State
+		0, 8, 'c', 'o', 'n', 's', 'u', 'm', 'e', 'r', // ConsumerType
+		0, 3, 'b', 'a', 'z', // Protocol name
+		0, 0, 0, 1, // 1 member
+		0, 2, 'i', 'd', // Member ID
+		0, 6, 's', 'a', 'r', 'a', 'm', 'a', // Client ID
+		0, 9, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't', // Client Host
+		0, 0, 0, 3, 0x01, 0x02, 0x03, // MemberMetadata
+		0, 0, 0, 3, 0x04, 0x05, 0x06, // MemberAssignment
+
+		0, 30, // ErrGroupAuthorizationFailed
+		0, 0,
+		0, 0,
+		0, 0,
+		0, 0,
+		0, 0, 0, 0}
+)
+
+func TestDescribeGroupsResponse(t *testing.T) {
+	response := &DescribeGroupsResponse{Groups: []*GroupDescription{}}
+	testResponse(t, "empty", response, describeGroupsResponseEmpty)
+
+	response = &DescribeGroupsResponse{
+		Groups: []*GroupDescription{
+			{
+				GroupID:      "foo",
+				State:        "bar",
+				ProtocolType: "consumer",
+				Protocol:     "baz",
+				Members: map[string]*GroupMemberDescription{
+					"id": {
+						ClientID:         "sarama",
+						ClientHost:       "localhost",
+						MemberMetadata:   []byte{0x01, 0x02, 0x03},
+						MemberAssignment: []byte{0x04, 0x05, 0x06},
+					},
+				},
+			},
+			{Err: ErrGroupAuthorizationFailed},
+		},
+	}
+	testResponse(t, "populated", response, describeGroupsResponsePopulated)
+
+	response.Version = 1
+	response.ThrottleTime = 100 * time.Millisecond
+	buf, err := encode(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &DescribeGroupsResponse{Version: 1}
+	testDecodable(t, "version 1", decoded, buf)
+	if !reflect.DeepEqual(decoded, response) {
+		t.Errorf("Decoding produced %#v", decoded)
+	}
+}
+
+func TestGroupMemberDescriptionAssignment(t *testing.T) {
+	assignment := &ConsumerGroupMemberAssignment{Version: 1, Topics: map[string][]int32{"one": {0, 2}}}
+	buf, err := encode(assignment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	member := &GroupMemberDescription{MemberAssignment: buf}
+	decoded, err := member.GetMemberAssignment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, assignment) {
+		t.Errorf("Decoding produced %#v", decoded)
+	}
+
+	member = &GroupMemberDescription{}
+	if decoded, err := member.GetMemberAssignment(); err != nil || len(decoded.Topics) != 0 {
+		t.Error("Expected a rebalancing member to have no partitions, got", decoded, err)
+	}
+}
@@ -0,0 +1,46 @@
+package sarama
+
+// EndTxnRequest asks the transaction coordinator to commit or abort the ongoing transaction
+// of a producer, which it does by writing markers to all the partitions of the transaction.
+type EndTxnRequest struct {
+	TransactionalID string
+	ProducerID      int64
+	ProducerEpoch   int16
+	Commit          bool // whether to commit rather than abort the transaction
+}
+
+func (r *EndTxnRequest) encode(pe packetEncoder) error {
+	if err := pe.putString(r.TransactionalID); err != nil {
+		return err
+	}
+	pe.putInt64(r.ProducerID)
+	pe.putInt16(r.ProducerEpoch)
+	pe.putBool(r.Commit)
+	return nil
+}
+
+func (r *EndTxnRequest) decode(pd packetDecoder) (err error) {
+	if r.TransactionalID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if r.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return err
+	}
+	r.Commit, err = pd.getBool()
+	return err
+}
+
+func (r *EndTxnRequest) key() int16 {
+	return 26
+}
+
+func (r *EndTxnRequest) version() int16 {
+	return 0
+}
+
+func (r *EndTxnRequest) requiredVersion() KafkaVersion {
+	return V0_11_0_0
+}
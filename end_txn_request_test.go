@@ -0,0 +1,19 @@
+package sarama
+
+import "testing"
+
+var endTxnRequest = []byte{
+	0x00, 0x03, 't', 'x', 'n',
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07,
+	0x00, 0x02,
+	0x01}
+
+func TestEndTxnRequest(t *testing.T) {
+	request := &EndTxnRequest{
+		TransactionalID: "txn",
+		ProducerID:      7,
+		ProducerEpoch:   2,
+		Commit:          true,
+	}
+	testRequest(t, "", request, endTxnRequest)
+}
@@ -0,0 +1,30 @@
+package sarama
+
+import "time"
+
+type EndTxnResponse struct {
+	ThrottleTime time.Duration // the time the request was throttled for by a quota
+	Err          KError
+}
+
+func (r *EndTxnResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putInt16(int16(r.Err))
+	return nil
+}
+
+func (r *EndTxnResponse) decode(pd packetDecoder) (err error) {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var endTxnResponse = []byte{
+	0x00, 0x00, 0x00, 0x64,
+	0x00, 0x33}
+
+func TestEndTxnResponse(t *testing.T) {
+	response := &EndTxnResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		Err:          ErrConcurrentTransactions,
+	}
+	testResponse(t, "", response, endTxnResponse)
+}
@@ -3,6 +3,8 @@ package sarama
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // ErrOutOfBrokers is the error returned when the client has run out of brokers to talk to because all of them errored
@@ -26,6 +28,11 @@ var ErrAlreadyConnected = errors.New("kafka: broker connection already initiated
 // ErrNotConnected is the error returned when trying to send or call Close() on a Broker that is not connected.
 var ErrNotConnected = errors.New("kafka: broker not connected")
 
+// ErrConnectionPoisoned is returned for the requests pending on a broker connection which lost its place
+// in the stream of responses, for example after timing out in the middle of one. The Broker closes such a
+// connection rather than risk handing out the wrong response, and must be opened again.
+var ErrConnectionPoisoned = errors.New("kafka: broker connection lost track of its responses and was closed")
+
 // ErrInsufficientData is returned when decoding and the packet is truncated. This can be expected
 // when requesting messages, since as an optimization the server is allowed to return a partial message at the end
 // of the message set.
@@ -37,6 +44,21 @@ var ErrShuttingDown = errors.New("kafka: message received by producer in process
 // ErrMessageTooLarge is returned when the next message to consume is larger than the configured Consumer.Fetch.Max
 var ErrMessageTooLarge = errors.New("kafka: message is larger than Consumer.Fetch.Max")
 
+// ErrTransactionNotInProgress is returned when a transactional producer is asked to produce messages or
+// to commit or abort a transaction, while no transaction was begun.
+var ErrTransactionNotInProgress = errors.New("kafka: no transaction in progress")
+
+// ErrTransactionInProgress is returned when beginning a transaction while the previous one was not ended.
+var ErrTransactionInProgress = errors.New("kafka: a transaction is already in progress")
+
+// ErrTransactionMustAbort is returned when committing a transaction some messages of which could not be
+// delivered; the reasons are reported on the Errors channel, and the transaction can only be aborted.
+var ErrTransactionMustAbort = errors.New("kafka: messages of the transaction failed to be delivered, it must be aborted")
+
+// ErrControllerNotAvailable is returned when the cluster metadata does not name a controller broker,
+// or names one the client does not know of.
+var ErrControllerNotAvailable = errors.New("kafka: controller is not available")
+
 // PacketEncodingError is returned from a failure while encoding a Kafka packet. This can happen, for example,
 // if you try to encode a string over 2^15 characters in length, since Kafka's encoding rules do not permit that.
 type PacketEncodingError struct {
@@ -65,6 +87,32 @@ func (err ConfigurationError) Error() string {
 	return "kafka: invalid configuration (" + string(err) + ")"
 }
 
+// ProxyError is the type of error returned when the proxy of Config.Net.Proxy cannot, or will not,
+// connect to a broker.
+type ProxyError string
+
+func (err ProxyError) Error() string {
+	return "kafka: proxy error (" + string(err) + ")"
+}
+
+// BrokerErrors is the type of error returned along with the merged results of a request sent to every
+// broker, when some of the brokers failed to answer. It maps the IDs of those brokers to their errors.
+type BrokerErrors map[int32]error
+
+func (err BrokerErrors) Error() string {
+	ids := make([]int, 0, len(err))
+	for id := range err {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	failures := make([]string, len(ids))
+	for i, id := range ids {
+		failures[i] = fmt.Sprintf("broker #%d: %s", id, err[int32(id)])
+	}
+	return fmt.Sprintf("kafka: %d brokers failed to answer (%s)", len(ids), strings.Join(failures, ", "))
+}
+
 // KError is the type of error that can be returned directly by the Kafka broker.
 // See https://cwiki.apache.org/confluence/display/KAFKA/A+Guide+To+The+Kafka+Protocol#AGuideToTheKafkaProtocol-ErrorCodes
 type KError int16
@@ -85,6 +133,7 @@ const (
 	ErrMessageSizeTooLarge             KError = 10
 	ErrStaleControllerEpochCode        KError = 11
 	ErrOffsetMetadataTooLarge          KError = 12
+	ErrNetworkException                KError = 13
 	ErrOffsetsLoadInProgress           KError = 14
 	ErrConsumerCoordinatorNotAvailable KError = 15
 	ErrNotCoordinatorForConsumer       KError = 16
@@ -92,6 +141,42 @@ const (
 	ErrMessageSetSizeTooLarge          KError = 18
 	ErrNotEnoughReplicas               KError = 19
 	ErrNotEnoughReplicasAfterAppend    KError = 20
+	ErrInvalidRequiredAcks             KError = 21
+	ErrIllegalGeneration               KError = 22
+	ErrInconsistentGroupProtocol       KError = 23
+	ErrInvalidGroupID                  KError = 24
+	ErrUnknownMemberID                 KError = 25
+	ErrInvalidSessionTimeout           KError = 26
+	ErrRebalanceInProgress             KError = 27
+	ErrInvalidCommitOffsetSize         KError = 28
+	ErrTopicAuthorizationFailed        KError = 29
+	ErrGroupAuthorizationFailed        KError = 30
+	ErrClusterAuthorizationFailed      KError = 31
+	ErrUnsupportedSASLMechanism        KError = 33
+	ErrIllegalSASLState                KError = 34
+	ErrUnsupportedVersion              KError = 35
+	ErrTopicAlreadyExists              KError = 36
+	ErrInvalidPartitions               KError = 37
+	ErrInvalidReplicationFactor        KError = 38
+	ErrInvalidReplicaAssignment        KError = 39
+	ErrInvalidConfig                   KError = 40
+	ErrNotController                   KError = 41
+	ErrInvalidRequest                  KError = 42
+	ErrPolicyViolation                 KError = 44
+	ErrOutOfOrderSequenceNumber        KError = 45
+	ErrDuplicateSequenceNumber         KError = 46
+	ErrInvalidProducerEpoch            KError = 47
+	ErrInvalidTxnState                 KError = 48
+	ErrInvalidProducerIDMapping        KError = 49
+	ErrInvalidTransactionTimeout       KError = 50
+	ErrConcurrentTransactions          KError = 51
+	ErrTransactionCoordinatorFenced    KError = 52
+	ErrTxnIDAuthorizationFailed        KError = 53
+	ErrOperationNotAttempted           KError = 55
+	ErrSASLAuthenticationFailed        KError = 58
+	ErrUnknownProducerID               KError = 59
+	ErrTopicDeletionDisabled           KError = 73
+	ErrUnsupportedCompressionType      KError = 76
 )
 
 func (err KError) Error() string {
@@ -126,6 +211,8 @@ func (err KError) Error() string {
 		return "kafka server: StaleControllerEpochCode (internal error code for broker-to-broker communication)."
 	case ErrOffsetMetadataTooLarge:
 		return "kafka server: Specified a string larger than the configured maximum for offset metadata."
+	case ErrNetworkException:
+		return "kafka server: The server disconnected before a response was received."
 	case ErrOffsetsLoadInProgress:
 		return "kafka server: The broker is still loading offsets after a leader change for that offset's topic partition."
 	case ErrConsumerCoordinatorNotAvailable:
@@ -140,6 +227,78 @@ func (err KError) Error() string {
 		return "kafka server: Messages are rejected since there are fewer in-sync replicas than required."
 	case ErrNotEnoughReplicasAfterAppend:
 		return "kafka server: Messages are written to the log, but to fewer in-sync replicas than required."
+	case ErrInvalidRequiredAcks:
+		return "kafka server: The requested requiredAcks is invalid."
+	case ErrIllegalGeneration:
+		return "kafka server: The provided generation id is not the current generation."
+	case ErrInconsistentGroupProtocol:
+		return "kafka server: The provided group protocol type is incompatible with the other members."
+	case ErrInvalidGroupID:
+		return "kafka server: The provided group id was empty."
+	case ErrUnknownMemberID:
+		return "kafka server: The provided member is not known in the current generation."
+	case ErrInvalidSessionTimeout:
+		return "kafka server: The provided session timeout is outside the allowed range."
+	case ErrRebalanceInProgress:
+		return "kafka server: A rebalance for the group is in progress. Please re-join the group."
+	case ErrInvalidCommitOffsetSize:
+		return "kafka server: The provided commit metadata was too large."
+	case ErrTopicAuthorizationFailed:
+		return "kafka server: The client is not authorized to access this topic."
+	case ErrGroupAuthorizationFailed:
+		return "kafka server: The client is not authorized to access this group."
+	case ErrClusterAuthorizationFailed:
+		return "kafka server: The client is not authorized to send this request type."
+	case ErrUnsupportedSASLMechanism:
+		return "kafka server: The broker does not support the requested SASL mechanism."
+	case ErrIllegalSASLState:
+		return "kafka server: Request is not valid given the current SASL state."
+	case ErrUnsupportedVersion:
+		return "kafka server: The version of API is not supported."
+	case ErrTopicAlreadyExists:
+		return "kafka server: Topic with this name already exists."
+	case ErrInvalidPartitions:
+		return "kafka server: Number of partitions is invalid."
+	case ErrInvalidReplicationFactor:
+		return "kafka server: Replication-factor is invalid."
+	case ErrInvalidReplicaAssignment:
+		return "kafka server: Replica assignment is invalid."
+	case ErrInvalidConfig:
+		return "kafka server: Configuration is invalid."
+	case ErrNotController:
+		return "kafka server: This is not the correct controller for this cluster."
+	case ErrInvalidRequest:
+		return "kafka server: The request was malformed or is not supported by this broker version."
+	case ErrPolicyViolation:
+		return "kafka server: Request parameters do not satisfy the configured policy."
+	case ErrOutOfOrderSequenceNumber:
+		return "kafka server: The broker received an out of order sequence number, records of the producer went missing."
+	case ErrDuplicateSequenceNumber:
+		return "kafka server: The broker received a duplicate sequence number, the records were already written."
+	case ErrInvalidProducerEpoch:
+		return "kafka server: Producer attempted an operation with an old epoch, a newer producer with the same transactional ID is active."
+	case ErrInvalidTxnState:
+		return "kafka server: The producer attempted a transactional operation in an invalid state."
+	case ErrInvalidProducerIDMapping:
+		return "kafka server: The producer attempted to use a producer ID which is not currently assigned to its transactional ID."
+	case ErrInvalidTransactionTimeout:
+		return "kafka server: The transaction timeout is larger than the maximum value allowed by the broker (transaction.max.timeout.ms)."
+	case ErrConcurrentTransactions:
+		return "kafka server: The producer attempted to update a transaction while another concurrent operation on the same transaction was ongoing."
+	case ErrTransactionCoordinatorFenced:
+		return "kafka server: The transaction coordinator sending a WriteTxnMarker is no longer the current coordinator for a given producer."
+	case ErrTxnIDAuthorizationFailed:
+		return "kafka server: The client is not authorized to use this transactional ID."
+	case ErrOperationNotAttempted:
+		return "kafka server: The broker did not attempt to execute this operation, because another one of the same request failed."
+	case ErrSASLAuthenticationFailed:
+		return "kafka server: SASL authentication failed, the credentials were rejected."
+	case ErrUnknownProducerID:
+		return "kafka server: The broker has no state for the producer ID, its records may have been removed by retention."
+	case ErrTopicDeletionDisabled:
+		return "kafka server: Topic deletion is disabled."
+	case ErrUnsupportedCompressionType:
+		return "kafka server: The requesting client does not support the compression type of given partition."
 	}
 
 	return fmt.Sprintf("Unknown error, how did this happen? Error code = %d", err)
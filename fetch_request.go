@@ -5,32 +5,92 @@ type fetchRequestBlock struct {
 	maxBytes    int32
 }
 
-func (f *fetchRequestBlock) encode(pe packetEncoder) error {
+func (f *fetchRequestBlock) encode(pe packetEncoder, version int16) error {
+	if version >= 9 {
+		pe.putInt32(-1) // current leader epoch: unknown, the broker does not fence the request
+	}
 	pe.putInt64(f.fetchOffset)
+	if version >= 5 {
+		pe.putInt64(-1) // log start offset: only used by followers
+	}
 	pe.putInt32(f.maxBytes)
 	return nil
 }
 
-func (f *fetchRequestBlock) decode(pd packetDecoder) (err error) {
+func (f *fetchRequestBlock) decode(pd packetDecoder, version int16) (err error) {
+	if version >= 9 {
+		if _, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
 	if f.fetchOffset, err = pd.getInt64(); err != nil {
 		return err
 	}
+	if version >= 5 {
+		if _, err = pd.getInt64(); err != nil {
+			return err
+		}
+	}
 	if f.maxBytes, err = pd.getInt32(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// IsolationLevel tells which records of transactional producers a FetchRequest returns.
+type IsolationLevel int8
+
+const (
+	// ReadUncommitted returns all the records, including the ones of aborted transactions.
+	ReadUncommitted IsolationLevel = iota
+	// ReadCommitted only returns records up to the last stable offset, the first one of a
+	// transaction still in progress, along with the list of aborted transactions to skip.
+	ReadCommitted
+)
+
 type FetchRequest struct {
 	MaxWaitTime int32
 	MinBytes    int32
-	blocks      map[string]map[int32]*fetchRequestBlock
+	// Version can be:
+	// - 0 (kafka 0.8 and later)
+	// - 1 (kafka 0.9 and later, the response carries the throttle time)
+	// - 2 (kafka 0.10 and later, the response may contain messages in the v1 format carrying timestamps)
+	// - 3 (kafka 0.10.1 and later, adds MaxBytes)
+	// - 4 (kafka 0.11 and later, the response may contain record batches of the v2 format)
+	// - 5 (kafka 1.0 and later, the response carries the log start offset)
+	// - 6 (kafka 1.0 and later)
+	// - 7 (kafka 1.1 and later, adds fetch sessions, which are not used: every request is a full one)
+	// - 8 (kafka 2.0 and later)
+	// - 9 (kafka 2.1 and later)
+	// - 10 (kafka 2.1 and later, the response may contain record batches compressed with ZSTD)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version int16
+	// MaxBytes limits the size of the response (version 3+ only). The first message of the first
+	// partition is returned even if it is larger, so that consumers can always make progress.
+	// It is set to MaxResponseSize if the request is raised to version 3 without it.
+	MaxBytes  int32
+	Isolation IsolationLevel // version 4+ only
+	blocks    map[string]map[int32]*fetchRequestBlock
 }
 
 func (f *FetchRequest) encode(pe packetEncoder) (err error) {
+	if f.Version < 0 || f.Version > 10 {
+		return PacketEncodingError{"invalid or unsupported FetchRequest version field"}
+	}
+
 	pe.putInt32(-1) // replica ID is always -1 for clients
 	pe.putInt32(f.MaxWaitTime)
 	pe.putInt32(f.MinBytes)
+	if f.Version >= 3 {
+		pe.putInt32(f.MaxBytes)
+	}
+	if f.Version >= 4 {
+		pe.putInt8(int8(f.Isolation))
+	}
+	if f.Version >= 7 {
+		pe.putInt32(0)  // session ID: none
+		pe.putInt32(-1) // session epoch: a full request, which does not create a session
+	}
 	err = pe.putArrayLength(len(f.blocks))
 	if err != nil {
 		return err
@@ -46,12 +106,18 @@ func (f *FetchRequest) encode(pe packetEncoder) (err error) {
 		}
 		for partition, block := range blocks {
 			pe.putInt32(partition)
-			err = block.encode(pe)
+			err = block.encode(pe, f.Version)
 			if err != nil {
 				return err
 			}
 		}
 	}
+	if f.Version >= 7 {
+		// the partitions to remove from the session, of which there is none
+		if err = pe.putArrayLength(0); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -65,14 +131,33 @@ func (f *FetchRequest) decode(pd packetDecoder) (err error) {
 	if f.MinBytes, err = pd.getInt32(); err != nil {
 		return err
 	}
+	if f.Version >= 3 {
+		if f.MaxBytes, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+	if f.Version >= 4 {
+		isolation, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		f.Isolation = IsolationLevel(isolation)
+	}
+	if f.Version >= 7 {
+		if _, err = pd.getInt32(); err != nil {
+			return err
+		}
+		if _, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
 	topicCount, err := pd.getArrayLength()
 	if err != nil {
 		return err
 	}
-	if topicCount == 0 {
-		return nil
+	if topicCount > 0 {
+		f.blocks = make(map[string]map[int32]*fetchRequestBlock)
 	}
-	f.blocks = make(map[string]map[int32]*fetchRequestBlock)
 	for i := 0; i < topicCount; i++ {
 		topic, err := pd.getString()
 		if err != nil {
@@ -89,12 +174,26 @@ func (f *FetchRequest) decode(pd packetDecoder) (err error) {
 				return err
 			}
 			fetchBlock := &fetchRequestBlock{}
-			if err = fetchBlock.decode(pd); err != nil {
+			if err = fetchBlock.decode(pd, f.Version); err != nil {
 				return nil
 			}
 			f.blocks[topic][partition] = fetchBlock
 		}
 	}
+	if f.Version >= 7 {
+		forgottenCount, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < forgottenCount; i++ {
+			if _, err = pd.getString(); err != nil {
+				return err
+			}
+			if _, err = pd.getInt32Array(); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -103,7 +202,46 @@ func (f *FetchRequest) key() int16 {
 }
 
 func (f *FetchRequest) version() int16 {
-	return 0
+	return f.Version
+}
+
+func (f *FetchRequest) setVersion(v int16) {
+	f.Version = v
+	if v >= 3 && f.MaxBytes == 0 {
+		f.MaxBytes = MaxResponseSize
+	}
+}
+
+func (f *FetchRequest) shallowCopy() versionedRequest {
+	c := *f
+	return &c
+}
+
+func (f *FetchRequest) maxVersion() int16 {
+	return 10
+}
+
+func (f *FetchRequest) requiredVersion() KafkaVersion {
+	switch f.Version {
+	case 1:
+		return V0_9_0_0
+	case 2:
+		return V0_10_0_0
+	case 3:
+		return V0_10_1_0
+	case 4:
+		return V0_11_0_0
+	case 5, 6:
+		return V1_0_0_0
+	case 7:
+		return V1_1_0_0
+	case 8:
+		return V2_0_0_0
+	case 9, 10:
+		return V2_1_0_0
+	default:
+		return minVersion
+	}
 }
 
 func (f *FetchRequest) AddBlock(topic string, partitionID int32, fetchOffset int64, maxBytes int32) {
@@ -17,6 +17,34 @@ var (
 		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
 		0x00, 0x00, 0x00, 0x01,
 		0x00, 0x00, 0x00, 0x12, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34, 0x00, 0x00, 0x00, 0x56}
+
+	fetchRequestV4 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0xEF,
+		0x00, 0x00, 0x10, 0x00, // max bytes
+		0x00, // isolation level
+		0x00, 0x00, 0x00, 0x00}
+
+	fetchRequestV4ReadCommitted = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0xEF,
+		0x00, 0x00, 0x10, 0x00, // max bytes
+		0x01, // isolation level
+		0x00, 0x00, 0x00, 0x00}
+
+	fetchRequestV10 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0xEF,
+		0x00, 0x00, 0x10, 0x00, // max bytes
+		0x00,                   // isolation level
+		0x00, 0x00, 0x00, 0x00, // session ID
+		0xFF, 0xFF, 0xFF, 0xFF, // session epoch
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x12,
+		0xFF, 0xFF, 0xFF, 0xFF, // current leader epoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // log start offset
+		0x00, 0x00, 0x00, 0x56,
+		0x00, 0x00, 0x00, 0x00} // forgotten topics
 )
 
 func TestFetchRequest(t *testing.T) {
@@ -32,3 +60,32 @@ func TestFetchRequest(t *testing.T) {
 	request.AddBlock("topic", 0x12, 0x34, 0x56)
 	testRequest(t, "one block", request, fetchRequestOneBlock)
 }
+
+func TestFetchRequestV2(t *testing.T) {
+	request := &FetchRequest{Version: 2}
+	testRequest(t, "no blocks", request, fetchRequestNoBlocks)
+}
+
+func TestFetchRequestV4(t *testing.T) {
+	request := &FetchRequest{Version: 4, MaxWaitTime: 0x20, MinBytes: 0xEF, MaxBytes: 0x1000}
+	testRequest(t, "with properties", request, fetchRequestV4)
+
+	request.Isolation = ReadCommitted
+	testRequest(t, "read committed", request, fetchRequestV4ReadCommitted)
+
+	request = &FetchRequest{}
+	request.setVersion(3)
+	if request.MaxBytes != MaxResponseSize {
+		t.Error("Expected MaxBytes to default to MaxResponseSize, got", request.MaxBytes)
+	}
+}
+
+func TestFetchRequestV10(t *testing.T) {
+	request := &FetchRequest{Version: 10, MaxWaitTime: 0x20, MinBytes: 0xEF, MaxBytes: 0x1000}
+	request.AddBlock("topic", 0x12, 0x34, 0x56)
+	testRequest(t, "one block", request, fetchRequestV10)
+
+	if request.requiredVersion() != V2_1_0_0 {
+		t.Error("Expected version 10 to require Kafka 2.1, got", request.requiredVersion())
+	}
+}
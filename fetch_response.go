@@ -1,12 +1,45 @@
 package sarama
 
+import "time"
+
+// AbortedTransaction is a transaction whose records, starting at FirstOffset, are to be ignored.
+type AbortedTransaction struct {
+	ProducerID  int64
+	FirstOffset int64
+}
+
+func (t *AbortedTransaction) decode(pd packetDecoder) (err error) {
+	if t.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if t.FirstOffset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *AbortedTransaction) encode(pe packetEncoder) error {
+	pe.putInt64(t.ProducerID)
+	pe.putInt64(t.FirstOffset)
+	return nil
+}
+
 type FetchResponseBlock struct {
 	Err                 KError
 	HighWaterMarkOffset int64
-	MsgSet              MessageSet
+	LastStableOffset    int64                 // version 4+ only
+	LogStartOffset      int64                 // version 5+ only
+	AbortedTransactions []*AbortedTransaction // version 4+ only
+	// MsgSet holds the messages in the v0 and v1 formats, and RecordBatches the batches in the v2
+	// format. Both may be present in a version 4+ response, when the format of a topic was upgraded.
+	MsgSet        MessageSet
+	RecordBatches []*RecordBatch
+	// Partial tells whether the data ended with an incomplete message or record batch, as brokers
+	// are allowed to return.
+	Partial bool
 }
 
-func (pr *FetchResponseBlock) decode(pd packetDecoder) (err error) {
+func (pr *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error) {
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -18,38 +51,151 @@ func (pr *FetchResponseBlock) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
-	msgSetSize, err := pd.getInt32()
+	if version >= 4 {
+		if pr.LastStableOffset, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if version >= 5 {
+			if pr.LogStartOffset, err = pd.getInt64(); err != nil {
+				return err
+			}
+		}
+
+		// a nullable array, null unless the request asked for committed records only
+		numTransactions, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		if int(numTransactions)*16 > pd.remaining() {
+			return ErrInsufficientData
+		}
+		pr.AbortedTransactions = nil
+		if numTransactions > 0 {
+			pr.AbortedTransactions = make([]*AbortedTransaction, numTransactions)
+			for i := range pr.AbortedTransactions {
+				pr.AbortedTransactions[i] = new(AbortedTransaction)
+				if err = pr.AbortedTransactions[i].decode(pd); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	recordsSize, err := pd.getInt32()
 	if err != nil {
 		return err
 	}
 
-	msgSetDecoder, err := pd.getSubset(int(msgSetSize))
+	recordsDecoder, err := pd.getSubset(int(recordsSize))
 	if err != nil {
 		return err
 	}
-	err = (&pr.MsgSet).decode(msgSetDecoder)
 
-	return err
+	if version < 4 {
+		err = (&pr.MsgSet).decode(recordsDecoder)
+		pr.Partial = pr.MsgSet.PartialTrailingMessage
+		return err
+	}
+
+	// from version 4 on, the data may mix both formats, which are told apart by their magic byte
+	pr.MsgSet = MessageSet{}
+	pr.RecordBatches = nil
+	for recordsDecoder.remaining() > 0 {
+		magic, err := recordsDecoder.peekInt8(magicOffset)
+		if err == nil {
+			if magic < 2 {
+				msb := new(MessageBlock)
+				if err = msb.decode(recordsDecoder); err == nil {
+					pr.MsgSet.Messages = append(pr.MsgSet.Messages, msb)
+				}
+			} else {
+				batch := new(RecordBatch)
+				if err = batch.decode(recordsDecoder); err == nil {
+					pr.RecordBatches = append(pr.RecordBatches, batch)
+				}
+			}
+		}
+
+		switch err {
+		case nil:
+		case ErrInsufficientData:
+			pr.Partial = true
+			pr.MsgSet.PartialTrailingMessage = magic < 2
+			return nil
+		default:
+			return err
+		}
+	}
+
+	return nil
 }
 
 type FetchResponse struct {
 	Blocks map[string]map[int32]*FetchResponseBlock
+	// Version must match the version of the request, it is set by the Broker before decoding
+	Version      int16
+	ThrottleTime time.Duration // the time the request was throttled for by a quota (version 1+ only)
+	Err          KError        // an error affecting the whole request rather than a partition (version 7+ only)
+	SessionID    int32         // the fetch session, always 0 as sessions are not used (version 7+ only)
 }
 
-func (pr *FetchResponseBlock) encode(pe packetEncoder) (err error) {
+func (pr *FetchResponseBlock) encode(pe packetEncoder, version int16) (err error) {
 	pe.putInt16(int16(pr.Err))
 
 	pe.putInt64(pr.HighWaterMarkOffset)
 
+	if version >= 4 {
+		pe.putInt64(pr.LastStableOffset)
+		if version >= 5 {
+			pe.putInt64(pr.LogStartOffset)
+		}
+		if pr.AbortedTransactions == nil {
+			pe.putInt32(-1)
+		} else if err = pe.putArrayLength(len(pr.AbortedTransactions)); err != nil {
+			return err
+		}
+		for _, transaction := range pr.AbortedTransactions {
+			if err = transaction.encode(pe); err != nil {
+				return err
+			}
+		}
+	} else if len(pr.RecordBatches) > 0 {
+		return PacketEncodingError{"record batches can only be fetched from FetchResponse version 4"}
+	}
+
 	pe.push(&lengthField{})
 	err = pr.MsgSet.encode(pe)
 	if err != nil {
 		return err
 	}
+	for _, batch := range pr.RecordBatches {
+		if err = batch.encode(pe); err != nil {
+			return err
+		}
+	}
 	return pe.pop()
 }
 
 func (fr *FetchResponse) decode(pd packetDecoder) (err error) {
+	if fr.Version >= 1 {
+		millis, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		fr.ThrottleTime = time.Duration(millis) * time.Millisecond
+	}
+
+	if fr.Version >= 7 {
+		tmp, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		fr.Err = KError(tmp)
+		if fr.SessionID, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
 	numTopics, err := pd.getArrayLength()
 	if err != nil {
 		return err
@@ -76,7 +222,7 @@ func (fr *FetchResponse) decode(pd packetDecoder) (err error) {
 			}
 
 			block := new(FetchResponseBlock)
-			err = block.decode(pd)
+			err = block.decode(pd, fr.Version)
 			if err != nil {
 				return err
 			}
@@ -88,6 +234,15 @@ func (fr *FetchResponse) decode(pd packetDecoder) (err error) {
 }
 
 func (fr *FetchResponse) encode(pe packetEncoder) (err error) {
+	if fr.Version >= 1 {
+		pe.putInt32(int32(fr.ThrottleTime / time.Millisecond))
+	}
+
+	if fr.Version >= 7 {
+		pe.putInt16(int16(fr.Err))
+		pe.putInt32(fr.SessionID)
+	}
+
 	err = pe.putArrayLength(len(fr.Blocks))
 	if err != nil {
 		return err
@@ -106,7 +261,7 @@ func (fr *FetchResponse) encode(pe packetEncoder) (err error) {
 
 		for id, block := range partitions {
 			pe.putInt32(id)
-			err = block.encode(pe)
+			err = block.encode(pe, fr.Version)
 			if err != nil {
 				return err
 			}
@@ -116,6 +271,10 @@ func (fr *FetchResponse) encode(pe packetEncoder) (err error) {
 	return nil
 }
 
+func (fr *FetchResponse) setVersion(v int16) {
+	fr.Version = v
+}
+
 func (fr *FetchResponse) GetBlock(topic string, partition int32) *FetchResponseBlock {
 	if fr.Blocks == nil {
 		return nil
@@ -171,3 +330,41 @@ func (fr *FetchResponse) AddMessage(topic string, partition int32, key, value En
 	msgBlock := &MessageBlock{Msg: msg, Offset: offset}
 	frb.MsgSet.Messages = append(frb.MsgSet.Messages, msgBlock)
 }
+
+// AddRecord appends a record to the last record batch of the partition, which can only be
+// encoded from version 4 on.
+func (fr *FetchResponse) AddRecord(topic string, partition int32, key, value Encoder, offset int64) {
+	if fr.Blocks == nil {
+		fr.Blocks = make(map[string]map[int32]*FetchResponseBlock)
+	}
+	partitions, ok := fr.Blocks[topic]
+	if !ok {
+		partitions = make(map[int32]*FetchResponseBlock)
+		fr.Blocks[topic] = partitions
+	}
+	frb, ok := partitions[partition]
+	if !ok {
+		frb = new(FetchResponseBlock)
+		partitions[partition] = frb
+	}
+	var kb []byte
+	var vb []byte
+	if key != nil {
+		kb, _ = key.Encode()
+	}
+	if value != nil {
+		vb, _ = value.Encode()
+	}
+	if len(frb.RecordBatches) == 0 {
+		frb.RecordBatches = append(frb.RecordBatches, &RecordBatch{
+			Version:       2,
+			FirstOffset:   offset,
+			ProducerID:    -1,
+			ProducerEpoch: -1,
+			FirstSequence: -1,
+		})
+	}
+	batch := frb.RecordBatches[len(frb.RecordBatches)-1]
+	batch.addRecord(&Record{Key: kb, Value: vb, OffsetDelta: offset - batch.FirstOffset})
+	batch.LastOffsetDelta = int32(offset - batch.FirstOffset)
+}
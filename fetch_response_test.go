@@ -2,7 +2,9 @@ package sarama
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
+	"time"
 )
 
 var (
@@ -26,6 +28,26 @@ var (
 		0x00,
 		0xFF, 0xFF, 0xFF, 0xFF,
 		0x00, 0x00, 0x00, 0x02, 0x00, 0xEE}
+
+	oneMessageFetchResponseV2 = []byte{
+		0x00, 0x00, 0x00, 0x64, // throttle time
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x05,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x10, 0x10, 0x10, 0x10,
+		0x00, 0x00, 0x00, 0x24,
+		// messageSet
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x55, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x18,
+		// message
+		0x80, 0xB0, 0x14, 0xC8, // CRC
+		0x01,
+		0x00,
+		0x00, 0x00, 0x01, 0x58, 0x8D, 0xCD, 0x59, 0x38, // timestamp
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0x02, 0x00, 0xEE}
 )
 
 func TestEmptyFetchResponse(t *testing.T) {
@@ -82,3 +104,130 @@ func TestOneMessageFetchResponse(t *testing.T) {
 		t.Error("Decoding produced incorrect message value.")
 	}
 }
+
+func TestOneMessageFetchResponseV2(t *testing.T) {
+	response := FetchResponse{Version: 2}
+	testDecodable(t, "one message", &response, oneMessageFetchResponseV2)
+
+	if response.ThrottleTime != 100*time.Millisecond {
+		t.Error("Decoding produced incorrect throttle time:", response.ThrottleTime)
+	}
+
+	block := response.GetBlock("topic", 5)
+	if block == nil {
+		t.Fatal("GetBlock didn't return block.")
+	}
+	if len(block.MsgSet.Messages) != 1 {
+		t.Fatal("Decoding produced incorrect number of messages.")
+	}
+	msg := block.MsgSet.Messages[0].Msg
+	if msg.Version != 1 {
+		t.Error("Decoding produced incorrect message version.")
+	}
+	if !msg.Timestamp.Equal(time.Unix(1479847795, 0)) {
+		t.Error("Decoding produced incorrect message timestamp:", msg.Timestamp)
+	}
+	if !bytes.Equal(msg.Value, []byte{0x00, 0xEE}) {
+		t.Error("Decoding produced incorrect message value.")
+	}
+}
+
+func TestMixedFormatsFetchResponseV4(t *testing.T) {
+	legacy := []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0F,
+		0x00, 0x00, 0x00, 0x10,
+		0x23, 0x96, 0x4a, 0xf7, // CRC
+		0x00,
+		0x00,
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0x02, 0x00, 0xEE}
+
+	var records []byte
+	records = append(records, legacy...)
+	records = append(records, recordBatchTwoRecords...)
+	records = append(records, recordBatchTwoRecords[:20]...) // partial trailing batch
+
+	buf := []byte{
+		0x00, 0x00, 0x00, 0x00, // throttle time
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x05,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x20, // high water mark
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x12, // last stable offset
+		0xFF, 0xFF, 0xFF, 0xFF, // aborted transactions (null)
+		0x00, 0x00, 0x00, byte(len(records))}
+	buf = append(buf, records...)
+
+	response := FetchResponse{Version: 4}
+	testDecodable(t, "mixed formats", &response, buf)
+
+	block := response.GetBlock("topic", 5)
+	if block == nil {
+		t.Fatal("GetBlock didn't return block.")
+	}
+	if block.LastStableOffset != 0x12 {
+		t.Error("Decoding produced incorrect last stable offset:", block.LastStableOffset)
+	}
+	if block.AbortedTransactions != nil {
+		t.Error("Decoding produced aborted transactions where there were none.")
+	}
+	if len(block.MsgSet.Messages) != 1 || block.MsgSet.Messages[0].Offset != 0x0F {
+		t.Error("Decoding did not produce the legacy message.")
+	}
+	if len(block.RecordBatches) != 1 || !reflect.DeepEqual(block.RecordBatches[0], newTestRecordBatch()) {
+		t.Error("Decoding did not produce the record batch.")
+	}
+	if !block.Partial {
+		t.Error("Decoding did not notice the partial trailing batch.")
+	}
+	if block.MsgSet.PartialTrailingMessage {
+		t.Error("Decoding reported the partial batch as a partial message.")
+	}
+}
+
+func TestFetchResponseV10(t *testing.T) {
+	batch := newTestRecordBatch()
+	batch.Codec = CompressionZSTD
+
+	response := &FetchResponse{
+		Version:      10,
+		ThrottleTime: 100 * time.Millisecond,
+		Err:          ErrNoError,
+		Blocks: map[string]map[int32]*FetchResponseBlock{
+			"topic": {5: {
+				HighWaterMarkOffset: 0x20,
+				LastStableOffset:    0x12,
+				LogStartOffset:      0x03,
+				RecordBatches:       []*RecordBatch{batch},
+			}},
+		},
+	}
+	buf, err := encode(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []byte{
+		0x00, 0x00, 0x00, 0x64, // throttle time
+		0x00, 0x00, // error
+		0x00, 0x00, 0x00, 0x00, // session ID
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x05,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x20, // high water mark
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x12, // last stable offset
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, // log start offset
+		0xFF, 0xFF, 0xFF, 0xFF} // aborted transactions (null)
+	if !bytes.HasPrefix(buf, header) {
+		t.Error("Encoding produced unexpected header", buf[:len(header)])
+	}
+
+	decoded := &FetchResponse{Version: 10}
+	testDecodable(t, "zstd batch", decoded, buf)
+	if !reflect.DeepEqual(decoded, response) {
+		t.Errorf("Decoding produced %#v", decoded)
+	}
+}
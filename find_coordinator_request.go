@@ -0,0 +1,86 @@
+package sarama
+
+// CoordinatorType tells which kind of coordinator a FindCoordinatorRequest looks for.
+type CoordinatorType int8
+
+const (
+	CoordinatorGroup       CoordinatorType = 0
+	CoordinatorTransaction CoordinatorType = 1
+)
+
+// FindCoordinatorRequest generalises ConsumerMetadataRequest, which is its version 0, to the
+// coordinators of transactions.
+type FindCoordinatorRequest struct {
+	// CoordinatorKey is the consumer group or the transactional ID to find the coordinator of.
+	CoordinatorKey  string
+	CoordinatorType CoordinatorType // version 1+ only, version 0 only knows of groups
+	// Version can be:
+	// - 0 (kafka 0.8.2 and later)
+	// - 1 (kafka 0.11 and later, required for transactions)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version int16
+}
+
+func (r *FindCoordinatorRequest) encode(pe packetEncoder) error {
+	if r.Version < 0 || r.Version > 1 {
+		return PacketEncodingError{"invalid or unsupported FindCoordinatorRequest version field"}
+	}
+	if r.Version == 0 && r.CoordinatorType != CoordinatorGroup {
+		return PacketEncodingError{"FindCoordinatorRequest version 0 can only find the coordinators of groups"}
+	}
+
+	if err := pe.putString(r.CoordinatorKey); err != nil {
+		return err
+	}
+	if r.Version >= 1 {
+		pe.putInt8(int8(r.CoordinatorType))
+	}
+
+	return nil
+}
+
+func (r *FindCoordinatorRequest) decode(pd packetDecoder) (err error) {
+	if r.CoordinatorKey, err = pd.getString(); err != nil {
+		return err
+	}
+
+	if r.Version >= 1 {
+		coordinatorType, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		r.CoordinatorType = CoordinatorType(coordinatorType)
+	}
+
+	return nil
+}
+
+func (r *FindCoordinatorRequest) key() int16 {
+	return 10
+}
+
+func (r *FindCoordinatorRequest) version() int16 {
+	return r.Version
+}
+
+func (r *FindCoordinatorRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *FindCoordinatorRequest) shallowCopy() versionedRequest {
+	c := *r
+	return &c
+}
+
+func (r *FindCoordinatorRequest) maxVersion() int16 {
+	return 1
+}
+
+func (r *FindCoordinatorRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	default:
+		return minVersion
+	}
+}
@@ -0,0 +1,33 @@
+package sarama
+
+import "testing"
+
+var (
+	findCoordinatorRequestGroup = []byte{
+		0x00, 0x05, 'g', 'r', 'o', 'u', 'p'}
+
+	findCoordinatorRequestTransaction = []byte{
+		0x00, 0x03, 't', 'x', 'n',
+		0x01}
+)
+
+func TestFindCoordinatorRequest(t *testing.T) {
+	// version 0 requests are decoded as ConsumerMetadataRequests
+	request := &FindCoordinatorRequest{CoordinatorKey: "group"}
+	testEncodable(t, "version 0", request, findCoordinatorRequestGroup)
+
+	request = &FindCoordinatorRequest{
+		CoordinatorKey:  "txn",
+		CoordinatorType: CoordinatorTransaction,
+		Version:         1,
+	}
+	testRequest(t, "version 1", request, findCoordinatorRequestTransaction)
+	if request.requiredVersion() != V0_11_0_0 {
+		t.Error("Expected version 1 to require Kafka 0.11, got", request.requiredVersion())
+	}
+
+	request.Version = 0
+	if _, err := encode(request); err == nil {
+		t.Error("Expected version 0 to refuse transaction coordinators")
+	}
+}
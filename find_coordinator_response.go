@@ -0,0 +1,73 @@
+package sarama
+
+import "time"
+
+type FindCoordinatorResponse struct {
+	// Version must match the version of the request, it is set by the Broker before decoding
+	Version      int16
+	ThrottleTime time.Duration // the time the request was throttled for by a quota (version 1+ only)
+	Err          KError
+	ErrMsg       *string // version 1+ only
+	Coordinator  *Broker // nil when Err is set
+}
+
+func (r *FindCoordinatorResponse) encode(pe packetEncoder) error {
+	if r.Version >= 1 {
+		pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	}
+
+	pe.putInt16(int16(r.Err))
+
+	if r.Version >= 1 {
+		if err := pe.putNullableString(r.ErrMsg); err != nil {
+			return err
+		}
+	}
+
+	if r.Coordinator == nil {
+		// the broker answers with an unknown node
+		pe.putInt32(-1)
+		if err := pe.putString(""); err != nil {
+			return err
+		}
+		pe.putInt32(-1)
+		return nil
+	}
+	return r.Coordinator.encode(pe)
+}
+
+func (r *FindCoordinatorResponse) decode(pd packetDecoder) (err error) {
+	if r.Version >= 1 {
+		millis, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		r.ThrottleTime = time.Duration(millis) * time.Millisecond
+	}
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	if r.Version >= 1 {
+		if r.ErrMsg, err = pd.getNullableString(); err != nil {
+			return err
+		}
+	}
+
+	coordinator := new(Broker)
+	if err := coordinator.decode(pd); err != nil {
+		return err
+	}
+	if coordinator.id >= 0 {
+		r.Coordinator = coordinator
+	}
+
+	return nil
+}
+
+func (r *FindCoordinatorResponse) setVersion(v int16) {
+	r.Version = v
+}
@@ -0,0 +1,56 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	findCoordinatorResponse = []byte{
+		0x00, 0x00, 0x00, 0x64,
+		0x00, 0x00,
+		0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0xAB,
+		0x00, 0x03, 'f', 'o', 'o',
+		0x00, 0x00, 0xCC, 0xDD}
+
+	findCoordinatorResponseError = []byte{
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x0F,
+		0x00, 0x03, 'm', 's', 'g',
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00,
+		0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+func TestFindCoordinatorResponse(t *testing.T) {
+	broker := NewBroker("foo:52445")
+	broker.id = 0xAB
+	response := &FindCoordinatorResponse{
+		Version:      1,
+		ThrottleTime: 100 * time.Millisecond,
+		Coordinator:  broker,
+	}
+	testEncodable(t, "success", response, findCoordinatorResponse)
+
+	decoded := &FindCoordinatorResponse{Version: 1}
+	testDecodable(t, "success", decoded, findCoordinatorResponse)
+	if !reflect.DeepEqual(response, decoded) {
+		t.Errorf("Decoded response does not match the encoded one\nencoded: %#v\ndecoded: %#v", response, decoded)
+	}
+
+	msg := "msg"
+	response = &FindCoordinatorResponse{
+		Version: 1,
+		Err:     ErrConsumerCoordinatorNotAvailable,
+		ErrMsg:  &msg,
+	}
+	testEncodable(t, "error", response, findCoordinatorResponseError)
+
+	decoded = &FindCoordinatorResponse{Version: 1}
+	testDecodable(t, "error", decoded, findCoordinatorResponseError)
+	if !reflect.DeepEqual(response, decoded) {
+		t.Errorf("Decoded response does not match the encoded one\nencoded: %#v\ndecoded: %#v", response, decoded)
+	}
+}
@@ -0,0 +1,21 @@
+package sarama
+
+import "testing"
+
+var (
+	basicHeartbeatRequest = []byte{
+		0, 3, 'f', 'o', 'o', // Group ID
+		0x00, 0x01, 0x02, 0x03, // Generation ID
+		0, 3, 'b', 'a', 'z', // Member ID
+	}
+)
+
+func TestHeartbeatRequest(t *testing.T) {
+	var request *HeartbeatRequest
+
+	request = new(HeartbeatRequest)
+	request.GroupID = "foo"
+	request.GenerationID = 66051
+	request.MemberID = "baz"
+	testRequest(t, "basic", request, basicHeartbeatRequest)
+}
@@ -0,0 +1,29 @@
+package sarama
+
+import "testing"
+
+var (
+	heartbeatResponseNoError = []byte{
+		0x00, 0x00}
+
+	heartbeatResponseRebalanceInProgress = []byte{
+		0x00, 0x1b}
+)
+
+func TestHeartbeatResponse(t *testing.T) {
+	var response *HeartbeatResponse
+
+	response = new(HeartbeatResponse)
+	testDecodable(t, "no error", response, heartbeatResponseNoError)
+	if response.Err != ErrNoError {
+		t.Error("Decoding error failed: no error expected but found", response.Err)
+	}
+	testResponse(t, "no error", response, heartbeatResponseNoError)
+
+	response = new(HeartbeatResponse)
+	testDecodable(t, "rebalance in progress", response, heartbeatResponseRebalanceInProgress)
+	if response.Err != ErrRebalanceInProgress {
+		t.Error("Decoding error failed: ErrRebalanceInProgress expected but found", response.Err)
+	}
+	testResponse(t, "rebalance in progress", response, heartbeatResponseRebalanceInProgress)
+}
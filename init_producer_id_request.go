@@ -0,0 +1,74 @@
+package sarama
+
+import "time"
+
+type InitProducerIDRequest struct {
+	// TransactionalID identifies a transactional producer across restarts, it is
+	// nil for producers which are only idempotent.
+	TransactionalID *string
+	// TransactionTimeout is how long the transaction coordinator waits for a
+	// transaction to be completed before aborting it.
+	TransactionTimeout time.Duration
+	// Version can be:
+	// - 0 (kafka 0.11 and later)
+	// - 1 (kafka 2.0 and later)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version int16
+}
+
+func (r *InitProducerIDRequest) encode(pe packetEncoder) error {
+	if r.Version < 0 || r.Version > 1 {
+		return PacketEncodingError{"invalid or unsupported InitProducerIDRequest version field"}
+	}
+
+	if err := pe.putNullableString(r.TransactionalID); err != nil {
+		return err
+	}
+	pe.putInt32(int32(r.TransactionTimeout / time.Millisecond))
+
+	return nil
+}
+
+func (r *InitProducerIDRequest) decode(pd packetDecoder) (err error) {
+	if r.TransactionalID, err = pd.getNullableString(); err != nil {
+		return err
+	}
+
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.TransactionTimeout = time.Duration(timeout) * time.Millisecond
+
+	return nil
+}
+
+func (r *InitProducerIDRequest) key() int16 {
+	return 22
+}
+
+func (r *InitProducerIDRequest) version() int16 {
+	return r.Version
+}
+
+func (r *InitProducerIDRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *InitProducerIDRequest) shallowCopy() versionedRequest {
+	c := *r
+	return &c
+}
+
+func (r *InitProducerIDRequest) maxVersion() int16 {
+	return 1
+}
+
+func (r *InitProducerIDRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V0_11_0_0
+	}
+}
@@ -0,0 +1,33 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	initProducerIDRequestNull = []byte{
+		0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0x64}
+
+	initProducerIDRequest = []byte{
+		0x00, 0x03, 't', 'x', 'n',
+		0x00, 0x00, 0x00, 0x64}
+)
+
+func TestInitProducerIDRequest(t *testing.T) {
+	request := &InitProducerIDRequest{
+		TransactionTimeout: 100 * time.Millisecond,
+	}
+	testRequest(t, "null transactional ID", request, initProducerIDRequestNull)
+
+	transactionalID := "txn"
+	request.TransactionalID = &transactionalID
+	testRequest(t, "transactional ID", request, initProducerIDRequest)
+
+	request.Version = 1
+	testRequest(t, "version 1", request, initProducerIDRequest)
+	if request.requiredVersion() != V2_0_0_0 {
+		t.Error("Expected version 1 to require Kafka 2.0, got", request.requiredVersion())
+	}
+}
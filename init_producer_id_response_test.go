@@ -0,0 +1,36 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	initProducerIDResponse = []byte{
+		0x00, 0x00, 0x00, 0x64,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07,
+		0x00, 0x02}
+
+	initProducerIDResponseError = []byte{
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x0F,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0xFF, 0xFF}
+)
+
+func TestInitProducerIDResponse(t *testing.T) {
+	response := &InitProducerIDResponse{
+		ThrottleTime:  100 * time.Millisecond,
+		ProducerID:    7,
+		ProducerEpoch: 2,
+	}
+	testResponse(t, "", response, initProducerIDResponse)
+
+	response = &InitProducerIDResponse{
+		Err:           ErrConsumerCoordinatorNotAvailable,
+		ProducerID:    -1,
+		ProducerEpoch: -1,
+	}
+	testResponse(t, "error", response, initProducerIDResponseError)
+}
@@ -0,0 +1,106 @@
+package sarama
+
+// GroupProtocol is one of the protocols a member supports when joining a group, along with the
+// protocol-specific metadata (for the "consumer" protocol type, an encoded ConsumerGroupMemberMetadata).
+type GroupProtocol struct {
+	Name     string
+	Metadata []byte
+}
+
+type JoinGroupRequest struct {
+	GroupID        string
+	SessionTimeout int32
+	MemberID       string
+	ProtocolType   string
+	GroupProtocols []*GroupProtocol // in order of preference
+}
+
+func (r *JoinGroupRequest) encode(pe packetEncoder) error {
+	if err := pe.putString(r.GroupID); err != nil {
+		return err
+	}
+	pe.putInt32(r.SessionTimeout)
+	if err := pe.putString(r.MemberID); err != nil {
+		return err
+	}
+	if err := pe.putString(r.ProtocolType); err != nil {
+		return err
+	}
+
+	if err := pe.putArrayLength(len(r.GroupProtocols)); err != nil {
+		return err
+	}
+	for _, protocol := range r.GroupProtocols {
+		if err := pe.putString(protocol.Name); err != nil {
+			return err
+		}
+		if err := pe.putBytes(protocol.Metadata); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *JoinGroupRequest) decode(pd packetDecoder) (err error) {
+	if r.GroupID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.SessionTimeout, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.MemberID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.ProtocolType, err = pd.getString(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	r.GroupProtocols = make([]*GroupProtocol, n)
+	for i := 0; i < n; i++ {
+		protocol := new(GroupProtocol)
+		if protocol.Name, err = pd.getString(); err != nil {
+			return err
+		}
+		if protocol.Metadata, err = pd.getBytes(); err != nil {
+			return err
+		}
+		r.GroupProtocols[i] = protocol
+	}
+
+	return nil
+}
+
+func (r *JoinGroupRequest) key() int16 {
+	return 11
+}
+
+func (r *JoinGroupRequest) version() int16 {
+	return 0
+}
+
+func (r *JoinGroupRequest) requiredVersion() KafkaVersion {
+	return V0_9_0_0
+}
+
+func (r *JoinGroupRequest) AddGroupProtocol(name string, metadata []byte) {
+	r.GroupProtocols = append(r.GroupProtocols, &GroupProtocol{Name: name, Metadata: metadata})
+}
+
+func (r *JoinGroupRequest) AddGroupProtocolMetadata(name string, metadata *ConsumerGroupMemberMetadata) error {
+	bin, err := encode(metadata)
+	if err != nil {
+		return err
+	}
+
+	r.AddGroupProtocol(name, bin)
+	return nil
+}
@@ -0,0 +1,97 @@
+package sarama
+
+type JoinGroupResponse struct {
+	Err           KError
+	GenerationID  int32
+	GroupProtocol string
+	LeaderID      string
+	MemberID      string
+	Members       map[string][]byte
+}
+
+// GetMembers decodes the metadata of every member of the group. Only the group leader receives
+// the member list, for all other members the result is empty.
+func (r *JoinGroupResponse) GetMembers() (map[string]ConsumerGroupMemberMetadata, error) {
+	members := make(map[string]ConsumerGroupMemberMetadata, len(r.Members))
+	for id, bin := range r.Members {
+		meta := new(ConsumerGroupMemberMetadata)
+		if err := decode(bin, meta); err != nil {
+			return nil, err
+		}
+		members[id] = *meta
+	}
+	return members, nil
+}
+
+func (r *JoinGroupResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.Err))
+	pe.putInt32(r.GenerationID)
+
+	if err := pe.putString(r.GroupProtocol); err != nil {
+		return err
+	}
+	if err := pe.putString(r.LeaderID); err != nil {
+		return err
+	}
+	if err := pe.putString(r.MemberID); err != nil {
+		return err
+	}
+
+	if err := pe.putArrayLength(len(r.Members)); err != nil {
+		return err
+	}
+	for memberID, memberMetadata := range r.Members {
+		if err := pe.putString(memberID); err != nil {
+			return err
+		}
+		if err := pe.putBytes(memberMetadata); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *JoinGroupResponse) decode(pd packetDecoder) (err error) {
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	if r.GenerationID, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.GroupProtocol, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.LeaderID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.MemberID, err = pd.getString(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	r.Members = make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		memberID, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		memberMetadata, err := pd.getBytes()
+		if err != nil {
+			return err
+		}
+		r.Members[memberID] = memberMetadata
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package sarama
+
+type ListGroupsRequest struct {
+	// Version can be:
+	// - 0 (kafka 0.9 and later)
+	// - 1 (kafka 0.11 and later, the response carries the throttle time)
+	// - 2 (kafka 2.0 and later)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version int16
+}
+
+func (r *ListGroupsRequest) encode(pe packetEncoder) error {
+	if r.Version < 0 || r.Version > 2 {
+		return PacketEncodingError{"invalid or unsupported ListGroupsRequest version field"}
+	}
+	return nil
+}
+
+func (r *ListGroupsRequest) decode(pd packetDecoder) (err error) {
+	return nil
+}
+
+func (r *ListGroupsRequest) key() int16 {
+	return 16
+}
+
+func (r *ListGroupsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *ListGroupsRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *ListGroupsRequest) shallowCopy() versionedRequest {
+	c := *r
+	return &c
+}
+
+func (r *ListGroupsRequest) maxVersion() int16 {
+	return 2
+}
+
+func (r *ListGroupsRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	case 2:
+		return V2_0_0_0
+	default:
+		return V0_9_0_0
+	}
+}
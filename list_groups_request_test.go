@@ -0,0 +1,13 @@
+package sarama
+
+import "testing"
+
+func TestListGroupsRequest(t *testing.T) {
+	testRequest(t, "version 0", &ListGroupsRequest{}, []byte{})
+
+	request := &ListGroupsRequest{Version: 1}
+	testRequest(t, "version 1", request, []byte{})
+	if request.requiredVersion() != V0_11_0_0 {
+		t.Error("Expected version 1 to require Kafka 0.11, got", request.requiredVersion())
+	}
+}
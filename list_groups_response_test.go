@@ -0,0 +1,44 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	listGroupsResponseEmpty = []byte{
+		0x00, 0x00, // no error
+		0x00, 0x00, 0x00, 0x00}
+
+	listGroupsResponseError = []byte{
+		0x00, 0x1F, // ErrClusterAuthorizationFailed
+		0x00, 0x00, 0x00, 0x00}
+
+	listGroupsResponseV1 = []byte{
+		0x00, 0x00, 0x00, 0x64, // throttle time
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x03, 'f', 'o', 'o',
+		0x00, 0x08, 'c', 'o', 'n', 's', 'u', 'm', 'e', 'r'}
+)
+
+func TestListGroupsResponse(t *testing.T) {
+	response := &ListGroupsResponse{Groups: map[string]string{}}
+	testResponse(t, "empty", response, listGroupsResponseEmpty)
+
+	response = &ListGroupsResponse{Err: ErrClusterAuthorizationFailed, Groups: map[string]string{}}
+	testResponse(t, "error", response, listGroupsResponseError)
+
+	response = &ListGroupsResponse{
+		Version:      1,
+		ThrottleTime: 100 * time.Millisecond,
+		Groups:       map[string]string{"foo": "consumer"},
+	}
+	testEncodable(t, "version 1", response, listGroupsResponseV1)
+	decoded := &ListGroupsResponse{Version: 1}
+	testDecodable(t, "version 1", decoded, listGroupsResponseV1)
+	if !reflect.DeepEqual(decoded, response) {
+		t.Errorf("Decoding produced %#v", decoded)
+	}
+}
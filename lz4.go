@@ -0,0 +1,393 @@
+package sarama
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// This file implements the subset of the LZ4 frame format
+// (https://github.com/lz4/lz4/blob/dev/doc/lz4_Frame_format.md) that Kafka
+// uses: the JVM client writes frames of independent 64KB blocks without block
+// or content checksums. The decoder also accepts linked blocks, checksums and
+// content sizes, as written by librdkafka and the lz4 tools.
+
+const (
+	lz4FrameMagic        uint32 = 0x184D2204
+	lz4SkippableMask     uint32 = 0xFFFFFFF0
+	lz4SkippableMagic    uint32 = 0x184D2A50
+	lz4Version                  = 1
+	lz4BlockSize                = 64 << 10 // the block size written by the JVM client
+	lz4BlockUncompressed        = 1 << 31  // flags a block stored as-is
+
+	lz4FlagBlockIndependence byte = 0x20
+	lz4FlagBlockChecksum     byte = 0x10
+	lz4FlagContentSize       byte = 0x08
+	lz4FlagContentChecksum   byte = 0x04
+	lz4FlagDictID            byte = 0x01
+
+	lz4MinMatch     = 4
+	lz4LastLiterals = 5  // the last five bytes of a block are always literals
+	lz4MFLimit      = 12 // the last match must start at least twelve bytes before the end of a block
+	lz4MaxOffset    = 65535
+	lz4HashLog      = 14
+)
+
+var errLZ4Corrupt = errors.New("lz4: corrupt input")
+
+// lz4Encode compresses src into a single LZ4 frame. When brokenHeaderChecksum
+// is set, the frame descriptor checksum also covers the magic number, as the
+// JVM client computed it before KIP-57; Kafka still expects that in messages
+// with magic byte 0.
+func lz4Encode(src []byte, brokenHeaderChecksum bool) []byte {
+	dst := make([]byte, 7, len(src)+len(src)/255+32)
+	binary.LittleEndian.PutUint32(dst, lz4FrameMagic)
+	dst[4] = lz4Version<<6 | lz4FlagBlockIndependence
+	dst[5] = 4 << 4 // 64KB maximum block size
+	if brokenHeaderChecksum {
+		dst[6] = lz4HeaderChecksum(dst[:6])
+	} else {
+		dst[6] = lz4HeaderChecksum(dst[4:6])
+	}
+
+	var table [1 << lz4HashLog]int32
+	for len(src) > 0 {
+		block := src
+		if len(block) > lz4BlockSize {
+			block = block[:lz4BlockSize]
+		}
+		src = src[len(block):]
+
+		sizeOffset := len(dst)
+		dst = append(dst, 0, 0, 0, 0)
+		dst = lz4CompressBlock(dst, block, &table)
+		if size := len(dst) - sizeOffset - 4; size < len(block) {
+			binary.LittleEndian.PutUint32(dst[sizeOffset:], uint32(size))
+		} else {
+			dst = append(dst[:sizeOffset+4], block...)
+			binary.LittleEndian.PutUint32(dst[sizeOffset:], uint32(len(block))|lz4BlockUncompressed)
+		}
+	}
+
+	return append(dst, 0, 0, 0, 0) // end mark
+}
+
+// lz4Decode decompresses one or more concatenated LZ4 frames.
+func lz4Decode(src []byte) ([]byte, error) {
+	dst := make([]byte, 0, 4*len(src))
+
+	for len(src) > 0 {
+		if len(src) < 4 {
+			return nil, errLZ4Corrupt
+		}
+		magic := binary.LittleEndian.Uint32(src)
+		if magic&lz4SkippableMask == lz4SkippableMagic {
+			if len(src) < 8 {
+				return nil, errLZ4Corrupt
+			}
+			skip := 8 + int(binary.LittleEndian.Uint32(src[4:]))
+			if skip < 8 || skip > len(src) {
+				return nil, errLZ4Corrupt
+			}
+			src = src[skip:]
+			continue
+		}
+		if magic != lz4FrameMagic {
+			return nil, errors.New("lz4: invalid frame magic number")
+		}
+
+		var err error
+		if dst, src, err = lz4DecodeFrame(dst, src); err != nil {
+			return nil, err
+		}
+	}
+
+	return dst, nil
+}
+
+// lz4DecodeFrame appends the contents of the frame at the start of src to dst,
+// returning the remaining input.
+func lz4DecodeFrame(dst, src []byte) ([]byte, []byte, error) {
+	if len(src) < 7 {
+		return nil, nil, errLZ4Corrupt
+	}
+	flags, bd := src[4], src[5]
+	if flags>>6 != lz4Version {
+		return nil, nil, errors.New("lz4: unsupported frame version")
+	}
+	if flags&lz4FlagDictID != 0 {
+		return nil, nil, errors.New("lz4: dictionaries are not supported")
+	}
+
+	headerLen := 6
+	if flags&lz4FlagContentSize != 0 {
+		headerLen += 8
+	}
+	if len(src) < headerLen+1 {
+		return nil, nil, errLZ4Corrupt
+	}
+	// accept the descriptor checksum written by both pre- and post-KIP-57 clients
+	checksum := src[headerLen]
+	if checksum != lz4HeaderChecksum(src[4:headerLen]) && checksum != lz4HeaderChecksum(src[:headerLen]) {
+		return nil, nil, errors.New("lz4: invalid frame header checksum")
+	}
+
+	if bd>>4&0x7 < 4 {
+		return nil, nil, errors.New("lz4: invalid maximum block size")
+	}
+	maxBlockSize := 1 << (8 + 2*uint(bd>>4&0x7))
+
+	start := len(dst)
+	src = src[headerLen+1:]
+	for {
+		if len(src) < 4 {
+			return nil, nil, errLZ4Corrupt
+		}
+		size := binary.LittleEndian.Uint32(src)
+		src = src[4:]
+		if size == 0 {
+			break
+		}
+
+		n := int(size &^ lz4BlockUncompressed)
+		if n > maxBlockSize || n > len(src) {
+			return nil, nil, errLZ4Corrupt
+		}
+		block := src[:n]
+		src = src[n:]
+
+		if flags&lz4FlagBlockChecksum != 0 {
+			if len(src) < 4 {
+				return nil, nil, errLZ4Corrupt
+			}
+			if binary.LittleEndian.Uint32(src) != xxHash32(block, 0) {
+				return nil, nil, errors.New("lz4: invalid block checksum")
+			}
+			src = src[4:]
+		}
+
+		if size&lz4BlockUncompressed != 0 {
+			dst = append(dst, block...)
+			continue
+		}
+
+		// blocks are decoded in place after the previous ones, so that
+		// linked blocks may refer back into them
+		var err error
+		if dst, err = lz4DecompressBlock(dst, block, len(dst)+maxBlockSize); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if flags&lz4FlagContentChecksum != 0 {
+		if len(src) < 4 {
+			return nil, nil, errLZ4Corrupt
+		}
+		if binary.LittleEndian.Uint32(src) != xxHash32(dst[start:], 0) {
+			return nil, nil, errors.New("lz4: invalid content checksum")
+		}
+		src = src[4:]
+	}
+
+	return dst, src, nil
+}
+
+func lz4HeaderChecksum(descriptor []byte) byte {
+	return byte(xxHash32(descriptor, 0) >> 8)
+}
+
+// lz4CompressBlock appends the LZ4 block encoding of src to dst. The hash
+// table is reset and reused across blocks.
+func lz4CompressBlock(dst, src []byte, table *[1 << lz4HashLog]int32) []byte {
+	for i := range table {
+		table[i] = 0
+	}
+
+	anchor := 0
+	if len(src) > lz4MFLimit {
+		last := len(src) - lz4MFLimit
+		matchLimit := len(src) - lz4LastLiterals
+
+		for i := 0; i <= last; {
+			seq := binary.LittleEndian.Uint32(src[i:])
+			h := (seq * 2654435761) >> (32 - lz4HashLog)
+			ref := int(table[h]) - 1 // positions are stored off by one so that zero means empty
+			table[h] = int32(i + 1)
+
+			if ref < 0 || i-ref > lz4MaxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+				i++
+				continue
+			}
+
+			length := lz4MinMatch
+			for i+length < matchLimit && src[ref+length] == src[i+length] {
+				length++
+			}
+
+			dst = lz4AppendSequence(dst, src[anchor:i], i-ref, length)
+			i += length
+			anchor = i
+		}
+	}
+
+	return lz4AppendSequence(dst, src[anchor:], 0, 0)
+}
+
+// lz4AppendSequence appends a run of literals followed by a match; the final
+// sequence of a block has no match, which is signalled by a zero offset.
+func lz4AppendSequence(dst, literals []byte, offset, length int) []byte {
+	token := byte(0xF0)
+	if len(literals) < 15 {
+		token = byte(len(literals) << 4)
+	}
+	if offset > 0 {
+		if length-lz4MinMatch < 15 {
+			token |= byte(length - lz4MinMatch)
+		} else {
+			token |= 0x0F
+		}
+	}
+
+	dst = append(dst, token)
+	if len(literals) >= 15 {
+		dst = lz4AppendLength(dst, len(literals)-15)
+	}
+	dst = append(dst, literals...)
+
+	if offset > 0 {
+		dst = append(dst, byte(offset), byte(offset>>8))
+		if length-lz4MinMatch >= 15 {
+			dst = lz4AppendLength(dst, length-lz4MinMatch-15)
+		}
+	}
+	return dst
+}
+
+func lz4AppendLength(dst []byte, n int) []byte {
+	for ; n >= 255; n -= 255 {
+		dst = append(dst, 255)
+	}
+	return append(dst, byte(n))
+}
+
+// lz4DecompressBlock appends the decoded contents of the block src to dst,
+// refusing to grow dst beyond limit bytes. Matches may refer back into
+// anything already in dst.
+func lz4DecompressBlock(dst, src []byte, limit int) ([]byte, error) {
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+
+		literals := int(token >> 4)
+		if literals == 15 {
+			n, read := lz4ReadLength(src[i:])
+			if read < 0 {
+				return nil, errLZ4Corrupt
+			}
+			literals += n
+			i += read
+		}
+		if literals > len(src)-i || len(dst)+literals > limit {
+			return nil, errLZ4Corrupt
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+
+		if i == len(src) {
+			break // the last sequence of a block has no match
+		}
+		if i+2 > len(src) {
+			return nil, errLZ4Corrupt
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+
+		length := int(token&0x0F) + lz4MinMatch
+		if token&0x0F == 15 {
+			n, read := lz4ReadLength(src[i:])
+			if read < 0 {
+				return nil, errLZ4Corrupt
+			}
+			length += n
+			i += read
+		}
+		if offset == 0 || offset > len(dst) || len(dst)+length > limit {
+			return nil, errLZ4Corrupt
+		}
+
+		// matches may overlap the bytes they produce, so copy one at a time
+		from := len(dst) - offset
+		for k := 0; k < length; k++ {
+			dst = append(dst, dst[from+k])
+		}
+	}
+	return dst, nil
+}
+
+// lz4ReadLength reads the extra bytes of a literal or match length, returning
+// the number of bytes consumed or -1 if the input ran out.
+func lz4ReadLength(src []byte) (n int, read int) {
+	for read < len(src) {
+		b := src[read]
+		read++
+		n += int(b)
+		if b != 255 {
+			return n, read
+		}
+	}
+	return 0, -1
+}
+
+const (
+	xxPrime1 uint32 = 2654435761
+	xxPrime2 uint32 = 2246822519
+	xxPrime3 uint32 = 3266489917
+	xxPrime4 uint32 = 668265263
+	xxPrime5 uint32 = 374761393
+)
+
+// xxHash32 implements the 32-bit xxHash used for LZ4 frame checksums.
+func xxHash32(b []byte, seed uint32) uint32 {
+	n := len(b)
+
+	var h uint32
+	if n >= 16 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+		for ; len(b) >= 16; b = b[16:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint32(b[0:]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint32(b[4:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint32(b[8:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint32(b[12:]))
+		}
+		h = rotl32(v1, 1) + rotl32(v2, 7) + rotl32(v3, 12) + rotl32(v4, 18)
+	} else {
+		h = seed + xxPrime5
+	}
+
+	h += uint32(n)
+	for ; len(b) >= 4; b = b[4:] {
+		h += binary.LittleEndian.Uint32(b) * xxPrime3
+		h = rotl32(h, 17) * xxPrime4
+	}
+	for _, c := range b {
+		h += uint32(c) * xxPrime5
+		h = rotl32(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 15
+	h *= xxPrime2
+	h ^= h >> 13
+	h *= xxPrime3
+	h ^= h >> 16
+	return h
+}
+
+func xxRound(acc, input uint32) uint32 {
+	return rotl32(acc+input*xxPrime2, 13) * xxPrime1
+}
+
+func rotl32(x uint32, r uint) uint32 {
+	return x<<r | x>>(32-r)
+}
@@ -0,0 +1,115 @@
+package sarama
+
+import (
+	"bytes"
+	"testing"
+)
+
+var (
+	lz4Repeat = "REPEATREPEATREPEATREPEATREPEATREPEAT"
+
+	// as written by `lz4 -B4 --no-frame-crc`, the framing used by the JVM client
+	lz4RepeatFrame = []byte{
+		4, 34, 77, 24, 96, 64, 130, // header
+		16, 0, 0, 0, 111, 82, 69, 80, 69, 65, 84, 6, 0, 6, 80, 69, 80, 69, 65, 84, // block
+		0, 0, 0, 0} // end mark
+
+	lz4RepeatFrameChecksums = []byte{
+		4, 34, 77, 24, 100, 64, 167,
+		16, 0, 0, 0, 111, 82, 69, 80, 69, 65, 84, 6, 0, 6, 80, 69, 80, 69, 65, 84,
+		0, 0, 0, 0,
+		223, 230, 38, 67} // content checksum
+
+	lz4RepeatFrameBlockChecksums = []byte{
+		4, 34, 77, 24, 112, 64, 173,
+		16, 0, 0, 0, 111, 82, 69, 80, 69, 65, 84, 6, 0, 6, 80, 69, 80, 69, 65, 84,
+		63, 10, 159, 168, // block checksum
+		0, 0, 0, 0}
+)
+
+func TestXXHash32(t *testing.T) {
+	if h := xxHash32(nil, 0); h != 0x02CC5D05 {
+		t.Errorf("Expected 0x02CC5D05 for no input, got %#x", h)
+	}
+	if h := xxHash32([]byte("abc"), 0); h != 0x32D153FF {
+		t.Errorf("Expected 0x32D153FF for abc, got %#x", h)
+	}
+}
+
+func TestLZ4Encode(t *testing.T) {
+	if frame := lz4Encode([]byte(lz4Repeat), false); !bytes.Equal(frame, lz4RepeatFrame) {
+		t.Errorf("Expected %s to generate %v, but was %v", lz4Repeat, lz4RepeatFrame, frame)
+	}
+
+	broken := lz4Encode([]byte(lz4Repeat), true)
+	if broken[6] == lz4RepeatFrame[6] || !bytes.Equal(broken[7:], lz4RepeatFrame[7:]) {
+		t.Errorf("Expected only the header checksum to change for legacy framing, got %v", broken)
+	}
+}
+
+func TestLZ4Decode(t *testing.T) {
+	legacy := lz4Encode([]byte(lz4Repeat), true)
+
+	for name, frame := range map[string][]byte{
+		"plain":            lz4RepeatFrame,
+		"content checksum": lz4RepeatFrameChecksums,
+		"block checksum":   lz4RepeatFrameBlockChecksums,
+		"legacy checksum":  legacy,
+	} {
+		data, err := lz4Decode(frame)
+		if err != nil {
+			t.Error(name, err)
+		} else if string(data) != lz4Repeat {
+			t.Errorf("%s: expected %s, got %s", name, lz4Repeat, data)
+		}
+	}
+
+	// two 64KB blocks, the second of which refers back into the first
+	linked := []byte{4, 34, 77, 24, 64, 64, 192}
+	linked = append(linked, 16, 1, 0, 0, 111, 's', 'a', 'r', 'a', 'm', 'a', 6, 0)
+	linked = append(linked, bytes.Repeat([]byte{255}, 256)...)
+	linked = append(linked, 226, 80, 'a', 's', 'a', 'r', 'a')
+	linked = append(linked, 35, 0, 0, 0, 15, 252, 255)
+	linked = append(linked, bytes.Repeat([]byte{255}, 25)...)
+	linked = append(linked, 65, 80, 'a', 'r', 'a', 'm', 'a', 0, 0, 0, 0)
+	data, err := lz4Decode(linked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, bytes.Repeat([]byte("sarama"), 12000)) {
+		t.Error("Decoding linked blocks produced", len(data), "unexpected bytes")
+	}
+
+	corrupt := make([]byte, len(lz4RepeatFrame))
+	copy(corrupt, lz4RepeatFrame)
+	corrupt[6]++
+	if _, err := lz4Decode(corrupt); err == nil {
+		t.Error("Expected a bad header checksum to be refused")
+	}
+	if _, err := lz4Decode(lz4RepeatFrame[:20]); err == nil {
+		t.Error("Expected a truncated frame to be refused")
+	}
+}
+
+func TestLZ4RoundTrip(t *testing.T) {
+	var random []byte
+	seed := uint32(1)
+	for i := 0; i < 200000; i++ {
+		seed = seed*1664525 + 1013904223
+		random = append(random, byte(seed>>24))
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":  {},
+		"short":  []byte("REALLY SHORT"),
+		"text":   bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 5000),
+		"random": random,
+	} {
+		decoded, err := lz4Decode(lz4Encode(data, false))
+		if err != nil {
+			t.Error(name, err)
+		} else if !bytes.Equal(decoded, data) {
+			t.Error(name, "did not survive a round trip")
+		}
+	}
+}
@@ -1,45 +1,82 @@
 package sarama
 
 import (
-	"bytes"
-	"compress/gzip"
 	"fmt"
-	"io/ioutil"
+	"time"
 )
 
 // CompressionCodec represents the various compression codecs recognized by Kafka in messages.
 type CompressionCodec int8
 
-// only the last two bits are really used
-const compressionCodecMask int8 = 0x03
+// only the last three bits are really used
+const compressionCodecMask int8 = 0x07
+
+// the attribute bit telling, in the 0.10 message format, that the timestamp was set by the broker
+const timestampTypeMask int8 = 0x08
 
 const (
 	CompressionNone   CompressionCodec = 0
 	CompressionGZIP   CompressionCodec = 1
 	CompressionSnappy CompressionCodec = 2
+	CompressionLZ4    CompressionCodec = 3
+	CompressionZSTD   CompressionCodec = 4 // record batches only, requires Kafka 2.1
 )
 
-// The spec just says: "This is a version id used to allow backwards compatible evolution of the message
-// binary format." but it doesn't say what the current value is, so presumably 0...
-const messageFormat int8 = 0
+func (cc CompressionCodec) String() string {
+	switch cc {
+	case CompressionNone:
+		return "none"
+	case CompressionGZIP:
+		return "GZIP"
+	case CompressionSnappy:
+		return "Snappy"
+	case CompressionLZ4:
+		return "LZ4"
+	case CompressionZSTD:
+		return "ZSTD"
+	default:
+		return fmt.Sprintf("unknown(%d)", int8(cc))
+	}
+}
 
 type Message struct {
-	Codec CompressionCodec // codec used to compress the message contents
-	Key   []byte           // the message key, may be nil
-	Value []byte           // the message contents
-	Set   *MessageSet      // the message set a message might wrap
+	Codec            CompressionCodec // codec used to compress the message contents
+	CompressionLevel int              // the level Codec compresses at, zero meaning its default
+	Key              []byte           // the message key, may be nil
+	Value            []byte           // the message contents
+	Set              *MessageSet      // the message set a message might wrap
+	Version          int8             // v1 requires Kafka 0.10
+	Timestamp        time.Time        // the timestamp of the message (version 1+ only)
+	LogAppendTime    bool             // whether Timestamp was set by the broker rather than the producer (version 1+ only)
 
 	compressedCache []byte
 }
 
 func (m *Message) encode(pe packetEncoder) error {
+	if m.Codec == CompressionZSTD {
+		return PacketEncodingError{"ZSTD compression requires the v2 record batch format"}
+	}
+
 	pe.push(&crc32Field{})
 
-	pe.putInt8(messageFormat)
+	pe.putInt8(m.Version)
 
 	attributes := int8(m.Codec) & compressionCodecMask
+	if m.LogAppendTime {
+		attributes |= timestampTypeMask
+	}
 	pe.putInt8(attributes)
 
+	if m.Version >= 1 {
+		timestamp := int64(-1)
+		if !m.Timestamp.Before(time.Unix(0, 0)) {
+			timestamp = m.Timestamp.UnixNano() / int64(time.Millisecond)
+		} else if !m.Timestamp.IsZero() {
+			return PacketEncodingError{fmt.Sprintf("invalid timestamp (%v)", m.Timestamp)}
+		}
+		pe.putInt64(timestamp)
+	}
+
 	err := pe.putBytes(m.Key)
 	if err != nil {
 		return err
@@ -50,28 +87,17 @@ func (m *Message) encode(pe packetEncoder) error {
 	if m.compressedCache != nil {
 		payload = m.compressedCache
 		m.compressedCache = nil
+	} else if m.Codec == CompressionNone {
+		payload = m.Value
+	} else if m.Codec == CompressionLZ4 && m.Version == 0 {
+		// brokers expect the pre-KIP-57 LZ4 framing in messages with magic byte 0
+		m.compressedCache = lz4Encode(m.Value, true)
+		payload = m.compressedCache
 	} else {
-		switch m.Codec {
-		case CompressionNone:
-			payload = m.Value
-		case CompressionGZIP:
-			var buf bytes.Buffer
-			writer := gzip.NewWriter(&buf)
-			if _, err = writer.Write(m.Value); err != nil {
-				return err
-			}
-			if err = writer.Close(); err != nil {
-				return err
-			}
-			m.compressedCache = buf.Bytes()
-			payload = m.compressedCache
-		case CompressionSnappy:
-			tmp := snappyEncode(m.Value)
-			m.compressedCache = tmp
-			payload = m.compressedCache
-		default:
-			return PacketEncodingError{fmt.Sprintf("unsupported compression codec (%d)", m.Codec)}
+		if m.compressedCache, err = compress(m.Codec, m.CompressionLevel, m.Value); err != nil {
+			return err
 		}
+		payload = m.compressedCache
 	}
 
 	if err = pe.putBytes(payload); err != nil {
@@ -87,12 +113,12 @@ func (m *Message) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
-	format, err := pd.getInt8()
+	m.Version, err = pd.getInt8()
 	if err != nil {
 		return err
 	}
-	if format != messageFormat {
-		return PacketDecodingError{"unexpected messageFormat"}
+	if m.Version > 1 {
+		return PacketDecodingError{fmt.Sprintf("unknown magic byte (%v)", m.Version)}
 	}
 
 	attribute, err := pd.getInt8()
@@ -101,6 +127,21 @@ func (m *Message) decode(pd packetDecoder) (err error) {
 	}
 	m.Codec = CompressionCodec(attribute & compressionCodecMask)
 
+	m.Timestamp = time.Time{}
+	m.LogAppendTime = false
+	if m.Version >= 1 {
+		m.LogAppendTime = attribute&timestampTypeMask != 0
+
+		millis, err := pd.getInt64()
+		if err != nil {
+			return err
+		}
+		// a negative timestamp means none was set
+		if millis >= 0 {
+			m.Timestamp = time.Unix(millis/1000, (millis%1000)*int64(time.Millisecond))
+		}
+	}
+
 	m.Key, err = pd.getBytes()
 	if err != nil {
 		return err
@@ -114,25 +155,11 @@ func (m *Message) decode(pd packetDecoder) (err error) {
 	switch m.Codec {
 	case CompressionNone:
 		// nothing to do
-	case CompressionGZIP:
-		if m.Value == nil {
-			return PacketDecodingError{"GZIP compression specified, but no data to uncompress"}
-		}
-		reader, err := gzip.NewReader(bytes.NewReader(m.Value))
-		if err != nil {
-			return err
-		}
-		if m.Value, err = ioutil.ReadAll(reader); err != nil {
-			return err
-		}
-		if err := m.decodeSet(); err != nil {
-			return err
-		}
-	case CompressionSnappy:
+	case CompressionGZIP, CompressionSnappy, CompressionLZ4:
 		if m.Value == nil {
-			return PacketDecodingError{"Snappy compression specified, but no data to uncompress"}
+			return PacketDecodingError{fmt.Sprintf("%s compression specified, but no data to uncompress", m.Codec)}
 		}
-		if m.Value, err = snappyDecode(m.Value); err != nil {
+		if m.Value, err = decompress(m.Codec, m.Value); err != nil {
 			return err
 		}
 		if err := m.decodeSet(); err != nil {
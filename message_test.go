@@ -1,6 +1,11 @@
 package sarama
 
-import "testing"
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+)
 
 var (
 	emptyMessage = []byte{
@@ -41,6 +46,22 @@ var (
 		0x1f, 0x8b, // Gzip Magic
 		0x08, // deflate compressed
 		0, 0, 0, 0, 0, 0, 0, 99, 96, 128, 3, 190, 202, 112, 143, 7, 12, 12, 255, 129, 0, 33, 200, 192, 136, 41, 3, 0, 199, 226, 155, 70, 52, 0, 0, 0}
+
+	emptyV1Message = []byte{
+		70, 20, 188, 130, // CRC
+		0x01,                          // magic version byte
+		0x00,                          // attribute flags
+		0, 0, 1, 88, 141, 205, 89, 56, // timestamp
+		0xFF, 0xFF, 0xFF, 0xFF, // key
+		0xFF, 0xFF, 0xFF, 0xFF} // value
+
+	emptyV1LogAppendTimeMessage = []byte{
+		160, 4, 243, 28, // CRC
+		0x01,                          // magic version byte
+		0x08,                          // attribute flags
+		0, 0, 1, 88, 141, 205, 89, 56, // timestamp
+		0xFF, 0xFF, 0xFF, 0xFF, // key
+		0xFF, 0xFF, 0xFF, 0xFF} // value
 )
 
 func TestMessageEncoding(t *testing.T) {
@@ -111,3 +132,104 @@ func TestMessageDecodingBulkGzip(t *testing.T) {
 		t.Errorf("Decoding produced a set with %d messages, but 2 were expected.", len(message.Set.Messages))
 	}
 }
+
+func TestMessageV1(t *testing.T) {
+	timestamp := time.Unix(1479847795, 0)
+
+	message := Message{Version: 1, Timestamp: timestamp}
+	testEncodable(t, "empty v1", &message, emptyV1Message)
+
+	message = Message{}
+	testDecodable(t, "empty v1", &message, emptyV1Message)
+	if message.Version != 1 {
+		t.Error("Decoding produced version", message.Version, "but expected 1.")
+	}
+	if !message.Timestamp.Equal(timestamp) {
+		t.Error("Decoding produced timestamp", message.Timestamp, "but expected", timestamp)
+	}
+	if message.LogAppendTime {
+		t.Error("Decoding produced a log append time where it was the create time.")
+	}
+
+	message = Message{}
+	testDecodable(t, "log append time", &message, emptyV1LogAppendTimeMessage)
+	if !message.LogAppendTime {
+		t.Error("Decoding did not notice the log append time.")
+	}
+	if !message.Timestamp.Equal(timestamp) {
+		t.Error("Decoding produced timestamp", message.Timestamp, "but expected", timestamp)
+	}
+}
+
+func TestMessageLZ4(t *testing.T) {
+	for _, version := range []int8{0, 1} {
+		set := &MessageSet{}
+		set.addMessage(&Message{Value: []byte("foo"), Version: version})
+		set.addMessage(&Message{Value: []byte("bar"), Version: version})
+		value, err := encode(set)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf, err := encode(&Message{Codec: CompressionLZ4, Value: value, Version: version})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the frame descriptor checksum covers the magic number only in v0 messages
+		frame := buf[len(buf)-len(lz4Encode(value, false)):]
+		brokenChecksum := frame[6] == lz4HeaderChecksum(frame[:6])
+		if brokenChecksum != (version == 0) {
+			t.Errorf("Version %d message was framed with the wrong header checksum", version)
+		}
+
+		message := Message{}
+		testDecodable(t, "lz4", &message, buf)
+		if message.Codec != CompressionLZ4 {
+			t.Errorf("Decoding produced codec %s, but expected LZ4.", message.Codec)
+		}
+		if message.Set == nil || len(message.Set.Messages) != 2 {
+			t.Error("Decoding did not produce the wrapped set.")
+		}
+	}
+}
+
+func TestMessageZSTDRefused(t *testing.T) {
+	if _, err := encode(&Message{Codec: CompressionZSTD, Value: []byte("foo"), Version: 1}); err == nil {
+		t.Error("Expected ZSTD compression to be refused outside of record batches")
+	}
+}
+
+func TestMessageCompressionLevel(t *testing.T) {
+	set := &MessageSet{}
+	for i := 0; i < 10; i++ {
+		set.addMessage(&Message{Value: []byte("REPEATREPEATREPEAT")})
+	}
+	value, err := encode(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sizes []int
+	// the last level exercises the pooled writers
+	for _, level := range []int{gzip.HuffmanOnly, gzip.BestCompression, gzip.BestCompression} {
+		buf, err := encode(&Message{Codec: CompressionGZIP, CompressionLevel: level, Value: value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, len(buf))
+
+		message := Message{}
+		testDecodable(t, "gzip level", &message, buf)
+		if !bytes.Equal(message.Value, value) || len(message.Set.Messages) != 10 {
+			t.Errorf("Decoding level %d did not produce the wrapped set", level)
+		}
+	}
+	if sizes[1] >= sizes[0] || sizes[2] != sizes[1] {
+		t.Error("Unexpected message sizes across compression levels:", sizes)
+	}
+
+	if _, err := encode(&Message{Codec: CompressionGZIP, CompressionLevel: 42, Value: value}); err == nil {
+		t.Error("Expected an invalid gzip level to be refused")
+	}
+}
@@ -1,40 +1,81 @@
 package sarama
 
 type MetadataRequest struct {
+	// Topics to fetch the metadata of. In version 0 an empty list stands for all the topics;
+	// from version 1 on only nil does, an empty list asking for none.
 	Topics []string
+	// Version can be:
+	// - 0 (kafka 0.8 and later)
+	// - 1 (kafka 0.10 and later, the response carries the controller ID, the racks of the
+	//   brokers and whether topics are internal)
+	// - 2 (kafka 0.10.1 and later, the response carries the cluster ID)
+	// - 3 (kafka 0.11 and later, the response carries the throttle time)
+	// - 4 (kafka 1.0 and later, adds AllowAutoTopicCreation)
+	// - 5 (kafka 1.0 and later, the response carries the offline replicas)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version int16
+	// AllowAutoTopicCreation lets the broker create the requested topics which do not exist, if its
+	// `auto.create.topics.enable` setting allows it (version 4+ only, earlier versions always let it).
+	// It is set if the request is raised to version 4 from an earlier one.
+	AllowAutoTopicCreation bool
 }
 
 func (mr *MetadataRequest) encode(pe packetEncoder) error {
-	err := pe.putArrayLength(len(mr.Topics))
-	if err != nil {
-		return err
+	if mr.Version < 0 || mr.Version > 5 {
+		return PacketEncodingError{"invalid or unsupported MetadataRequest version field"}
 	}
 
-	for i := range mr.Topics {
-		err = pe.putString(mr.Topics[i])
+	if mr.Version >= 1 && mr.Topics == nil {
+		pe.putInt32(-1)
+	} else {
+		err := pe.putArrayLength(len(mr.Topics))
 		if err != nil {
 			return err
 		}
+
+		for i := range mr.Topics {
+			err = pe.putString(mr.Topics[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if mr.Version >= 4 {
+		pe.putBool(mr.AllowAutoTopicCreation)
 	}
 	return nil
 }
 
 func (mr *MetadataRequest) decode(pd packetDecoder) error {
-	topicCount, err := pd.getArrayLength()
+	topicCount, err := pd.getInt32()
 	if err != nil {
 		return err
 	}
-	if topicCount == 0 {
-		return nil
+	switch {
+	case topicCount < 0 && mr.Version >= 1:
+		mr.Topics = nil
+	case topicCount < 0 || int(topicCount) > pd.remaining():
+		return PacketDecodingError{"invalid array length"}
+	case topicCount == 0:
+		if mr.Version >= 1 {
+			mr.Topics = []string{}
+		}
+	default:
+		mr.Topics = make([]string, topicCount)
+		for i := range mr.Topics {
+			topic, err := pd.getString()
+			if err != nil {
+				return err
+			}
+			mr.Topics[i] = topic
+		}
 	}
 
-	mr.Topics = make([]string, topicCount)
-	for i := range mr.Topics {
-		topic, err := pd.getString()
-		if err != nil {
+	if mr.Version >= 4 {
+		if mr.AllowAutoTopicCreation, err = pd.getBool(); err != nil {
 			return err
 		}
-		mr.Topics[i] = topic
 	}
 	return nil
 }
@@ -44,5 +85,39 @@ func (mr *MetadataRequest) key() int16 {
 }
 
 func (mr *MetadataRequest) version() int16 {
-	return 0
+	return mr.Version
+}
+
+func (mr *MetadataRequest) setVersion(v int16) {
+	if mr.Version == 0 && v >= 1 && len(mr.Topics) == 0 {
+		mr.Topics = nil // keep asking for all the topics
+	}
+	if mr.Version < 4 && v >= 4 {
+		mr.AllowAutoTopicCreation = true // keep letting the broker config decide
+	}
+	mr.Version = v
+}
+
+func (mr *MetadataRequest) shallowCopy() versionedRequest {
+	c := *mr
+	return &c
+}
+
+func (mr *MetadataRequest) maxVersion() int16 {
+	return 5
+}
+
+func (mr *MetadataRequest) requiredVersion() KafkaVersion {
+	switch mr.Version {
+	case 1:
+		return V0_10_0_0
+	case 2:
+		return V0_10_1_0
+	case 3:
+		return V0_11_0_0
+	case 4, 5:
+		return V1_0_0_0
+	default:
+		return minVersion
+	}
 }
@@ -27,3 +27,61 @@ func TestMetadataRequest(t *testing.T) {
 	request.Topics = []string{"foo", "bar", "baz"}
 	testRequest(t, "three topics", request, metadataRequestThreeTopics)
 }
+
+func TestMetadataRequestV1(t *testing.T) {
+	request := &MetadataRequest{Version: 1}
+	testRequest(t, "all topics", request, []byte{0xFF, 0xFF, 0xFF, 0xFF})
+
+	request.Topics = []string{}
+	testRequest(t, "no topics", request, metadataRequestNoTopics)
+
+	request.Topics = []string{"topic1"}
+	testRequest(t, "one topic", request, metadataRequestOneTopic)
+
+	if request.requiredVersion() != V0_10_0_0 {
+		t.Error("Expected version 1 to require Kafka 0.10, got", request.requiredVersion())
+	}
+}
+
+func TestMetadataRequestRaisedToV1(t *testing.T) {
+	request := &MetadataRequest{Topics: []string{}}
+	request.setVersion(1)
+	if request.Topics != nil {
+		t.Error("Expected a request for all topics to keep asking for all of them at version 1")
+	}
+
+	request = &MetadataRequest{Topics: []string{"topic1"}}
+	request.setVersion(1)
+	if len(request.Topics) != 1 {
+		t.Error("Expected the topics of the request to be kept, got", request.Topics)
+	}
+}
+
+func TestMetadataRequestV4(t *testing.T) {
+	request := &MetadataRequest{Version: 4, AllowAutoTopicCreation: true}
+	testRequest(t, "all topics", request, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x01})
+
+	request = &MetadataRequest{Version: 4, Topics: []string{"topic1"}}
+	testRequest(t, "one topic", request, []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x06, 't', 'o', 'p', 'i', 'c', '1',
+		0x00})
+
+	if request.requiredVersion() != V1_0_0_0 {
+		t.Error("Expected version 4 to require Kafka 1.0, got", request.requiredVersion())
+	}
+}
+
+func TestMetadataRequestRaisedToV4(t *testing.T) {
+	request := &MetadataRequest{Version: 1}
+	request.setVersion(4)
+	if !request.AllowAutoTopicCreation {
+		t.Error("Expected a request raised to version 4 to leave topic creation to the broker config")
+	}
+
+	request = &MetadataRequest{Version: 4}
+	request.setVersion(5)
+	if request.AllowAutoTopicCreation {
+		t.Error("Expected a version 4 request to keep its AllowAutoTopicCreation")
+	}
+}
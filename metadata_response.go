@@ -1,14 +1,17 @@
 package sarama
 
+import "time"
+
 type PartitionMetadata struct {
-	Err      KError
-	ID       int32
-	Leader   int32
-	Replicas []int32
-	Isr      []int32
+	Err             KError
+	ID              int32
+	Leader          int32
+	Replicas        []int32
+	Isr             []int32
+	OfflineReplicas []int32 // the replicas whose log directory is offline (version 5+ only)
 }
 
-func (pm *PartitionMetadata) decode(pd packetDecoder) (err error) {
+func (pm *PartitionMetadata) decode(pd packetDecoder, version int16) (err error) {
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -35,10 +38,17 @@ func (pm *PartitionMetadata) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
+	if version >= 5 {
+		pm.OfflineReplicas, err = pd.getInt32Array()
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (pm *PartitionMetadata) encode(pe packetEncoder) (err error) {
+func (pm *PartitionMetadata) encode(pe packetEncoder, version int16) (err error) {
 	pe.putInt16(int16(pm.Err))
 	pe.putInt32(pm.ID)
 	pe.putInt32(pm.Leader)
@@ -53,16 +63,24 @@ func (pm *PartitionMetadata) encode(pe packetEncoder) (err error) {
 		return err
 	}
 
+	if version >= 5 {
+		err = pe.putInt32Array(pm.OfflineReplicas)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 type TopicMetadata struct {
 	Err        KError
 	Name       string
+	IsInternal bool // whether the topic is internal to Kafka, such as __consumer_offsets (version 1+ only)
 	Partitions []*PartitionMetadata
 }
 
-func (tm *TopicMetadata) decode(pd packetDecoder) (err error) {
+func (tm *TopicMetadata) decode(pd packetDecoder, version int16) (err error) {
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -74,6 +92,12 @@ func (tm *TopicMetadata) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
+	if version >= 1 {
+		if tm.IsInternal, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+
 	n, err := pd.getArrayLength()
 	if err != nil {
 		return err
@@ -81,7 +105,7 @@ func (tm *TopicMetadata) decode(pd packetDecoder) (err error) {
 	tm.Partitions = make([]*PartitionMetadata, n)
 	for i := 0; i < n; i++ {
 		tm.Partitions[i] = new(PartitionMetadata)
-		err = tm.Partitions[i].decode(pd)
+		err = tm.Partitions[i].decode(pd, version)
 		if err != nil {
 			return err
 		}
@@ -90,7 +114,7 @@ func (tm *TopicMetadata) decode(pd packetDecoder) (err error) {
 	return nil
 }
 
-func (tm *TopicMetadata) encode(pe packetEncoder) (err error) {
+func (tm *TopicMetadata) encode(pe packetEncoder, version int16) (err error) {
 	pe.putInt16(int16(tm.Err))
 
 	err = pe.putString(tm.Name)
@@ -98,13 +122,17 @@ func (tm *TopicMetadata) encode(pe packetEncoder) (err error) {
 		return err
 	}
 
+	if version >= 1 {
+		pe.putBool(tm.IsInternal)
+	}
+
 	err = pe.putArrayLength(len(tm.Partitions))
 	if err != nil {
 		return err
 	}
 
 	for _, pm := range tm.Partitions {
-		err = pm.encode(pe)
+		err = pm.encode(pe, version)
 		if err != nil {
 			return err
 		}
@@ -116,9 +144,22 @@ func (tm *TopicMetadata) encode(pe packetEncoder) (err error) {
 type MetadataResponse struct {
 	Brokers []*Broker
 	Topics  []*TopicMetadata
+	// Version must match the version of the request, it is set by the Broker before decoding
+	Version      int16
+	ThrottleTime time.Duration // version 3+ only
+	ClusterID    *string       // version 2+ only
+	ControllerID int32         // the ID of the controller broker, -1 if there is none (version 1+ only)
 }
 
 func (m *MetadataResponse) decode(pd packetDecoder) (err error) {
+	if m.Version >= 3 {
+		throttle, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		m.ThrottleTime = time.Duration(throttle) * time.Millisecond
+	}
+
 	n, err := pd.getArrayLength()
 	if err != nil {
 		return err
@@ -131,6 +172,23 @@ func (m *MetadataResponse) decode(pd packetDecoder) (err error) {
 		if err != nil {
 			return err
 		}
+		if m.Version >= 1 {
+			if m.Brokers[i].rack, err = pd.getNullableString(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if m.Version >= 2 {
+		if m.ClusterID, err = pd.getNullableString(); err != nil {
+			return err
+		}
+	}
+
+	if m.Version >= 1 {
+		if m.ControllerID, err = pd.getInt32(); err != nil {
+			return err
+		}
 	}
 
 	n, err = pd.getArrayLength()
@@ -141,7 +199,7 @@ func (m *MetadataResponse) decode(pd packetDecoder) (err error) {
 	m.Topics = make([]*TopicMetadata, n)
 	for i := 0; i < n; i++ {
 		m.Topics[i] = new(TopicMetadata)
-		err = m.Topics[i].decode(pd)
+		err = m.Topics[i].decode(pd, m.Version)
 		if err != nil {
 			return err
 		}
@@ -151,6 +209,10 @@ func (m *MetadataResponse) decode(pd packetDecoder) (err error) {
 }
 
 func (m *MetadataResponse) encode(pe packetEncoder) error {
+	if m.Version >= 3 {
+		pe.putInt32(int32(m.ThrottleTime / time.Millisecond))
+	}
+
 	err := pe.putArrayLength(len(m.Brokers))
 	if err != nil {
 		return err
@@ -160,6 +222,21 @@ func (m *MetadataResponse) encode(pe packetEncoder) error {
 		if err != nil {
 			return err
 		}
+		if m.Version >= 1 {
+			if err = pe.putNullableString(broker.rack); err != nil {
+				return err
+			}
+		}
+	}
+
+	if m.Version >= 2 {
+		if err = pe.putNullableString(m.ClusterID); err != nil {
+			return err
+		}
+	}
+
+	if m.Version >= 1 {
+		pe.putInt32(m.ControllerID)
 	}
 
 	err = pe.putArrayLength(len(m.Topics))
@@ -167,7 +244,7 @@ func (m *MetadataResponse) encode(pe packetEncoder) error {
 		return err
 	}
 	for _, tm := range m.Topics {
-		err = tm.encode(pe)
+		err = tm.encode(pe, m.Version)
 		if err != nil {
 			return err
 		}
@@ -176,6 +253,10 @@ func (m *MetadataResponse) encode(pe packetEncoder) error {
 	return nil
 }
 
+func (m *MetadataResponse) setVersion(v int16) {
+	m.Version = v
+}
+
 // testing API
 
 func (m *MetadataResponse) AddBroker(addr string, id int32) {
@@ -1,6 +1,10 @@
 package sarama
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+	"time"
+)
 
 var (
 	emptyMetadataResponse = []byte{
@@ -137,3 +141,93 @@ func TestMetadataResponseWithTopics(t *testing.T) {
 		t.Error("Decoding produced invalid partition count for topic 1.")
 	}
 }
+
+func TestMetadataResponseV1(t *testing.T) {
+	buf := []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0xab, 0xff,
+		0x00, 0x09, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't',
+		0x00, 0x00, 0x00, 0x33,
+		0x00, 0x05, 'r', 'a', 'c', 'k', '1', // rack
+
+		0x00, 0x00, 0xab, 0xff, // controller ID
+
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00,
+		0x00, 0x03, 'f', 'o', 'o',
+		0x01, // is internal
+		0x00, 0x00, 0x00, 0x00}
+
+	response := MetadataResponse{Version: 1}
+	testDecodable(t, "v1", &response, buf)
+	if response.ControllerID != 0xabff {
+		t.Error("Decoding produced invalid controller ID", response.ControllerID)
+	}
+	if len(response.Brokers) != 1 || response.Brokers[0].addr != "localhost:51" || response.Brokers[0].Rack() != "rack1" {
+		t.Error("Decoding produced invalid brokers.")
+	}
+	if len(response.Topics) != 1 || response.Topics[0].Name != "foo" || !response.Topics[0].IsInternal {
+		t.Error("Decoding produced invalid topics.")
+	}
+
+	response = MetadataResponse{Version: 1, ControllerID: 7}
+	response.AddBroker("localhost:51", 0xabff)
+	response.AddTopic("foo", ErrNoError)
+	testEncodable(t, "v1", &response, []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0xab, 0xff,
+		0x00, 0x09, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't',
+		0x00, 0x00, 0x00, 0x33,
+		0xFF, 0xFF, // rack
+		0x00, 0x00, 0x00, 0x07,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00,
+		0x00, 0x03, 'f', 'o', 'o',
+		0x00,
+		0x00, 0x00, 0x00, 0x00})
+}
+
+func TestMetadataResponseV5(t *testing.T) {
+	buf := []byte{
+		0x00, 0x00, 0x00, 0x64, // throttle time
+
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x09, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't',
+		0x00, 0x00, 0x00, 0x33,
+		0xFF, 0xFF, // rack
+
+		0x00, 0x07, 'c', 'l', 'u', 's', 't', 'e', 'r', // cluster ID
+		0x00, 0x00, 0x00, 0x01, // controller ID
+
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00,
+		0x00, 0x03, 'f', 'o', 'o',
+		0x00,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, // replicas
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, // isr
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02} // offline replicas
+
+	response := MetadataResponse{Version: 5}
+	testDecodable(t, "v5", &response, buf)
+
+	clusterID := "cluster"
+	expected := &MetadataResponse{
+		Version:      5,
+		ThrottleTime: 100 * time.Millisecond,
+		ClusterID:    &clusterID,
+		ControllerID: 1,
+	}
+	expected.AddBroker("localhost:51", 1)
+	expected.AddTopicPartition("foo", 0, 1, []int32{1, 2}, []int32{1}, ErrNoError)
+	expected.Topics[0].Partitions[0].OfflineReplicas = []int32{2}
+	if !reflect.DeepEqual(&response, expected) {
+		t.Errorf("Decoding produced %+v, expected %+v", response, expected)
+	}
+
+	testEncodable(t, "v5", expected, buf)
+}
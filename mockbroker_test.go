@@ -21,6 +21,11 @@ const (
 
 type requestHandlerFunc func(req *request) (res encoder)
 
+// saslHandlerFunc answers a raw SASL token sent after a successful SASL handshake. It returns the
+// challenge to send back and whether the exchange is complete; returning an error rejects the
+// client, which makes the mock broker close the connection like Kafka does.
+type saslHandlerFunc func(token []byte) (challenge []byte, done bool, err error)
+
 // mockBroker is a mock Kafka broker. It consists of a TCP server on a
 // kernel-selected localhost port that can accept many connections. It reads
 // Kafka requests from that connection and passes them to the user specified
@@ -46,6 +51,8 @@ type mockBroker struct {
 	t            *testing.T
 	latency      time.Duration
 	handler      requestHandlerFunc
+	saslHandler  saslHandlerFunc
+	apiVersions  *ApiVersionsResponse
 	history      []RequestResponse
 	lock         sync.Mutex
 }
@@ -68,6 +75,22 @@ func (b *mockBroker) SetHandler(handler requestHandlerFunc) {
 	b.lock.Unlock()
 }
 
+// SetApiVersions sets the response the mock broker answers ApiVersionsRequests
+// with. By default it advertises every version Sarama implements.
+func (b *mockBroker) SetApiVersions(res *ApiVersionsResponse) {
+	b.lock.Lock()
+	b.apiVersions = res
+	b.lock.Unlock()
+}
+
+// SetSASLHandler sets the function that answers the raw SASL tokens a client
+// sends once the handler answered its SaslHandshakeRequest without error.
+func (b *mockBroker) SetSASLHandler(handler saslHandlerFunc) {
+	b.lock.Lock()
+	b.saslHandler = handler
+	b.lock.Unlock()
+}
+
 func (b *mockBroker) SetHandlerByMap(handlerMap map[string]MockResponse) {
 	b.SetHandler(func(req *request) (res encoder) {
 		reqTypeName := reflect.TypeOf(req.body).Elem().Name()
@@ -152,7 +175,18 @@ func (b *mockBroker) handleRequests(conn net.Conn, idx int, wg *sync.WaitGroup)
 	}()
 
 	resHeader := make([]byte, 8)
+	authenticating := false
 	for {
+		if authenticating {
+			done, err := b.handleSASLToken(conn, idx)
+			if err != nil {
+				Logger.Printf("*** mockbroker/%d/%d: SASL authentication failed: %v", b.brokerID, idx, err)
+				break
+			}
+			authenticating = !done
+			continue
+		}
+
 		req, err := decodeRequest(conn)
 		if err != nil {
 			Logger.Printf("*** mockbroker/%d/%d: invalid request: err=%+v, %+v", b.brokerID, idx, err, spew.Sdump(req))
@@ -165,7 +199,16 @@ func (b *mockBroker) handleRequests(conn net.Conn, idx int, wg *sync.WaitGroup)
 		}
 
 		b.lock.Lock()
-		res := b.handler(req)
+		var res encoder
+		if _, ok := req.body.(*ApiVersionsRequest); ok {
+			res = b.apiVersions
+		} else {
+			res = b.handler(req)
+		}
+		// responses are encoded at the version of the request they answer
+		if vr, ok := res.(versionedResponse); ok {
+			vr.setVersion(req.body.version())
+		}
 		b.history = append(b.history, RequestResponse{req.body, res})
 		b.lock.Unlock()
 
@@ -173,6 +216,9 @@ func (b *mockBroker) handleRequests(conn net.Conn, idx int, wg *sync.WaitGroup)
 			Logger.Printf("*** mockbroker/%d/%d: ignored %v", b.brokerID, idx, spew.Sdump(req))
 			continue
 		}
+		if handshake, ok := res.(*SaslHandshakeResponse); ok && handshake.Err == ErrNoError {
+			authenticating = true
+		}
 		Logger.Printf("*** mockbroker/%d/%d: served %v -> %v", b.brokerID, idx, req, res)
 
 		encodedRes, err := encode(res)
@@ -198,6 +244,36 @@ func (b *mockBroker) handleRequests(conn net.Conn, idx int, wg *sync.WaitGroup)
 	Logger.Printf("*** mockbroker/%d/%d: connection closed, err=%v", b.BrokerID(), idx, err)
 }
 
+func (b *mockBroker) handleSASLToken(conn net.Conn, idx int) (bool, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return false, err
+	}
+	token := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := io.ReadFull(conn, token); err != nil {
+		return false, err
+	}
+
+	b.lock.Lock()
+	handler := b.saslHandler
+	b.lock.Unlock()
+	if handler == nil {
+		return false, fmt.Errorf("no SASL handler to answer %q", token)
+	}
+
+	challenge, done, err := handler(token)
+	if err != nil {
+		return false, err
+	}
+	Logger.Printf("*** mockbroker/%d/%d: served SASL token %q -> %q", b.brokerID, idx, token, challenge)
+
+	binary.BigEndian.PutUint32(header, uint32(len(challenge)))
+	if _, err := conn.Write(append(header, challenge...)); err != nil {
+		return false, err
+	}
+	return done, nil
+}
+
 func (b *mockBroker) defaultRequestHandler(req *request) (res encoder) {
 	select {
 	case res, ok := <-b.expectations:
@@ -245,6 +321,7 @@ func newMockBrokerAddr(t *testing.T, brokerID int32, addr string) *mockBroker {
 		t:            t,
 		brokerID:     brokerID,
 		expectations: make(chan encoder, 512),
+		apiVersions:  newMockApiVersionsResponse(),
 	}
 	broker.handler = broker.defaultRequestHandler
 
@@ -268,6 +345,25 @@ func newMockBrokerAddr(t *testing.T, brokerID int32, addr string) *mockBroker {
 	return broker
 }
 
+// newMockApiVersionsResponse advertises every request Sarama knows how to
+// decode, from version 0 up to the highest version it implements.
+func newMockApiVersionsResponse() *ApiVersionsResponse {
+	res := &ApiVersionsResponse{}
+	for key := int16(0); key < 100; key++ {
+		body := allocateBody(key, 0)
+		if body == nil {
+			continue
+		}
+		block := &ApiVersionsResponseBlock{ApiKey: key}
+		// some requests are only decoded as versioned ones from version 1 on
+		if versioned, ok := allocateBody(key, 1).(versionedRequest); ok {
+			block.MaxVersion = versioned.maxVersion()
+		}
+		res.ApiVersions = append(res.ApiVersions, block)
+	}
+	return res
+}
+
 func (b *mockBroker) Returns(e encoder) {
 	b.expectations <- e
 }
@@ -24,16 +24,18 @@ func newMockWrapper(res encoder) *mockWrapper {
 
 // mockMetadataResponse is a `MetadataResponse` builder.
 type mockMetadataResponse struct {
-	leaders map[string]map[int32]int32
-	brokers map[string]int32
-	t       *testing.T
+	leaders    map[string]map[int32]int32
+	brokers    map[string]int32
+	controller int32
+	t          *testing.T
 }
 
 func newMockMetadataResponse(t *testing.T) *mockMetadataResponse {
 	return &mockMetadataResponse{
-		leaders: make(map[string]map[int32]int32),
-		brokers: make(map[string]int32),
-		t:       t,
+		leaders:    make(map[string]map[int32]int32),
+		brokers:    make(map[string]int32),
+		controller: -1,
+		t:          t,
 	}
 }
 
@@ -52,13 +54,19 @@ func (mmr *mockMetadataResponse) SetBroker(addr string, brokerID int32) *mockMet
 	return mmr
 }
 
+func (mmr *mockMetadataResponse) SetController(brokerID int32) *mockMetadataResponse {
+	mmr.controller = brokerID
+	return mmr
+}
+
 func (mor *mockMetadataResponse) For(reqBody decoder) encoder {
 	metadataRequest := reqBody.(*MetadataRequest)
-	metadataResponse := &MetadataResponse{}
+	metadataResponse := &MetadataResponse{ControllerID: mor.controller}
 	for addr, brokerID := range mor.brokers {
 		metadataResponse.AddBroker(addr, brokerID)
 	}
-	if len(metadataRequest.Topics) == 0 {
+	// a version 0 request for all topics decodes to nil Topics, just like a version 1 one
+	if metadataRequest.Topics == nil {
 		for topic, partitions := range mor.leaders {
 			for partition, brokerID := range partitions {
 				metadataResponse.AddTopicPartition(topic, partition, brokerID, nil, nil, ErrNoError)
@@ -182,7 +190,10 @@ func (mfr *mockFetchResponse) For(reqBody decoder) encoder {
 			maxOffset := initialOffset + int64(mfr.getMessageCount(topic, partition))
 			for i := 0; i < mfr.batchSize && offset < maxOffset; {
 				msg := mfr.getMessage(topic, partition, offset)
-				if msg != nil {
+				if msg != nil && fetchRequest.Version >= 4 {
+					res.AddRecord(topic, partition, nil, msg, offset)
+					i++
+				} else if msg != nil {
 					res.AddMessage(topic, partition, nil, msg, offset)
 					i++
 				}
@@ -268,6 +279,48 @@ func (mr *mockConsumerMetadataResponse) For(reqBody decoder) encoder {
 	return res
 }
 
+// mockFindCoordinatorResponse is a `FindCoordinatorResponse` builder.
+type mockFindCoordinatorResponse struct {
+	coordinators map[CoordinatorType]map[string]interface{}
+	t            *testing.T
+}
+
+func newMockFindCoordinatorResponse(t *testing.T) *mockFindCoordinatorResponse {
+	return &mockFindCoordinatorResponse{
+		coordinators: make(map[CoordinatorType]map[string]interface{}),
+		t:            t,
+	}
+}
+
+func (mr *mockFindCoordinatorResponse) set(coordinatorType CoordinatorType, key string, v interface{}) *mockFindCoordinatorResponse {
+	if mr.coordinators[coordinatorType] == nil {
+		mr.coordinators[coordinatorType] = make(map[string]interface{})
+	}
+	mr.coordinators[coordinatorType][key] = v
+	return mr
+}
+
+func (mr *mockFindCoordinatorResponse) SetCoordinator(coordinatorType CoordinatorType, key string, broker *mockBroker) *mockFindCoordinatorResponse {
+	return mr.set(coordinatorType, key, broker)
+}
+
+func (mr *mockFindCoordinatorResponse) SetError(coordinatorType CoordinatorType, key string, kerror KError) *mockFindCoordinatorResponse {
+	return mr.set(coordinatorType, key, kerror)
+}
+
+func (mr *mockFindCoordinatorResponse) For(reqBody decoder) encoder {
+	req := reqBody.(*FindCoordinatorRequest)
+	res := &FindCoordinatorResponse{}
+	v := mr.coordinators[req.CoordinatorType][req.CoordinatorKey]
+	switch v := v.(type) {
+	case *mockBroker:
+		res.Coordinator = &Broker{id: v.BrokerID(), addr: v.Addr()}
+	case KError:
+		res.Err = v
+	}
+	return res
+}
+
 // mockOffsetCommitResponse is a `OffsetCommitResponse` builder.
 type mockOffsetCommitResponse struct {
 	errors map[string]map[string]map[int32]KError
@@ -355,6 +408,11 @@ func (mr *mockProduceResponse) For(reqBody decoder) encoder {
 			res.AddTopicPartition(topic, partition, mr.getError(topic, partition))
 		}
 	}
+	for topic, partitions := range req.recordBatches {
+		for partition := range partitions {
+			res.AddTopicPartition(topic, partition, mr.getError(topic, partition))
+		}
+	}
 	return res
 }
 
@@ -409,3 +467,18 @@ func (mr *mockOffsetFetchResponse) For(reqBody decoder) encoder {
 	}
 	return res
 }
+
+// mockSyncGroupResponse is a `SyncGroupResponse` builder that hands every member
+// the assignment the group leader computed for it.
+type mockSyncGroupResponse struct {
+	t *testing.T
+}
+
+func newMockSyncGroupResponse(t *testing.T) *mockSyncGroupResponse {
+	return &mockSyncGroupResponse{t: t}
+}
+
+func (mr *mockSyncGroupResponse) For(reqBody decoder) encoder {
+	req := reqBody.(*SyncGroupRequest)
+	return &SyncGroupResponse{MemberAssignment: req.GroupAssignments[req.MemberID]}
+}
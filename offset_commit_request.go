@@ -39,12 +39,16 @@ type OffsetCommitRequest struct {
 	ConsumerGroup           string
 	ConsumerGroupGeneration int32  // v1 or later
 	ConsumerID              string // v1 or later
-	RetentionTime           int64  // v2 or later
+	RetentionTime           int64  // v2 or later, -1 for the broker's default
 
 	// Version can be:
-	// - 0 (kafka 0.8.1 and later)
-	// - 1 (kafka 0.8.2 and later)
-	// - 2 (kafka 0.8.3 and later)
+	// - 0 (kafka 0.8.1 and later, offsets stored in zookeeper)
+	// - 1 (kafka 0.8.2 and later, offsets stored in kafka)
+	// - 2 (kafka 0.9.0 and later)
+	// It is the lowest version the request may be sent at; the broker sends it at
+	// the highest version allowed by Config.Version and the broker itself. Version
+	// 0 requests are never raised though, as that would move the offsets from
+	// zookeeper to kafka.
 	Version int16
 	blocks  map[string]map[int32]*offsetCommitRequestBlock
 }
@@ -74,7 +78,7 @@ func (r *OffsetCommitRequest) encode(pe packetEncoder) error {
 
 	if r.Version >= 2 {
 		pe.putInt64(r.RetentionTime)
-	} else if r.RetentionTime != 0 {
+	} else if r.RetentionTime != 0 && r.RetentionTime != -1 {
 		Logger.Println("Non-zero RetentionTime specified for OffsetCommitRequest version <2, it will be ignored")
 	}
 
@@ -159,6 +163,31 @@ func (r *OffsetCommitRequest) version() int16 {
 	return r.Version
 }
 
+func (r *OffsetCommitRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *OffsetCommitRequest) shallowCopy() versionedRequest {
+	c := *r
+	return &c
+}
+
+func (r *OffsetCommitRequest) maxVersion() int16 {
+	if r.Version == 0 {
+		return 0 // keep storing the offsets in zookeeper
+	}
+	return 2
+}
+
+func (r *OffsetCommitRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 2:
+		return V0_9_0_0
+	default:
+		return minVersion
+	}
+}
+
 func (r *OffsetCommitRequest) AddBlock(topic string, partitionID int32, offset int64, timestamp int64, metadata string) {
 	if r.blocks == nil {
 		r.blocks = make(map[string]map[int32]*offsetCommitRequestBlock)
@@ -2,8 +2,12 @@ package sarama
 
 type OffsetFetchRequest struct {
 	ConsumerGroup string
-	Version       int16
-	partitions    map[string][]int32
+	// Version can be:
+	// - 0 (kafka 0.8.1 and later, offsets fetched from zookeeper)
+	// - 1 (kafka 0.8.2 and later, offsets fetched from kafka)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version    int16
+	partitions map[string][]int32
 }
 
 func (r *OffsetFetchRequest) encode(pe packetEncoder) (err error) {
@@ -62,6 +66,26 @@ func (r *OffsetFetchRequest) version() int16 {
 	return r.Version
 }
 
+func (r *OffsetFetchRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *OffsetFetchRequest) shallowCopy() versionedRequest {
+	c := *r
+	return &c
+}
+
+func (r *OffsetFetchRequest) maxVersion() int16 {
+	if r.Version == 0 {
+		return 0 // keep fetching the offsets from zookeeper
+	}
+	return 1
+}
+
+func (r *OffsetFetchRequest) requiredVersion() KafkaVersion {
+	return minVersion
+}
+
 func (r *OffsetFetchRequest) AddPartition(topic string, partitionID int32) {
 	if r.partitions == nil {
 		r.partitions = make(map[string][]int32)
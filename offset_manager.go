@@ -26,6 +26,10 @@ type offsetManager struct {
 	conf   *Config
 	group  string
 
+	// only set when the offsets are committed on behalf of a ConsumerGroup member
+	memberID   string
+	generation int32
+
 	lock sync.Mutex
 	poms map[string]map[int32]*partitionOffsetManager
 	boms map[*Broker]*brokerOffsetManager
@@ -34,17 +38,25 @@ type offsetManager struct {
 // NewOffsetManagerFromClient creates a new OffsetManager from the given client.
 // It is still necessary to call Close() on the underlying client when finished with the partition manager.
 func NewOffsetManagerFromClient(group string, client Client) (OffsetManager, error) {
+	return newOffsetManagerFromClient(group, "", 0, client)
+}
+
+// newOffsetManagerFromClient creates an OffsetManager that commits offsets as the given member
+// and generation of a consumer group, which the coordinator requires once the group is managed by Kafka.
+func newOffsetManagerFromClient(group, memberID string, generation int32, client Client) (OffsetManager, error) {
 	// Check that we are not dealing with a closed Client before processing any other arguments
 	if client.Closed() {
 		return nil, ErrClosedClient
 	}
 
 	om := &offsetManager{
-		client: client,
-		conf:   client.Config(),
-		group:  group,
-		poms:   make(map[string]map[int32]*partitionOffsetManager),
-		boms:   make(map[*Broker]*brokerOffsetManager),
+		client:     client,
+		conf:       client.Config(),
+		group:      group,
+		memberID:   memberID,
+		generation: generation,
+		poms:       make(map[string]map[int32]*partitionOffsetManager),
+		boms:       make(map[*Broker]*brokerOffsetManager),
 	}
 
 	return om, nil
@@ -339,6 +351,20 @@ func (pom *partitionOffsetManager) updateCommitted(offset int64, metadata string
 	}
 }
 
+// discardPending drops an offset that can no longer be committed, so that a pending AsyncClose
+// does not wait for it forever.
+func (pom *partitionOffsetManager) discardPending() {
+	pom.lock.Lock()
+	defer pom.lock.Unlock()
+
+	pom.dirty = false
+
+	select {
+	case pom.clean <- none{}:
+	default:
+	}
+}
+
 func (pom *partitionOffsetManager) NextOffset() (int64, string) {
 	pom.lock.Lock()
 	defer pom.lock.Unlock()
@@ -466,6 +492,10 @@ func (bom *brokerOffsetManager) flushToBroker() {
 		case ErrUnknownTopicOrPartition, ErrNotLeaderForPartition, ErrLeaderNotAvailable:
 			delete(bom.subscriptions, s)
 			s.rebalance <- none{}
+		case ErrIllegalGeneration, ErrUnknownMemberID, ErrRebalanceInProgress:
+			// the group generation we are committing for has ended, retrying can never succeed
+			s.handleError(err)
+			s.discardPending()
 		default:
 			s.handleError(err)
 			delete(bom.subscriptions, s)
@@ -476,8 +506,11 @@ func (bom *brokerOffsetManager) flushToBroker() {
 
 func (bom *brokerOffsetManager) constructRequest() *OffsetCommitRequest {
 	r := &OffsetCommitRequest{
-		Version:       1,
-		ConsumerGroup: bom.parent.group,
+		Version:                 1,
+		ConsumerGroup:           bom.parent.group,
+		ConsumerGroupGeneration: bom.parent.generation,
+		ConsumerID:              bom.parent.memberID,
+		RetentionTime:           -1,
 	}
 
 	for s := range bom.subscriptions {
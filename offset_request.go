@@ -5,27 +5,42 @@ type offsetRequestBlock struct {
 	maxOffsets int32
 }
 
-func (r *offsetRequestBlock) encode(pe packetEncoder) error {
+func (r *offsetRequestBlock) encode(pe packetEncoder, version int16) error {
 	pe.putInt64(int64(r.time))
-	pe.putInt32(r.maxOffsets)
+	if version == 0 {
+		pe.putInt32(r.maxOffsets)
+	}
 	return nil
 }
 
-func (r *offsetRequestBlock) decode(pd packetDecoder) (err error) {
+func (r *offsetRequestBlock) decode(pd packetDecoder, version int16) (err error) {
 	if r.time, err = pd.getInt64(); err != nil {
 		return err
 	}
-	if r.maxOffsets, err = pd.getInt32(); err != nil {
-		return err
+	if version == 0 {
+		if r.maxOffsets, err = pd.getInt32(); err != nil {
+			return err
+		}
+	} else {
+		r.maxOffsets = 1
 	}
 	return nil
 }
 
 type OffsetRequest struct {
-	blocks map[string]map[int32]*offsetRequestBlock
+	// Version can be:
+	// - 0 (kafka 0.8 and later)
+	// - 1 (kafka 0.10.1 and later, a time resolves to the exact first offset at or after it)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version int16
+	blocks  map[string]map[int32]*offsetRequestBlock
 }
 
 func (r *OffsetRequest) encode(pe packetEncoder) error {
+	if r.Version < 0 || r.Version > 1 {
+		return PacketEncodingError{"invalid or unsupported OffsetRequest version field"}
+	}
+
 	pe.putInt32(-1) // replica ID is always -1 for clients
 	err := pe.putArrayLength(len(r.blocks))
 	if err != nil {
@@ -42,7 +57,7 @@ func (r *OffsetRequest) encode(pe packetEncoder) error {
 		}
 		for partition, block := range partitions {
 			pe.putInt32(partition)
-			if err = block.encode(pe); err != nil {
+			if err = block.encode(pe, r.Version); err != nil {
 				return err
 			}
 		}
@@ -79,7 +94,7 @@ func (r *OffsetRequest) decode(pd packetDecoder) error {
 				return err
 			}
 			block := &offsetRequestBlock{}
-			if err := block.decode(pd); err != nil {
+			if err := block.decode(pd, r.Version); err != nil {
 				return err
 			}
 			r.blocks[topic][partition] = block
@@ -93,7 +108,37 @@ func (r *OffsetRequest) key() int16 {
 }
 
 func (r *OffsetRequest) version() int16 {
-	return 0
+	return r.Version
+}
+
+func (r *OffsetRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *OffsetRequest) shallowCopy() versionedRequest {
+	c := *r
+	return &c
+}
+
+func (r *OffsetRequest) maxVersion() int16 {
+	// version 1 returns a single offset per partition
+	for _, partitions := range r.blocks {
+		for _, block := range partitions {
+			if block.maxOffsets != 1 {
+				return 0
+			}
+		}
+	}
+	return 1
+}
+
+func (r *OffsetRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V0_10_1_0
+	default:
+		return minVersion
+	}
 }
 
 func (r *OffsetRequest) AddBlock(topic string, partitionID int32, time int64, maxOffsets int32) {
@@ -15,6 +15,14 @@ var (
 		0x00, 0x00, 0x00, 0x04,
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
 		0x00, 0x00, 0x00, 0x02}
+
+	offsetRequestOneBlockV1 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x03, 'b', 'a', 'r',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x04,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
 )
 
 func TestOffsetRequest(t *testing.T) {
@@ -24,3 +32,19 @@ func TestOffsetRequest(t *testing.T) {
 	request.AddBlock("foo", 4, 1, 2)
 	testRequest(t, "one block", request, offsetRequestOneBlock)
 }
+
+func TestOffsetRequestV1(t *testing.T) {
+	request := &OffsetRequest{Version: 1}
+	testRequest(t, "no blocks", request, offsetRequestNoBlocks)
+
+	request.AddBlock("bar", 4, 1, 1)
+	testRequest(t, "one block", request, offsetRequestOneBlockV1)
+
+	if request.maxVersion() != 1 {
+		t.Error("Expected a single offset per partition to allow version 1")
+	}
+	request.AddBlock("foo", 4, 1, 2)
+	if request.maxVersion() != 0 {
+		t.Error("Expected several offsets per partition to require version 0")
+	}
+}
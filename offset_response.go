@@ -2,29 +2,55 @@ package sarama
 
 type OffsetResponseBlock struct {
 	Err     KError
-	Offsets []int64
+	Offsets []int64 // version 0; from version 1 on it only holds Offset
+	// Timestamp and Offset are only set from version 1 on. Offset is the first one at or
+	// after the requested time, Timestamp the time of its message; both are -1 if there is none.
+	Timestamp int64
+	Offset    int64
 }
 
-func (r *OffsetResponseBlock) decode(pd packetDecoder) (err error) {
+func (r *OffsetResponseBlock) decode(pd packetDecoder, version int16) (err error) {
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
 	}
 	r.Err = KError(tmp)
 
-	r.Offsets, err = pd.getInt64Array()
+	if version == 0 {
+		r.Offsets, err = pd.getInt64Array()
+		return err
+	}
+
+	if r.Timestamp, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if r.Offset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	r.Offsets = []int64{r.Offset}
 
-	return err
+	return nil
 }
 
-func (r *OffsetResponseBlock) encode(pe packetEncoder) (err error) {
+func (r *OffsetResponseBlock) encode(pe packetEncoder, version int16) (err error) {
 	pe.putInt16(int16(r.Err))
 
-	return pe.putInt64Array(r.Offsets)
+	if version == 0 {
+		return pe.putInt64Array(r.Offsets)
+	}
+
+	pe.putInt64(r.Timestamp)
+	pe.putInt64(r.Offset)
+	return nil
 }
 
 type OffsetResponse struct {
-	Blocks map[string]map[int32]*OffsetResponseBlock
+	Version int16
+	Blocks  map[string]map[int32]*OffsetResponseBlock
+}
+
+func (r *OffsetResponse) setVersion(v int16) {
+	r.Version = v
 }
 
 func (r *OffsetResponse) decode(pd packetDecoder) (err error) {
@@ -54,7 +80,7 @@ func (r *OffsetResponse) decode(pd packetDecoder) (err error) {
 			}
 
 			block := new(OffsetResponseBlock)
-			err = block.decode(pd)
+			err = block.decode(pd, r.Version)
 			if err != nil {
 				return err
 			}
@@ -106,7 +132,7 @@ func (r *OffsetResponse) encode(pe packetEncoder) (err error) {
 		}
 		for partition, block := range partitions {
 			pe.putInt32(partition)
-			if err = block.encode(pe); err != nil {
+			if err = block.encode(pe, r.Version); err != nil {
 				return err
 			}
 		}
@@ -126,5 +152,5 @@ func (r *OffsetResponse) AddTopicPartition(topic string, partition int32, offset
 		byTopic = make(map[int32]*OffsetResponseBlock)
 		r.Blocks[topic] = byTopic
 	}
-	byTopic[partition] = &OffsetResponseBlock{Offsets: []int64{offset}}
+	byTopic[partition] = &OffsetResponseBlock{Offsets: []int64{offset}, Timestamp: -1, Offset: offset}
 }
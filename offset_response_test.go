@@ -1,6 +1,9 @@
 package sarama
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 var (
 	emptyOffsetResponse = []byte{
@@ -19,6 +22,16 @@ var (
 		0x00, 0x00, 0x00, 0x02,
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06}
+
+	normalOffsetResponseV1 = []byte{
+		0x00, 0x00, 0x00, 0x01,
+
+		0x00, 0x01, 'z',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00,
+		0x00, 0x00, 0x01, 0x58, 0x1A, 0xE6, 0x4B, 0x08,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06}
 )
 
 func TestEmptyOffsetResponse(t *testing.T) {
@@ -60,3 +73,19 @@ func TestNormalOffsetResponse(t *testing.T) {
 	}
 
 }
+
+func TestNormalOffsetResponseV1(t *testing.T) {
+	expected := &OffsetResponse{
+		Version: 1,
+		Blocks: map[string]map[int32]*OffsetResponseBlock{
+			"z": {2: {Err: ErrNoError, Offsets: []int64{6}, Timestamp: 1477920049928, Offset: 6}},
+		},
+	}
+	testEncodable(t, "normal", expected, normalOffsetResponseV1)
+
+	response := &OffsetResponse{Version: 1}
+	testDecodable(t, "normal", response, normalOffsetResponseV1)
+	if !reflect.DeepEqual(response, expected) {
+		t.Errorf("Decoding produced %#v", response.Blocks["z"][2])
+	}
+}
@@ -6,20 +6,27 @@ package sarama
 type packetDecoder interface {
 	// Primitives
 	getInt8() (int8, error)
+	getBool() (bool, error)
 	getInt16() (int16, error)
 	getInt32() (int32, error)
 	getInt64() (int64, error)
+	getVarint() (int64, error)
 	getArrayLength() (int, error)
 
 	// Collections
 	getBytes() ([]byte, error)
+	getVarintBytes() ([]byte, error)
+	getRawBytes(length int) ([]byte, error)
 	getString() (string, error)
+	getNullableString() (*string, error)
 	getInt32Array() ([]int32, error)
 	getInt64Array() ([]int64, error)
+	getStringArray() ([]string, error)
 
 	// Subsets
 	remaining() int
 	getSubset(length int) (packetDecoder, error)
+	peekInt8(offset int) (int8, error)
 
 	// Stacks, see PushDecoder
 	push(in pushDecoder) error
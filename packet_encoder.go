@@ -6,17 +6,22 @@ package sarama
 type packetEncoder interface {
 	// Primitives
 	putInt8(in int8)
+	putBool(in bool)
 	putInt16(in int16)
 	putInt32(in int32)
 	putInt64(in int64)
+	putVarint(in int64)
 	putArrayLength(in int) error
 
 	// Collections
 	putBytes(in []byte) error
+	putVarintBytes(in []byte) error
 	putRawBytes(in []byte) error
 	putString(in string) error
+	putNullableString(in *string) error
 	putInt32Array(in []int32) error
 	putInt64Array(in []int64) error
+	putStringArray(in []string) error
 
 	// Stacks, see PushEncoder
 	push(in pushEncoder)
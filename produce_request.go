@@ -17,14 +17,79 @@ const (
 )
 
 type ProduceRequest struct {
-	RequiredAcks RequiredAcks
-	Timeout      int32
-	msgSets      map[string]map[int32]*MessageSet
+	// TransactionalID is the transaction the records are part of, if any (version 3+ only)
+	TransactionalID *string
+	RequiredAcks    RequiredAcks
+	Timeout         int32
+	// Version can be:
+	// - 0 (kafka 0.8 and later)
+	// - 1 (kafka 0.9 and later, the response carries the throttle time)
+	// - 2 (kafka 0.10 and later, required for messages in the v1 format carrying timestamps)
+	// - 3 (kafka 0.11 and later, carries record batches instead of message sets)
+	// - 4 (kafka 1.0 and later)
+	// - 5 (kafka 1.0 and later, the response carries the log start offset)
+	// - 6 (kafka 2.0 and later)
+	// - 7 (kafka 2.1 and later, required for record batches compressed with ZSTD)
+	// It is the lowest version the request may be sent at, see OffsetCommitRequest.Version.
+	Version       int16
+	msgSets       map[string]map[int32]*MessageSet
+	recordBatches map[string]map[int32]*RecordBatch
 }
 
 func (p *ProduceRequest) encode(pe packetEncoder) error {
+	if p.Version < 0 || p.Version > 7 {
+		return PacketEncodingError{"invalid or unsupported ProduceRequest version field"}
+	}
+	if p.Version < 3 && len(p.recordBatches) > 0 {
+		return PacketEncodingError{"record batches can only be produced from ProduceRequest version 3"}
+	}
+	if p.Version >= 3 && len(p.msgSets) > 0 {
+		return PacketEncodingError{"message sets can only be produced up to ProduceRequest version 2"}
+	}
+	if p.Version < 7 {
+		for _, partitions := range p.recordBatches {
+			for _, batch := range partitions {
+				if batch.Codec == CompressionZSTD {
+					return PacketEncodingError{"ZSTD compressed record batches can only be produced from ProduceRequest version 7"}
+				}
+			}
+		}
+	}
+
+	if p.Version >= 3 {
+		if err := pe.putNullableString(p.TransactionalID); err != nil {
+			return err
+		}
+	}
 	pe.putInt16(int16(p.RequiredAcks))
 	pe.putInt32(p.Timeout)
+
+	if p.Version >= 3 {
+		err := pe.putArrayLength(len(p.recordBatches))
+		if err != nil {
+			return err
+		}
+		for topic, partitions := range p.recordBatches {
+			if err = pe.putString(topic); err != nil {
+				return err
+			}
+			if err = pe.putArrayLength(len(partitions)); err != nil {
+				return err
+			}
+			for id, batch := range partitions {
+				pe.putInt32(id)
+				pe.push(&lengthField{})
+				if err = batch.encode(pe); err != nil {
+					return err
+				}
+				if err = pe.pop(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
 	err := pe.putArrayLength(len(p.msgSets))
 	if err != nil {
 		return err
@@ -54,7 +119,12 @@ func (p *ProduceRequest) encode(pe packetEncoder) error {
 	return nil
 }
 
-func (p *ProduceRequest) decode(pd packetDecoder) error {
+func (p *ProduceRequest) decode(pd packetDecoder) (err error) {
+	if p.Version >= 3 {
+		if p.TransactionalID, err = pd.getNullableString(); err != nil {
+			return err
+		}
+	}
 	requiredAcks, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -70,7 +140,6 @@ func (p *ProduceRequest) decode(pd packetDecoder) error {
 	if topicCount == 0 {
 		return nil
 	}
-	p.msgSets = make(map[string]map[int32]*MessageSet)
 	for i := 0; i < topicCount; i++ {
 		topic, err := pd.getString()
 		if err != nil {
@@ -80,26 +149,32 @@ func (p *ProduceRequest) decode(pd packetDecoder) error {
 		if err != nil {
 			return err
 		}
-		p.msgSets[topic] = make(map[int32]*MessageSet)
 		for j := 0; j < partitionCount; j++ {
 			partition, err := pd.getInt32()
 			if err != nil {
 				return err
 			}
-			messageSetSize, err := pd.getInt32()
+			size, err := pd.getInt32()
 			if err != nil {
 				return err
 			}
-			msgSetDecoder, err := pd.getSubset(int(messageSetSize))
+			subset, err := pd.getSubset(int(size))
 			if err != nil {
 				return err
 			}
-			msgSet := &MessageSet{}
-			err = msgSet.decode(msgSetDecoder)
-			if err != nil {
-				return err
+			if p.Version >= 3 {
+				batch := &RecordBatch{}
+				if err = batch.decode(subset); err != nil {
+					return err
+				}
+				p.AddBatch(topic, partition, batch)
+			} else {
+				msgSet := &MessageSet{}
+				if err = msgSet.decode(subset); err != nil {
+					return err
+				}
+				p.AddSet(topic, partition, msgSet)
 			}
-			p.msgSets[topic][partition] = msgSet
 		}
 	}
 	return nil
@@ -110,7 +185,43 @@ func (p *ProduceRequest) key() int16 {
 }
 
 func (p *ProduceRequest) version() int16 {
-	return 0
+	return p.Version
+}
+
+func (p *ProduceRequest) setVersion(v int16) {
+	p.Version = v
+}
+
+func (p *ProduceRequest) shallowCopy() versionedRequest {
+	c := *p
+	return &c
+}
+
+func (p *ProduceRequest) maxVersion() int16 {
+	// brokers reject message sets from version 3 on
+	if len(p.msgSets) > 0 {
+		return 2
+	}
+	return 7
+}
+
+func (p *ProduceRequest) requiredVersion() KafkaVersion {
+	switch p.Version {
+	case 1:
+		return V0_9_0_0
+	case 2:
+		return V0_10_0_0
+	case 3:
+		return V0_11_0_0
+	case 4, 5:
+		return V1_0_0_0
+	case 6:
+		return V2_0_0_0
+	case 7:
+		return V2_1_0_0
+	default:
+		return minVersion
+	}
 }
 
 func (p *ProduceRequest) AddMessage(topic string, partition int32, msg *Message) {
@@ -143,3 +254,16 @@ func (p *ProduceRequest) AddSet(topic string, partition int32, set *MessageSet)
 
 	p.msgSets[topic][partition] = set
 }
+
+// AddBatch sets the records of a partition, which requires version 3 of the request.
+func (p *ProduceRequest) AddBatch(topic string, partition int32, batch *RecordBatch) {
+	if p.recordBatches == nil {
+		p.recordBatches = make(map[string]map[int32]*RecordBatch)
+	}
+
+	if p.recordBatches[topic] == nil {
+		p.recordBatches[topic] = make(map[int32]*RecordBatch)
+	}
+
+	p.recordBatches[topic][partition] = batch
+}
@@ -2,6 +2,7 @@ package sarama
 
 import (
 	"testing"
+	"time"
 )
 
 var (
@@ -32,6 +33,25 @@ var (
 		0x00,
 		0xFF, 0xFF, 0xFF, 0xFF,
 		0x00, 0x00, 0x00, 0x02, 0x00, 0xEE}
+
+	produceRequestOneMessageV2 = []byte{
+		0x01, 0x23,
+		0x00, 0x00, 0x04, 0x44,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0xAD,
+		0x00, 0x00, 0x00, 0x24,
+		// messageSet
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x18,
+		// message
+		0x80, 0xB0, 0x14, 0xC8, // CRC
+		0x01,
+		0x00,
+		0x00, 0x00, 0x01, 0x58, 0x8D, 0xCD, 0x59, 0x38, // timestamp
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0x02, 0x00, 0xEE}
 )
 
 func TestProduceRequest(t *testing.T) {
@@ -45,3 +65,67 @@ func TestProduceRequest(t *testing.T) {
 	request.AddMessage("topic", 0xAD, &Message{Codec: CompressionNone, Key: nil, Value: []byte{0x00, 0xEE}})
 	testRequest(t, "one message", request, produceRequestOneMessage)
 }
+
+func TestProduceRequestV2(t *testing.T) {
+	request := &ProduceRequest{RequiredAcks: 0x123, Timeout: 0x444, Version: 2}
+	request.AddMessage("topic", 0xAD, &Message{
+		Codec:     CompressionNone,
+		Key:       nil,
+		Value:     []byte{0x00, 0xEE},
+		Version:   1,
+		Timestamp: time.Unix(1479847795, 0),
+	})
+	testRequest(t, "one message", request, produceRequestOneMessageV2)
+}
+
+func TestProduceRequestV3(t *testing.T) {
+	transactionalID := "txn"
+	request := &ProduceRequest{TransactionalID: &transactionalID, RequiredAcks: 0x123, Timeout: 0x444, Version: 3}
+	request.AddBatch("topic", 0xAD, newTestRecordBatch())
+
+	expected := []byte{
+		0x00, 0x03, 't', 'x', 'n',
+		0x01, 0x23,
+		0x00, 0x00, 0x04, 0x44,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0xAD,
+		0x00, 0x00, 0x00, byte(len(recordBatchTwoRecords))}
+	testRequest(t, "one batch", request, append(expected, recordBatchTwoRecords...))
+
+	if request.maxVersion() != 7 {
+		t.Error("Expected a request carrying record batches to allow version 7")
+	}
+
+	legacy := &ProduceRequest{}
+	legacy.AddMessage("topic", 0xAD, &Message{Value: []byte{0x00, 0xEE}})
+	if legacy.maxVersion() != 2 {
+		t.Error("Expected a request carrying message sets to stop at version 2")
+	}
+	legacy.Version = 3
+	if _, err := encode(legacy); err == nil {
+		t.Error("Expected message sets to be refused at version 3")
+	}
+}
+
+func TestProduceRequestV7(t *testing.T) {
+	batch := newTestRecordBatch()
+	batch.Codec = CompressionZSTD
+
+	request := &ProduceRequest{RequiredAcks: 0x123, Timeout: 0x444, Version: 3}
+	request.AddBatch("topic", 0xAD, batch)
+	if _, err := encode(request); err == nil {
+		t.Error("Expected ZSTD compressed batches to be refused below version 7")
+	}
+
+	request.Version = 7
+	body, err := encode(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRequest(t, "zstd batch", request, body)
+	if request.requiredVersion() != V2_1_0_0 {
+		t.Error("Expected version 7 to require Kafka 2.1, got", request.requiredVersion())
+	}
+}
@@ -1,11 +1,16 @@
 package sarama
 
+import "time"
+
 type ProduceResponseBlock struct {
 	Err    KError
 	Offset int64
+	// Timestamp is the log append time of the messages, if the topic uses it (version 2+ only)
+	Timestamp      time.Time
+	LogStartOffset int64 // the first offset of the partition's log (version 5+ only)
 }
 
-func (pr *ProduceResponseBlock) decode(pd packetDecoder) (err error) {
+func (pr *ProduceResponseBlock) decode(pd packetDecoder, version int16) (err error) {
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -17,11 +22,31 @@ func (pr *ProduceResponseBlock) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
+	if version >= 2 {
+		millis, err := pd.getInt64()
+		if err != nil {
+			return err
+		}
+		// -1 unless the topic uses the log append time
+		if millis >= 0 {
+			pr.Timestamp = time.Unix(millis/1000, (millis%1000)*int64(time.Millisecond))
+		}
+	}
+
+	if version >= 5 {
+		if pr.LogStartOffset, err = pd.getInt64(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 type ProduceResponse struct {
 	Blocks map[string]map[int32]*ProduceResponseBlock
+	// Version must match the version of the request, it is set by the Broker before decoding
+	Version      int16
+	ThrottleTime time.Duration // the time the request was throttled for by a quota (version 1+ only)
 }
 
 func (pr *ProduceResponse) decode(pd packetDecoder) (err error) {
@@ -51,7 +76,7 @@ func (pr *ProduceResponse) decode(pd packetDecoder) (err error) {
 			}
 
 			block := new(ProduceResponseBlock)
-			err = block.decode(pd)
+			err = block.decode(pd, pr.Version)
 			if err != nil {
 				return err
 			}
@@ -59,6 +84,14 @@ func (pr *ProduceResponse) decode(pd packetDecoder) (err error) {
 		}
 	}
 
+	if pr.Version >= 1 {
+		millis, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		pr.ThrottleTime = time.Duration(millis) * time.Millisecond
+	}
+
 	return nil
 }
 
@@ -80,11 +113,28 @@ func (pr *ProduceResponse) encode(pe packetEncoder) error {
 			pe.putInt32(id)
 			pe.putInt16(int16(prb.Err))
 			pe.putInt64(prb.Offset)
+			if pr.Version >= 2 {
+				timestamp := int64(-1)
+				if !prb.Timestamp.IsZero() {
+					timestamp = prb.Timestamp.UnixNano() / int64(time.Millisecond)
+				}
+				pe.putInt64(timestamp)
+			}
+			if pr.Version >= 5 {
+				pe.putInt64(prb.LogStartOffset)
+			}
 		}
 	}
+	if pr.Version >= 1 {
+		pe.putInt32(int32(pr.ThrottleTime / time.Millisecond))
+	}
 	return nil
 }
 
+func (pr *ProduceResponse) setVersion(v int16) {
+	pr.Version = v
+}
+
 func (pr *ProduceResponse) GetBlock(topic string, partition int32) *ProduceResponseBlock {
 	if pr.Blocks == nil {
 		return nil
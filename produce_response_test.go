@@ -1,6 +1,10 @@
 package sarama
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+	"time"
+)
 
 var (
 	produceResponseNoBlocks = []byte{
@@ -22,6 +26,24 @@ var (
 		0x00, 0x00, 0x00, 0x02,
 		0x00, 0x02,
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	produceResponseV2 = []byte{
+		0x00, 0x00, 0x00, 0x01,
+
+		0x00, 0x03, 'f', 'o', 'o',
+		0x00, 0x00, 0x00, 0x02,
+
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF,
+		0x00, 0x00, 0x01, 0x58, 0x8D, 0xCD, 0x59, 0x38, // log append time
+
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // create time
+
+		0x00, 0x00, 0x00, 0x64} // throttle time
 )
 
 func TestProduceResponse(t *testing.T) {
@@ -65,3 +87,65 @@ func TestProduceResponse(t *testing.T) {
 		}
 	}
 }
+
+func TestProduceResponseV2(t *testing.T) {
+	response := ProduceResponse{Version: 2}
+
+	testDecodable(t, "v2", &response, produceResponseV2)
+	if response.ThrottleTime != 100*time.Millisecond {
+		t.Error("Decoding produced throttle time", response.ThrottleTime, "instead of 100ms")
+	}
+	if block := response.GetBlock("foo", 1); block == nil {
+		t.Error("Decoding did not produce a block for foo/1")
+	} else if !block.Timestamp.Equal(time.Unix(1479847795, 0)) {
+		t.Error("Decoding failed for foo/1/Timestamp, got:", block.Timestamp)
+	}
+	if block := response.GetBlock("foo", 2); block == nil {
+		t.Error("Decoding did not produce a block for foo/2")
+	} else if !block.Timestamp.IsZero() {
+		t.Error("Decoding produced a timestamp for foo/2 where there was none, got:", block.Timestamp)
+	}
+
+	testEncodable(t, "v2", &ProduceResponse{
+		Version:      2,
+		ThrottleTime: 100 * time.Millisecond,
+		Blocks: map[string]map[int32]*ProduceResponseBlock{
+			"foo": {1: {Offset: 0xFF, Timestamp: time.Unix(1479847795, 0)}},
+		},
+	}, []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x03, 'f', 'o', 'o',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF,
+		0x00, 0x00, 0x01, 0x58, 0x8D, 0xCD, 0x59, 0x38,
+		0x00, 0x00, 0x00, 0x64})
+}
+
+func TestProduceResponseV5(t *testing.T) {
+	expected := &ProduceResponse{
+		Version:      5,
+		ThrottleTime: 100 * time.Millisecond,
+		Blocks: map[string]map[int32]*ProduceResponseBlock{
+			"foo": {1: {Offset: 0xFF, LogStartOffset: 0x10}},
+		},
+	}
+	buf := []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x03, 'f', 'o', 'o',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // create time
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, // log start offset
+		0x00, 0x00, 0x00, 0x64}
+	testEncodable(t, "v5", expected, buf)
+
+	response := &ProduceResponse{Version: 5}
+	testDecodable(t, "v5", response, buf)
+	if !reflect.DeepEqual(response, expected) {
+		t.Errorf("Decoding produced %#v", response)
+	}
+}
@@ -0,0 +1,181 @@
+package sarama
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// ProxyType is the kind of proxy Config.Net.Proxy connects through.
+type ProxyType string
+
+const (
+	// ProxyTypeSOCKS5 is a SOCKS version 5 proxy, see RFC 1928, with optional username/password
+	// authentication, see RFC 1929.
+	ProxyTypeSOCKS5 = ProxyType("SOCKS5")
+	// ProxyTypeHTTPConnect is an HTTP proxy which tunnels connections with the CONNECT method,
+	// with optional basic authentication.
+	ProxyTypeHTTPConnect = ProxyType("HTTP CONNECT")
+)
+
+// connectThroughProxy asks the proxy at the other end of conn to connect it to addr.
+func connectThroughProxy(conn net.Conn, conf *Config, addr string) (net.Conn, error) {
+	switch conf.Net.Proxy.Type {
+	case ProxyTypeHTTPConnect:
+		return connectThroughHTTPProxy(conn, conf, addr)
+	default:
+		return conn, connectThroughSOCKS5Proxy(conn, conf, addr)
+	}
+}
+
+const (
+	socks5Version         = 5
+	socks5NoAuth          = 0
+	socks5UserPassAuth    = 2
+	socks5NoAcceptable    = 0xff
+	socks5UserPassVersion = 1
+	socks5Connect         = 1
+	socks5IPv4            = 1
+	socks5DomainName      = 3
+	socks5IPv6            = 4
+)
+
+func connectThroughSOCKS5Proxy(conn net.Conn, conf *Config, addr string) error {
+	host, portstr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portstr)
+	if err != nil {
+		return err
+	}
+
+	// negotiate the authentication method, offering username/password only if there are credentials
+	methods := []byte{socks5NoAuth}
+	if conf.Net.Proxy.User != "" {
+		methods = append(methods, socks5UserPassAuth)
+	}
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return ProxyError(fmt.Sprintf("unexpected SOCKS version %d", reply[0]))
+	}
+
+	switch reply[1] {
+	case socks5NoAuth:
+	case socks5UserPassAuth:
+		user, password := conf.Net.Proxy.User, conf.Net.Proxy.Password
+		if len(user) > 255 || len(password) > 255 {
+			return ProxyError("SOCKS5 credentials are limited to 255 bytes")
+		}
+		auth := []byte{socks5UserPassVersion, byte(len(user))}
+		auth = append(auth, user...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return ProxyError("SOCKS5 proxy rejected the credentials")
+		}
+	case socks5NoAcceptable:
+		return ProxyError("SOCKS5 proxy accepts none of the authentication methods offered")
+	default:
+		return ProxyError(fmt.Sprintf("SOCKS5 proxy chose unexpected authentication method %d", reply[1]))
+	}
+
+	request := []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return ProxyError("SOCKS5 host names are limited to 255 bytes")
+		}
+		request = append(request, socks5DomainName, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, socks5IPv4)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, socks5IPv6)
+		request = append(request, ip.To16()...)
+	}
+	request = append(request, 0, 0)
+	binary.BigEndian.PutUint16(request[len(request)-2:], uint16(port))
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	// the reply carries the address the proxy bound to, which we have no use for
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0 {
+		return ProxyError(fmt.Sprintf("SOCKS5 proxy failed to connect to %s with reply code %d", addr, header[1]))
+	}
+	var boundAddrLength int
+	switch header[3] {
+	case socks5IPv4:
+		boundAddrLength = net.IPv4len
+	case socks5IPv6:
+		boundAddrLength = net.IPv6len
+	case socks5DomainName:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		boundAddrLength = int(length[0])
+	default:
+		return ProxyError(fmt.Sprintf("SOCKS5 proxy replied with unexpected address type %d", header[3]))
+	}
+	_, err = io.ReadFull(conn, make([]byte, boundAddrLength+2))
+	return err
+}
+
+func connectThroughHTTPProxy(conn net.Conn, conf *Config, addr string) (net.Conn, error) {
+	request := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+	if conf.Net.Proxy.User != "" {
+		credentials := conf.Net.Proxy.User + ":" + conf.Net.Proxy.Password
+		request += "Proxy-Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(credentials)) + "\r\n"
+	}
+	if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, &http.Request{Method: "CONNECT"})
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, ProxyError(fmt.Sprintf("HTTP proxy failed to connect to %s: %s", addr, response.Status))
+	}
+
+	if reader.Buffered() > 0 {
+		// the broker never speaks first, but do not lose what the proxy sent past its response anyway
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose reads first return what was buffered while reading from it.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
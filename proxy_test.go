@@ -0,0 +1,191 @@
+package sarama
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// newTestProxy starts a proxy of the given type which requires the given credentials, and connects
+// its clients to the address they ask for.
+func newTestProxy(t *testing.T, proxyType ProxyType, user, password string) net.Listener {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+
+				var reader io.Reader = conn
+				var addr string
+				if proxyType == ProxyTypeHTTPConnect {
+					buffered := bufio.NewReader(conn)
+					reader, addr = buffered, acceptHTTPConnect(t, conn, buffered, user, password)
+				} else {
+					addr = acceptSOCKS5(t, conn, user, password)
+				}
+				if addr == "" {
+					return
+				}
+
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer target.Close()
+				if proxyType == ProxyTypeHTTPConnect {
+					_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				} else {
+					_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				}
+
+				go func() {
+					_, _ = io.Copy(target, reader)
+					_ = target.Close()
+				}()
+				_, _ = io.Copy(conn, target)
+			}()
+		}
+	}()
+
+	return listener
+}
+
+// acceptSOCKS5 returns the address the client asks for, or "" if it fails to authenticate.
+func acceptSOCKS5(t *testing.T, conn net.Conn, user, password string) string {
+	read := func(n int) []byte {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Error(err)
+			return nil
+		}
+		return buf
+	}
+
+	header := read(2)
+	if header == nil || read(int(header[1])) == nil {
+		return ""
+	}
+
+	if user == "" {
+		_, _ = conn.Write([]byte{5, 0})
+	} else {
+		_, _ = conn.Write([]byte{5, 2})
+		header := read(2)
+		if header == nil {
+			return ""
+		}
+		gotUser := read(int(header[1]))
+		length := read(1)
+		if gotUser == nil || length == nil {
+			return ""
+		}
+		gotPassword := read(int(length[0]))
+		if string(gotUser) != user || string(gotPassword) != password {
+			_, _ = conn.Write([]byte{1, 1})
+			return ""
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	}
+
+	request := read(4)
+	if request == nil {
+		return ""
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		host = net.IP(read(net.IPv4len)).String()
+	case 4:
+		host = net.IP(read(net.IPv6len)).String()
+	case 3:
+		length := read(1)
+		if length == nil {
+			return ""
+		}
+		host = string(read(int(length[0])))
+	}
+	port := read(2)
+	if port == nil {
+		return ""
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+}
+
+// acceptHTTPConnect returns the address the client asks for, or "" if it fails to authenticate.
+func acceptHTTPConnect(t *testing.T, conn net.Conn, reader *bufio.Reader, user, password string) string {
+	request, err := http.ReadRequest(reader)
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	if request.Method != "CONNECT" {
+		t.Error("Expected a CONNECT request, got", request.Method)
+		return ""
+	}
+
+	if user != "" {
+		expected := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+		if request.Header.Get("Proxy-Authorization") != expected {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return ""
+		}
+	}
+	return request.Host
+}
+
+func TestBrokerProxies(t *testing.T) {
+	for _, proxyType := range []ProxyType{ProxyTypeSOCKS5, ProxyTypeHTTPConnect} {
+		mb := newMockBroker(t, 0)
+		mb.Returns(new(MetadataResponse))
+		proxy := newTestProxy(t, proxyType, "user", "secret")
+
+		conf := NewConfig()
+		conf.Net.Proxy.Enable = true
+		conf.Net.Proxy.Type = proxyType
+		conf.Net.Proxy.Addr = proxy.Addr().String()
+		conf.Net.Proxy.User = "user"
+		conf.Net.Proxy.Password = "secret"
+
+		// a host name, which the proxy resolves
+		_, port, err := net.SplitHostPort(mb.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		broker := NewBroker(net.JoinHostPort("localhost", port))
+		if err := broker.Open(conf); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := broker.GetMetadata(new(MetadataRequest)); err != nil {
+			t.Error(proxyType, err)
+		}
+		safeClose(t, broker)
+
+		conf.Net.Proxy.Password = "wrong"
+		broker = NewBroker(mb.Addr())
+		if err := broker.Open(conf); err != nil {
+			t.Fatal(err)
+		}
+		if connected, err := broker.Connected(); connected {
+			t.Error(proxyType, "Expected the proxy to refuse the wrong credentials")
+		} else if _, ok := err.(ProxyError); !ok {
+			t.Error(proxyType, "Expected a ProxyError, got", err)
+		}
+
+		_ = proxy.Close()
+		mb.Close()
+	}
+}
@@ -0,0 +1,123 @@
+package sarama
+
+import "time"
+
+// RecordHeader is a key/value pair attached to a record, available from Kafka 0.11 on.
+type RecordHeader struct {
+	Key   []byte
+	Value []byte
+}
+
+func (h *RecordHeader) encode(pe packetEncoder) error {
+	if err := pe.putVarintBytes(h.Key); err != nil {
+		return err
+	}
+	return pe.putVarintBytes(h.Value)
+}
+
+func (h *RecordHeader) decode(pd packetDecoder) (err error) {
+	if h.Key, err = pd.getVarintBytes(); err != nil {
+		return err
+	}
+	if h.Value, err = pd.getVarintBytes(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Record is a single entry of a RecordBatch. Its offset and timestamp are relative to the
+// ones of the batch.
+type Record struct {
+	Attributes     int8
+	TimestampDelta time.Duration
+	OffsetDelta    int64
+	Key            []byte
+	Value          []byte
+	Headers        []*RecordHeader
+}
+
+func (r *Record) encode(pe packetEncoder) error {
+	// records are prefixed by their length as a varint, whose own size depends on the length
+	var prep prepEncoder
+	if err := r.encodeBody(&prep); err != nil {
+		return err
+	}
+	pe.putVarint(int64(prep.length))
+	return r.encodeBody(pe)
+}
+
+func (r *Record) encodeBody(pe packetEncoder) error {
+	pe.putInt8(r.Attributes)
+	pe.putVarint(int64(r.TimestampDelta / time.Millisecond))
+	pe.putVarint(r.OffsetDelta)
+	if err := pe.putVarintBytes(r.Key); err != nil {
+		return err
+	}
+	if err := pe.putVarintBytes(r.Value); err != nil {
+		return err
+	}
+
+	pe.putVarint(int64(len(r.Headers)))
+	for _, h := range r.Headers {
+		if err := h.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Record) decode(pd packetDecoder) (err error) {
+	length, err := pd.getVarint()
+	if err != nil {
+		return err
+	}
+	if length < 0 {
+		return PacketDecodingError{"invalid record length"}
+	}
+
+	body, err := pd.getSubset(int(length))
+	if err != nil {
+		return err
+	}
+
+	if r.Attributes, err = body.getInt8(); err != nil {
+		return err
+	}
+	timestampDelta, err := body.getVarint()
+	if err != nil {
+		return err
+	}
+	r.TimestampDelta = time.Duration(timestampDelta) * time.Millisecond
+	if r.OffsetDelta, err = body.getVarint(); err != nil {
+		return err
+	}
+	if r.Key, err = body.getVarintBytes(); err != nil {
+		return err
+	}
+	if r.Value, err = body.getVarintBytes(); err != nil {
+		return err
+	}
+
+	numHeaders, err := body.getVarint()
+	if err != nil {
+		return err
+	}
+	if numHeaders < 0 || int(numHeaders) > body.remaining() {
+		return PacketDecodingError{"invalid header count"}
+	}
+	r.Headers = nil
+	if numHeaders > 0 {
+		r.Headers = make([]*RecordHeader, numHeaders)
+		for i := range r.Headers {
+			r.Headers[i] = new(RecordHeader)
+			if err := r.Headers[i].decode(body); err != nil {
+				return err
+			}
+		}
+	}
+
+	if body.remaining() != 0 {
+		return PacketDecodingError{"invalid record length"}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package sarama
+
+import (
+	"fmt"
+	"time"
+)
+
+// the attribute bits of a record batch besides the codec and the timestamp type
+const (
+	isTransactionalMask int16 = 0x10
+	controlMask         int16 = 0x20
+)
+
+// the offset of the magic byte in both a MessageBlock and a RecordBatch, which tells them apart
+const magicOffset = 16
+
+// RecordBatch is the unit of the v2 message format introduced in Kafka 0.11. Offsets and timestamps
+// of the records it contains are relative to the ones of the batch, and the batch as a whole is
+// compressed and checksummed (with CRC32C).
+type RecordBatch struct {
+	FirstOffset          int64
+	PartitionLeaderEpoch int32
+	Version              int8 // always 2
+	Codec                CompressionCodec
+	CompressionLevel     int  // the level Codec compresses at when encoding, zero meaning its default
+	LogAppendTime        bool // whether the timestamps were set by the broker rather than the producer
+	IsTransactional      bool
+	Control              bool // whether the batch holds transaction markers rather than user records
+	LastOffsetDelta      int32
+	FirstTimestamp       time.Time
+	MaxTimestamp         time.Time
+	ProducerID           int64
+	ProducerEpoch        int16
+	FirstSequence        int32
+	Records              []*Record
+
+	compressedRecords []byte
+}
+
+// LastOffset is the offset of the last record of the batch, which compaction may have removed.
+func (b *RecordBatch) LastOffset() int64 {
+	return b.FirstOffset + int64(b.LastOffsetDelta)
+}
+
+func (b *RecordBatch) addRecord(r *Record) {
+	b.Records = append(b.Records, r)
+}
+
+func (b *RecordBatch) encode(pe packetEncoder) error {
+	if b.Version != 2 {
+		return PacketEncodingError{fmt.Sprintf("unsupported record batch version (%d)", b.Version)}
+	}
+
+	pe.putInt64(b.FirstOffset)
+	pe.push(&lengthField{})
+	pe.putInt32(b.PartitionLeaderEpoch)
+	pe.putInt8(b.Version)
+	pe.push(&crc32Field{polynomial: crcCastagnoli})
+	pe.putInt16(b.computeAttributes())
+	pe.putInt32(b.LastOffsetDelta)
+	pe.putInt64(batchTimestamp(b.FirstTimestamp))
+	pe.putInt64(batchTimestamp(b.MaxTimestamp))
+	pe.putInt64(b.ProducerID)
+	pe.putInt16(b.ProducerEpoch)
+	pe.putInt32(b.FirstSequence)
+	if err := pe.putArrayLength(len(b.Records)); err != nil {
+		return err
+	}
+
+	if b.Codec == CompressionNone {
+		if err := recordsArray(b.Records).encode(pe); err != nil {
+			return err
+		}
+	} else {
+		// like messages, the compressed records are cached between the two encoding passes
+		if b.compressedRecords == nil {
+			raw, err := encode(recordsArray(b.Records))
+			if err != nil {
+				return err
+			}
+			if b.compressedRecords, err = compress(b.Codec, b.CompressionLevel, raw); err != nil {
+				return err
+			}
+			if err := pe.putRawBytes(b.compressedRecords); err != nil {
+				return err
+			}
+		} else {
+			if err := pe.putRawBytes(b.compressedRecords); err != nil {
+				return err
+			}
+			b.compressedRecords = nil
+		}
+	}
+
+	if err := pe.pop(); err != nil {
+		return err
+	}
+	return pe.pop()
+}
+
+func (b *RecordBatch) decode(pd packetDecoder) (err error) {
+	if b.FirstOffset, err = pd.getInt64(); err != nil {
+		return err
+	}
+
+	length, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	body, err := pd.getSubset(int(length))
+	if err != nil {
+		return err
+	}
+
+	if b.PartitionLeaderEpoch, err = body.getInt32(); err != nil {
+		return err
+	}
+	if b.Version, err = body.getInt8(); err != nil {
+		return err
+	}
+	if b.Version != 2 {
+		return PacketDecodingError{fmt.Sprintf("unknown record batch version (%d)", b.Version)}
+	}
+
+	if err = body.push(&crc32Field{polynomial: crcCastagnoli}); err != nil {
+		return err
+	}
+
+	attributes, err := body.getInt16()
+	if err != nil {
+		return err
+	}
+	b.Codec = CompressionCodec(int8(attributes) & compressionCodecMask)
+	b.LogAppendTime = attributes&int16(timestampTypeMask) != 0
+	b.IsTransactional = attributes&isTransactionalMask != 0
+	b.Control = attributes&controlMask != 0
+
+	if b.LastOffsetDelta, err = body.getInt32(); err != nil {
+		return err
+	}
+	firstTimestamp, err := body.getInt64()
+	if err != nil {
+		return err
+	}
+	b.FirstTimestamp = timestampFromBatch(firstTimestamp)
+	maxTimestamp, err := body.getInt64()
+	if err != nil {
+		return err
+	}
+	b.MaxTimestamp = timestampFromBatch(maxTimestamp)
+	if b.ProducerID, err = body.getInt64(); err != nil {
+		return err
+	}
+	if b.ProducerEpoch, err = body.getInt16(); err != nil {
+		return err
+	}
+	if b.FirstSequence, err = body.getInt32(); err != nil {
+		return err
+	}
+
+	// not an array length: compressed records may take fewer bytes than there are records
+	numRecords, err := body.getInt32()
+	if err != nil {
+		return err
+	}
+	if numRecords < 0 {
+		return PacketDecodingError{"invalid record count"}
+	}
+
+	payload, err := body.getRawBytes(body.remaining())
+	if err != nil {
+		return err
+	}
+	if err = body.pop(); err != nil {
+		return err
+	}
+
+	if payload, err = decompress(b.Codec, payload); err != nil {
+		return err
+	}
+	if int(numRecords) > len(payload) {
+		return PacketDecodingError{"invalid record count"}
+	}
+	records := &realDecoder{raw: payload}
+	b.Records = make([]*Record, numRecords)
+	for i := range b.Records {
+		b.Records[i] = new(Record)
+		if err = b.Records[i].decode(records); err != nil {
+			if err == ErrInsufficientData {
+				return PacketDecodingError{"record batch holds fewer records than announced"}
+			}
+			return err
+		}
+	}
+	if records.remaining() != 0 {
+		return PacketDecodingError{"record batch holds more records than announced"}
+	}
+
+	return nil
+}
+
+func (b *RecordBatch) computeAttributes() int16 {
+	attributes := int16(int8(b.Codec) & compressionCodecMask)
+	if b.LogAppendTime {
+		attributes |= int16(timestampTypeMask)
+	}
+	if b.IsTransactional {
+		attributes |= isTransactionalMask
+	}
+	if b.Control {
+		attributes |= controlMask
+	}
+	return attributes
+}
+
+// batchTimestamp converts a timestamp to milliseconds, an empty one being sent as -1
+func batchTimestamp(t time.Time) int64 {
+	if t.IsZero() {
+		return -1
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func timestampFromBatch(millis int64) time.Time {
+	if millis < 0 {
+		return time.Time{}
+	}
+	return time.Unix(millis/1000, (millis%1000)*int64(time.Millisecond))
+}
+
+type recordsArray []*Record
+
+func (ra recordsArray) encode(pe packetEncoder) error {
+	for _, r := range ra {
+		if err := r.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
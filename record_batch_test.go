@@ -0,0 +1,115 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	recordBatchTwoRecords = []byte{
+		0, 0, 0, 0, 0, 0, 0, 0x10, // first offset
+		0, 0, 0, 77, // length
+		0, 0, 0, 0, // partition leader epoch
+		2,               // magic
+		11, 223, 87, 55, // CRC32C
+		0, 0, // attributes
+		0, 0, 0, 1, // last offset delta
+		0, 0, 1, 88, 141, 205, 89, 56, // first timestamp
+		0, 0, 1, 88, 141, 205, 89, 61, // max timestamp
+		255, 255, 255, 255, 255, 255, 255, 255, // producer ID
+		255, 255, // producer epoch
+		255, 255, 255, 255, // first sequence
+		0, 0, 0, 2, // number of records
+		// first record
+		36,            // length
+		0,             // attributes
+		0,             // timestamp delta
+		0,             // offset delta
+		1,             // key (null)
+		8, 1, 2, 3, 4, // value
+		2,                                  // number of headers
+		10, 't', 'r', 'a', 'c', 'e', 2, 10, // header
+		// second record
+		16,     // length
+		0,      // attributes
+		10,     // timestamp delta
+		2,      // offset delta
+		2, 'k', // key
+		2, 'v', // value
+		0, // number of headers
+	}
+)
+
+func newTestRecordBatch() *RecordBatch {
+	return &RecordBatch{
+		FirstOffset:     0x10,
+		Version:         2,
+		LastOffsetDelta: 1,
+		FirstTimestamp:  time.Unix(1479847795, 0),
+		MaxTimestamp:    time.Unix(1479847795, 5*int64(time.Millisecond)),
+		ProducerID:      -1,
+		ProducerEpoch:   -1,
+		FirstSequence:   -1,
+		Records: []*Record{
+			{
+				Value:   []byte{1, 2, 3, 4},
+				Headers: []*RecordHeader{{Key: []byte("trace"), Value: []byte{10}}},
+			},
+			{
+				TimestampDelta: 5 * time.Millisecond,
+				OffsetDelta:    1,
+				Key:            []byte("k"),
+				Value:          []byte("v"),
+			},
+		},
+	}
+}
+
+func TestRecordBatchEncoding(t *testing.T) {
+	testEncodable(t, "two records", newTestRecordBatch(), recordBatchTwoRecords)
+}
+
+func TestRecordBatchDecoding(t *testing.T) {
+	batch := new(RecordBatch)
+	testDecodable(t, "two records", batch, recordBatchTwoRecords)
+
+	if !reflect.DeepEqual(batch, newTestRecordBatch()) {
+		t.Errorf("Decoding produced %#v", batch)
+	}
+	if batch.LastOffset() != 0x11 {
+		t.Error("Decoding produced last offset", batch.LastOffset())
+	}
+
+	corrupt := make([]byte, len(recordBatchTwoRecords))
+	copy(corrupt, recordBatchTwoRecords)
+	corrupt[len(corrupt)-2] = 'w'
+	if err := decode(corrupt, new(RecordBatch)); err == nil {
+		t.Error("Expected the CRC check to fail")
+	}
+
+	if err := decode(recordBatchTwoRecords[:40], new(RecordBatch)); err != ErrInsufficientData {
+		t.Error("Expected ErrInsufficientData for a partial batch, got", err)
+	}
+}
+
+func TestRecordBatchCompression(t *testing.T) {
+	for _, codec := range []CompressionCodec{CompressionGZIP, CompressionSnappy, CompressionLZ4, CompressionZSTD} {
+		batch := newTestRecordBatch()
+		batch.Codec = codec
+		batch.Control = true
+		batch.IsTransactional = true
+		batch.LogAppendTime = true
+
+		buf, err := encode(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded := new(RecordBatch)
+		testDecodable(t, codec.String(), decoded, buf)
+		if !reflect.DeepEqual(decoded, batch) {
+			t.Errorf("Decoding %s produced %#v", codec, decoded)
+		}
+	}
+}
@@ -11,6 +11,27 @@ type requestBody interface {
 	decoder
 	key() int16
 	version() int16
+	// requiredVersion is the oldest Kafka release supporting the request at its current version
+	requiredVersion() KafkaVersion
+}
+
+// versionedRequest is implemented by the requests that can be encoded at several protocol versions.
+// The version they are created with is the lowest one the caller accepts; the Broker sends them at the
+// highest version up to maxVersion supported by both Config.Version and the broker. The version is
+// negotiated on a shallow copy, since setting it may change other fields too, so that the caller's
+// request is left as it was and may be sent again, to a broker supporting fewer versions.
+type versionedRequest interface {
+	requestBody
+	setVersion(v int16)
+	maxVersion() int16
+	shallowCopy() versionedRequest
+}
+
+// versionedResponse is implemented by the responses whose layout depends on the version of the
+// request they answer; the Broker sets their version before decoding them.
+type versionedResponse interface {
+	decoder
+	setVersion(v int16)
 }
 
 type request struct {
@@ -82,19 +103,60 @@ func decodeRequest(r io.Reader) (req *request, err error) {
 func allocateBody(key, version int16) requestBody {
 	switch key {
 	case 0:
-		return &ProduceRequest{}
+		return &ProduceRequest{Version: version}
 	case 1:
-		return &FetchRequest{}
+		return &FetchRequest{Version: version}
 	case 2:
-		return &OffsetRequest{}
+		return &OffsetRequest{Version: version}
 	case 3:
-		return &MetadataRequest{}
+		return &MetadataRequest{Version: version}
 	case 8:
 		return &OffsetCommitRequest{Version: version}
 	case 9:
-		return &OffsetFetchRequest{}
+		return &OffsetFetchRequest{Version: version}
 	case 10:
+		if version >= 1 {
+			return &FindCoordinatorRequest{Version: version}
+		}
 		return &ConsumerMetadataRequest{}
+	case 11:
+		return &JoinGroupRequest{}
+	case 12:
+		return &HeartbeatRequest{}
+	case 13:
+		return &LeaveGroupRequest{}
+	case 14:
+		return &SyncGroupRequest{}
+	case 15:
+		return &DescribeGroupsRequest{Version: version}
+	case 16:
+		return &ListGroupsRequest{Version: version}
+	case 17:
+		return &SaslHandshakeRequest{}
+	case 18:
+		return &ApiVersionsRequest{}
+	case 19:
+		return &CreateTopicsRequest{Version: version}
+	case 20:
+		return &DeleteTopicsRequest{Version: version}
+	case 21:
+		return &DeleteRecordsRequest{}
+	case 22:
+		return &InitProducerIDRequest{Version: version}
+	case 24:
+		return &AddPartitionsToTxnRequest{}
+	case 25:
+		return &AddOffsetsToTxnRequest{}
+	case 26:
+		return &EndTxnRequest{}
+	case 28:
+		return &TxnOffsetCommitRequest{}
+	case 32:
+		return &DescribeConfigsRequest{Version: version}
+	case 33:
+		return &AlterConfigsRequest{Version: version}
+	case 37:
+		return &CreatePartitionsRequest{}
 	}
 	return nil
 }
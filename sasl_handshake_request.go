@@ -0,0 +1,26 @@
+package sarama
+
+type SaslHandshakeRequest struct {
+	Mechanism string
+}
+
+func (r *SaslHandshakeRequest) encode(pe packetEncoder) error {
+	return pe.putString(r.Mechanism)
+}
+
+func (r *SaslHandshakeRequest) decode(pd packetDecoder) (err error) {
+	r.Mechanism, err = pd.getString()
+	return err
+}
+
+func (r *SaslHandshakeRequest) key() int16 {
+	return 17
+}
+
+func (r *SaslHandshakeRequest) version() int16 {
+	return 0
+}
+
+func (r *SaslHandshakeRequest) requiredVersion() KafkaVersion {
+	return V0_10_0_0
+}
@@ -0,0 +1,46 @@
+package sarama
+
+type SaslHandshakeResponse struct {
+	Err               KError
+	EnabledMechanisms []string
+}
+
+func (r *SaslHandshakeResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.Err))
+
+	if err := pe.putArrayLength(len(r.EnabledMechanisms)); err != nil {
+		return err
+	}
+	for _, mechanism := range r.EnabledMechanisms {
+		if err := pe.putString(mechanism); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *SaslHandshakeResponse) decode(pd packetDecoder) error {
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	r.EnabledMechanisms = make([]string, n)
+	for i := range r.EnabledMechanisms {
+		if r.EnabledMechanisms[i], err = pd.getString(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package sarama
+
+import "testing"
+
+var (
+	saslHandshakeResponse = []byte{
+		0x00, 0x21, // ErrUnsupportedSASLMechanism
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x03, 'f', 'o', 'o',
+	}
+)
+
+func TestSaslHandshakeResponse(t *testing.T) {
+	var response *SaslHandshakeResponse
+
+	response = new(SaslHandshakeResponse)
+	testDecodable(t, "unsupported mechanism", response, saslHandshakeResponse)
+	if response.Err != ErrUnsupportedSASLMechanism {
+		t.Error("Decoding error failed: ErrUnsupportedSASLMechanism expected but found", response.Err)
+	}
+	if len(response.EnabledMechanisms) != 1 || response.EnabledMechanisms[0] != "foo" {
+		t.Error("Decoding response failed to find the enabled mechanisms, found", response.EnabledMechanisms)
+	}
+	testResponse(t, "unsupported mechanism", response, saslHandshakeResponse)
+}
@@ -0,0 +1,151 @@
+package sarama
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// scramClient implements the client side of a SCRAM authentication (RFC 5802), without channel
+// binding, as used by the SCRAM-SHA-256 and SCRAM-SHA-512 SASL mechanisms of Kafka.
+type scramClient struct {
+	hashGen  func() hash.Hash
+	user     string
+	password string
+
+	nonce           string
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newSCRAMClient(hashGen func() hash.Hash, user, password string) (*scramClient, error) {
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &scramClient{
+		hashGen:  hashGen,
+		user:     user,
+		password: password,
+		nonce:    base64.RawStdEncoding.EncodeToString(nonce),
+	}, nil
+}
+
+// the GS2 header for a client that does not support channel binding
+const scramGS2Header = "n,,"
+
+func (c *scramClient) firstMessage() []byte {
+	// ',' and '=' are the only characters that need escaping in a SCRAM user name
+	user := strings.Replace(strings.Replace(c.user, "=", "=3D", -1), ",", "=2C", -1)
+	c.clientFirstBare = "n=" + user + ",r=" + c.nonce
+	return []byte(scramGS2Header + c.clientFirstBare)
+}
+
+func (c *scramClient) finalMessage(serverFirst []byte) ([]byte, error) {
+	attrs, err := parseSCRAMAttributes(serverFirst)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := attrs['r']
+	if !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) {
+		return nil, PacketDecodingError{"SCRAM server nonce does not extend the client nonce"}
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs['s'])
+	if err != nil || len(salt) == 0 {
+		return nil, PacketDecodingError{"invalid SCRAM salt"}
+	}
+	iterations, err := strconv.Atoi(attrs['i'])
+	if err != nil || iterations < 1 {
+		return nil, PacketDecodingError{"invalid SCRAM iteration count"}
+	}
+
+	saltedPassword := c.saltPassword(salt, iterations)
+	clientKey := c.hmac(saltedPassword, []byte("Client Key"))
+	storedKey := c.hash(clientKey)
+
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte(scramGS2Header)) + ",r=" + nonce
+	authMessage := []byte(c.clientFirstBare + "," + string(serverFirst) + "," + withoutProof)
+
+	clientSignature := c.hmac(storedKey, authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range proof {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+
+	serverKey := c.hmac(saltedPassword, []byte("Server Key"))
+	c.serverSignature = c.hmac(serverKey, authMessage)
+
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// verifyServer checks the server-final message, which proves that the broker knows the credentials too.
+func (c *scramClient) verifyServer(serverFinal []byte) error {
+	attrs, err := parseSCRAMAttributes(serverFinal)
+	if err != nil {
+		return err
+	}
+
+	if e, ok := attrs['e']; ok {
+		Logger.Printf("SCRAM authentication rejected by the server: %s\n", e)
+		return ErrSASLAuthenticationFailed
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(attrs['v'])
+	if err != nil || !hmac.Equal(signature, c.serverSignature) {
+		return ErrSASLAuthenticationFailed
+	}
+
+	return nil
+}
+
+// saltPassword computes Hi(password, salt, iterations) from RFC 5802, which is PBKDF2 with HMAC
+// as the pseudorandom function and an output the size of a single hash.
+func (c *scramClient) saltPassword(salt []byte, iterations int) []byte {
+	prf := hmac.New(c.hashGen, []byte(c.password))
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+
+	result := make([]byte, len(u))
+	copy(result, u)
+	for n := 1; n < iterations; n++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for i := range result {
+			result[i] ^= u[i]
+		}
+	}
+	return result
+}
+
+func (c *scramClient) hmac(key, data []byte) []byte {
+	mac := hmac.New(c.hashGen, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (c *scramClient) hash(data []byte) []byte {
+	h := c.hashGen()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// parseSCRAMAttributes splits a SCRAM message into its comma separated `k=value` attributes.
+func parseSCRAMAttributes(msg []byte) (map[byte]string, error) {
+	attrs := make(map[byte]string)
+	for _, field := range bytes.Split(msg, []byte(",")) {
+		if len(field) < 2 || field[1] != '=' {
+			return nil, PacketDecodingError{fmt.Sprintf("malformed SCRAM message %q", msg)}
+		}
+		attrs[field[0]] = string(field[2:])
+	}
+	return attrs, nil
+}
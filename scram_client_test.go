@@ -0,0 +1,61 @@
+package sarama
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+// Test vector from RFC 7677, section 3
+func TestSCRAMClientSHA256(t *testing.T) {
+	client, err := newSCRAMClient(sha256.New, "user", "pencil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.nonce = "rOprNGfwEbeRWgbNEkqO"
+
+	if first := string(client.firstMessage()); first != "n,,n=user,r=rOprNGfwEbeRWgbNEkqO" {
+		t.Error("Unexpected client-first message", first)
+	}
+
+	final, err := client.finalMessage([]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(final) != "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=" {
+		t.Error("Unexpected client-final message", string(final))
+	}
+
+	if err := client.verifyServer([]byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")); err != nil {
+		t.Error("Expected the server signature to be accepted, got", err)
+	}
+	if err := client.verifyServer([]byte("v=AAAATRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")); err != ErrSASLAuthenticationFailed {
+		t.Error("Expected a forged server signature to be rejected, got", err)
+	}
+	if err := client.verifyServer([]byte("e=invalid-proof")); err != ErrSASLAuthenticationFailed {
+		t.Error("Expected a server error to be reported, got", err)
+	}
+}
+
+func TestSCRAMClientRejectsForeignNonce(t *testing.T) {
+	client, err := newSCRAMClient(sha256.New, "user", "pencil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.firstMessage()
+
+	if _, err := client.finalMessage([]byte("r=somebodyelse,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")); err == nil {
+		t.Error("Expected a server nonce not extending ours to be rejected")
+	}
+}
+
+func TestSCRAMClientEscapesUser(t *testing.T) {
+	client, err := newSCRAMClient(sha256.New, "a=b,c", "pencil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.nonce = "nonce"
+
+	if first := string(client.firstMessage()); first != "n,,n=a=3Db=2Cc,r=nonce" {
+		t.Error("Unexpected client-first message", first)
+	}
+}
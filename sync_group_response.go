@@ -0,0 +1,35 @@
+package sarama
+
+type SyncGroupResponse struct {
+	Err              KError
+	MemberAssignment []byte
+}
+
+// GetMemberAssignment decodes the partitions the group leader assigned to this member.
+func (r *SyncGroupResponse) GetMemberAssignment() (*ConsumerGroupMemberAssignment, error) {
+	assignment := new(ConsumerGroupMemberAssignment)
+	if len(r.MemberAssignment) == 0 {
+		// the broker sends an empty assignment to members which were not given any partitions
+		return assignment, nil
+	}
+	if err := decode(r.MemberAssignment, assignment); err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+func (r *SyncGroupResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.Err))
+	return pe.putBytes(r.MemberAssignment)
+}
+
+func (r *SyncGroupResponse) decode(pd packetDecoder) (err error) {
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	r.MemberAssignment, err = pd.getBytes()
+	return err
+}
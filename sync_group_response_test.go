@@ -0,0 +1,41 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+var (
+	syncGroupResponseNoError = []byte{
+		0x00, 0x00, // No error
+		0, 0, 0, 3, 0x01, 0x02, 0x03, // Member assignment data
+	}
+
+	syncGroupResponseWithError = []byte{
+		0, 27, // ErrRebalanceInProgress
+		0, 0, 0, 0, // No member assignment data
+	}
+)
+
+func TestSyncGroupResponse(t *testing.T) {
+	var response *SyncGroupResponse
+
+	response = new(SyncGroupResponse)
+	testDecodable(t, "no error", response, syncGroupResponseNoError)
+	if response.Err != ErrNoError {
+		t.Error("Decoding Err failed: no error expected but found", response.Err)
+	}
+	if !reflect.DeepEqual(response.MemberAssignment, []byte{0x01, 0x02, 0x03}) {
+		t.Error("Decoding MemberAssignment failed, found:", response.MemberAssignment)
+	}
+	testResponse(t, "no error", response, syncGroupResponseNoError)
+
+	response = new(SyncGroupResponse)
+	testDecodable(t, "with error", response, syncGroupResponseWithError)
+	if response.Err != ErrRebalanceInProgress {
+		t.Error("Decoding Err failed: ErrRebalanceInProgress expected but found", response.Err)
+	}
+	if len(response.MemberAssignment) != 0 {
+		t.Error("Decoding MemberAssignment failed, found:", response.MemberAssignment)
+	}
+}
@@ -0,0 +1,451 @@
+package sarama
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type txnState int
+
+const (
+	txnReady      txnState = iota // no transaction is in progress
+	txnInProgress                 // messages and offsets can be added to the transaction
+	txnEnding                     // the transaction is being committed or aborted
+	txnMustAbort                  // part of the transaction failed, so it can only be aborted
+	txnFenced                     // a newer producer with the same transactional ID took over
+)
+
+// transactionManager holds the producer ID and epoch of an idempotent producer, along with the
+// sequence number of the next record it sends to each partition. Brokers only accept the records
+// of a producer in sequence, which lets them discard the ones a retry sends twice.
+//
+// For transactional producers, it also tracks the state of the current transaction: the partitions
+// and offsets added to it, and the messages of it which were not delivered yet.
+type transactionManager struct {
+	client Client
+	conf   *Config
+
+	producerID      int64
+	producerEpoch   int16
+	sequenceNumbers map[string]map[int32]int32
+
+	transactionalID *string // nil unless the producer is transactional
+	state           txnState
+	partitions      map[string]map[int32]bool
+	hasOffsets      bool
+	pending         sync.WaitGroup
+	failure         error // the first error of the transaction
+
+	lock sync.Mutex
+}
+
+func newTransactionManager(client Client) (*transactionManager, error) {
+	tm := &transactionManager{client: client, conf: client.Config()}
+	if tm.conf.Producer.Transaction.ID != "" {
+		transactionalID := tm.conf.Producer.Transaction.ID
+		tm.transactionalID = &transactionalID
+	}
+
+	if err := tm.initProducerID(); err != nil {
+		return nil, err
+	}
+	return tm, nil
+}
+
+// initProducerID obtains a new producer ID, whose sequence numbers start over at zero. Transactional
+// producers keep their producer ID but get a new epoch, which aborts any pending transaction.
+// You must hold the lock before calling this function, unless the manager is not yet shared.
+func (tm *transactionManager) initProducerID() error {
+	var response *InitProducerIDResponse
+	var err error
+
+	if tm.transactionalID == nil {
+		response, err = tm.client.InitProducerID()
+	} else {
+		err = tm.sendToCoordinator(func(coordinator *Broker) error {
+			request := &InitProducerIDRequest{
+				TransactionalID:    tm.transactionalID,
+				TransactionTimeout: tm.conf.Producer.Transaction.Timeout,
+			}
+			response, err = coordinator.InitProducerID(request)
+			if err == nil && response.Err != ErrNoError {
+				return response.Err
+			}
+			return err
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	tm.producerID = response.ProducerID
+	tm.producerEpoch = response.ProducerEpoch
+	tm.sequenceNumbers = make(map[string]map[int32]int32)
+	Logger.Printf("producer/txnmanager using producer ID %d with epoch %d\n", tm.producerID, tm.producerEpoch)
+	return nil
+}
+
+// assignSequences stamps the messages with the producer ID and their sequence numbers, in order. Retried
+// messages keep the sequence numbers they were first sent with, unless the producer ID changed since.
+func (tm *transactionManager) assignSequences(msgSets map[string]map[int32][]*ProducerMessage) {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	for topic, partitionSet := range msgSets {
+		partitions := tm.sequenceNumbers[topic]
+		if partitions == nil {
+			partitions = make(map[int32]int32)
+			tm.sequenceNumbers[topic] = partitions
+		}
+
+		for partition, msgs := range partitionSet {
+			for _, msg := range msgs {
+				if msg.hasSequence && msg.producerID == tm.producerID && msg.producerEpoch == tm.producerEpoch {
+					continue
+				}
+
+				msg.producerID = tm.producerID
+				msg.producerEpoch = tm.producerEpoch
+				msg.sequenceNumber = partitions[partition]
+				msg.hasSequence = true
+
+				// sequence numbers wrap around, like in the JVM producer
+				if partitions[partition] == math.MaxInt32 {
+					partitions[partition] = 0
+				} else {
+					partitions[partition]++
+				}
+			}
+		}
+	}
+}
+
+// reset obtains a new producer ID after a broker refused records stamped with the given one,
+// unless that already happened because of other records.
+func (tm *transactionManager) reset(producerID int64, producerEpoch int16) error {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	if producerID != tm.producerID || producerEpoch != tm.producerEpoch {
+		return nil
+	}
+
+	Logger.Printf("producer/txnmanager abandoning producer ID %d with epoch %d\n", tm.producerID, tm.producerEpoch)
+	return tm.initProducerID()
+}
+
+func (tm *transactionManager) isTransactional() bool {
+	return tm.transactionalID != nil
+}
+
+func (tm *transactionManager) begin() error {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	switch tm.state {
+	case txnReady:
+	case txnFenced:
+		return ErrInvalidProducerEpoch
+	default:
+		return ErrTransactionInProgress
+	}
+
+	tm.state = txnInProgress
+	tm.partitions = make(map[string]map[int32]bool)
+	tm.hasOffsets = false
+	return nil
+}
+
+// accept tells whether a new message can be sent, which for transactional producers requires a
+// transaction to be in progress. The message then counts as pending until done is called for it.
+func (tm *transactionManager) accept(msg *ProducerMessage) bool {
+	if tm.transactionalID == nil {
+		return true
+	}
+
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	if tm.state != txnInProgress {
+		return false
+	}
+	tm.pending.Add(1)
+	msg.flags |= intxn
+	return true
+}
+
+// done records the delivery, or the failure, of a message of the transaction.
+func (tm *transactionManager) done(err error) {
+	if err != nil {
+		tm.lock.Lock()
+		tm.fail(err)
+		tm.lock.Unlock()
+	}
+	tm.pending.Done()
+}
+
+// fail records the first error of the transaction. You must hold the lock before calling this function.
+func (tm *transactionManager) fail(err error) {
+	if err == ErrInvalidProducerEpoch {
+		tm.state = txnFenced
+	}
+	if tm.failure == nil {
+		tm.failure = err
+	}
+}
+
+// stopAccepting ends the transaction to new messages before committing or aborting it.
+func (tm *transactionManager) stopAccepting(commit bool) error {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	switch {
+	case tm.state == txnInProgress:
+	case tm.state == txnMustAbort && !commit:
+	case tm.state == txnMustAbort:
+		return ErrTransactionMustAbort
+	case tm.state == txnFenced:
+		return ErrInvalidProducerEpoch
+	default:
+		return ErrTransactionNotInProgress
+	}
+
+	tm.state = txnEnding
+	return nil
+}
+
+// end commits or aborts the transaction once its messages are all delivered or failed.
+func (tm *transactionManager) end(commit bool) error {
+	tm.pending.Wait()
+
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	if tm.state == txnFenced {
+		return ErrInvalidProducerEpoch
+	}
+	if commit && tm.failure != nil {
+		Logger.Printf("producer/txnmanager cannot commit the transaction because %s\n", tm.failure)
+		tm.state = txnMustAbort
+		return ErrTransactionMustAbort
+	}
+
+	if len(tm.partitions) > 0 || tm.hasOffsets {
+		err := tm.sendToCoordinator(func(coordinator *Broker) error {
+			request := &EndTxnRequest{
+				TransactionalID: *tm.transactionalID,
+				ProducerID:      tm.producerID,
+				ProducerEpoch:   tm.producerEpoch,
+				Commit:          commit,
+			}
+			response, err := coordinator.EndTxn(request)
+			if err == nil && response.Err != ErrNoError {
+				return response.Err
+			}
+			return err
+		})
+		if err != nil {
+			tm.fail(err)
+			if tm.state != txnFenced {
+				tm.state = txnMustAbort
+			}
+			return err
+		}
+		tm.partitions = nil
+		tm.hasOffsets = false
+	}
+
+	if tm.failure != nil {
+		// failed messages may have left gaps in the sequence numbers, which a new
+		// epoch starts over
+		if err := tm.initProducerID(); err != nil {
+			tm.state = txnMustAbort
+			return err
+		}
+		tm.failure = nil
+	}
+
+	tm.state = txnReady
+	return nil
+}
+
+// addPartitions adds the partitions of the messages to the transaction, before they are produced.
+func (tm *transactionManager) addPartitions(msgSets map[string]map[int32][]*ProducerMessage) error {
+	if tm.transactionalID == nil {
+		return nil
+	}
+
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	added := make(map[string][]int32)
+	for topic, partitionSet := range msgSets {
+		for partition := range partitionSet {
+			if !tm.partitions[topic][partition] {
+				added[topic] = append(added[topic], partition)
+			}
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	err := tm.sendToCoordinator(func(coordinator *Broker) error {
+		request := &AddPartitionsToTxnRequest{
+			TransactionalID: *tm.transactionalID,
+			ProducerID:      tm.producerID,
+			ProducerEpoch:   tm.producerEpoch,
+			TopicPartitions: added,
+		}
+		response, err := coordinator.AddPartitionsToTxn(request)
+		if err != nil {
+			return err
+		}
+		return firstPartitionError(response.Errors)
+	})
+	if err != nil {
+		tm.fail(err)
+		return err
+	}
+
+	for topic, partitions := range added {
+		if tm.partitions[topic] == nil {
+			tm.partitions[topic] = make(map[int32]bool)
+		}
+		for _, partition := range partitions {
+			tm.partitions[topic][partition] = true
+		}
+	}
+	return nil
+}
+
+// addOffsets commits offsets of a consumer group as part of the transaction.
+func (tm *transactionManager) addOffsets(offsets map[string]map[int32]int64, groupID string) error {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	switch tm.state {
+	case txnInProgress:
+	case txnFenced:
+		return ErrInvalidProducerEpoch
+	default:
+		return ErrTransactionNotInProgress
+	}
+
+	err := tm.sendToCoordinator(func(coordinator *Broker) error {
+		request := &AddOffsetsToTxnRequest{
+			TransactionalID: *tm.transactionalID,
+			ProducerID:      tm.producerID,
+			ProducerEpoch:   tm.producerEpoch,
+			GroupID:         groupID,
+		}
+		response, err := coordinator.AddOffsetsToTxn(request)
+		if err == nil && response.Err != ErrNoError {
+			return response.Err
+		}
+		return err
+	})
+	if err != nil {
+		tm.fail(err)
+		return err
+	}
+	tm.hasOffsets = true
+
+	request := &TxnOffsetCommitRequest{
+		TransactionalID: *tm.transactionalID,
+		ConsumerGroup:   groupID,
+		ProducerID:      tm.producerID,
+		ProducerEpoch:   tm.producerEpoch,
+	}
+	for topic, partitions := range offsets {
+		for partition, offset := range partitions {
+			request.AddBlock(topic, partition, offset, "")
+		}
+	}
+	err = tm.retry(
+		func() (*Broker, error) { return tm.client.Coordinator(groupID) },
+		func() error { return tm.client.RefreshCoordinator(groupID) },
+		func(coordinator *Broker) error {
+			response, err := coordinator.TxnOffsetCommit(request)
+			if err != nil {
+				return err
+			}
+			return firstPartitionError(response.Errors)
+		})
+	if err != nil {
+		tm.fail(err)
+		return err
+	}
+	return nil
+}
+
+// sendToCoordinator sends a request to the transaction coordinator, see retry.
+func (tm *transactionManager) sendToCoordinator(send func(coordinator *Broker) error) error {
+	return tm.retry(
+		func() (*Broker, error) { return tm.client.TransactionCoordinator(*tm.transactionalID) },
+		func() error { return tm.client.RefreshTransactionCoordinator(*tm.transactionalID) },
+		send)
+}
+
+// retry sends a request to a coordinator, up to Producer.Transaction.Retry.Max more times when the
+// coordinator moved, is still loading, is busy with a previous request or could not be reached.
+func (tm *transactionManager) retry(coordinator func() (*Broker, error), refresh func() error, send func(coordinator *Broker) error) error {
+	for attemptsRemaining := tm.conf.Producer.Transaction.Retry.Max; ; attemptsRemaining-- {
+		broker, err := coordinator()
+		if err == nil {
+			err = send(broker)
+		}
+
+		switch err {
+		case nil:
+			return nil
+		case ErrNotCoordinatorForConsumer, ErrConsumerCoordinatorNotAvailable:
+			if err := refresh(); err != nil {
+				Logger.Println("producer/txnmanager failed to refresh the coordinator:", err)
+			}
+		case ErrOffsetsLoadInProgress, ErrConcurrentTransactions, ErrOutOfBrokers:
+			// the coordinator should soon be able to answer
+		default:
+			switch err.(type) {
+			case KError, PacketEncodingError, ConfigurationError:
+				return err
+			}
+			if err == ErrClosedClient {
+				return err
+			}
+			if broker == nil {
+				Logger.Println("producer/txnmanager failed to find the coordinator:", err)
+			} else {
+				Logger.Printf("producer/txnmanager request to coordinator %s failed: %s\n", broker.Addr(), err)
+			}
+		}
+
+		if attemptsRemaining <= 0 {
+			return err
+		}
+		Logger.Printf("producer/txnmanager retrying after %dms... (%d attempts remaining)\n",
+			tm.conf.Producer.Transaction.Retry.Backoff/time.Millisecond, attemptsRemaining)
+		time.Sleep(tm.conf.Producer.Transaction.Retry.Backoff)
+	}
+}
+
+// firstPartitionError returns an error of the partitions, preferring the ones which caused the
+// others not to be attempted.
+func firstPartitionError(errors map[string]map[int32]KError) error {
+	var err error
+	for _, partitions := range errors {
+		for _, kerr := range partitions {
+			switch kerr {
+			case ErrNoError:
+			case ErrOperationNotAttempted:
+				if err == nil {
+					err = kerr
+				}
+			default:
+				return kerr
+			}
+		}
+	}
+	return err
+}
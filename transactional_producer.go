@@ -0,0 +1,91 @@
+package sarama
+
+// TransactionalProducer is an AsyncProducer which sends messages as part of transactions. The messages
+// of a transaction, and the consumer offsets added to it, all become visible to consumers reading
+// committed messages when it is committed, and none of them if it is aborted.
+//
+// Messages can only be sent between BeginTxn and CommitTxn or AbortTxn; the ones sent outside of a
+// transaction are returned with ErrTransactionNotInProgress. Only one transaction can be in progress
+// at a time, and the methods of a TransactionalProducer must not be called concurrently with each other.
+type TransactionalProducer interface {
+	AsyncProducer
+
+	// BeginTxn starts a new transaction.
+	BeginTxn() error
+
+	// CommitTxn waits until the messages of the transaction are delivered, then commits it. If some
+	// of them failed, it returns ErrTransactionMustAbort and the transaction must be aborted instead.
+	CommitTxn() error
+
+	// AbortTxn waits until the messages of the transaction are delivered or failed, then aborts it.
+	AbortTxn() error
+
+	// AddOffsetsToTxn commits offsets of the given consumer group as part of the transaction, which is
+	// how a consume-transform-produce loop processes each message exactly once. As with OffsetManager,
+	// the offsets are the ones of the next messages to consume.
+	AddOffsetsToTxn(offsets map[string]map[int32]int64, groupID string) error
+}
+
+// NewTransactionalProducer creates a new TransactionalProducer using the given broker addresses and
+// configuration, which must set Producer.Transaction.ID. Creating it fences off any other producer
+// with the same transactional ID, and aborts the transaction such a producer left in progress.
+func NewTransactionalProducer(addrs []string, conf *Config) (TransactionalProducer, error) {
+	client, err := NewClient(addrs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := NewTransactionalProducerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	p.(*asyncProducer).ownClient = true
+	return p, nil
+}
+
+// NewTransactionalProducerFromClient creates a new TransactionalProducer using the given client. It is
+// still necessary to call Close() on the underlying client when shutting down this producer.
+func NewTransactionalProducerFromClient(client Client) (TransactionalProducer, error) {
+	// Check that we are not dealing with a closed Client before processing any other arguments
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	if client.Config().Producer.Transaction.ID == "" {
+		return nil, ConfigurationError("Producer.Transaction.ID must be set for a transactional producer")
+	}
+
+	p, err := newAsyncProducer(client)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *asyncProducer) BeginTxn() error {
+	return p.txnmgr.begin()
+}
+
+func (p *asyncProducer) CommitTxn() error {
+	return p.endTxn(true)
+}
+
+func (p *asyncProducer) AbortTxn() error {
+	return p.endTxn(false)
+}
+
+func (p *asyncProducer) AddOffsetsToTxn(offsets map[string]map[int32]int64, groupID string) error {
+	return p.txnmgr.addOffsets(offsets, groupID)
+}
+
+func (p *asyncProducer) endTxn(commit bool) error {
+	// the dispatcher handles messages in order, so once it got past this one, the messages sent
+	// before ending the transaction were all accepted into it
+	p.input <- &ProducerMessage{flags: endtxn}
+
+	if err := p.txnmgr.stopAccepting(commit); err != nil {
+		return err
+	}
+	return p.txnmgr.end(commit)
+}
@@ -0,0 +1,256 @@
+package sarama
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newTransactionalProducerConfig() *Config {
+	config := newIdempotentProducerConfig()
+	config.Producer.Transaction.ID = "txn"
+	config.Producer.Transaction.Retry.Backoff = 0
+	config.Metadata.Retry.Backoff = 0
+	return config
+}
+
+// newTransactionalBroker answers the requests of a transactional producer as the only broker of the
+// cluster, coordinating both the transaction and the consumer group "my_group".
+func newTransactionalBroker(t *testing.T, produceResponse *mockProduceResponse) *mockBroker {
+	broker := newMockBroker(t, 1)
+
+	offsetCommitResponse := new(TxnOffsetCommitResponse)
+	offsetCommitResponse.AddError("my_topic", 0, ErrNoError)
+
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": newMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()),
+		"FindCoordinatorRequest": newMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorTransaction, "txn", broker),
+		"ConsumerMetadataRequest": newMockConsumerMetadataResponse(t).
+			SetCoordinator("my_group", broker),
+		"InitProducerIDRequest": newMockWrapper(&InitProducerIDResponse{ProducerID: 1000, ProducerEpoch: 1}),
+		"AddPartitionsToTxnRequest": newMockWrapper(&AddPartitionsToTxnResponse{
+			Errors: map[string]map[int32]KError{"my_topic": {0: ErrNoError}},
+		}),
+		"ProduceRequest":         produceResponse,
+		"AddOffsetsToTxnRequest": newMockWrapper(&AddOffsetsToTxnResponse{}),
+		"TxnOffsetCommitRequest": newMockWrapper(offsetCommitResponse),
+		"EndTxnRequest":          newMockWrapper(&EndTxnResponse{}),
+	})
+	return broker
+}
+
+// requestsOfType returns the requests the broker received whose type has the given name.
+func requestsOfType(b *mockBroker, name string) []requestBody {
+	var requests []requestBody
+	for _, rr := range b.History() {
+		if reflect.TypeOf(rr.Request).Elem().Name() == name {
+			requests = append(requests, rr.Request)
+		}
+	}
+	return requests
+}
+
+func TestTransactionalProducerRequiresTransactionalID(t *testing.T) {
+	broker := newMockBroker(t, 1)
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(broker.Addr(), broker.BrokerID())
+	broker.Returns(metadataResponse)
+
+	client, err := NewClient([]string{broker.Addr()}, newIdempotentProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewTransactionalProducerFromClient(client); err == nil {
+		t.Error("Expected a transactional producer to require Producer.Transaction.ID")
+	}
+
+	client.Config().Producer.Transaction.ID = "txn"
+	if _, err := NewAsyncProducerFromClient(client); err == nil {
+		t.Error("Expected an async producer to refuse Producer.Transaction.ID")
+	}
+
+	safeClose(t, client)
+	broker.Close()
+}
+
+func TestTransactionalProducerCommit(t *testing.T) {
+	broker := newTransactionalBroker(t, newMockProduceResponse(t))
+
+	producer, err := NewTransactionalProducer([]string{broker.Addr()}, newTransactionalProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// messages can only be sent as part of a transaction
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	if msg := <-producer.Errors(); msg.Err != ErrTransactionNotInProgress {
+		t.Error("Expected ErrTransactionNotInProgress, got", msg.Err)
+	}
+
+	if err := producer.BeginTxn(); err != nil {
+		t.Fatal(err)
+	}
+	if err := producer.BeginTxn(); err != ErrTransactionInProgress {
+		t.Error("Expected ErrTransactionInProgress, got", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	}
+	expectResults(t, producer, 2, 0)
+
+	if err := producer.AddOffsetsToTxn(map[string]map[int32]int64{"my_topic": {0: 42}}, "my_group"); err != nil {
+		t.Fatal(err)
+	}
+	if err := producer.CommitTxn(); err != nil {
+		t.Fatal(err)
+	}
+	if err := producer.CommitTxn(); err != ErrTransactionNotInProgress {
+		t.Error("Expected ErrTransactionNotInProgress, got", err)
+	}
+
+	closeProducer(t, producer)
+
+	initRequests := requestsOfType(broker, "InitProducerIDRequest")
+	if len(initRequests) != 1 || *initRequests[0].(*InitProducerIDRequest).TransactionalID != "txn" {
+		t.Error("Expected a single InitProducerIDRequest for the transactional ID, got", initRequests)
+	}
+
+	addRequests := requestsOfType(broker, "AddPartitionsToTxnRequest")
+	if len(addRequests) != 1 {
+		t.Fatal("Expected a single AddPartitionsToTxnRequest, got", len(addRequests))
+	}
+	if partitions := addRequests[0].(*AddPartitionsToTxnRequest).TopicPartitions; !reflect.DeepEqual(partitions, map[string][]int32{"my_topic": {0}}) {
+		t.Error("Unexpected partitions added to the transaction", partitions)
+	}
+
+	produceRequests := requestsOfType(broker, "ProduceRequest")
+	if len(produceRequests) != 1 {
+		t.Fatal("Expected a single ProduceRequest, got", len(produceRequests))
+	}
+	produceRequest := produceRequests[0].(*ProduceRequest)
+	if produceRequest.TransactionalID == nil || *produceRequest.TransactionalID != "txn" {
+		t.Error("Expected the ProduceRequest to carry the transactional ID")
+	}
+	if batch := produceRequest.recordBatches["my_topic"][0]; !batch.IsTransactional || batch.ProducerID != 1000 {
+		t.Error("Expected a transactional batch of producer 1000, got", batch.IsTransactional, batch.ProducerID)
+	}
+
+	offsetRequests := requestsOfType(broker, "TxnOffsetCommitRequest")
+	if len(offsetRequests) != 1 || offsetRequests[0].(*TxnOffsetCommitRequest).blocks["my_topic"][0].offset != 42 {
+		t.Error("Expected the offsets to be committed within the transaction")
+	}
+
+	endRequests := requestsOfType(broker, "EndTxnRequest")
+	if len(endRequests) != 1 || !endRequests[0].(*EndTxnRequest).Commit {
+		t.Error("Expected the transaction to be committed")
+	}
+
+	broker.Close()
+}
+
+func TestTransactionalProducerMustAbort(t *testing.T) {
+	produceResponse := newMockProduceResponse(t).SetError("my_topic", 0, ErrInvalidMessage)
+	broker := newTransactionalBroker(t, produceResponse)
+
+	producer, err := NewTransactionalProducer([]string{broker.Addr()}, newTransactionalProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := producer.BeginTxn(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	}
+	expectResults(t, producer, 0, 2)
+
+	if err := producer.CommitTxn(); err != ErrTransactionMustAbort {
+		t.Error("Expected ErrTransactionMustAbort, got", err)
+	}
+	if err := producer.BeginTxn(); err != ErrTransactionInProgress {
+		t.Error("Expected ErrTransactionInProgress, got", err)
+	}
+	if err := producer.AbortTxn(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed messages left a gap in the sequence numbers, so the producer bumped its epoch
+	if initRequests := requestsOfType(broker, "InitProducerIDRequest"); len(initRequests) != 2 {
+		t.Error("Expected a second InitProducerIDRequest after aborting, got", len(initRequests))
+	}
+	endRequests := requestsOfType(broker, "EndTxnRequest")
+	if len(endRequests) != 1 || endRequests[0].(*EndTxnRequest).Commit {
+		t.Error("Expected the transaction to be aborted")
+	}
+
+	if err := producer.BeginTxn(); err != nil {
+		t.Error(err)
+	}
+	if err := producer.AbortTxn(); err != nil {
+		t.Error(err)
+	}
+	if endRequests := requestsOfType(broker, "EndTxnRequest"); len(endRequests) != 1 {
+		t.Error("Expected no EndTxnRequest for an empty transaction, got", len(endRequests))
+	}
+
+	closeProducer(t, producer)
+	broker.Close()
+}
+
+func TestTransactionalProducerCoordinatorRetry(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	coordinator := newMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(coordinator.Addr(), coordinator.BrokerID())
+	seedBroker.Returns(metadataResponse)
+
+	// the coordinator is still loading the transaction log, then moves to another broker
+	seedBroker.Returns(&FindCoordinatorResponse{Err: ErrConsumerCoordinatorNotAvailable})
+	seedBroker.Returns(&FindCoordinatorResponse{Coordinator: &Broker{id: seedBroker.BrokerID(), addr: seedBroker.Addr()}})
+	seedBroker.Returns(&InitProducerIDResponse{Err: ErrNotCoordinatorForConsumer})
+	seedBroker.Returns(&FindCoordinatorResponse{Coordinator: &Broker{id: coordinator.BrokerID(), addr: coordinator.Addr()}})
+	coordinator.Returns(&InitProducerIDResponse{Err: ErrConcurrentTransactions})
+	coordinator.Returns(&InitProducerIDResponse{ProducerID: 1000, ProducerEpoch: 1})
+
+	producer, err := NewTransactionalProducer([]string{seedBroker.Addr()}, newTransactionalProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closeProducer(t, producer)
+	seedBroker.Close()
+	coordinator.Close()
+}
+
+func TestTransactionManagerCoordinatorLookupFailure(t *testing.T) {
+	config := newTransactionalProducerConfig()
+	config.Producer.Transaction.Retry.Max = 1
+	tm := &transactionManager{conf: config}
+
+	lookupErr := errors.New("coordinator lookup failed")
+	lookups := 0
+	err := tm.retry(
+		func() (*Broker, error) {
+			lookups++
+			return nil, lookupErr
+		},
+		func() error { return nil },
+		func(*Broker) error {
+			t.Error("Did not expect a request to be sent without a coordinator")
+			return nil
+		})
+
+	if err != lookupErr {
+		t.Error("Expected the lookup error, got", err)
+	}
+	if lookups != 2 {
+		t.Error("Expected the lookup to be retried once, got", lookups, "lookups")
+	}
+}
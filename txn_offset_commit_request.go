@@ -0,0 +1,137 @@
+package sarama
+
+type txnOffsetCommitRequestBlock struct {
+	offset   int64
+	metadata string
+}
+
+func (r *txnOffsetCommitRequestBlock) encode(pe packetEncoder) error {
+	pe.putInt64(r.offset)
+	return pe.putString(r.metadata)
+}
+
+func (r *txnOffsetCommitRequestBlock) decode(pd packetDecoder) (err error) {
+	if r.offset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	metadata, err := pd.getNullableString()
+	if err != nil {
+		return err
+	}
+	if metadata != nil {
+		r.metadata = *metadata
+	}
+	return nil
+}
+
+// TxnOffsetCommitRequest commits the offsets of a consumer group as part of a transaction, they only
+// become visible once the transaction is committed. It is sent to the coordinator of the group.
+type TxnOffsetCommitRequest struct {
+	TransactionalID string
+	ConsumerGroup   string
+	ProducerID      int64
+	ProducerEpoch   int16
+	blocks          map[string]map[int32]*txnOffsetCommitRequestBlock
+}
+
+func (r *TxnOffsetCommitRequest) encode(pe packetEncoder) error {
+	if err := pe.putString(r.TransactionalID); err != nil {
+		return err
+	}
+	if err := pe.putString(r.ConsumerGroup); err != nil {
+		return err
+	}
+	pe.putInt64(r.ProducerID)
+	pe.putInt16(r.ProducerEpoch)
+
+	if err := pe.putArrayLength(len(r.blocks)); err != nil {
+		return err
+	}
+	for topic, partitions := range r.blocks {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for partition, block := range partitions {
+			pe.putInt32(partition)
+			if err := block.encode(pe); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *TxnOffsetCommitRequest) decode(pd packetDecoder) (err error) {
+	if r.TransactionalID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.ConsumerGroup, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if r.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return err
+	}
+
+	topicCount, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if topicCount == 0 {
+		return nil
+	}
+	r.blocks = make(map[string]map[int32]*txnOffsetCommitRequestBlock)
+	for i := 0; i < topicCount; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		partitionCount, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		r.blocks[topic] = make(map[int32]*txnOffsetCommitRequestBlock)
+		for j := 0; j < partitionCount; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			block := &txnOffsetCommitRequestBlock{}
+			if err := block.decode(pd); err != nil {
+				return err
+			}
+			r.blocks[topic][partition] = block
+		}
+	}
+	return nil
+}
+
+func (r *TxnOffsetCommitRequest) key() int16 {
+	return 28
+}
+
+func (r *TxnOffsetCommitRequest) version() int16 {
+	return 0
+}
+
+func (r *TxnOffsetCommitRequest) requiredVersion() KafkaVersion {
+	return V0_11_0_0
+}
+
+func (r *TxnOffsetCommitRequest) AddBlock(topic string, partitionID int32, offset int64, metadata string) {
+	if r.blocks == nil {
+		r.blocks = make(map[string]map[int32]*txnOffsetCommitRequestBlock)
+	}
+
+	if r.blocks[topic] == nil {
+		r.blocks[topic] = make(map[int32]*txnOffsetCommitRequestBlock)
+	}
+
+	r.blocks[topic][partitionID] = &txnOffsetCommitRequestBlock{offset, metadata}
+}
@@ -0,0 +1,26 @@
+package sarama
+
+import "testing"
+
+var txnOffsetCommitRequest = []byte{
+	0x00, 0x03, 't', 'x', 'n',
+	0x00, 0x05, 'g', 'r', 'o', 'u', 'p',
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07,
+	0x00, 0x02,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x7B,
+	0x00, 0x04, 'm', 'e', 't', 'a'}
+
+func TestTxnOffsetCommitRequest(t *testing.T) {
+	request := &TxnOffsetCommitRequest{
+		TransactionalID: "txn",
+		ConsumerGroup:   "group",
+		ProducerID:      7,
+		ProducerEpoch:   2,
+	}
+	request.AddBlock("topic", 1, 123, "meta")
+	testRequest(t, "", request, txnOffsetCommitRequest)
+}
@@ -0,0 +1,20 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var txnOffsetCommitResponse = []byte{
+	0x00, 0x00, 0x00, 0x64,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x10}
+
+func TestTxnOffsetCommitResponse(t *testing.T) {
+	response := &TxnOffsetCommitResponse{ThrottleTime: 100 * time.Millisecond}
+	response.AddError("topic", 1, ErrNotCoordinatorForConsumer)
+	testResponse(t, "", response, txnOffsetCommitResponse)
+}
@@ -1,6 +1,11 @@
 package sarama
 
-import "sort"
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
 
 type none struct{}
 
@@ -45,10 +50,9 @@ func withRecover(fn func()) {
 func safeAsyncClose(b *Broker) {
 	tmp := b // local var prevents clobbering in goroutine
 	go withRecover(func() {
-		if connected, _ := tmp.Connected(); connected {
-			if err := tmp.Close(); err != nil {
-				Logger.Println("Error closing broker", tmp.ID(), ":", err)
-			}
+		// also cancels a connection still backing off, see Net.Reconnect
+		if err := tmp.Close(); err != nil && err != ErrNotConnected {
+			Logger.Println("Error closing broker", tmp.ID(), ":", err)
 		}
 	})
 }
@@ -87,3 +91,99 @@ func (b ByteEncoder) Encode() ([]byte, error) {
 func (b ByteEncoder) Length() int {
 	return len(b)
 }
+
+// KafkaVersion instances represent versions of the upstream Kafka broker.
+type KafkaVersion struct {
+	// it's a struct rather than just typing the array directly to make it opaque and stop people
+	// generating their own arbitrary versions
+	version [4]uint
+}
+
+func newKafkaVersion(major, minor, veryMinor, patch uint) KafkaVersion {
+	return KafkaVersion{
+		version: [4]uint{major, minor, veryMinor, patch},
+	}
+}
+
+// IsAtLeast return true if and only if the version it is called on is
+// greater than or equal to the version passed in:
+//    V1.IsAtLeast(V2) // false
+//    V2.IsAtLeast(V1) // true
+func (v KafkaVersion) IsAtLeast(other KafkaVersion) bool {
+	for i := range v.version {
+		if v.version[i] > other.version[i] {
+			return true
+		} else if v.version[i] < other.version[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (v KafkaVersion) String() string {
+	if v.version[0] == 0 {
+		return fmt.Sprintf("0.%d.%d.%d", v.version[1], v.version[2], v.version[3])
+	}
+	return fmt.Sprintf("%d.%d.%d", v.version[0], v.version[1], v.version[2])
+}
+
+// Effective constants defining the supported kafka versions.
+var (
+	V0_8_2_0  = newKafkaVersion(0, 8, 2, 0)
+	V0_8_2_1  = newKafkaVersion(0, 8, 2, 1)
+	V0_8_2_2  = newKafkaVersion(0, 8, 2, 2)
+	V0_9_0_0  = newKafkaVersion(0, 9, 0, 0)
+	V0_9_0_1  = newKafkaVersion(0, 9, 0, 1)
+	V0_10_0_0 = newKafkaVersion(0, 10, 0, 0)
+	V0_10_0_1 = newKafkaVersion(0, 10, 0, 1)
+	V0_10_1_0 = newKafkaVersion(0, 10, 1, 0)
+	V0_10_2_0 = newKafkaVersion(0, 10, 2, 0)
+	V0_11_0_0 = newKafkaVersion(0, 11, 0, 0)
+	V1_0_0_0  = newKafkaVersion(1, 0, 0, 0)
+	V1_1_0_0  = newKafkaVersion(1, 1, 0, 0)
+	V2_0_0_0  = newKafkaVersion(2, 0, 0, 0)
+	V2_1_0_0  = newKafkaVersion(2, 1, 0, 0)
+
+	// SupportedVersions lists the Kafka versions Config.Version may be set to, oldest first.
+	SupportedVersions = []KafkaVersion{
+		V0_8_2_0,
+		V0_8_2_1,
+		V0_8_2_2,
+		V0_9_0_0,
+		V0_9_0_1,
+		V0_10_0_0,
+		V0_10_0_1,
+		V0_10_1_0,
+		V0_10_2_0,
+		V0_11_0_0,
+		V1_0_0_0,
+		V1_1_0_0,
+		V2_0_0_0,
+		V2_1_0_0,
+	}
+	minVersion = V0_8_2_0
+	MaxVersion = V2_1_0_0
+)
+
+// ParseKafkaVersion parses a version string such as "0.10.2.0" or "2.1.0" into a KafkaVersion.
+func ParseKafkaVersion(s string) (KafkaVersion, error) {
+	fields := strings.Split(s, ".")
+
+	var parts []uint
+	for _, field := range fields {
+		n, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return minVersion, fmt.Errorf("invalid version `%s`", s)
+		}
+		parts = append(parts, uint(n))
+	}
+
+	switch {
+	case len(parts) == 4 && parts[0] == 0:
+		return newKafkaVersion(0, parts[1], parts[2], parts[3]), nil
+	case len(parts) == 3 && parts[0] > 0:
+		return newKafkaVersion(parts[0], parts[1], parts[2], 0), nil
+	default:
+		return minVersion, fmt.Errorf("invalid version `%s`", s)
+	}
+}
@@ -0,0 +1,53 @@
+package sarama
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstd encoders and decoders are expensive to create but safe for concurrent use, so a single
+// decoder and one encoder per compression level are shared by the whole process.
+var (
+	zstdLock     sync.Mutex
+	zstdEncoders = make(map[zstd.EncoderLevel]*zstd.Encoder)
+	zstdDecoder  *zstd.Decoder
+)
+
+// zstdEncode compresses src into a single zstd frame; level follows the levels of the zstd tools,
+// zero meaning the default.
+func zstdEncode(level int, src []byte) ([]byte, error) {
+	encoderLevel := zstd.SpeedDefault
+	if level != 0 {
+		encoderLevel = zstd.EncoderLevelFromZstd(level)
+	}
+
+	zstdLock.Lock()
+	encoder, ok := zstdEncoders[encoderLevel]
+	if !ok {
+		var err error
+		if encoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(encoderLevel), zstd.WithZeroFrames(true)); err != nil {
+			zstdLock.Unlock()
+			return nil, err
+		}
+		zstdEncoders[encoderLevel] = encoder
+	}
+	zstdLock.Unlock()
+
+	return encoder.EncodeAll(src, nil), nil
+}
+
+func zstdDecode(src []byte) ([]byte, error) {
+	zstdLock.Lock()
+	if zstdDecoder == nil {
+		var err error
+		if zstdDecoder, err = zstd.NewReader(nil); err != nil {
+			zstdLock.Unlock()
+			return nil, err
+		}
+	}
+	decoder := zstdDecoder
+	zstdLock.Unlock()
+
+	return decoder.DecodeAll(src, nil)
+}